@@ -3,19 +3,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/internal/data"
 	"github.com/roguepikachu/bonsai/internal/http/handler"
 	appRouter "github.com/roguepikachu/bonsai/internal/http/router"
+	"github.com/roguepikachu/bonsai/internal/metrics"
+	"github.com/roguepikachu/bonsai/internal/ratelimit"
+	"github.com/roguepikachu/bonsai/internal/selftest"
 	"github.com/roguepikachu/bonsai/internal/service"
+	"github.com/roguepikachu/bonsai/internal/worker"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 
+	"github.com/roguepikachu/bonsai/internal/repository"
 	cachedrepo "github.com/roguepikachu/bonsai/internal/repository/cached"
 	pgrepo "github.com/roguepikachu/bonsai/internal/repository/postgres"
 )
@@ -29,7 +39,10 @@ func main() {
 	ctx := context.Background()
 
 	// Setup Redis client
-	redisClient := data.NewRedisClient()
+	redisClient, err := data.NewRedisClient()
+	if err != nil {
+		logger.Fatal(ctx, "failed to init redis: %v", err)
+	}
 	defer func() {
 		if err := redisClient.Close(); err != nil {
 			logger.WithField(ctx, "error", err.Error()).Warn("redis close failed")
@@ -41,23 +54,25 @@ func main() {
 	if err != nil {
 		logger.Fatal(ctx, "failed to init postgres: %v", err)
 	}
-	// Setup Postgres repository and ensure schema if configured
-	pgRepo := pgrepo.NewSnippetRepository(pgPool)
 	defer pgPool.Close()
-	if config.Conf.AutoMigrate {
-		if err := pgRepo.EnsureSchema(ctx); err != nil {
-			logger.Fatal(ctx, "failed to ensure postgres schema: %v", err)
-		}
+	replicaPool, err := data.NewPostgresReplicaPool(ctx)
+	if err != nil {
+		logger.Fatal(ctx, "failed to init postgres replica: %v", err)
+	}
+	if replicaPool != nil {
+		defer replicaPool.Close()
 	}
 
-	// Compose cached repository: Postgres primary + Redis cache
-	repo := cachedrepo.NewSnippetRepository(pgRepo, redisClient, 10*time.Minute)
-	svc := service.NewService(repo, &service.RealClock{})
-	snippetHandler := handler.NewHandler(svc)
-	healthHandler := handler.NewHealthHandler(pgPool, redisClient)
-
-	r := appRouter.NewRouter(snippetHandler, healthHandler)
-
+	// Serve the probes while the rest starts up, so a slow start (startupz 503) is told apart
+	// from a dead process (no livez answer)
+	var started atomic.Bool
+	healthHandler := handler.NewHealthHandler(pgPool, redisClient,
+		handler.WithDegradedThreshold(time.Duration(config.Conf.ReadinessDegradedMillis)*time.Millisecond),
+		handler.WithReadinessCache(time.Duration(config.Conf.ReadinessCacheMillis)*time.Millisecond),
+		handler.WithStartupFlag(&started),
+	)
+	var routes routerSwitch
+	routes.Store(appRouter.NewStartupRouter(healthHandler))
 	port := config.Conf.BonsaiPort
 	if port == "" {
 		logger.Info(ctx, "no port configured, falling back to default: 8080")
@@ -66,7 +81,7 @@ func main() {
 
 	srv := &http.Server{
 		Addr:              ":" + port,
-		Handler:           r,
+		Handler:           &routes,
 		ReadTimeout:       5 * time.Second,
 		ReadHeaderTimeout: 5 * time.Second,
 		WriteTimeout:      15 * time.Second,
@@ -81,10 +96,196 @@ func main() {
 		}
 	}()
 
-	// Graceful shutdown on SIGINT/SIGTERM
+	// Setup Postgres repository and ensure schema if configured
+	pgRepo := pgrepo.NewSnippetRepository(pgPool,
+		pgrepo.WithMaxTags(config.Conf.MaxTagsPerSnippet),
+		pgrepo.WithPurgeGrace(config.Conf.ReadGraceWindow),
+		pgrepo.WithReplica(replicaPool),
+	)
+	if config.Conf.AutoMigrate {
+		if err := pgRepo.EnsureSchema(ctx); err != nil {
+			logger.Fatal(ctx, "failed to ensure postgres schema: %v", err)
+		}
+	}
+
+	// Compose cached repository: Postgres primary + Redis cache
+	writePolicy := cachedrepo.WriteLazy
+	if config.Conf.CacheOnWrite {
+		writePolicy = cachedrepo.WriteWarm
+	}
+	// Prometheus registry shared by the HTTP middleware and the cache
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	appMetrics := metrics.New(registry)
+	// Warming preloads the page a plain GET /v1/snippets asks for
+	var warmListOpts []repository.ListOption
+	if config.Conf.DefaultListOrder == handler.ListOrderOldest {
+		warmListOpts = append(warmListOpts, repository.WithSort(repository.SortCreatedAtAsc))
+	}
+	cacheOpts := []cachedrepo.Option{
+		cachedrepo.WithWritePolicy(writePolicy),
+		cachedrepo.WithMetrics(appMetrics),
+		cachedrepo.WithKeyPrefix(config.Conf.CacheKeyPrefix),
+		cachedrepo.WithWarmListPage(service.ServiceDefaultLimit, warmListOpts...),
+	}
+	if maxTTL := config.Conf.CacheMaxTTLSeconds; maxTTL > 0 {
+		cacheOpts = append(cacheOpts, cachedrepo.WithMaxTTL(time.Duration(maxTTL)*time.Second))
+	}
+	if n := config.Conf.RedisPipelineBatchSize; n > 0 {
+		cacheOpts = append(cacheOpts, cachedrepo.WithPipelineBatchSize(n))
+	}
+	if p := config.Conf.CacheMissProbability; p > 0 {
+		cacheOpts = append(cacheOpts, cachedrepo.WithMissCacheProbability(p))
+	}
+	if p := config.Conf.CacheShadowReadFraction; p > 0 {
+		cacheOpts = append(cacheOpts, cachedrepo.WithShadowReads(p))
+	}
+	if config.Conf.CacheInvalidationPubSub {
+		cacheOpts = append(cacheOpts, cachedrepo.WithInvalidationChannel(config.Conf.CacheInvalidationChannel))
+	}
+	asyncCacheWrites := false
+	switch config.Conf.CacheWriteMode {
+	case "", "sync":
+	case "async":
+		asyncCacheWrites = true
+		cacheOpts = append(cacheOpts, cachedrepo.WithWriteBehind(config.Conf.CacheWriteBuffer))
+	default:
+		logger.Fatal(ctx, "invalid CACHE_WRITE_MODE %q: want sync or async", config.Conf.CacheWriteMode)
+	}
+	cacheTTL := 10 * time.Minute
+	if secs := config.Conf.CacheTTLSeconds; secs > 0 {
+		cacheTTL = time.Duration(secs) * time.Second
+	}
+	repo := cachedrepo.NewSnippetRepository(pgRepo, redisClient, cacheTTL, cacheOpts...)
+	// Fail before serving traffic if the schema, database or cache cannot round-trip a snippet
+	if config.Conf.StartupSelfTest {
+		if err := selftest.Run(ctx, repo); err != nil {
+			logger.Fatal(ctx, "startup self-test failed: %v", err)
+		}
+		logger.Info(ctx, "startup self-test passed")
+	}
+	// Expiry is stamped with the app clock but filtered with Postgres NOW(), so the two must agree
+	if config.Conf.ClockSkewPolicy != "off" {
+		limit := time.Duration(config.Conf.MaxClockSkewMillis) * time.Millisecond
+		skew, err := selftest.CheckClockSkew(ctx, pgRepo, service.RealClock{}, limit)
+		switch {
+		case errors.Is(err, selftest.ErrClockSkew) && config.Conf.ClockSkewPolicy == "fail":
+			logger.Fatal(ctx, "startup clock check failed: %v", err)
+		case err != nil:
+			logger.WithField(ctx, "error", err.Error()).Warn("startup clock check failed")
+		default:
+			logger.WithField(ctx, "skew", skew.String()).Debug("app and database clocks agree")
+		}
+	}
+	expiryRounding, err := service.ParseExpiryRounding(config.Conf.ExpiryRounding)
+	if err != nil {
+		logger.Fatal(ctx, "invalid EXPIRY_ROUNDING: %v", err)
+	}
+	switch config.Conf.DeleteMode {
+	case "", "soft":
+	case "hard":
+		if _, ok := any(repo).(repository.HardDeleter); !ok {
+			logger.Fatal(ctx, "DELETE_MODE=hard is not supported by the snippet store")
+		}
+	default:
+		logger.Fatal(ctx, "invalid DELETE_MODE %q: must be soft or hard", config.Conf.DeleteMode)
+	}
+	svcOpts := []service.Option{
+		service.WithDuplicateHint(config.Conf.DuplicateHint),
+		service.WithGetCoalescing(config.Conf.CoalesceGets),
+		service.WithStrictTag(config.Conf.StrictTag),
+		service.WithImportDropInvalidExpiry(config.Conf.ImportDropInvalidExpiry),
+		service.WithReviveOnUpdate(config.Conf.AllowReviveOnUpdate),
+		service.WithLanguageDetection(config.Conf.AutoDetectLanguage),
+		service.WithFacetWindow(config.Conf.FacetWindow),
+		service.WithMetadataMaxItems(config.Conf.MetadataMaxItems),
+		service.WithMaxContentBytes(config.Conf.MaxContentBytes),
+		service.WithMaxTags(config.Conf.MaxTagsPerSnippet),
+		service.WithMaxTagLength(config.Conf.MaxTagLength),
+		service.WithExpiryJitter(config.Conf.ExpiryJitter),
+		service.WithExpiryRounding(expiryRounding),
+		service.WithReadGrace(config.Conf.ReadGraceWindow),
+		service.WithViewCounting(!config.Conf.DisableViewCounts),
+		service.WithHardDelete(config.Conf.DeleteMode == "hard"),
+	}
+	switch config.Conf.IDGenerator {
+	case "", "uuid":
+	case "short":
+		svcOpts = append(svcOpts, service.WithShortIDs(config.Conf.ShortIDLength))
+	default:
+		logger.Fatal(ctx, "invalid ID_GENERATOR %q: must be uuid or short", config.Conf.IDGenerator)
+	}
+	if config.Conf.SkipIDCollisionCheck {
+		svcOpts = append(svcOpts, service.WithIDCollisionCheck(false))
+	}
+	svc := service.NewServiceWithOptions(repo, &service.RealClock{}, svcOpts...)
+	// Short random IDs get crowded as the corpus grows; UUIDs skip this check
+	if config.Conf.IDEntropyPolicy != "off" {
+		risk, err := svc.CheckIDEntropy(ctx, config.Conf.MaxIDCollisionRisk)
+		switch {
+		case errors.Is(err, service.ErrIDEntropy) && config.Conf.IDEntropyPolicy == "fail":
+			logger.Fatal(ctx, "startup id entropy check failed: %v", err)
+		case err != nil:
+			logger.WithField(ctx, "error", err.Error()).Warn("startup id entropy check failed")
+		default:
+			logger.WithField(ctx, "risk", risk).Debug("short id collision risk acceptable")
+		}
+	}
+	// Background jobs run under a supervisor that restarts panicked workers and caps concurrency per job type
+	workerCtx, stopWorkers := context.WithCancel(ctx)
+	defer stopWorkers()
+	supervisor := worker.NewSupervisor(workerCtx, worker.WithDefaultLimit(config.Conf.MaxWorkersPerJob))
+	if config.Conf.PurgeExpired {
+		interval := time.Duration(config.Conf.PurgeIntervalSeconds) * time.Second
+		if err := supervisor.Go(worker.JobPurge, worker.PurgeExpired(repo, interval)); err != nil {
+			logger.Fatal(ctx, "start purge job: %v", err)
+		}
+	}
+	if !config.Conf.DisableViewCounts {
+		interval := time.Duration(config.Conf.ViewFlushIntervalSeconds) * time.Second
+		if err := supervisor.Go(worker.JobViewFlush, worker.FlushViews(repo, interval)); err != nil {
+			logger.Fatal(ctx, "start view flush job: %v", err)
+		}
+	}
+	if config.Conf.CacheInvalidationPubSub {
+		if err := supervisor.Go(worker.JobCacheInvalidation, repo.SubscribeInvalidations); err != nil {
+			logger.Fatal(ctx, "start cache invalidation subscriber: %v", err)
+		}
+	}
+	if asyncCacheWrites {
+		if err := supervisor.Go(worker.JobCacheWriteBehind, repo.RunWriteBehind); err != nil {
+			logger.Fatal(ctx, "start cache write-behind worker: %v", err)
+		}
+	}
+
+	snippetHandler := handler.NewHandler(svc)
+
+	routerOpts := []appRouter.Option{
+		appRouter.WithCacheStats(handler.NewCacheStatsHandler(repo)),
+		appRouter.WithWorkerStats(handler.NewWorkerStatsHandler(supervisor)),
+		appRouter.WithMetrics(appMetrics),
+	}
+	if config.Conf.RateLimitRPS > 0 {
+		routerOpts = append(routerOpts, appRouter.WithRateLimit(ratelimit.NewRedis(redisClient, config.Conf.RateLimitRPS, config.Conf.RateLimitBurst)))
+	}
+	if config.Conf.AdminCacheRefresh {
+		routerOpts = append(routerOpts, appRouter.WithCacheRefresh(handler.NewCacheRefreshHandler(repo)))
+	}
+	if config.Conf.CacheWarm {
+		warmCache(ctx, repo)
+	}
+	routes.Store(appRouter.NewRouter(snippetHandler, healthHandler, routerOpts...))
+
+	// Graceful shutdown on SIGINT/SIGTERM, also while still waiting for dependencies
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
-	<-stop
+
+	// Startup finishes once every dependency answered at least once
+	if waitForDependencies(ctx, healthHandler, stop) {
+		started.Store(true)
+		logger.Info(ctx, "startup complete")
+		<-stop
+	}
 	logger.WithField(ctx, "signal", "interrupt").Info("shutdown signal received")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -95,5 +296,59 @@ func main() {
 			logger.WithField(ctx, "error", cerr.Error()).Error("server close failed")
 		}
 	}
+	stopWorkers()
+	if err := supervisor.Wait(); err != nil {
+		logger.WithField(ctx, "error", err.Error()).Error("background worker failed")
+	}
 	logger.Info(ctx, "server stopped cleanly")
 }
+
+// waitForDependencies pings every dependency once a second until all of them answer. It
+// returns false if a shutdown signal arrives or ctx ends first.
+func waitForDependencies(ctx context.Context, h *handler.HealthHandler, stop <-chan os.Signal) bool {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		err := h.Ping(ctx)
+		if err == nil {
+			return true
+		}
+		logger.WithField(ctx, "error", err.Error()).Warn("waiting for dependencies")
+		select {
+		case <-stop:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// warmCache preloads recent snippets and the default list page into Redis, giving up after
+// CACHE_WARM_TIMEOUT_MS. Failures are logged and startup continues with a cold cache.
+func warmCache(ctx context.Context, repo *cachedrepo.SnippetRepository) {
+	n := config.Conf.CacheWarmSnippets
+	if n <= 0 {
+		n = 100
+	}
+	timeout := 5 * time.Second
+	if ms := config.Conf.CacheWarmTimeoutMillis; ms > 0 {
+		timeout = time.Duration(ms) * time.Millisecond
+	}
+	warmCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	if err := repo.Warm(warmCtx, n); err != nil {
+		logger.WithField(ctx, "error", err.Error()).Warn("cache warming failed, starting with a cold cache")
+	}
+}
+
+// routerSwitch serves whichever router was stored last, so the server can answer probes from the
+// startup router before the full router exists.
+type routerSwitch struct{ current atomic.Pointer[gin.Engine] }
+
+// Store replaces the router serving new requests.
+func (s *routerSwitch) Store(r *gin.Engine) { s.current.Store(r) }
+
+func (s *routerSwitch) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.current.Load().ServeHTTP(w, req)
+}
@@ -3,8 +3,13 @@ package config
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env"
 	"github.com/joho/godotenv"
@@ -17,8 +22,23 @@ type Config struct {
 	BonsaiPort string `env:"BONSAI_PORT"`
 	// RedisPort is the port on which the Redis server runs.
 	RedisPort string `env:"REDIS_PORT"`
+	// RedisSentinelAddrs and RedisSentinelMaster, when both set, connect through Redis Sentinel
+	// (comma-separated host:port list and master name) instead of RedisPort.
+	RedisSentinelAddrs  []string `env:"REDIS_SENTINEL_ADDRS"`
+	RedisSentinelMaster string   `env:"REDIS_SENTINEL_MASTER"`
+	// RedisTLS, if true, connects to Redis over TLS. RedisTLSCACert is an optional CA bundle in place
+	// of the system roots, RedisTLSCert and RedisTLSKey an optional client certificate, and
+	// RedisTLSServerName overrides the host name checked against the server certificate.
+	RedisTLS           bool   `env:"REDIS_TLS"`
+	RedisTLSCACert     string `env:"REDIS_TLS_CA_CERT"`
+	RedisTLSCert       string `env:"REDIS_TLS_CERT"`
+	RedisTLSKey        string `env:"REDIS_TLS_KEY"`
+	RedisTLSServerName string `env:"REDIS_TLS_SERVER_NAME"`
 	// PostgresURL is the full DSN for connecting to Postgres. If provided, it will be used as-is.
 	PostgresURL string `env:"POSTGRES_URL"`
+	// PostgresReplicaURL is the DSN of a read replica. When set, snippet reads, lists and counts
+	// go to it and writes to the primary; when empty everything uses the primary.
+	PostgresReplicaURL string `env:"POSTGRES_REPLICA_URL"`
 	// PostgresHost is the hostname for Postgres (used if PostgresURL is empty).
 	PostgresHost string `env:"POSTGRES_HOST"`
 	// PostgresPort is the port for Postgres (used if PostgresURL is empty).
@@ -29,10 +49,233 @@ type Config struct {
 	PostgresPassword string `env:"POSTGRES_PASSWORD"`
 	// PostgresDB is the database name for Postgres (used if PostgresURL is empty).
 	PostgresDB string `env:"POSTGRES_DB"`
-	// PostgresSSLMode controls the sslmode parameter when building a DSN (disable, require, verify-ca, verify-full).
+	// PostgresSSLMode controls the sslmode parameter (disable, require, verify-ca, verify-full). When
+	// set it also overrides the sslmode of PostgresURL and PostgresReplicaURL.
 	PostgresSSLMode string `env:"POSTGRES_SSLMODE"`
+	// PostgresSSLRootCert is a CA bundle for verify-ca/verify-full; PostgresSSLCert and PostgresSSLKey
+	// are a client certificate and key. Those set also override the DSN's values in PostgresURL and
+	// PostgresReplicaURL.
+	PostgresSSLRootCert string `env:"POSTGRES_SSLROOTCERT"`
+	PostgresSSLCert     string `env:"POSTGRES_SSLCERT"`
+	PostgresSSLKey      string `env:"POSTGRES_SSLKEY"`
+	// PostgresConnectAttempts and PostgresConnectBaseDelayMillis bound the retries of the first
+	// Postgres connection at startup; the delay doubles after each failure (0 uses the defaults
+	// of 5 attempts and 500ms).
+	PostgresConnectAttempts        int `env:"POSTGRES_CONNECT_ATTEMPTS"`
+	PostgresConnectBaseDelayMillis int `env:"POSTGRES_CONNECT_BASE_DELAY_MS"`
 	// AutoMigrate, if true, will run light schema migrations on startup.
 	AutoMigrate bool `env:"AUTO_MIGRATE"`
+	// RequireJSONContentType, if true, rejects create/update requests whose Content-Type is not JSON with 415.
+	RequireJSONContentType bool `env:"REQUIRE_JSON_CONTENT_TYPE"`
+	// JSONVendorContentType is an additional media type (e.g. application/vnd.bonsai+json) accepted as JSON.
+	JSONVendorContentType string `env:"JSON_VENDOR_CONTENT_TYPE"`
+	// DuplicateHint, if true, sets duplicate_of on create responses when identical content already exists.
+	DuplicateHint bool `env:"DUPLICATE_HINT"`
+	// MaxQueryValues caps how many values a single list query parameter may repeat (0 uses the default).
+	MaxQueryValues int `env:"MAX_QUERY_VALUES"`
+	// MaxListPage caps how deep offset pagination may go; deeper pages get 400 page_too_deep
+	// (0 uses the default of 1000). Cursor pagination is exempt.
+	MaxListPage int `env:"MAX_LIST_PAGE"`
+	// MaxQueryLength caps the raw query string length of list requests in bytes (0 uses the default).
+	MaxQueryLength int `env:"MAX_QUERY_LENGTH"`
+	// StrictAccept, if true, answers 406 when the Accept header matches no supported media type.
+	StrictAccept bool `env:"STRICT_ACCEPT"`
+	// CacheOnWrite, if true, writes snippets to Redis on create and update so the first read is a hit.
+	// When false (default) the cache is filled lazily by the first read.
+	CacheOnWrite bool `env:"CACHE_ON_WRITE"`
+	// CacheWriteMode is "sync" (default) to write the cache before a create or update responds, or
+	// "async" to queue the write for a background worker. CacheWriteBuffer caps the queue (0 uses
+	// the default of 1024); writes that do not fit are dropped and filled by the next read.
+	CacheWriteMode   string `env:"CACHE_WRITE_MODE"`
+	CacheWriteBuffer int    `env:"CACHE_WRITE_BUFFER"`
+	// CacheWarm, if true, preloads the CacheWarmSnippets most recent snippets (0 uses the default of
+	// 100) and the first page of the default list into Redis before startup completes. Warming
+	// gives up after CacheWarmTimeoutMillis (0 uses the default of 5000); failures are logged only.
+	CacheWarm              bool `env:"CACHE_WARM"`
+	CacheWarmSnippets      int  `env:"CACHE_WARM_SNIPPETS"`
+	CacheWarmTimeoutMillis int  `env:"CACHE_WARM_TIMEOUT_MS"`
+	// CacheKeyPrefix starts every snippet cache key, e.g. "staging:", so environments sharing one
+	// Redis do not collide. Empty by default.
+	CacheKeyPrefix string `env:"CACHE_KEY_PREFIX"`
+	// MaxTagsPerSnippet caps how many tags, after normalization, one snippet may carry on create,
+	// update and import (0 uses the default of 256). Larger tag sets are rejected with 400 too_many_tags.
+	MaxTagsPerSnippet int `env:"MAX_TAGS_PER_SNIPPET"`
+	// MaxTagLength caps each tag's length in characters after trimming (0 uses the default of 64).
+	// Longer tags are rejected with 400 tag_too_long.
+	MaxTagLength int `env:"MAX_TAG_LENGTH"`
+	// ListMaxTags caps how many tags each list item returns (0 means unlimited). Get always returns all tags.
+	ListMaxTags int `env:"LIST_MAX_TAGS"`
+	// CoalesceGets, if true, makes concurrent reads of the same snippet ID share one repository call.
+	CoalesceGets bool `env:"COALESCE_GETS"`
+	// StrictTag, if true, answers 404 unknown_tag when listing by a tag no snippet has ever carried.
+	StrictTag bool `env:"STRICT_TAG"`
+	// ExtraResponseHeaders are stamped on every response, e.g. "X-Bonsai-Region=us-east-1,X-Bonsai-Instance=a1".
+	ExtraResponseHeaders map[string]string `env:"EXTRA_RESPONSE_HEADERS"`
+	// MaxWorkersPerJob caps concurrent background workers of each job type (0 uses the default of 1).
+	MaxWorkersPerJob int `env:"MAX_WORKERS_PER_JOB"`
+	// AllowClientIDs, if true, lets POST /v1/snippets choose the snippet ID via "id".
+	AllowClientIDs bool `env:"ALLOW_CLIENT_IDS"`
+	// PurgeExpired, if true, runs a background job that deletes expired snippets from Postgres.
+	PurgeExpired bool `env:"PURGE_EXPIRED"`
+	// PurgeIntervalSeconds is how often the purge job runs (0 uses the default of 300).
+	PurgeIntervalSeconds int `env:"PURGE_INTERVAL_SECONDS"`
+	// DisableViewCounts, if true, stops counting snippet reads.
+	DisableViewCounts bool `env:"DISABLE_VIEW_COUNTS"`
+	// ViewFlushIntervalSeconds is how often counted reads are flushed from Redis to Postgres
+	// (0 uses the default of 10).
+	ViewFlushIntervalSeconds int `env:"VIEW_FLUSH_INTERVAL_SECONDS"`
+	// ImportDropInvalidExpiry, if true, drops an imported expires_at that is not after created_at
+	// instead of rejecting the record (default).
+	ImportDropInvalidExpiry bool `env:"IMPORT_DROP_INVALID_EXPIRY"`
+	// CacheMissProbability is the chance (0-1] a snippet read on a cache miss is written back to Redis.
+	// 0 or unset means always; lower it to reduce cache writes when Redis is near capacity.
+	CacheMissProbability float64 `env:"CACHE_MISS_PROBABILITY"`
+	// ExpiryJitter spreads expires_in-based expiries within ±ExpiryJitter of the TTL, e.g. 0.1 for ±10%,
+	// so batches created with the same TTL do not expire at once. 0 or unset means exact expiries.
+	ExpiryJitter float64 `env:"EXPIRY_JITTER"`
+	// ExpiryRounding rounds expires_in-based expiries up to the next "minute" or "hour" boundary,
+	// so they display cleanly. "none" or unset keeps them exact.
+	ExpiryRounding string `env:"EXPIRY_ROUNDING"`
+	// ReadGraceWindow keeps expired snippets readable by ID for this long, e.g. "10m", answering
+	// with a Warning header and "expired": true before 410. 0 or unset means immediate 410.
+	ReadGraceWindow time.Duration `env:"READ_GRACE_WINDOW"`
+	// CacheShadowReadFraction is the share (0-1) of snippet and list cache hits also read from Postgres
+	// to log and count divergence; the cached value is still served. 0 or unset disables shadow reads.
+	CacheShadowReadFraction float64 `env:"CACHE_SHADOW_READ_FRACTION"`
+	// CacheInvalidationPubSub, if true, broadcasts cache invalidations to other instances over Redis pub/sub
+	// and applies theirs. CacheInvalidationChannel names the channel (default "bonsai:invalidate").
+	CacheInvalidationPubSub  bool   `env:"CACHE_INVALIDATION_PUBSUB"`
+	CacheInvalidationChannel string `env:"CACHE_INVALIDATION_CHANNEL"`
+	// CacheTTLSeconds is the default cache TTL of snippets and list pages (0 uses the default of 600).
+	// A snippet is never cached past its own expiry.
+	CacheTTLSeconds int `env:"CACHE_TTL_SECONDS"`
+	// CacheMaxTTLSeconds caps per-snippet cache_ttl_seconds hints (0 caps them at the default cache TTL).
+	CacheMaxTTLSeconds int `env:"CACHE_MAX_TTL_SECONDS"`
+	// OverLimitPolicy controls list requests with limit above the maximum of 100:
+	// "reject" (default) answers 400, "cap" silently lowers the limit to 100.
+	OverLimitPolicy string `env:"OVER_LIMIT_POLICY"`
+	// DefaultListOrder is the list order when a request has no sort: "newest" (default) or "oldest".
+	DefaultListOrder string `env:"DEFAULT_LIST_ORDER"`
+	// DeleteMode is "soft" (default), keeping deleted snippets restorable, or "hard", removing them.
+	DeleteMode string `env:"DELETE_MODE"`
+	// AdminToken is the bearer token for admin-only requests such as delete, restore,
+	// ?include_deleted=1 and cache stats; empty disables them.
+	AdminToken string `env:"ADMIN_TOKEN"`
+	// ConflictingFilterPolicy controls list requests that both include and exclude a tag:
+	// "error" (default) answers 400, "exclude_wins" or "include_wins" drop the tag from the other side.
+	ConflictingFilterPolicy string `env:"CONFLICTING_FILTER_POLICY"`
+	// RedisPipelineBatchSize caps commands per pipelined Redis round-trip for multi-key operations (0 uses the default of 100).
+	RedisPipelineBatchSize int `env:"REDIS_PIPELINE_BATCH_SIZE"`
+	// MaxListItems is a hard cap on items per list page regardless of the requested limit (0 means no extra cap).
+	// Capped responses set limit_truncated.
+	MaxListItems int `env:"MAX_LIST_ITEMS"`
+	// ListView is the list view used when a request has no ?view=: "summary" (default) omits
+	// content, "full" includes it.
+	ListView string `env:"LIST_VIEW"`
+	// ListFullMaxItems caps items per full-view list page, since each carries its content
+	// (0 uses the default of 50). Capped responses set limit_truncated.
+	ListFullMaxItems int `env:"LIST_FULL_MAX_ITEMS"`
+	// ListEchoFilters, if true, adds the applied filters to every list response, as ?echo_filters=1 does.
+	ListEchoFilters bool `env:"LIST_ECHO_FILTERS"`
+	// ListMeta is where list pagination metadata goes when a request has no ?meta=: "body"
+	// (default) wraps the items, "headers" returns a bare array with X-Page/X-Total headers.
+	ListMeta string `env:"LIST_META"`
+	// AccessLogProbeSample logs one in every N successful health check and probe requests;
+	// 0 or 1 logs them all and a negative value skips them.
+	AccessLogProbeSample int `env:"ACCESS_LOG_PROBE_SAMPLE"`
+	// MaxURILength caps the request URI (path plus query) in bytes; longer requests get 414 (0 uses the default of 8192).
+	MaxURILength int `env:"MAX_URI_LENGTH"`
+	// StrictJSON, if true, rejects write bodies with data after the JSON object with 400 instead of ignoring it.
+	StrictJSON bool `env:"STRICT_JSON"`
+	// StrictTemplateVars, if true, answers 400 when reading a templated snippet without a value for
+	// every {{var}} placeholder; by default unknown placeholders are left as-is.
+	StrictTemplateVars bool `env:"STRICT_TEMPLATE_VARS"`
+	// ReadinessDegradedMillis is the dependency ping latency beyond which /v1/readyz reports a
+	// check as degraded while staying ready (0 uses the default of 500).
+	ReadinessDegradedMillis int `env:"READINESS_DEGRADED_MS"`
+	// ReadinessCacheMillis is how long /v1/readyz reuses a result in which every dependency
+	// answered (0 uses the default of 2000, negative disables it). Failures are never reused.
+	ReadinessCacheMillis int `env:"READINESS_CACHE_MS"`
+	// HealthSchema selects the /v1/health payload: "legacy" (default) keeps {code, data:{ok:true}, message}
+	// regardless of other envelope changes, "status" answers {"status":"ok"}.
+	HealthSchema string `env:"HEALTH_SCHEMA"`
+	// IDGenerator selects how snippet IDs are generated: "uuid" (default) or "short" for random
+	// base62 IDs of ShortIDLength characters (0 uses the default of 10).
+	IDGenerator   string `env:"ID_GENERATOR"`
+	ShortIDLength int    `env:"SHORT_ID_LENGTH"`
+	// SkipIDCollisionCheck, if true, skips the pre-insert existence query for generated snippet IDs.
+	// When unset the check runs only for short-ID generators; UUIDs always skip it.
+	SkipIDCollisionCheck bool `env:"SKIP_ID_COLLISION_CHECK"`
+	// MarkdownPreview, if true, renders ?preview=html of markdown snippets to sanitized HTML.
+	// When false markdown is escaped like any other language.
+	MarkdownPreview bool `env:"MARKDOWN_PREVIEW"`
+	// AllowReviveOnUpdate, if true, lets PUT revive an expired snippet with a new expiry instead of answering 410.
+	AllowReviveOnUpdate bool `env:"ALLOW_REVIVE_ON_UPDATE"`
+	// CacheExemptRoutes lists route templates whose reads always bypass Redis and hit Postgres,
+	// e.g. "/v1/snippets/mine,/v1/snippets/:id". Writes on any route still invalidate the cache.
+	CacheExemptRoutes []string `env:"CACHE_EXEMPT_ROUTES"`
+	// StartupSelfTest, if true, writes and reads back a throwaway snippet (tagged bonsai-selftest)
+	// through the cached repository on boot and exits if any step fails.
+	StartupSelfTest bool `env:"STARTUP_SELF_TEST"`
+	// MaxClockSkewMillis is the largest difference between the app clock and Postgres NOW() accepted
+	// at startup (0 uses the default of 1000). ClockSkewPolicy decides what happens beyond it:
+	// "warn" (default) logs, "fail" exits and "off" skips the check.
+	MaxClockSkewMillis int    `env:"MAX_CLOCK_SKEW_MS"`
+	ClockSkewPolicy    string `env:"CLOCK_SKEW_POLICY"`
+	// AutoDetectLanguage, if true, fills an unset snippet language from its content on create and update.
+	AutoDetectLanguage bool `env:"AUTO_DETECT_LANGUAGE"`
+	// MaxIDCollisionRisk is the highest accepted chance of two short snippet IDs colliding given the
+	// current snippet count, checked at startup (0 uses the default of 0.01). IDEntropyPolicy decides
+	// what happens beyond it: "warn" (default) logs, "fail" exits and "off" skips the check.
+	MaxIDCollisionRisk float64 `env:"MAX_ID_COLLISION_RISK"`
+	IDEntropyPolicy    string  `env:"ID_ENTROPY_POLICY"`
+	// PreviewLines is how many content lines a preview includes (0 uses the default of 10).
+	PreviewLines int `env:"PREVIEW_LINES"`
+	// FacetWindow caps how many of the most recent matching snippets ?facets=true counts
+	// (0 uses the default of 1000); facets are flagged approximate when more match.
+	FacetWindow int `env:"FACET_WINDOW"`
+	// APIDocs, if true, serves Swagger UI for the OpenAPI document at /v1/docs.
+	APIDocs bool `env:"API_DOCS"`
+	// MaxContentBytes caps snippet content size in bytes on create, import and update (0 uses the
+	// default of 10240).
+	MaxContentBytes int `env:"MAX_CONTENT_BYTES"`
+	// MetadataMaxItems caps how many snippets GET /v1/snippets/metadata returns (0 uses the
+	// default of 1000); the response is flagged truncated when more carry the tag.
+	MetadataMaxItems int `env:"METADATA_MAX_ITEMS"`
+	// ListDefaultFilter holds list filters, as a query string, applied when a GET /v1/snippets request
+	// has none, e.g. "tag=featured" for a curated home feed. Any explicit filter replaces them.
+	ListDefaultFilter url.Values `env:"LIST_DEFAULT_FILTER"`
+	// AdminCacheRefresh, if true, exposes POST /v1/admin/cache/refresh/:id to reload a snippet
+	// from Postgres into the cache.
+	AdminCacheRefresh bool `env:"ADMIN_CACHE_REFRESH"`
+	// Gzip, if true, compresses responses for clients that accept gzip.
+	Gzip bool `env:"GZIP"`
+	// GzipLevel trades speed for ratio, 1 (fastest) to 9 (smallest); 0 uses the default of 6.
+	GzipLevel int `env:"GZIP_LEVEL"`
+	// GzipContentTypes lists the media types to compress, e.g. "application/json,text/*".
+	// Empty uses JSON, text/* and YAML.
+	GzipContentTypes []string `env:"GZIP_CONTENT_TYPES"`
+	// CORSAllowedOrigins lists the origins browsers may call the API from, e.g.
+	// "https://app.example.com"; "*" allows any. Empty disables CORS.
+	CORSAllowedOrigins []string `env:"CORS_ALLOWED_ORIGINS"`
+	// CORSAllowedMethods lists the methods allowed cross-origin; empty uses GET, POST, PUT, PATCH and DELETE.
+	CORSAllowedMethods []string `env:"CORS_ALLOWED_METHODS"`
+	// CORSAllowedHeaders lists the request headers allowed cross-origin; empty uses Accept,
+	// Content-Type, If-None-Match, X-Request-ID and X-Client-ID.
+	CORSAllowedHeaders []string `env:"CORS_ALLOWED_HEADERS"`
+	// CORSMaxAgeSeconds is how long browsers may cache a preflight answer (0 uses the default of 600).
+	CORSMaxAgeSeconds int `env:"CORS_MAX_AGE_SECONDS"`
+	// CORSAllowCredentials, if true, lets browsers send cookies and auth headers cross-origin.
+	CORSAllowCredentials bool `env:"CORS_ALLOW_CREDENTIALS"`
+	// MaxStreamingConns caps concurrent long-lived streaming responses across all streaming
+	// routes; further ones get 503 too_many_streams. 0 or unset means no cap.
+	MaxStreamingConns int `env:"MAX_STREAMING_CONNS"`
+	// RateLimitRPS is how many requests per second each client (X-Client-ID, or remote IP without
+	// one) may make, shared across replicas through Redis. 0 or unset disables rate limiting.
+	RateLimitRPS float64 `env:"RATE_LIMIT_RPS"`
+	// RateLimitBurst is how many requests a client may make at once after being idle (0 uses
+	// RateLimitRPS rounded up).
+	RateLimitBurst int `env:"RATE_LIMIT_BURST"`
 }
 
 // Conf holds the global configuration for the Bonsai application.
@@ -50,11 +293,67 @@ func loadDotEnv() {
 	}
 }
 
+// protectedHeaders may not be overridden through ExtraResponseHeaders.
+var protectedHeaders = map[string]bool{
+	"Content-Type":              true,
+	"Content-Length":            true,
+	"Content-Encoding":          true,
+	"Set-Cookie":                true,
+	"Strict-Transport-Security": true,
+	"Content-Security-Policy":   true,
+	"X-Content-Type-Options":    true,
+	"X-Frame-Options":           true,
+	"X-Request-Id":              true,
+	"X-Client-Id":               true,
+}
+
+// ParseHeaderMap parses "Name=value,Name2=value2" into canonical header names,
+// rejecting invalid or protected names.
+func ParseHeaderMap(v string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(v, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !ok || !validHeaderName(name) {
+			return nil, fmt.Errorf("invalid response header %q", pair)
+		}
+		name = http.CanonicalHeaderKey(name)
+		if protectedHeaders[name] {
+			return nil, fmt.Errorf("response header %q cannot be overridden", name)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// validHeaderName reports whether name is a non-empty RFC 7230 token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r >= 0x7f || r <= ' ' || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsers holds custom env parsers for types caarlos0/env does not support natively.
+var parsers = env.CustomParsers{
+	reflect.TypeOf(map[string]string{}): func(v string) (interface{}, error) { return ParseHeaderMap(v) },
+	reflect.TypeOf(url.Values{}):        func(v string) (interface{}, error) { return url.ParseQuery(v) },
+}
+
 // InitConf initializes the global configuration by loading environment variables and .env files.
 func InitConf() {
 	loadDotEnv()
 
-	if err := env.Parse(&Conf); err != nil {
+	if err := env.ParseWithFuncs(&Conf, parsers); err != nil {
 		logger.Fatal(context.Background(), "%v", err)
 	}
 }
@@ -0,0 +1,19 @@
+package config
+
+import "testing"
+
+func TestParseHeaderMap(t *testing.T) {
+	got, err := ParseHeaderMap("x-bonsai-region=us-east-1, X-Bonsai-Instance = a1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got["X-Bonsai-Region"] != "us-east-1" || got["X-Bonsai-Instance"] != "a1" || len(got) != 2 {
+		t.Fatalf("unexpected headers: %v", got)
+	}
+
+	for _, bad := range []string{"no-equals", "bad name=x", "=x", "Content-Type=text/plain", "x-request-id=1"} {
+		if _, err := ParseHeaderMap(bad); err == nil {
+			t.Fatalf("want error for %q", bad)
+		}
+	}
+}
@@ -4,6 +4,8 @@ package data
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -11,18 +13,95 @@ import (
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
-// NewPostgresPool creates a new pgx connection pool based on environment configuration.
+// Defaults for retrying the first Postgres connection at startup.
+const (
+	DefaultPostgresConnectAttempts  = 5
+	DefaultPostgresConnectBaseDelay = 500 * time.Millisecond
+	maxPostgresConnectDelay         = 30 * time.Second
+)
+
+// NewPostgresPool creates a new pgx connection pool based on environment configuration and pings
+// it, retrying with exponential backoff (POSTGRES_CONNECT_ATTEMPTS, POSTGRES_CONNECT_BASE_DELAY_MS)
+// so a database that is still starting does not crash the process.
 func NewPostgresPool(ctx context.Context) (*pgxpool.Pool, error) {
-	if dsn := config.Conf.PostgresURL; dsn != "" {
+	return connectWithRetry(ctx, "postgres", newPostgresPool, postgresConnectAttempts(), postgresConnectBaseDelay())
+}
+
+// newPostgresPool builds the pool from config without connecting.
+func newPostgresPool(ctx context.Context) (*pgxpool.Pool, error) {
+	cfg, err := postgresPoolConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return pgxpool.NewWithConfig(ctx, cfg)
+}
+
+// postgresPoolConfig parses POSTGRES_URL, or builds a DSN from the POSTGRES_* parts including the
+// sslmode and certificate paths, which pgx turns into the connection's TLS config.
+func postgresPoolConfig(ctx context.Context) (*pgxpool.Config, error) {
+	dsn := config.Conf.PostgresURL
+	if dsn != "" {
 		logger.Info(ctx, "initializing postgres pool via DSN (host masked)")
-		cfg, err := pgxpool.ParseConfig(dsn)
+	} else {
+		dsn = postgresDSN(ctx)
+	}
+	return parsePoolConfig(dsn)
+}
+
+// parsePoolConfig parses dsn with the POSTGRES_SSL* settings applied and sets the pool timeouts.
+func parsePoolConfig(dsn string) (*pgxpool.Config, error) {
+	cfg, err := pgxpool.ParseConfig(withSSLSettings(dsn))
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxConnIdleTime = 30 * time.Second
+	cfg.MaxConnLifetime = 30 * time.Minute
+	return cfg, nil
+}
+
+// withSSLSettings sets the POSTGRES_SSL* settings that are configured on dsn, overriding the DSN's
+// own values, so that they also apply to POSTGRES_URL and POSTGRES_REPLICA_URL. dsn may be a URL
+// or in keyword/value form.
+func withSSLSettings(dsn string) string {
+	var settings [][2]string
+	for _, s := range [][2]string{
+		{"sslmode", config.Conf.PostgresSSLMode},
+		{"sslrootcert", config.Conf.PostgresSSLRootCert},
+		{"sslcert", config.Conf.PostgresSSLCert},
+		{"sslkey", config.Conf.PostgresSSLKey},
+	} {
+		if s[1] != "" {
+			settings = append(settings, s)
+		}
+	}
+	if len(settings) == 0 {
+		return dsn
+	}
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
 		if err != nil {
-			return nil, err
+			// Left for pgx to report
+			return dsn
+		}
+		q := u.Query()
+		for _, s := range settings {
+			q.Set(s[0], s[1])
 		}
-		cfg.MaxConnIdleTime = 30 * time.Second
-		cfg.MaxConnLifetime = 30 * time.Minute
-		return pgxpool.NewWithConfig(ctx, cfg)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	// Later keywords win in pgx's keyword/value parser
+	quote := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	for _, s := range settings {
+		dsn += fmt.Sprintf(" %s='%s'", s[0], quote.Replace(s[1]))
 	}
+	return dsn
+}
+
+// postgresDSN builds a DSN from the POSTGRES_* parts. sslmode=verify-full with POSTGRES_SSLROOTCERT
+// checks the server certificate and host name against a custom CA; POSTGRES_SSLCERT and
+// POSTGRES_SSLKEY add a client certificate.
+func postgresDSN(ctx context.Context) string {
 	host := config.Conf.PostgresHost
 	if host == "" {
 		host = "127.0.0.1"
@@ -44,13 +123,84 @@ func NewPostgresPool(ctx context.Context) (*pgxpool.Pool, error) {
 	if sslmode == "" {
 		sslmode = "disable"
 	}
+	params := url.Values{"sslmode": {sslmode}}
+	for key, path := range map[string]string{
+		"sslrootcert": config.Conf.PostgresSSLRootCert,
+		"sslcert":     config.Conf.PostgresSSLCert,
+		"sslkey":      config.Conf.PostgresSSLKey,
+	} {
+		if path != "" {
+			params.Set(key, path)
+		}
+	}
 	logger.With(ctx, map[string]any{"host": host, "port": port, "db": db, "sslmode": sslmode}).Info("initializing postgres pool")
-	dsn := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s", user, pass, host, port, db, sslmode)
-	cfg, err := pgxpool.ParseConfig(dsn)
+	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?%s", user, pass, host, port, db, params.Encode())
+}
+
+// NewPostgresReplicaPool creates a pgx connection pool for the read replica at
+// config.Conf.PostgresReplicaURL, or returns nil when no replica is configured.
+func NewPostgresReplicaPool(ctx context.Context) (*pgxpool.Pool, error) {
+	dsn := config.Conf.PostgresReplicaURL
+	if dsn == "" {
+		return nil, nil
+	}
+	logger.Info(ctx, "initializing postgres replica pool via DSN (host masked)")
+	cfg, err := parsePoolConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
-	cfg.MaxConnIdleTime = 30 * time.Second
-	cfg.MaxConnLifetime = 30 * time.Minute
-	return pgxpool.NewWithConfig(ctx, cfg)
+	open := func(ctx context.Context) (*pgxpool.Pool, error) { return pgxpool.NewWithConfig(ctx, cfg) }
+	return connectWithRetry(ctx, "postgres replica", open, postgresConnectAttempts(), postgresConnectBaseDelay())
+}
+
+// pool is the part of *pgxpool.Pool that connectWithRetry needs.
+type pool interface {
+	Ping(ctx context.Context) error
+	Close()
+}
+
+// connectWithRetry opens a pool and pings it, retrying up to attempts times with a delay that
+// starts at base and doubles each time (capped at 30s). It gives up early when ctx is done and
+// otherwise returns the last error once the attempts are used up.
+func connectWithRetry[P pool](ctx context.Context, name string, open func(context.Context) (P, error), attempts int, base time.Duration) (P, error) {
+	var zero P
+	delay := base
+	for attempt := 1; ; attempt++ {
+		p, err := open(ctx)
+		if err == nil {
+			if err = p.Ping(ctx); err == nil {
+				if attempt > 1 {
+					logger.WithField(ctx, "attempt", attempt).Infof("%s connected", name)
+				}
+				return p, nil
+			}
+			p.Close()
+		}
+		if attempt >= attempts {
+			return zero, fmt.Errorf("connect %s after %d attempts: %w", name, attempt, err)
+		}
+		logger.With(ctx, map[string]any{"attempt": attempt, "max_attempts": attempts, "retry_in": delay.String(), "error": err.Error()}).Warnf("%s not reachable, retrying", name)
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return zero, fmt.Errorf("connect %s: %w (last error: %v)", name, ctx.Err(), err)
+		case <-t.C:
+		}
+		delay = min(delay*2, maxPostgresConnectDelay)
+	}
+}
+
+func postgresConnectAttempts() int {
+	if n := config.Conf.PostgresConnectAttempts; n > 0 {
+		return n
+	}
+	return DefaultPostgresConnectAttempts
+}
+
+func postgresConnectBaseDelay() time.Duration {
+	if ms := config.Conf.PostgresConnectBaseDelayMillis; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return DefaultPostgresConnectBaseDelay
 }
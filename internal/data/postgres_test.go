@@ -0,0 +1,201 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roguepikachu/bonsai/internal/config"
+)
+
+type fakePool struct {
+	pingErr error
+	closed  bool
+}
+
+func (p *fakePool) Ping(context.Context) error { return p.pingErr }
+func (p *fakePool) Close()                     { p.closed = true }
+
+// failingFactory fails the first n opens, alternating between open and ping errors, then succeeds.
+func failingFactory(n int) (open func(context.Context) (*fakePool, error), opened *[]*fakePool) {
+	var pools []*fakePool
+	calls := 0
+	return func(context.Context) (*fakePool, error) {
+		calls++
+		if calls > n {
+			p := &fakePool{}
+			pools = append(pools, p)
+			return p, nil
+		}
+		if calls%2 == 1 {
+			return nil, errors.New("connection refused")
+		}
+		p := &fakePool{pingErr: errors.New("the database system is starting up")}
+		pools = append(pools, p)
+		return p, nil
+	}, &pools
+}
+
+func TestConnectWithRetry_SucceedsAfterFailures(t *testing.T) {
+	open, pools := failingFactory(3)
+	p, err := connectWithRetry(context.Background(), "postgres", open, 5, time.Millisecond)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p.closed {
+		t.Fatalf("returned pool must not be closed")
+	}
+	for _, fp := range (*pools)[:len(*pools)-1] {
+		if !fp.closed {
+			t.Fatalf("pools that failed to ping must be closed")
+		}
+	}
+}
+
+func TestConnectWithRetry_GivesUp(t *testing.T) {
+	open, _ := failingFactory(3)
+	if _, err := connectWithRetry(context.Background(), "postgres", open, 3, time.Millisecond); err == nil {
+		t.Fatalf("want an error after the attempts are used up")
+	}
+}
+
+func TestConnectWithRetry_FirstAttempt(t *testing.T) {
+	open, pools := failingFactory(0)
+	start := time.Now()
+	if _, err := connectWithRetry(context.Background(), "postgres", open, 5, time.Hour); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(*pools) != 1 || time.Since(start) > time.Second {
+		t.Fatalf("want a single attempt without waiting, got %d pools", len(*pools))
+	}
+}
+
+func TestConnectWithRetry_RespectsContext(t *testing.T) {
+	open, _ := failingFactory(100)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := connectWithRetry(ctx, "postgres", open, 100, time.Hour)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want the deadline error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatalf("retry did not stop at the deadline")
+	}
+}
+
+func TestPostgresPoolConfig_TLS(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	certPath, keyPath := writeTestCert(t)
+
+	config.Conf = config.Config{PostgresHost: "db.internal"}
+	cfg, err := postgresPoolConfig(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ConnConfig.TLSConfig != nil {
+		t.Fatalf("default sslmode must stay plaintext")
+	}
+
+	config.Conf = config.Config{
+		PostgresHost:        "db.internal",
+		PostgresSSLMode:     "verify-full",
+		PostgresSSLRootCert: certPath,
+		PostgresSSLCert:     certPath,
+		PostgresSSLKey:      keyPath,
+	}
+	cfg, err = postgresPoolConfig(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tlsCfg := cfg.ConnConfig.TLSConfig
+	if tlsCfg == nil || tlsCfg.ServerName != "db.internal" || tlsCfg.InsecureSkipVerify {
+		t.Fatalf("want verify-full against db.internal, got %+v", tlsCfg)
+	}
+	if tlsCfg.RootCAs == nil || len(tlsCfg.Certificates) != 1 {
+		t.Fatalf("want the custom CA and client certificate, got roots=%v certs=%d", tlsCfg.RootCAs, len(tlsCfg.Certificates))
+	}
+	if len(cfg.ConnConfig.Fallbacks) != 0 {
+		t.Fatalf("verify-full must not fall back to plaintext")
+	}
+
+	config.Conf.PostgresSSLRootCert = "/does/not/exist.pem"
+	if _, err := postgresPoolConfig(context.Background()); err == nil {
+		t.Fatalf("want an error for a missing root certificate")
+	}
+}
+
+func TestPostgresPoolConfig_TLSWithURLs(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	certPath, keyPath := writeTestCert(t)
+	tlsSettings := func(c config.Config) config.Config {
+		c.PostgresSSLMode = "verify-full"
+		c.PostgresSSLRootCert = certPath
+		c.PostgresSSLCert = certPath
+		c.PostgresSSLKey = keyPath
+		return c
+	}
+	wantTLS := func(t *testing.T, name string, cfg *pgxpool.Config, host string) {
+		t.Helper()
+		tlsCfg := cfg.ConnConfig.TLSConfig
+		if tlsCfg == nil || tlsCfg.ServerName != host || tlsCfg.InsecureSkipVerify {
+			t.Fatalf("%s: want verify-full against %s, got %+v", name, host, tlsCfg)
+		}
+		if tlsCfg.RootCAs == nil || len(tlsCfg.Certificates) != 1 {
+			t.Fatalf("%s: want the custom CA and client certificate, got roots=%v certs=%d", name, tlsCfg.RootCAs, len(tlsCfg.Certificates))
+		}
+		if len(cfg.ConnConfig.Fallbacks) != 0 {
+			t.Fatalf("%s: verify-full must not fall back to plaintext", name)
+		}
+	}
+
+	// The configured settings override the URL's own sslmode
+	config.Conf = tlsSettings(config.Config{PostgresURL: "postgres://u:p@primary.internal:5432/bonsai?sslmode=disable"})
+	cfg, err := postgresPoolConfig(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantTLS(t, "POSTGRES_URL", cfg, "primary.internal")
+
+	config.Conf = tlsSettings(config.Config{PostgresURL: "host=kv.internal user=u dbname=bonsai sslmode=disable"})
+	if cfg, err = postgresPoolConfig(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantTLS(t, "keyword/value POSTGRES_URL", cfg, "kv.internal")
+
+	config.Conf = tlsSettings(config.Config{PostgresReplicaURL: "postgresql://u:p@replica.internal/bonsai"})
+	if cfg, err = parsePoolConfig(config.Conf.PostgresReplicaURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wantTLS(t, "POSTGRES_REPLICA_URL", cfg, "replica.internal")
+
+	// Without POSTGRES_SSL* settings the URL is used as-is
+	config.Conf = config.Config{PostgresURL: "postgres://u:p@primary.internal/bonsai?sslmode=disable"}
+	if cfg, err = postgresPoolConfig(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ConnConfig.TLSConfig != nil {
+		t.Fatalf("want the URL's sslmode=disable kept, got %+v", cfg.ConnConfig.TLSConfig)
+	}
+
+	config.Conf = tlsSettings(config.Config{PostgresReplicaURL: "postgres://u:p@replica.internal/bonsai"})
+	config.Conf.PostgresSSLRootCert = "/does/not/exist.pem"
+	if _, err := parsePoolConfig(config.Conf.PostgresReplicaURL); err == nil {
+		t.Fatalf("want an error for a missing root certificate")
+	}
+}
+
+func TestWithSSLSettings_QuotesKeywordValues(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf = config.Config{PostgresSSLMode: "require", PostgresSSLRootCert: `/etc/o'brien certs/ca.pem`}
+	got := withSSLSettings("host=db")
+	want := `host=db sslmode='require' sslrootcert='/etc/o\'brien certs/ca.pem'`
+	if got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
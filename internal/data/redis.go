@@ -3,20 +3,112 @@ package data
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
-// NewRedisClient creates and returns a new Redis client using environment variables.
-func NewRedisClient() *redis.Client {
+// NewRedisClient creates and returns a new Redis client using environment variables. When
+// REDIS_SENTINEL_ADDRS and REDIS_SENTINEL_MASTER are set the client discovers the master through
+// Sentinel and follows failovers; otherwise it connects directly to REDIS_PORT. REDIS_TLS
+// switches either mode to TLS.
+func NewRedisClient() (*redis.Client, error) {
+	ctx := context.Background()
+	failover, err := redisFailoverOptions()
+	if err != nil {
+		return nil, err
+	}
+	if failover != nil {
+		// The master address is only known once Sentinel answers, so without an override the
+		// server name is taken from each dialed address.
+		if failover.TLSConfig, err = redisTLSConfig(""); err != nil {
+			return nil, err
+		}
+		logger.With(ctx, map[string]any{"mode": "sentinel", "master": failover.MasterName, "sentinels": failover.SentinelAddrs, "tls": failover.TLSConfig != nil}).Info("initializing redis client")
+		return redis.NewFailoverClient(failover), nil
+	}
 	redisAddr := config.Conf.RedisPort
 	if redisAddr == "" {
 		redisAddr = ":6379"
 	}
-	logger.WithField(context.Background(), "addr", redisAddr).Info("initializing redis client")
+	host, _, _ := net.SplitHostPort(redisAddr)
+	tlsConfig, err := redisTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+	logger.With(ctx, map[string]any{"mode": "direct", "addr": redisAddr, "tls": tlsConfig != nil}).Info("initializing redis client")
 	return redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
+		Addr:      redisAddr,
+		TLSConfig: tlsConfig,
+	}), nil
+}
+
+// redisFailoverOptions builds the Sentinel options from config, or returns nil when Sentinel is
+// not configured. Setting only one of the sentinel addresses and the master name is an error.
+func redisFailoverOptions() (*redis.FailoverOptions, error) {
+	var addrs []string
+	for _, a := range config.Conf.RedisSentinelAddrs {
+		if a = strings.TrimSpace(a); a != "" {
+			addrs = append(addrs, a)
+		}
+	}
+	master := strings.TrimSpace(config.Conf.RedisSentinelMaster)
+	switch {
+	case len(addrs) == 0 && master == "":
+		return nil, nil
+	case len(addrs) == 0:
+		return nil, errors.New("REDIS_SENTINEL_MASTER is set but REDIS_SENTINEL_ADDRS is empty")
+	case master == "":
+		return nil, errors.New("REDIS_SENTINEL_ADDRS is set but REDIS_SENTINEL_MASTER is empty")
+	}
+	return &redis.FailoverOptions{
+		MasterName:    master,
+		SentinelAddrs: addrs,
+	}, nil
+}
+
+// redisTLSConfig builds the TLS config from config, or returns nil when REDIS_TLS is off.
+// serverName is the host checked against the server certificate unless REDIS_TLS_SERVER_NAME
+// overrides it. Certificate settings without REDIS_TLS are an error rather than silently plaintext.
+func redisTLSConfig(serverName string) (*tls.Config, error) {
+	c := config.Conf
+	if !c.RedisTLS {
+		if c.RedisTLSCACert != "" || c.RedisTLSCert != "" || c.RedisTLSKey != "" || c.RedisTLSServerName != "" {
+			return nil, errors.New("REDIS_TLS_* settings require REDIS_TLS=true")
+		}
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+	if c.RedisTLSServerName != "" {
+		cfg.ServerName = c.RedisTLSServerName
+	}
+	if c.RedisTLSCACert != "" {
+		pem, err := os.ReadFile(c.RedisTLSCACert)
+		if err != nil {
+			return nil, fmt.Errorf("read REDIS_TLS_CA_CERT: %w", err)
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("REDIS_TLS_CA_CERT %s holds no PEM certificates", c.RedisTLSCACert)
+		}
+	}
+	if (c.RedisTLSCert == "") != (c.RedisTLSKey == "") {
+		return nil, errors.New("REDIS_TLS_CERT and REDIS_TLS_KEY must be set together")
+	}
+	if c.RedisTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(c.RedisTLSCert, c.RedisTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
 }
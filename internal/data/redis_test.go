@@ -0,0 +1,155 @@
+package data
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roguepikachu/bonsai/internal/config"
+)
+
+func TestRedisFailoverOptions(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	config.Conf.RedisSentinelAddrs, config.Conf.RedisSentinelMaster = nil, ""
+	if opts, err := redisFailoverOptions(); err != nil || opts != nil {
+		t.Fatalf("without sentinel config: want nil, nil, got %v, %v", opts, err)
+	}
+
+	config.Conf.RedisSentinelAddrs = []string{"sentinel-1:26379", " sentinel-2:26379 ", ""}
+	config.Conf.RedisSentinelMaster = "mymaster"
+	opts, err := redisFailoverOptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.MasterName != "mymaster" || !reflect.DeepEqual(opts.SentinelAddrs, []string{"sentinel-1:26379", "sentinel-2:26379"}) {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	config.Conf.RedisSentinelMaster = ""
+	if _, err := redisFailoverOptions(); err == nil {
+		t.Fatalf("want an error for addresses without a master name")
+	}
+	config.Conf.RedisSentinelAddrs, config.Conf.RedisSentinelMaster = nil, "mymaster"
+	if _, err := redisFailoverOptions(); err == nil {
+		t.Fatalf("want an error for a master name without addresses")
+	}
+}
+
+func TestNewRedisClient_Sentinel(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	config.Conf.RedisSentinelAddrs = []string{"127.0.0.1:1"}
+	config.Conf.RedisSentinelMaster = "mymaster"
+	client, err := NewRedisClient()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	if got := client.Options().Addr; got != "FailoverClient" {
+		t.Fatalf("want a failover client, got addr %q", got)
+	}
+}
+
+// writeTestCert writes a self-signed certificate and its key as PEM files and returns their paths.
+func writeTestCert(t *testing.T) (certPath, keyPath string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "bonsai test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certPath, keyPath = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+func TestRedisTLSConfig(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	certPath, keyPath := writeTestCert(t)
+
+	config.Conf = config.Config{}
+	if cfg, err := redisTLSConfig("cache.internal"); err != nil || cfg != nil {
+		t.Fatalf("TLS off: want plaintext, got %v, %v", cfg, err)
+	}
+	config.Conf.RedisTLSCACert = certPath
+	if _, err := redisTLSConfig("cache.internal"); err == nil {
+		t.Fatalf("want an error for a CA without REDIS_TLS")
+	}
+
+	config.Conf = config.Config{RedisTLS: true, RedisTLSCACert: certPath, RedisTLSCert: certPath, RedisTLSKey: keyPath}
+	cfg, err := redisTLSConfig("cache.internal")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.ServerName != "cache.internal" || cfg.InsecureSkipVerify || cfg.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("want verification of cache.internal, got %+v", cfg)
+	}
+	if cfg.RootCAs == nil || len(cfg.Certificates) != 1 {
+		t.Fatalf("want the custom CA and client certificate, got roots=%v certs=%d", cfg.RootCAs, len(cfg.Certificates))
+	}
+
+	config.Conf.RedisTLSServerName = "redis.example.com"
+	if cfg, err := redisTLSConfig("cache.internal"); err != nil || cfg.ServerName != "redis.example.com" {
+		t.Fatalf("want the server name override, got %v, %v", cfg, err)
+	}
+
+	config.Conf.RedisTLSKey = ""
+	if _, err := redisTLSConfig("cache.internal"); err == nil {
+		t.Fatalf("want an error for a certificate without a key")
+	}
+	config.Conf = config.Config{RedisTLS: true, RedisTLSCACert: keyPath}
+	if _, err := redisTLSConfig("cache.internal"); err == nil {
+		t.Fatalf("want an error for a CA file without certificates")
+	}
+}
+
+func TestNewRedisClient_TLS(t *testing.T) {
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+
+	config.Conf = config.Config{RedisPort: "cache.internal:6380", RedisTLS: true}
+	client, err := NewRedisClient()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	if cfg := client.Options().TLSConfig; cfg == nil || cfg.ServerName != "cache.internal" {
+		t.Fatalf("want TLS verifying cache.internal, got %+v", cfg)
+	}
+}
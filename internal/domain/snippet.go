@@ -3,21 +3,129 @@ package domain
 
 import (
 	"errors"
+	"strings"
 	"time"
 )
 
 // CreateSnippetRequestDTO represents the expected request body for creating a snippet.
 type CreateSnippetRequestDTO struct {
-	Content   string   `json:"content" binding:"required,max=10240"`
+	// ID is a client-supplied snippet ID, accepted only when the server allows it.
+	ID        string   `json:"id,omitempty" binding:"omitempty,max=64"`
+	Content   string   `json:"content" binding:"required"`
 	ExpiresIn int      `json:"expires_in" binding:"omitempty,gte=0,lte=2592000"`
 	Tags      []string `json:"tags"`
+	// VisibleFrom schedules publication; the snippet is hidden until this time.
+	VisibleFrom *time.Time `json:"visible_from,omitempty"`
+	// Language names the content's language, e.g. "go" or "markdown".
+	Language string `json:"language" binding:"omitempty,max=32"`
+	// CacheTTLSeconds hints how long the snippet may be cached; capped by the server maximum.
+	CacheTTLSeconds int `json:"cache_ttl_seconds" binding:"omitempty,gte=0,lte=2592000"`
+	// Templated enables {{var}} substitution from ?var.<name>= query parameters on read.
+	Templated bool `json:"templated"`
+	// MaxViews makes the snippet gone once it was read this many times; omitted means unlimited.
+	MaxViews *int `json:"max_views,omitempty" binding:"omitempty,min=1"`
+	// Visibility is "public" (default) or "private"; private snippets require an X-Client-ID.
+	Visibility string `json:"visibility,omitempty" binding:"omitempty,oneof=public private"`
+	// Title is a short label shown in lists.
+	Title string `json:"title,omitempty" binding:"omitempty,max=200"`
 }
 
 // UpdateSnippetRequestDTO represents the expected request body for updating a snippet.
 type UpdateSnippetRequestDTO struct {
-	Content   string   `json:"content" binding:"required,max=10240"`
+	Content   string   `json:"content" binding:"required"`
 	ExpiresIn int      `json:"expires_in" binding:"omitempty,gte=0,lte=2592000"`
 	Tags      []string `json:"tags"`
+	// VisibleFrom reschedules publication; omitted keeps the current schedule.
+	VisibleFrom *time.Time `json:"visible_from,omitempty"`
+	// Language changes the content's language; omitted keeps the current one.
+	Language string `json:"language" binding:"omitempty,max=32"`
+	// CacheTTLSeconds changes the cache TTL hint; omitted keeps the current one.
+	CacheTTLSeconds int `json:"cache_ttl_seconds" binding:"omitempty,gte=0,lte=2592000"`
+	// Templated turns {{var}} substitution on or off; omitted keeps the current setting.
+	Templated *bool `json:"templated,omitempty"`
+	// Visibility changes who can read the snippet; omitted keeps the current setting.
+	Visibility string `json:"visibility,omitempty" binding:"omitempty,oneof=public private"`
+	// Title replaces the title; omitted keeps the current one and "" clears it.
+	Title *string `json:"title,omitempty" binding:"omitnil,max=200"`
+}
+
+// PatchSnippetRequestDTO represents the expected request body for a partial update.
+// Omitted fields keep their current value and are not validated.
+type PatchSnippetRequestDTO struct {
+	Content *string `json:"content" binding:"omitnil,min=1"`
+	// ExpiresIn resets the expiry from now; 0 removes it.
+	ExpiresIn *int `json:"expires_in" binding:"omitnil,gte=0,lte=2592000"`
+	// Tags replaces the tag set; an empty list clears it.
+	Tags            *[]string  `json:"tags"`
+	VisibleFrom     *time.Time `json:"visible_from,omitempty"`
+	Language        string     `json:"language" binding:"omitempty,max=32"`
+	CacheTTLSeconds int        `json:"cache_ttl_seconds" binding:"omitempty,gte=0,lte=2592000"`
+	Templated       *bool      `json:"templated,omitempty"`
+	Visibility      string     `json:"visibility,omitempty" binding:"omitempty,oneof=public private"`
+	Title           *string    `json:"title,omitempty" binding:"omitnil,max=200"`
+}
+
+// ImportSnippetDTO is one record of an import, carrying its original timestamps.
+type ImportSnippetDTO struct {
+	Content   string     `json:"content" binding:"required"`
+	Tags      []string   `json:"tags"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ImportSnippetsRequestDTO represents the expected request body for importing snippets.
+type ImportSnippetsRequestDTO struct {
+	Snippets []ImportSnippetDTO `json:"snippets" binding:"required,min=1,max=100,dive"`
+}
+
+// TagsResponseDTO lists distinct tags, most used first.
+type TagsResponseDTO struct {
+	Tags []string `json:"tags"`
+}
+
+// SnippetCountResponseDTO is the number of active snippets matching a count request.
+type SnippetCountResponseDTO struct {
+	Count int `json:"count"`
+}
+
+// TagCountDTO is a tag with the number of active snippets carrying it.
+type TagCountDTO struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TagCountsResponseDTO lists distinct tags with their counts, most used first.
+type TagCountsResponseDTO struct {
+	Tags []TagCountDTO `json:"tags"`
+}
+
+// BatchGetRequestDTO represents the expected request body for fetching several snippets at once.
+type BatchGetRequestDTO struct {
+	IDs []string `json:"ids" binding:"required,min=1,max=100,dive,required,max=64"`
+}
+
+// BatchGetResponseDTO lists the requested snippets that exist and are readable, in request order.
+type BatchGetResponseDTO struct {
+	Items []SnippetResponseDTO `json:"items"`
+}
+
+// ImportResultDTO reports the outcome of one import record; exactly one of ID and Error is set.
+type ImportResultDTO struct {
+	Index int       `json:"index"`
+	ID    string    `json:"id,omitempty"`
+	Error *ErrorDTO `json:"error,omitempty"`
+}
+
+// ErrorDTO is the code/message pair used in error envelopes.
+type ErrorDTO struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ImportSnippetsResponseDTO represents the response for an import.
+type ImportSnippetsResponseDTO struct {
+	Imported int               `json:"imported"`
+	Items    []ImportResultDTO `json:"items"`
 }
 
 // SnippetResponseDTO represents the response for a single snippet.
@@ -27,6 +135,48 @@ type SnippetResponseDTO struct {
 	CreatedAt string   `json:"created_at"`
 	ExpiresAt *string  `json:"expires_at,omitempty"`
 	Tags      []string `json:"tags,omitempty"`
+	// DuplicateOf is a non-blocking hint set on create when identical content already exists.
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+	// VisibleFrom is set when publication is scheduled.
+	VisibleFrom *string `json:"visible_from,omitempty"`
+	// Version starts at 1 and increments on every update.
+	Version int `json:"version,omitempty"`
+	// Language is the content's language when known, given by the creator or auto-detected.
+	Language string `json:"language,omitempty"`
+	// LineCount is the number of lines in Content.
+	LineCount int `json:"line_count"`
+	// CacheTTLSeconds is the creator's cache TTL hint when set.
+	CacheTTLSeconds int `json:"cache_ttl_seconds,omitempty"`
+	// Templated is true when Content had {{var}} placeholders substituted on read.
+	Templated bool `json:"templated,omitempty"`
+	// Source is how the snippet was created: api, import, fork or batch.
+	Source string `json:"source,omitempty"`
+	// Preview is set when requested with ?preview=html|text.
+	Preview *string `json:"preview,omitempty"`
+	// Views is how often the snippet was read, including reads not yet flushed to storage.
+	Views int64 `json:"views"`
+	// MaxViews is the read limit after which the snippet is gone, when set.
+	MaxViews int `json:"max_views,omitempty"`
+	// Visibility is "public" or "private".
+	Visibility string `json:"visibility,omitempty"`
+	// Title is the snippet's title when set.
+	Title string `json:"title,omitempty"`
+	// Expired is true when the snippet expired but is still readable within the grace window.
+	Expired bool `json:"expired,omitempty"`
+	// DeletedAt is set when the snippet was soft-deleted; only admins see such snippets.
+	DeletedAt *string `json:"deleted_at,omitempty"`
+}
+
+// SnippetDiffResponseDTO represents the changes between two versions of a snippet.
+type SnippetDiffResponseDTO struct {
+	ID            string   `json:"id"`
+	From          int      `json:"from"`
+	To            int      `json:"to"`
+	Diff          string   `json:"diff"`
+	TagsAdded     []string `json:"tags_added,omitempty"`
+	TagsRemoved   []string `json:"tags_removed,omitempty"`
+	ExpiresAtFrom *string  `json:"expires_at_from,omitempty"`
+	ExpiresAtTo   *string  `json:"expires_at_to,omitempty"`
 }
 
 // ListSnippetsResponseDTO represents the response for listing snippets.
@@ -34,13 +184,92 @@ type ListSnippetsResponseDTO struct {
 	Page  int                  `json:"page"`
 	Limit int                  `json:"limit"`
 	Items []SnippetListItemDTO `json:"items"`
+	// Total is the number of active snippets matching the filters across all pages.
+	Total int `json:"total"`
+	// NextCursor resumes the listing after the last item via ?cursor=; empty when no page may follow.
+	NextCursor string `json:"next_cursor,omitempty"`
+	// LimitTruncated is true when the requested limit exceeded the server's maximum page size
+	// and Limit reports the cap that was served instead.
+	LimitTruncated bool `json:"limit_truncated,omitempty"`
+	// Facets is set when requested with ?facets=true.
+	Facets *FacetsDTO `json:"facets,omitempty"`
+	// Filters is set when requested with ?echo_filters=1.
+	Filters *ListFiltersDTO `json:"filters,omitempty"`
+}
+
+// ListFiltersDTO echoes the filters a list request was served with, after defaults, caps and
+// normalization were applied.
+type ListFiltersDTO struct {
+	// Page is omitted for cursor pages.
+	Page   int  `json:"page,omitempty"`
+	Limit  int  `json:"limit"`
+	Cursor bool `json:"cursor,omitempty"`
+	// Tags is the de-duplicated, sorted tag filter; TagMatch only applies to several tags.
+	Tags     []string `json:"tags,omitempty"`
+	TagMatch string   `json:"tag_match,omitempty"`
+	// ExcludeTags is the de-duplicated, sorted exclude_tag filter.
+	ExcludeTags []string `json:"exclude_tags,omitempty"`
+	Q           string   `json:"q,omitempty"`
+	// Sort is one of the sort values, or "relevance" for text queries without an explicit sort.
+	Sort   string `json:"sort"`
+	Source string `json:"source,omitempty"`
+	// Owner is set for /v1/snippets/mine.
+	Owner string `json:"owner,omitempty"`
+	// CreatedAfter is the ?created_after= bound of an incremental listing.
+	CreatedAfter string `json:"created_after,omitempty"`
+	View         string `json:"view"`
+	// Expiry is always "active": lists leave out expired and not yet visible snippets.
+	Expiry string `json:"expiry"`
+}
+
+// FacetsDTO reports tag counts over the snippets matching a list query.
+type FacetsDTO struct {
+	Tags map[string]int `json:"tags"`
+	// Approximate is true when only the most recent matching snippets were counted.
+	Approximate bool `json:"approximate"`
 }
 
 // SnippetListItemDTO represents a snippet in a list response.
 type SnippetListItemDTO struct {
-	ID        string  `json:"id"`
-	CreatedAt string  `json:"created_at"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
+	ID string `json:"id"`
+	// Title is the snippet's title when set.
+	Title     string   `json:"title,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+	// TagsTruncated is true when Tags was capped; fetch the snippet for the full set.
+	TagsTruncated bool `json:"tags_truncated,omitempty"`
+	// Source is how the snippet was created: api, import, fork or batch.
+	Source string `json:"source,omitempty"`
+	// Content is only set for ?view=full listings.
+	Content string `json:"content,omitempty"`
+	// Visibility is "public" or "private"; private items only appear in the owner's listing.
+	Visibility string `json:"visibility,omitempty"`
+	// DeletedAt is set for soft-deleted items, listed only with ?include_deleted=1.
+	DeletedAt *string `json:"deleted_at,omitempty"`
+	// Deleted marks a soft-deleted item. In incremental listings (?created_after=) such items are
+	// tombstones carrying only their id and timestamps.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// SnippetMetadataDTO describes a snippet without its content.
+type SnippetMetadataDTO struct {
+	ID        string   `json:"id"`
+	Title     string   `json:"title,omitempty"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
+	Tags      []string `json:"tags"`
+	Language  string   `json:"language,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	Version   int      `json:"version,omitempty"`
+}
+
+// SnippetMetadataResponseDTO lists the metadata of the most recent snippets carrying a tag.
+type SnippetMetadataResponseDTO struct {
+	Tag   string               `json:"tag"`
+	Items []SnippetMetadataDTO `json:"items"`
+	// Truncated is true when more snippets carry the tag than the server returns at once.
+	Truncated bool `json:"truncated"`
 }
 
 // Snippet represents a code snippet entity.
@@ -50,6 +279,99 @@ type Snippet struct {
 	Tags      []string  `json:"tags"`
 	CreatedAt time.Time `json:"created_at"`
 	ExpiresAt time.Time `json:"expires_at"`
+	// OwnerID is the client ID (X-Client-ID) that created the snippet.
+	OwnerID string `json:"owner_id,omitempty"`
+	// ContentHash is the hex-encoded SHA-256 of Content.
+	ContentHash string `json:"content_hash,omitempty"`
+	// DuplicateOf is set by the service on create only and is never persisted.
+	DuplicateOf string `json:"-"`
+	// VisibleFrom hides the snippet until this time; zero means visible immediately.
+	VisibleFrom time.Time `json:"visible_from"`
+	// Version starts at 1 on create and increments on every update.
+	Version int `json:"version"`
+	// Language names the content's language; empty means unknown.
+	Language string `json:"language,omitempty"`
+	// CacheTTLSeconds overrides the server cache TTL for this snippet; 0 uses the server default.
+	CacheTTLSeconds int `json:"cache_ttl_seconds,omitempty"`
+	// Templated marks Content as containing {{var}} placeholders substituted on read; storage keeps them.
+	Templated bool `json:"templated,omitempty"`
+	// Source records how the snippet was created, one of the Source* values.
+	Source string `json:"source,omitempty"`
+	// Views counts reads flushed to storage; recent reads may still be pending in the cache.
+	Views int64 `json:"views,omitempty"`
+	// MaxViews makes the snippet gone once Views reaches it; 0 means unlimited.
+	MaxViews int `json:"max_views,omitempty"`
+	// Visibility is VisibilityPublic or VisibilityPrivate; empty means public.
+	Visibility string `json:"visibility,omitempty"`
+	// Title is an optional short label; empty means untitled.
+	Title string `json:"title,omitempty"`
+	// DeletedAt is set when the snippet was soft-deleted; zero means live.
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Visibility values control who can read a snippet.
+const (
+	// VisibilityPublic snippets are readable by anyone and listed (default).
+	VisibilityPublic = "public"
+	// VisibilityPrivate snippets are only readable and listed for their owner.
+	VisibilityPrivate = "private"
+)
+
+// VisibleTo reports whether the client may read the snippet: public snippets are readable by
+// anyone, private ones only by their owner.
+func (s Snippet) VisibleTo(clientID string) bool {
+	return s.Visibility != VisibilityPrivate || (clientID != "" && clientID == s.OwnerID)
+}
+
+// Source values record which path created a snippet.
+const (
+	SourceAPI    = "api"
+	SourceImport = "import"
+	SourceFork   = "fork"
+	SourceBatch  = "batch"
+)
+
+// ValidSource reports whether s is one of the Source* values.
+func ValidSource(s string) bool {
+	switch s {
+	case SourceAPI, SourceImport, SourceFork, SourceBatch:
+		return true
+	}
+	return false
+}
+
+// SnippetVersion is an immutable record of a snippet's content at a given version.
+type SnippetVersion struct {
+	SnippetID string    `json:"snippet_id"`
+	Version   int       `json:"version"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// CreatedAt is when this version was written.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// VersionOf returns the version record for the snippet's current state, written at.
+func VersionOf(s Snippet, at time.Time) SnippetVersion {
+	return SnippetVersion{SnippetID: s.ID, Version: s.Version, Content: s.Content, Tags: s.Tags, ExpiresAt: s.ExpiresAt, CreatedAt: at}
+}
+
+// LineCount returns the number of lines in Content. A trailing newline ends the last
+// line rather than starting an empty one, and empty content has no lines.
+func (s Snippet) LineCount() int {
+	if s.Content == "" {
+		return 0
+	}
+	n := strings.Count(s.Content, "\n")
+	if !strings.HasSuffix(s.Content, "\n") {
+		n++
+	}
+	return n
+}
+
+// IsVisibleAt reports whether the snippet's scheduled publication time has passed at now.
+func (s Snippet) IsVisibleAt(now time.Time) bool {
+	return s.VisibleFrom.IsZero() || !now.Before(s.VisibleFrom)
 }
 
 var (
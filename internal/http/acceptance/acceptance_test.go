@@ -466,6 +466,18 @@ func getRedisKeyCount(t *testing.T, pattern string) int {
 	return len(keys)
 }
 
+func getRedisListEpoch(t *testing.T) string {
+	t.Helper()
+	rdb := redis.NewClient(&redis.Options{Addr: testRedisAddr, DB: 1})
+	defer func() { _ = rdb.Close() }() // Best effort cleanup
+
+	epoch, err := rdb.Get(context.Background(), "snippets:epoch").Result()
+	if err != nil {
+		t.Fatalf("Failed to get list cache epoch: %v", err)
+	}
+	return epoch
+}
+
 func Test_HealthEndpoints(t *testing.T) {
 	// Test /v1/health
 	var healthResp struct {
@@ -1091,6 +1103,7 @@ func Test_CacheInvalidation(t *testing.T) {
 	if listCacheCount == 0 {
 		t.Error("Expected list cache keys to exist")
 	}
+	epochBefore := getRedisListEpoch(t)
 
 	// Create a new snippet - should invalidate list caches
 	createReq := map[string]any{
@@ -1118,11 +1131,9 @@ func Test_CacheInvalidation(t *testing.T) {
 		Tags:    []string{"new"},
 	})
 
-	// List caches should be invalidated
-	time.Sleep(100 * time.Millisecond) // Give cache invalidation time to process
-	newListCacheCount := getRedisKeyCount(t, "snippets:*")
-	if newListCacheCount >= listCacheCount {
-		t.Errorf("Expected list caches to be invalidated, had %d, now have %d", listCacheCount, newListCacheCount)
+	// List caches should be invalidated by moving to a new epoch; the old keys expire with their TTL
+	if epochAfter := getRedisListEpoch(t); epochAfter == epochBefore {
+		t.Errorf("Expected list caches to be invalidated, epoch still %s", epochAfter)
 	}
 }
 
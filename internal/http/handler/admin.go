@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/config"
+	"github.com/roguepikachu/bonsai/internal/service"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// requireAdmin checks the request's bearer token against config.Conf.AdminToken. It writes 403
+// when no admin token is configured and 401 when the token is missing or wrong.
+func requireAdmin(c *gin.Context) bool {
+	token := config.Conf.AdminToken
+	if token == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "forbidden", "message": "admin access is disabled"}})
+		return false
+	}
+	got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		c.Header("WWW-Authenticate", `Bearer realm="bonsai"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": gin.H{"code": "unauthorized", "message": "admin token required"}})
+		return false
+	}
+	return true
+}
+
+// applyIncludeDeleted honours ?include_deleted=1 by marking the request context so reads also
+// return soft-deleted snippets. Only admins may ask for them; the error response is written and
+// false returned otherwise or when the value is not a boolean.
+func applyIncludeDeleted(c *gin.Context) bool {
+	raw := c.Query("include_deleted")
+	if raw == "" {
+		return true
+	}
+	include, err := strconv.ParseBool(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "include_deleted must be a boolean"}})
+		return false
+	}
+	if !include {
+		return true
+	}
+	if !requireAdmin(c) {
+		return false
+	}
+	c.Request = c.Request.WithContext(ctxutil.WithIncludeDeleted(c.Request.Context()))
+	return true
+}
+
+// Delete handles deleting a snippet. Snippets are soft-deleted and can be restored unless the
+// server runs with DELETE_MODE=hard. Admin only.
+func (h *Handler) Delete(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if err := h.svc.DeleteSnippet(ctx, id); err != nil {
+		if errors.Is(err, service.ErrSnippetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
+			return
+		}
+		logger.Error(ctx, "failed to delete snippet: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	logger.WithField(ctx, "id", id).Info("snippet deleted")
+	c.Status(http.StatusNoContent)
+}
+
+// Restore handles undoing a soft delete and returns the restored snippet. Admin only.
+func (h *Handler) Restore(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	snippet, err := h.svc.RestoreSnippet(ctx, id)
+	if err != nil {
+		if errors.Is(err, service.ErrSnippetNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
+			return
+		}
+		logger.Error(ctx, "failed to restore snippet: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	logger.WithField(ctx, "id", id).Info("snippet restored")
+	c.JSON(http.StatusOK, toSnippetResponse(snippet))
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/config"
+	"github.com/roguepikachu/bonsai/internal/domain"
+)
+
+func TestSnippetDeleteAndRestore_RequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	svc := &mockSnippetService{byID: map[string]domain.Snippet{"a": {ID: "a", Content: "x", CreatedAt: time.Now()}}}
+	h := NewHandler(svc)
+	r := gin.New()
+	r.DELETE("/v1/snippets/:id", h.Delete)
+	r.POST("/v1/snippets/:id/restore", h.Restore)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(http.MethodDelete, "/v1/snippets/a", "secret"); w.Code != http.StatusForbidden {
+		t.Fatalf("without an admin token configured: want 403, got %d", w.Code)
+	}
+	config.Conf.AdminToken = "secret"
+	if w := do(http.MethodDelete, "/v1/snippets/a", ""); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Fatalf("without a token: want 401 with WWW-Authenticate, got %d", w.Code)
+	}
+	if w := do(http.MethodDelete, "/v1/snippets/a", "wrong"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("with a wrong token: want 401, got %d", w.Code)
+	}
+	if len(svc.deleted) != 0 {
+		t.Fatalf("unauthorized requests must not delete, got %v", svc.deleted)
+	}
+
+	if w := do(http.MethodDelete, "/v1/snippets/a", "secret"); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: want 204, got %d (%s)", w.Code, w.Body.String())
+	}
+	if len(svc.deleted) != 1 || svc.deleted[0] != "a" {
+		t.Fatalf("want a deleted, got %v", svc.deleted)
+	}
+	if w := do(http.MethodDelete, "/v1/snippets/missing", "secret"); w.Code != http.StatusNotFound {
+		t.Fatalf("delete missing: want 404, got %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/v1/snippets/a/restore", "secret"); w.Code != http.StatusOK {
+		t.Fatalf("restore: want 200, got %d (%s)", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/v1/snippets/missing/restore", "secret"); w.Code != http.StatusNotFound {
+		t.Fatalf("restore missing: want 404, got %d", w.Code)
+	}
+}
+
+func TestSnippetIncludeDeleted(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	config.Conf.AdminToken = "secret"
+	deletedAt := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	svc := &mockSnippetService{
+		byID: map[string]domain.Snippet{"a": {ID: "a", Content: "x", CreatedAt: deletedAt, DeletedAt: deletedAt}},
+		list: []domain.Snippet{{ID: "a", CreatedAt: deletedAt, DeletedAt: deletedAt}},
+	}
+	h := NewHandler(svc)
+	r := gin.New()
+	r.GET("/v1/snippets", h.List)
+	r.GET("/v1/snippets/:id", h.Get)
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/v1/snippets?include_deleted=1", "/v1/snippets/a?include_deleted=1"} {
+		if w := do(path, ""); w.Code != http.StatusUnauthorized {
+			t.Fatalf("%s without a token: want 401, got %d", path, w.Code)
+		}
+		w := do(path, "secret")
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: want 200, got %d (%s)", path, w.Code, w.Body.String())
+		}
+		if !svc.includeDeleted {
+			t.Fatalf("%s: want the read to include deleted snippets", path)
+		}
+		if want := `"deleted_at":"2025-08-30T12:00:00Z"`; !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("%s: want %s in %s", path, want, w.Body.String())
+		}
+	}
+	if w := do("/v1/snippets?include_deleted=0", ""); w.Code != http.StatusOK || svc.includeDeleted {
+		t.Fatalf("include_deleted=0: want a normal read, got %d, include %v", w.Code, svc.includeDeleted)
+	}
+	if w := do("/v1/snippets?include_deleted=maybe", "secret"); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid include_deleted: want 400, got %d", w.Code)
+	}
+}
+
+func TestSnippetList_CreatedAfterTombstones(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	config.Conf.AdminToken = "secret"
+	created := time.Date(2025, 8, 30, 10, 0, 0, 0, time.UTC)
+	deletedAt := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	svc := &mockSnippetService{list: []domain.Snippet{
+		{ID: "live", Title: "kept", CreatedAt: created, Tags: []string{"go"}},
+		{ID: "gone", Title: "dropped", CreatedAt: created, Tags: []string{"go"}, Source: domain.SourceAPI, DeletedAt: deletedAt},
+	}}
+	h := NewHandler(svc)
+	r := gin.New()
+	r.GET("/v1/snippets", h.List)
+	do := func(path, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("/v1/snippets?include_deleted=1&created_after=2025-08-30T09:00:00Z", "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200, got %d (%s)", w.Code, w.Body.String())
+	}
+	if want := time.Date(2025, 8, 30, 9, 0, 0, 0, time.UTC); !svc.gotOpts.CreatedAfter.Equal(want) || !svc.includeDeleted {
+		t.Fatalf("want created_after %v with deleted snippets, got %v, include %v", want, svc.gotOpts.CreatedAfter, svc.includeDeleted)
+	}
+	body := w.Body.String()
+	if want := `{"id":"gone","created_at":"2025-08-30T10:00:00Z","deleted_at":"2025-08-30T12:00:00Z","deleted":true}`; !strings.Contains(body, want) {
+		t.Fatalf("want tombstone %s in %s", want, body)
+	}
+	if !strings.Contains(body, `"title":"kept"`) || strings.Contains(body, "dropped") {
+		t.Fatalf("want the live item in full and only a tombstone for the deleted one, got %s", body)
+	}
+
+	if w := do("/v1/snippets?created_after=yesterday", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid created_after: want 400, got %d", w.Code)
+	}
+}
@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// CacheRefresher reloads a snippet from the primary store into the cache.
+type CacheRefresher interface {
+	Refresh(ctx context.Context, id string) (domain.Snippet, error)
+}
+
+// CacheRefreshHandler lets operators rehydrate a cached snippet after an out-of-band change.
+type CacheRefreshHandler struct {
+	src CacheRefresher
+}
+
+// NewCacheRefreshHandler constructs a CacheRefreshHandler.
+func NewCacheRefreshHandler(src CacheRefresher) *CacheRefreshHandler {
+	return &CacheRefreshHandler{src: src}
+}
+
+// Refresh reloads the snippet from Postgres, rewrites its cache entry and returns it.
+// Snippets that are gone or expired are evicted and answer 404. Requires the admin token.
+func (h *CacheRefreshHandler) Refresh(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	snippet, err := h.src.Refresh(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
+		return
+	}
+	if err != nil {
+		logger.Error(ctx, "failed to refresh cached snippet: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	logger.WithField(ctx, "id", id).Info("cache refreshed")
+	c.JSON(http.StatusOK, toSnippetResponse(snippet))
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/config"
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+)
+
+type stubRefresher map[string]domain.Snippet
+
+func (s stubRefresher) Refresh(_ context.Context, id string) (domain.Snippet, error) {
+	if id == "broken" {
+		return domain.Snippet{}, errors.New("primary down")
+	}
+	if snippet, ok := s[id]; ok {
+		return snippet, nil
+	}
+	return domain.Snippet{}, repository.ErrNotFound
+}
+
+func TestCacheRefresh(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf.AdminToken = "secret"
+	r := gin.New()
+	r.POST("/v1/admin/cache/refresh/:id", NewCacheRefreshHandler(stubRefresher{"x": {ID: "x", Content: "fresh", CreatedAt: time.Now()}}).Refresh)
+	tests := map[string]int{"x": http.StatusOK, "gone": http.StatusNotFound, "broken": http.StatusInternalServerError}
+	for id, want := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/v1/admin/cache/refresh/"+id, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("%s: want %d, got %d (%s)", id, want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestCacheRefresh_RequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	r := gin.New()
+	r.POST("/v1/admin/cache/refresh/:id", NewCacheRefreshHandler(stubRefresher{"x": {ID: "x", Content: "private", CreatedAt: time.Now()}}).Refresh)
+	post := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/admin/cache/refresh/x", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	config.Conf.AdminToken = ""
+	if w := post("Bearer anything"); w.Code != http.StatusForbidden {
+		t.Fatalf("without an admin token configured: want 403, got %d", w.Code)
+	}
+	config.Conf.AdminToken = "secret"
+	for _, auth := range []string{"", "Bearer wrong"} {
+		w := post(auth)
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("auth %q: want 401, got %d", auth, w.Code)
+		}
+		if strings.Contains(w.Body.String(), "private") {
+			t.Fatalf("auth %q: snippet content leaked: %s", auth, w.Body.String())
+		}
+	}
+}
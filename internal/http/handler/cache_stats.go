@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/pkg"
+)
+
+// CacheStatsSource reports cache hit and miss counters since process start, separately for
+// snippet lookups and list (page, count and tag) lookups.
+type CacheStatsSource interface {
+	SnippetStats() (hits, misses uint64)
+	ListStats() (hits, misses uint64)
+}
+
+// CacheStatsHandler exposes cache effectiveness for operators.
+type CacheStatsHandler struct {
+	src CacheStatsSource
+}
+
+// NewCacheStatsHandler constructs a CacheStatsHandler.
+func NewCacheStatsHandler(src CacheStatsSource) *CacheStatsHandler {
+	return &CacheStatsHandler{src: src}
+}
+
+// Stats reports hits, misses and hit ratio overall and for snippet and list lookups.
+// Counters reset on restart. Admin only.
+func (h *CacheStatsHandler) Stats(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	snippetHits, snippetMisses := h.src.SnippetStats()
+	listHits, listMisses := h.src.ListStats()
+	data := cacheStats(snippetHits+listHits, snippetMisses+listMisses)
+	data["snippet"] = cacheStats(snippetHits, snippetMisses)
+	data["list"] = cacheStats(listHits, listMisses)
+	c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, data, "ok"))
+}
+
+// cacheStats reports one hits/misses pair with its hit ratio.
+func cacheStats(hits, misses uint64) gin.H {
+	var ratio float64
+	if total := hits + misses; total > 0 {
+		ratio = float64(hits) / float64(total)
+	}
+	return gin.H{"hits": hits, "misses": misses, "hit_ratio": ratio}
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/config"
+)
+
+type stubCacheStats struct{ snippetHits, snippetMisses, listHits, listMisses uint64 }
+
+func (s stubCacheStats) SnippetStats() (hits, misses uint64) { return s.snippetHits, s.snippetMisses }
+
+func (s stubCacheStats) ListStats() (hits, misses uint64) { return s.listHits, s.listMisses }
+
+// cacheStatsBody is one hits/misses pair of the /v1/cache/stats response.
+type cacheStatsBody struct {
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+func TestCacheStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf.AdminToken = "secret"
+	tests := []struct {
+		name                        string
+		stats                       stubCacheStats
+		want, wantSnippet, wantList cacheStatsBody
+	}{
+		{"no lookups", stubCacheStats{}, cacheStatsBody{}, cacheStatsBody{}, cacheStatsBody{}},
+		{"three hits one miss", stubCacheStats{3, 1, 0, 0}, cacheStatsBody{3, 1, 0.75}, cacheStatsBody{3, 1, 0.75}, cacheStatsBody{}},
+		{"all misses", stubCacheStats{0, 2, 0, 2}, cacheStatsBody{0, 4, 0}, cacheStatsBody{0, 2, 0}, cacheStatsBody{0, 2, 0}},
+		{"snippets hit, lists miss", stubCacheStats{3, 0, 0, 1}, cacheStatsBody{3, 1, 0.75}, cacheStatsBody{3, 0, 1}, cacheStatsBody{0, 1, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/v1/cache/stats", NewCacheStatsHandler(tt.stats).Stats)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/v1/cache/stats", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("want 200, got %d", w.Code)
+			}
+			var resp struct {
+				Data struct {
+					cacheStatsBody
+					Snippet cacheStatsBody `json:"snippet"`
+					List    cacheStatsBody `json:"list"`
+				} `json:"data"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if resp.Data.cacheStatsBody != tt.want || resp.Data.Snippet != tt.wantSnippet || resp.Data.List != tt.wantList {
+				t.Fatalf("got %+v, want %+v with snippet %+v and list %+v", resp.Data, tt.want, tt.wantSnippet, tt.wantList)
+			}
+		})
+	}
+}
+
+func TestCacheStats_RequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	r := gin.New()
+	r.GET("/v1/cache/stats", NewCacheStatsHandler(stubCacheStats{1, 1, 1, 1}).Stats)
+	get := func(auth string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/cache/stats", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	config.Conf.AdminToken = ""
+	if code := get("Bearer anything"); code != http.StatusForbidden {
+		t.Fatalf("without an admin token configured: want 403, got %d", code)
+	}
+	config.Conf.AdminToken = "secret"
+	if code := get(""); code != http.StatusUnauthorized {
+		t.Fatalf("without a token: want 401, got %d", code)
+	}
+	if code := get("Bearer wrong"); code != http.StatusUnauthorized {
+		t.Fatalf("with a wrong token: want 401, got %d", code)
+	}
+	if code := get("Bearer secret"); code != http.StatusOK {
+		t.Fatalf("with the admin token: want 200, got %d", code)
+	}
+}
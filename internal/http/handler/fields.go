@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+)
+
+// jsonField locates a struct field by its JSON name.
+type jsonField struct {
+	index     int
+	omitEmpty bool
+}
+
+// listItemFields maps each JSON field name of domain.SnippetListItemDTO to its struct field, so
+// the ?fields= whitelist follows the DTO as it changes.
+var listItemFields = jsonFields(reflect.TypeOf(domain.SnippetListItemDTO{}))
+
+// jsonFields indexes the exported, JSON-encoded fields of struct type t by their JSON name.
+func jsonFields(t reflect.Type) map[string]jsonField {
+	fields := make(map[string]jsonField, t.NumField())
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = jsonField{index: i, omitEmpty: strings.Contains(opts, "omitempty")}
+	}
+	return fields
+}
+
+// parseListFields splits a ?fields= value into field names, rejecting names the list item DTO
+// does not have. An empty value returns nil, meaning every field.
+func parseListFields(raw string) ([]string, error) {
+	var fields []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := listItemFields[name]; !ok {
+			return nil, fmt.Errorf("unknown field %q", name)
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// projectListItem keeps only the given fields of item. Empty omitempty fields are left out as
+// they would be in the full item.
+func projectListItem(item domain.SnippetListItemDTO, fields []string) map[string]any {
+	v := reflect.ValueOf(item)
+	out := make(map[string]any, len(fields))
+	for _, name := range fields {
+		f := listItemFields[name]
+		fv := v.Field(f.index)
+		if f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		out[name] = fv.Interface()
+	}
+	return out
+}
+
+// isEmptyValue reports whether v is empty by encoding/json's omitempty rules.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
@@ -3,20 +3,44 @@ package handler
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-redis/redis/v8"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/pkg"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
+// HealthSchemaStatus makes /v1/health answer {"status":"ok"} instead of the legacy payload.
+const HealthSchemaStatus = "status"
+
+// legacyHealthResponse is the frozen /v1/health payload {code, data:{ok:true}, message}.
+// It is deliberately independent of pkg.Response so envelope changes never reach health clients.
+type legacyHealthResponse struct {
+	Code int `json:"code"`
+	Data struct {
+		OK bool `json:"ok"`
+	} `json:"data"`
+	Message string `json:"message"`
+}
+
 // Health handles the legacy simple health endpoint for backwards compatibility.
+// The legacy payload is kept unless config.Conf.HealthSchema opts into HealthSchemaStatus.
 func Health(c *gin.Context) {
-	c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, gin.H{"ok": true}, "ok"))
+	if config.Conf.HealthSchema == HealthSchemaStatus {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+	resp := legacyHealthResponse{Code: http.StatusOK, Message: "ok"}
+	resp.Data.OK = true
+	c.JSON(http.StatusOK, resp)
 }
 
 // Pinger is a minimal interface for types that can be pinged for health checks.
@@ -29,11 +53,66 @@ type HealthHandler struct {
 	pg    Pinger
 	redis Pinger
 	// optional: future deps can be added here
+	// pingTimeout bounds each dependency's ping separately.
 	pingTimeout time.Duration
+	// degradedAfter marks successful pings slower than this as degraded; 0 never does.
+	degradedAfter time.Duration
+	// startup, when set, reports whether one-time startup work has finished; nil counts as finished.
+	startup *atomic.Bool
+	// cacheFor is how long Readiness reuses a result in which every dependency answered; 0 disables it.
+	cacheFor time.Duration
+	// mu guards the cached result and serializes refreshes, so concurrent probes share one round of pings.
+	mu       sync.Mutex
+	cached   []healthCheck
+	cachedAt time.Time
+}
+
+// DefaultDegradedThreshold is the ping latency beyond which a readiness check is degraded
+// unless WithDegradedThreshold says otherwise.
+const DefaultDegradedThreshold = 500 * time.Millisecond
+
+// DefaultReadinessCache is how long a healthy readiness result is reused unless
+// WithReadinessCache says otherwise.
+const DefaultReadinessCache = 2 * time.Second
+
+// Readiness check statuses. A degraded check still counts as ready.
+const (
+	CheckOK       = "ok"
+	CheckDegraded = "degraded"
+	CheckDown     = "down"
+)
+
+// HealthOption configures HealthHandler.
+type HealthOption func(*HealthHandler)
+
+// WithDegradedThreshold marks successful pings slower than d as degraded; values below 1 keep
+// DefaultDegradedThreshold.
+func WithDegradedThreshold(d time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		if d > 0 {
+			h.degradedAfter = d
+		}
+	}
+}
+
+// WithReadinessCache makes Readiness reuse a result in which every dependency answered for d
+// instead of pinging on every probe. Zero keeps DefaultReadinessCache and negative values disable
+// the cache. Failed results are never reused, so recovery shows on the next probe.
+func WithReadinessCache(d time.Duration) HealthOption {
+	return func(h *HealthHandler) {
+		if d != 0 {
+			h.cacheFor = max(d, 0)
+		}
+	}
+}
+
+// WithStartupFlag makes the startup and readiness probes answer 503 until done is set.
+func WithStartupFlag(done *atomic.Bool) HealthOption {
+	return func(h *HealthHandler) { h.startup = done }
 }
 
 // NewHealthHandler constructs a HealthHandler.
-func NewHealthHandler(pg *pgxpool.Pool, redis *redis.Client) *HealthHandler {
+func NewHealthHandler(pg *pgxpool.Pool, redis *redis.Client, opts ...HealthOption) *HealthHandler {
 	// Adapters turning concrete clients into Pinger
 	var pgPinger Pinger
 	if pg != nil {
@@ -43,11 +122,17 @@ func NewHealthHandler(pg *pgxpool.Pool, redis *redis.Client) *HealthHandler {
 	if redis != nil {
 		redisPinger = redisPingerAdapter{redis}
 	}
-	return &HealthHandler{
-		pg:          pgPinger,
-		redis:       redisPinger,
-		pingTimeout: 1 * time.Second,
+	h := &HealthHandler{
+		pg:            pgPinger,
+		redis:         redisPinger,
+		pingTimeout:   1 * time.Second,
+		degradedAfter: DefaultDegradedThreshold,
+		cacheFor:      DefaultReadinessCache,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 type pgPingerAdapter struct{ pool *pgxpool.Pool }
@@ -63,43 +148,108 @@ func (h *HealthHandler) Liveness(c *gin.Context) {
 	c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, gin.H{"status": "alive"}, "ok"))
 }
 
-// Readiness checks external dependencies to decide if we can serve traffic.
-func (h *HealthHandler) Readiness(c *gin.Context) {
-	ctx, cancel := context.WithTimeout(c.Request.Context(), h.pingTimeout)
-	defer cancel()
+// healthCheck is the readiness result of one dependency.
+type healthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
 
-	type check struct {
-		name   string
-		status string
-		err    string
+// check pings one dependency under its own timeout and reports how long the ping took.
+func (h *HealthHandler) check(ctx context.Context, name string, p Pinger) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, h.pingTimeout)
+	defer cancel()
+	start := time.Now()
+	err := p.Ping(ctx)
+	elapsed := time.Since(start)
+	res := healthCheck{Name: name, Status: CheckOK, LatencyMS: elapsed.Milliseconds()}
+	switch {
+	case err != nil:
+		res.Status, res.Error = CheckDown, err.Error()
+	case h.degradedAfter > 0 && elapsed > h.degradedAfter:
+		res.Status = CheckDegraded
 	}
-	results := make([]check, 0, 2)
-	ready := true
+	return res
+}
 
-	// Postgres
+// checks runs the check of every configured dependency.
+func (h *HealthHandler) checks(ctx context.Context) []healthCheck {
+	results := make([]healthCheck, 0, 2)
 	if h.pg != nil {
-		if err := h.pg.Ping(ctx); err != nil {
-			ready = false
-			results = append(results, check{name: "postgres", status: "down", err: err.Error()})
-		} else {
-			results = append(results, check{name: "postgres", status: "up"})
+		results = append(results, h.check(ctx, "postgres", h.pg))
+	}
+	if h.redis != nil {
+		results = append(results, h.check(ctx, "redis", h.redis))
+	}
+	return results
+}
+
+// cachedChecks returns the last result while it is fresh and every dependency answered in it,
+// and runs the checks otherwise.
+func (h *HealthHandler) cachedChecks(ctx context.Context) []healthCheck {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.cached != nil && time.Since(h.cachedAt) < h.cacheFor {
+		return h.cached
+	}
+	results := h.checks(ctx)
+	h.cached = nil
+	if h.cacheFor > 0 && !anyDown(results) {
+		h.cached, h.cachedAt = results, time.Now()
+	}
+	return results
+}
+
+// anyDown reports whether a dependency failed its check.
+func anyDown(results []healthCheck) bool {
+	for _, r := range results {
+		if r.Status == CheckDown {
+			return true
 		}
 	}
+	return false
+}
 
-	// Redis
-	if h.redis != nil {
-		if err := h.redis.Ping(ctx); err != nil {
-			ready = false
-			results = append(results, check{name: "redis", status: "down", err: err.Error()})
-		} else {
-			results = append(results, check{name: "redis", status: "up"})
+// Ping checks every dependency once and returns the first that is down, e.g. for startup to
+// wait until they are reachable.
+func (h *HealthHandler) Ping(ctx context.Context) error {
+	for _, r := range h.checks(ctx) {
+		if r.Status == CheckDown {
+			return fmt.Errorf("%s: %s", r.Name, r.Error)
 		}
 	}
+	return nil
+}
+
+// Startup reports whether one-time startup work, such as schema migration and the first
+// dependency checks, has finished: 503 before then and 200 after, for startup probes that keep
+// slow starts from tripping liveness.
+func (h *HealthHandler) Startup(c *gin.Context) {
+	if h.startup != nil && !h.startup.Load() {
+		c.JSON(http.StatusServiceUnavailable, pkg.NewResponse(http.StatusServiceUnavailable, gin.H{"status": "starting"}, "starting"))
+		return
+	}
+	c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, gin.H{"status": "started"}, "ok"))
+}
+
+// Readiness checks external dependencies to decide if we can serve traffic. Slow but successful
+// pings are reported as degraded and keep the service ready; failed ones answer 503. Healthy
+// results are reused for a short while; see WithReadinessCache. Until startup has finished (see
+// WithStartupFlag) it answers 503 without pinging, since the API routes cannot serve yet.
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.startup != nil && !h.startup.Load() {
+		c.JSON(http.StatusServiceUnavailable, pkg.NewResponse(http.StatusServiceUnavailable, gin.H{"ready": false, "status": "starting"}, "starting"))
+		return
+	}
+	results := h.cachedChecks(ctx)
+	ready := !anyDown(results)
 
 	if ready {
 		c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, gin.H{"ready": true, "checks": results}, "ready"))
 		return
 	}
-	logger.WithField(c.Request.Context(), "checks", results).Warn("readiness failed")
+	logger.WithField(ctx, "checks", results).Warn("readiness failed")
 	c.JSON(http.StatusServiceUnavailable, pkg.NewResponse(http.StatusServiceUnavailable, gin.H{"ready": false, "checks": results}, "not ready"))
 }
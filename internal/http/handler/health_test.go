@@ -6,10 +6,13 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/config"
 )
 
 // fake pgxpool with Ping override
@@ -523,3 +526,227 @@ func TestReadiness_ErrorMessages(t *testing.T) {
 		}
 	}
 }
+
+func TestHealth_LegacySchemaPreserved(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+
+	tests := []struct {
+		schema string
+		want   string
+	}{
+		{"", `{"code":200,"data":{"ok":true},"message":"ok"}`},
+		{"legacy", `{"code":200,"data":{"ok":true},"message":"ok"}`},
+		{HealthSchemaStatus, `{"status":"ok"}`},
+	}
+	for _, tt := range tests {
+		config.Conf.HealthSchema = tt.schema
+		r := gin.New()
+		r.GET("/v1/health", Health)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+		if w.Code != http.StatusOK || w.Body.String() != tt.want {
+			t.Fatalf("schema %q: want 200 %s, got %d %s", tt.schema, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestReadiness_CheckLatencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	readyz := func(hh *HealthHandler) (int, map[string]map[string]any) {
+		r := gin.New()
+		r.GET("/v1/readyz", hh.Readiness)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/readyz", nil))
+		var resp struct {
+			Data struct {
+				Checks []map[string]any `json:"checks"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		checks := map[string]map[string]any{}
+		for _, c := range resp.Data.Checks {
+			checks[c["name"].(string)] = c
+		}
+		return w.Code, checks
+	}
+
+	t.Run("slow ping is degraded but ready", func(t *testing.T) {
+		hh := &HealthHandler{pg: slowPinger{delay: 30 * time.Millisecond}, redis: &fakePinger{}, pingTimeout: time.Second, degradedAfter: 10 * time.Millisecond}
+		code, checks := readyz(hh)
+		if code != http.StatusOK {
+			t.Fatalf("want 200, got %d", code)
+		}
+		if pg := checks["postgres"]; pg["status"] != CheckDegraded || pg["latency_ms"].(float64) < 30 {
+			t.Fatalf("want postgres degraded with latency >= 30ms, got %v", pg)
+		}
+		if rd := checks["redis"]; rd["status"] != CheckOK || rd["latency_ms"] == nil {
+			t.Fatalf("want redis ok with a latency, got %v", rd)
+		}
+	})
+
+	t.Run("failed ping is down", func(t *testing.T) {
+		hh := &HealthHandler{pg: &fakePinger{}, redis: &fakePinger{err: errors.New("connection refused")}, pingTimeout: time.Second, degradedAfter: time.Second}
+		code, checks := readyz(hh)
+		if code != http.StatusServiceUnavailable {
+			t.Fatalf("want 503, got %d", code)
+		}
+		if rd := checks["redis"]; rd["status"] != CheckDown || rd["error"] != "connection refused" {
+			t.Fatalf("want redis down with its error, got %v", rd)
+		}
+		if pg := checks["postgres"]; pg["status"] != CheckOK {
+			t.Fatalf("want postgres ok, got %v", pg)
+		}
+	})
+
+	t.Run("each check has its own timeout", func(t *testing.T) {
+		hh := &HealthHandler{pg: slowPinger{delay: 80 * time.Millisecond}, redis: slowPinger{delay: 30 * time.Millisecond}, pingTimeout: 50 * time.Millisecond}
+		code, checks := readyz(hh)
+		if code != http.StatusServiceUnavailable {
+			t.Fatalf("want 503, got %d", code)
+		}
+		if checks["postgres"]["status"] != CheckDown || checks["redis"]["status"] != CheckOK {
+			t.Fatalf("want postgres timed out and redis ok after it, got %v", checks)
+		}
+	})
+}
+
+func TestNewHealthHandler_DegradedThreshold(t *testing.T) {
+	if hh := NewHealthHandler(nil, nil); hh.degradedAfter != DefaultDegradedThreshold {
+		t.Fatalf("want default threshold, got %v", hh.degradedAfter)
+	}
+	if hh := NewHealthHandler(nil, nil, WithDegradedThreshold(0)); hh.degradedAfter != DefaultDegradedThreshold {
+		t.Fatalf("want 0 to keep the default, got %v", hh.degradedAfter)
+	}
+	if hh := NewHealthHandler(nil, nil, WithDegradedThreshold(time.Millisecond)); hh.degradedAfter != time.Millisecond {
+		t.Fatalf("want 1ms, got %v", hh.degradedAfter)
+	}
+}
+
+func TestStartup_WaitsForFlag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var started atomic.Bool
+	hh := NewHealthHandler(nil, nil, WithStartupFlag(&started))
+	r := gin.New()
+	r.GET("/v1/startupz", hh.Startup)
+	r.GET("/v1/readyz", hh.Readiness)
+	r.GET("/v1/livez", hh.Liveness)
+	get := func(path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := get("/v1/startupz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("before startup: want 503, got %d", code)
+	}
+	if code := get("/v1/readyz"); code != http.StatusServiceUnavailable {
+		t.Fatalf("readiness before startup: want 503, got %d", code)
+	}
+	if code := get("/v1/livez"); code != http.StatusOK {
+		t.Fatalf("liveness must not wait for startup, got %d", code)
+	}
+	started.Store(true)
+	if code := get("/v1/startupz"); code != http.StatusOK {
+		t.Fatalf("after startup: want 200, got %d", code)
+	}
+	if code := get("/v1/readyz"); code != http.StatusOK {
+		t.Fatalf("readiness after startup: want 200, got %d", code)
+	}
+
+	// Without a flag there is no startup work to wait for
+	r = gin.New()
+	r.GET("/v1/startupz", NewHealthHandler(nil, nil).Startup)
+	if code := get("/v1/startupz"); code != http.StatusOK {
+		t.Fatalf("without a startup flag: want 200, got %d", code)
+	}
+}
+
+func TestHealthHandler_Ping(t *testing.T) {
+	hh := &HealthHandler{pg: &fakePinger{}, redis: &fakePinger{err: errors.New("connection refused")}, pingTimeout: time.Second}
+	if err := hh.Ping(context.Background()); err == nil || err.Error() != "redis: connection refused" {
+		t.Fatalf("want the redis failure, got %v", err)
+	}
+	hh.redis = &fakePinger{}
+	if err := hh.Ping(context.Background()); err != nil {
+		t.Fatalf("want no error, got %v", err)
+	}
+}
+
+func TestReadiness_CachesHealthyResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pg, redis := &fakePinger{}, &fakePinger{}
+	hh := &HealthHandler{pg: pg, redis: redis, pingTimeout: time.Second, cacheFor: time.Minute}
+	r := gin.New()
+	r.GET("/v1/readyz", hh.Readiness)
+	get := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/readyz", nil))
+		return w.Code
+	}
+
+	const requests = 200
+	done := make(chan int, requests)
+	for i := 0; i < requests; i++ {
+		go func() { done <- get() }()
+	}
+	for i := 0; i < requests; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Fatalf("want 200, got %d", code)
+		}
+	}
+	if pg.pingCount != 1 || redis.pingCount != 1 {
+		t.Fatalf("want one ping per dependency for %d requests, got pg=%d redis=%d", requests, pg.pingCount, redis.pingCount)
+	}
+
+	// Once the window passes the next probe pings again
+	hh.cacheFor = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	get()
+	if pg.pingCount != 2 {
+		t.Fatalf("want a fresh ping after the window, got %d", pg.pingCount)
+	}
+}
+
+func TestReadiness_DoesNotCacheFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	pg := &fakePinger{err: errors.New("connection refused")}
+	hh := &HealthHandler{pg: pg, redis: &fakePinger{}, pingTimeout: time.Second, cacheFor: time.Minute}
+	r := gin.New()
+	r.GET("/v1/readyz", hh.Readiness)
+	get := func() int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/readyz", nil))
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := get(); code != http.StatusServiceUnavailable {
+			t.Fatalf("want 503, got %d", code)
+		}
+	}
+	if pg.pingCount != 3 {
+		t.Fatalf("want every failing probe to ping, got %d", pg.pingCount)
+	}
+
+	// Recovery shows on the next probe
+	pg.err = nil
+	if code := get(); code != http.StatusOK {
+		t.Fatalf("want 200 after recovery, got %d", code)
+	}
+}
+
+func TestNewHealthHandler_ReadinessCache(t *testing.T) {
+	if hh := NewHealthHandler(nil, nil); hh.cacheFor != DefaultReadinessCache {
+		t.Fatalf("want default cache, got %v", hh.cacheFor)
+	}
+	if hh := NewHealthHandler(nil, nil, WithReadinessCache(0)); hh.cacheFor != DefaultReadinessCache {
+		t.Fatalf("want 0 to keep the default, got %v", hh.cacheFor)
+	}
+	if hh := NewHealthHandler(nil, nil, WithReadinessCache(-1)); hh.cacheFor != 0 {
+		t.Fatalf("want negative to disable, got %v", hh.cacheFor)
+	}
+}
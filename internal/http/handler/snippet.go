@@ -1,27 +1,86 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/preview"
+	"github.com/roguepikachu/bonsai/internal/repository"
 	"github.com/roguepikachu/bonsai/internal/service"
+	"github.com/roguepikachu/bonsai/internal/templating"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
 const (
 	// TimeFormat is the standard format for time serialization.
 	TimeFormat = "2006-01-02T15:04:05Z"
+	// DefaultMaxQueryValues is the default cap on repeated values for a single list query parameter.
+	DefaultMaxQueryValues = 50
+	// DefaultMaxQueryLength is the default cap on the raw list query string length.
+	DefaultMaxQueryLength = 4096
+	// DefaultMaxListPage is the default deepest page offset pagination may request.
+	DefaultMaxListPage = 1000
+	// OverLimitReject answers 400 when a list limit exceeds service.ServiceMaxLimit (default).
+	OverLimitReject = "reject"
+	// OverLimitCap silently lowers a list limit above service.ServiceMaxLimit to the maximum.
+	OverLimitCap = "cap"
+	// ListViewSummary lists snippets without their content (default).
+	ListViewSummary = "summary"
+	// ListViewFull lists snippets with their content inline.
+	ListViewFull = "full"
+	// DefaultListFullMaxItems is the default cap on items per full-view list page.
+	DefaultListFullMaxItems = 50
+	// ListMetaBody wraps list items in an object carrying the pagination metadata (default).
+	ListMetaBody = "body"
+	// ListMetaHeaders returns list items as a bare JSON array with the pagination metadata in
+	// X-Page, X-Limit, X-Total and X-Total-Pages headers.
+	ListMetaHeaders = "headers"
+	// ConflictPolicyError answers 400 contradictory_filters when a tag is both included and excluded (default).
+	ConflictPolicyError = "error"
+	// ConflictPolicyExcludeWins drops a tag that is also excluded from the included tags.
+	ConflictPolicyExcludeWins = "exclude_wins"
+	// ConflictPolicyIncludeWins drops a tag that is also included from the excluded tags.
+	ConflictPolicyIncludeWins = "include_wins"
+	// ListOrderNewest lists snippets newest first when a request has no sort (default).
+	ListOrderNewest = "newest"
+	// ListOrderOldest lists snippets oldest first when a request has no sort.
+	ListOrderOldest = "oldest"
 )
 
 // SnippetService defines the handler's dependency contract.
 type SnippetService interface {
-	CreateSnippet(ctx context.Context, content string, expiresIn int, tags []string) (domain.Snippet, error)
-	ListSnippets(ctx context.Context, page, limit int, tag string) ([]domain.Snippet, error)
+	CreateSnippet(ctx context.Context, content string, expiresIn int, tags []string, opts ...service.SnippetOption) (domain.Snippet, error)
+	ListSnippets(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error)
+	ListSnippetsAfter(ctx context.Context, cursor repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error)
+	CountSnippets(ctx context.Context, tag string, opts ...repository.ListOption) (int, error)
+	TagFacets(ctx context.Context, tag string, opts ...repository.ListOption) (service.TagFacets, error)
+	SnippetMetadataByTag(ctx context.Context, tag string) ([]domain.Snippet, bool, error)
+	ListTags(ctx context.Context, prefix string) ([]repository.TagCount, error)
 	GetSnippetByID(ctx context.Context, id string) (domain.Snippet, service.SnippetMeta, error)
-	UpdateSnippet(ctx context.Context, id string, content string, expiresIn int, tags []string) (domain.Snippet, error)
+	GetSnippetsByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error)
+	RecordView(ctx context.Context, id string) int64
+	PatchSnippet(ctx context.Context, id string, patch service.SnippetPatch, opts ...service.SnippetOption) (domain.Snippet, error)
+	UpdateSnippet(ctx context.Context, id string, content string, expiresIn int, tags []string, opts ...service.SnippetOption) (domain.Snippet, error)
+	DiffVersions(ctx context.Context, id string, from, to int) (service.SnippetDiff, error)
+	ImportSnippet(ctx context.Context, rec service.ImportRecord) (domain.Snippet, error)
+	DeleteSnippet(ctx context.Context, id string) error
+	RestoreSnippet(ctx context.Context, id string) (domain.Snippet, error)
 }
 
 // Handler handles HTTP requests for snippets.
@@ -34,88 +93,773 @@ func NewHandler(svc SnippetService) *Handler {
 	return &Handler{svc: svc}
 }
 
+// requireJSONContentType enforces a JSON Content-Type on write requests when
+// config.Conf.RequireJSONContentType is set, writing a 415 response otherwise.
+// By default Gin parses JSON bodies regardless of Content-Type, so this is opt-in.
+func requireJSONContentType(c *gin.Context) bool {
+	if !config.Conf.RequireJSONContentType {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if err == nil && (mediaType == gin.MIMEJSON || (config.Conf.JSONVendorContentType != "" && mediaType == config.Conf.JSONVendorContentType)) {
+		return true
+	}
+	c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": gin.H{"code": "unsupported_media_type", "message": "content type must be application/json"}})
+	return false
+}
+
+// errTrailingJSON is returned by bindJSON in strict mode when the body continues after the JSON value.
+var errTrailingJSON = errors.New("unexpected data after JSON object")
+
+// bindJSON binds and validates the JSON request body into obj. With config.Conf.StrictJSON set,
+// anything but whitespace after the first JSON value is rejected; otherwise it is ignored.
+func bindJSON(c *gin.Context, obj any) error {
+	if !config.Conf.StrictJSON {
+		return c.ShouldBindJSON(obj)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	var first json.RawMessage
+	if err := dec.Decode(&first); err == nil {
+		if dec.More() || len(bytes.TrimSpace(body[dec.InputOffset():])) > 0 {
+			return errTrailingJSON
+		}
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return c.ShouldBindJSON(obj)
+}
+
+// formatTime renders t in TimeFormat, returning nil for the zero time.
+func formatTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	v := t.UTC().Format(TimeFormat)
+	return &v
+}
+
+// toSnippetResponse maps a snippet to its single-item response DTO.
+func toSnippetResponse(snippet domain.Snippet) domain.SnippetResponseDTO {
+	return domain.SnippetResponseDTO{
+		ID:              snippet.ID,
+		Content:         snippet.Content,
+		CreatedAt:       snippet.CreatedAt.UTC().Format(TimeFormat),
+		ExpiresAt:       formatTime(snippet.ExpiresAt),
+		Tags:            snippet.Tags,
+		VisibleFrom:     formatTime(snippet.VisibleFrom),
+		Version:         snippet.Version,
+		Language:        snippet.Language,
+		LineCount:       snippet.LineCount(),
+		CacheTTLSeconds: snippet.CacheTTLSeconds,
+		Templated:       snippet.Templated,
+		Source:          snippet.Source,
+		Views:           snippet.Views,
+		MaxViews:        snippet.MaxViews,
+		Visibility:      snippet.Visibility,
+		Title:           snippet.Title,
+		DeletedAt:       formatTime(snippet.DeletedAt),
+	}
+}
+
+// snippetOptions maps optional request fields to service snippet options.
+func snippetOptions(visibleFrom *time.Time, language string, cacheTTLSeconds int, templated *bool, visibility string, title *string) []service.SnippetOption {
+	var opts []service.SnippetOption
+	if visibleFrom != nil {
+		opts = append(opts, service.WithVisibleFrom(*visibleFrom))
+	}
+	if language != "" {
+		opts = append(opts, service.WithLanguage(language))
+	}
+	if cacheTTLSeconds > 0 {
+		opts = append(opts, service.WithCacheTTL(cacheTTLSeconds))
+	}
+	if templated != nil {
+		opts = append(opts, service.WithTemplated(*templated))
+	}
+	if visibility != "" {
+		opts = append(opts, service.WithVisibility(visibility))
+	}
+	if title != nil {
+		opts = append(opts, service.WithTitle(*title))
+	}
+	return opts
+}
+
 // Create handles the creation of a new snippet.
 func (h *Handler) Create(c *gin.Context) {
 	ctx := c.Request.Context()
+	if !requireJSONContentType(c) {
+		return
+	}
 	var req domain.CreateSnippetRequestDTO
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		logger.Error(ctx, "failed to bind JSON: %s", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": err.Error()}})
 		return
 	}
 
-	snippet, err := h.svc.CreateSnippet(ctx, req.Content, req.ExpiresIn, req.Tags)
+	// Checked here as well as in the service: over HTTP the context always carries a client ID,
+	// generated when the header is missing, and a snippet owned by it could never be read again
+	if req.Visibility == domain.VisibilityPrivate && callerClientID(c) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": "private snippets require an X-Client-ID"}})
+		return
+	}
+	opts := snippetOptions(req.VisibleFrom, req.Language, req.CacheTTLSeconds, &req.Templated, req.Visibility, &req.Title)
+	if req.ID != "" {
+		if !config.Conf.AllowClientIDs {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": "client-supplied ids are not enabled"}})
+			return
+		}
+		if !validClientID(req.ID) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": "id may only contain letters, digits, '-' and '_', and must not be a reserved name"}})
+			return
+		}
+		opts = append(opts, service.WithID(req.ID))
+	}
+	if req.MaxViews != nil {
+		opts = append(opts, service.WithMaxViews(*req.MaxViews))
+	}
+
+	snippet, err := h.svc.CreateSnippet(ctx, req.Content, req.ExpiresIn, req.Tags, opts...)
+	if errors.Is(err, service.ErrDuplicateID) {
+		c.JSON(http.StatusConflict, gin.H{"error": gin.H{"code": "conflict", "message": "a snippet with this id already exists"}})
+		return
+	}
+	if errors.Is(err, repository.ErrTooManyTags) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "too_many_tags", "message": "too many tags", "details": err.Error()}})
+		return
+	}
+	if errors.Is(err, service.ErrPrivateRequiresOwner) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": "private snippets require an X-Client-ID"}})
+		return
+	}
+	if errors.Is(err, service.ErrContentTooLarge) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "content_too_large", "message": "content too large", "details": err.Error()}})
+		return
+	}
+	if errors.Is(err, service.ErrTagTooLong) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "tag_too_long", "message": "tag too long", "details": err.Error()}})
+		return
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to create snippet: %s", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
 		return
 	}
 	logger.With(ctx, map[string]any{"id": snippet.ID, "tags": snippet.Tags}).Info("snippet created")
-	createdAt := snippet.CreatedAt.UTC().Format(TimeFormat)
-	var expiresAt *string
-	if !snippet.ExpiresAt.IsZero() {
-		v := snippet.ExpiresAt.UTC().Format(TimeFormat)
-		expiresAt = &v
-	}
-	resp := domain.SnippetResponseDTO{
-		ID:        snippet.ID,
-		Content:   snippet.Content,
-		CreatedAt: createdAt,
-		ExpiresAt: expiresAt,
-		Tags:      snippet.Tags,
-	}
+	resp := toSnippetResponse(snippet)
+	resp.DuplicateOf = snippet.DuplicateOf
 	c.JSON(http.StatusCreated, resp)
 }
 
-// List handles listing all snippets with pagination and optional tag filter.
-func (h *Handler) List(c *gin.Context) {
+// reservedIDs would be shadowed by static routes under /v1/snippets.
+var reservedIDs = map[string]bool{"mine": true, "import": true, "batch-get": true, "metadata": true, "count": true}
+
+// callerClientID returns the X-Client-ID the caller sent, or "" for anonymous callers. Unlike
+// ctxutil.ClientID it is empty when the request ID middleware had to generate an ID.
+func callerClientID(c *gin.Context) string {
+	return c.GetHeader("X-Client-ID")
+}
+
+// validClientID reports whether a client-supplied ID is URL-safe and not reserved.
+func validClientID(id string) bool {
+	if reservedIDs[id] {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Import handles storing a batch of snippets with their original timestamps.
+// Records are imported independently; failures are reported per record.
+func (h *Handler) Import(c *gin.Context) {
 	ctx := c.Request.Context()
-	type queryParams struct {
-		Page  int    `form:"page,default=1" binding:"gte=1"`
-		Limit int    `form:"limit,default=20" binding:"gte=1,lte=100"`
-		Tag   string `form:"tag"`
+	if !requireJSONContentType(c) {
+		return
+	}
+	var req domain.ImportSnippetsRequestDTO
+	if err := bindJSON(c, &req); err != nil {
+		logger.Error(ctx, "failed to bind JSON: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": err.Error()}})
+		return
+	}
+	resp := domain.ImportSnippetsResponseDTO{Items: make([]domain.ImportResultDTO, 0, len(req.Snippets))}
+	for i, rec := range req.Snippets {
+		in := service.ImportRecord{Content: rec.Content, Tags: rec.Tags, CreatedAt: rec.CreatedAt}
+		if rec.ExpiresAt != nil {
+			in.ExpiresAt = *rec.ExpiresAt
+		}
+		result := domain.ImportResultDTO{Index: i}
+		snippet, err := h.svc.ImportSnippet(ctx, in)
+		switch {
+		case err == nil:
+			result.ID = snippet.ID
+			resp.Imported++
+		case errors.Is(err, service.ErrInvalidExpiry):
+			result.Error = &domain.ErrorDTO{Code: "invalid_expiry", Message: err.Error()}
+		case errors.Is(err, repository.ErrTooManyTags):
+			result.Error = &domain.ErrorDTO{Code: "too_many_tags", Message: err.Error()}
+		case errors.Is(err, service.ErrContentTooLarge):
+			result.Error = &domain.ErrorDTO{Code: "content_too_large", Message: err.Error()}
+		case errors.Is(err, service.ErrTagTooLong):
+			result.Error = &domain.ErrorDTO{Code: "tag_too_long", Message: err.Error()}
+		default:
+			logger.Error(ctx, "failed to import snippet: %s", err.Error())
+			result.Error = &domain.ErrorDTO{Code: "internal_error", Message: "internal server error"}
+		}
+		resp.Items = append(resp.Items, result)
+	}
+	logger.With(ctx, map[string]any{"imported": resp.Imported, "records": len(req.Snippets)}).Info("snippets imported")
+	c.JSON(http.StatusOK, resp)
+}
+
+// listQuery holds the pagination and filter parameters shared by list endpoints.
+type listQuery struct {
+	Page  int `form:"page,default=1" binding:"gte=1"`
+	Limit int `form:"limit,default=20" binding:"gte=1"`
+	// Tags may repeat (?tag=go&tag=web); TagMatch combines them with "all" (default) or "any".
+	Tags     []string `form:"tag"`
+	TagMatch string   `form:"tag_match"`
+	// ExcludeTags may repeat too; snippets carrying any of them are left out.
+	ExcludeTags []string `form:"exclude_tag"`
+	Q           string   `form:"q"`
+	// Cursor, if set, switches to cursor pagination and page is ignored.
+	Cursor string `form:"cursor"`
+	// Sort is one of the repository.Sort* values, e.g. "-expires_at".
+	Sort string `form:"sort"`
+	// Source is one of the domain.Source* values, e.g. "import".
+	Source string `form:"source"`
+	// CreatedAfter, an RFC 3339 time, lists only snippets created (or, with include_deleted,
+	// deleted) since then, for incremental syncs.
+	CreatedAfter time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	// Facets adds tag counts over the matching snippets to the response.
+	Facets bool `form:"facets"`
+	// View is ListViewSummary or ListViewFull; empty uses config.Conf.ListView.
+	View string `form:"view"`
+	// EchoFilters adds the applied filters to the response; config.Conf.ListEchoFilters forces it on.
+	EchoFilters bool `form:"echo_filters"`
+	// Meta is ListMetaBody or ListMetaHeaders; empty uses config.Conf.ListMeta.
+	Meta string `form:"meta"`
+	// Fields is a comma-separated list of item fields to return, e.g. "id,created_at"; empty returns all.
+	Fields string `form:"fields"`
+	// fields is Fields parsed and checked by bindListQuery.
+	fields []string
+}
+
+// checkQueryLimits rejects list requests with an oversized query string or too many
+// repeated values for one parameter, writing a 400 response when exceeded.
+func checkQueryLimits(c *gin.Context) bool {
+	maxLength := config.Conf.MaxQueryLength
+	if maxLength <= 0 {
+		maxLength = DefaultMaxQueryLength
+	}
+	maxValues := config.Conf.MaxQueryValues
+	if maxValues <= 0 {
+		maxValues = DefaultMaxQueryValues
+	}
+	tooMany := len(c.Request.URL.RawQuery) > maxLength
+	if !tooMany {
+		for _, values := range c.Request.URL.Query() {
+			if len(values) > maxValues {
+				tooMany = true
+				break
+			}
+		}
+	}
+	if tooMany {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "too_many_parameters", "message": "too many query parameters"}})
+		return false
+	}
+	return true
+}
+
+// bindListQuery parses and caps list query parameters, writing a 400 response on failure.
+// defaults supplies the filters used when the request carries none; see applyDefaultFilter.
+func bindListQuery(c *gin.Context, defaults url.Values) (listQuery, bool) {
+	var q listQuery
+	if !checkQueryLimits(c) {
+		return q, false
 	}
-	var q queryParams
 	if err := c.ShouldBindQuery(&q); err != nil {
-		logger.Error(ctx, "invalid query params: %s", err.Error())
+		logger.Error(c.Request.Context(), "invalid query params: %s", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": err.Error()}})
-		return
+		return q, false
 	}
-	// Cap pagination defensively
-	if q.Limit < 1 {
-		q.Limit = service.ServiceDefaultLimit
+	if !applyIncludeDeleted(c) {
+		return q, false
+	}
+	applyDefaultFilter(&q, defaults)
+	if !resolveTagConflicts(c, &q) {
+		return q, false
 	}
 	if q.Limit > service.ServiceMaxLimit {
+		if config.Conf.OverLimitPolicy != OverLimitCap {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": fmt.Sprintf("limit must be at most %d", service.ServiceMaxLimit)}})
+			return q, false
+		}
 		q.Limit = service.ServiceMaxLimit
 	}
+	if q.TagMatch != "" && q.TagMatch != repository.TagMatchAll && q.TagMatch != repository.TagMatchAny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "tag_match must be all or any"}})
+		return q, false
+	}
+	if !repository.ValidSort(q.Sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "sort must be one of created_at, -created_at, expires_at, -expires_at"}})
+		return q, false
+	}
+	if q.Source != "" && !domain.ValidSource(q.Source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "source must be one of api, import, fork, batch"}})
+		return q, false
+	}
+	if q.View == "" {
+		q.View = config.Conf.ListView
+	}
+	switch q.View {
+	case "", ListViewSummary:
+		q.View = ListViewSummary
+	case ListViewFull:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "view must be summary or full"}})
+		return q, false
+	}
+	fields, err := parseListFields(q.Fields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": err.Error()}})
+		return q, false
+	}
+	q.fields = fields
+	if q.Meta == "" {
+		q.Meta = config.Conf.ListMeta
+	}
+	switch q.Meta {
+	case "", ListMetaBody:
+		q.Meta = ListMetaBody
+	case ListMetaHeaders:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "meta must be body or headers"}})
+		return q, false
+	}
+	// Text queries rank by relevance and cursors resume newest first, so neither takes the default order
+	if q.Sort == "" && q.Q == "" && q.Cursor == "" && config.Conf.DefaultListOrder == ListOrderOldest {
+		q.Sort = repository.SortCreatedAtAsc
+	}
+	// Cursors only encode the newest-first position
+	if q.Cursor != "" && q.Sort != "" && q.Sort != repository.SortCreatedAtDesc {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": "cursor pagination only supports sort=-created_at"}})
+		return q, false
+	}
+	// Cap pagination defensively
+	if q.Limit < 1 {
+		q.Limit = service.ServiceDefaultLimit
+	}
 	if q.Page < 1 {
 		q.Page = service.ServiceDefaultPage
 	}
-	items, err := h.svc.ListSnippets(ctx, q.Page, q.Limit, q.Tag)
+	// Deep offsets scan and discard every earlier row; cursor pages seek directly and are exempt.
+	maxPage := config.Conf.MaxListPage
+	if maxPage <= 0 {
+		maxPage = DefaultMaxListPage
+	}
+	if q.Cursor == "" && q.Page > maxPage {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "page_too_deep", "message": fmt.Sprintf("page must be at most %d; use cursor pagination (next_cursor) to read further", maxPage)}})
+		return q, false
+	}
+	return q, true
+}
+
+// resolveTagConflicts applies config.Conf.ConflictingFilterPolicy to tags that are both
+// included and excluded, writing a 400 response under the error policy.
+func resolveTagConflicts(c *gin.Context, q *listQuery) bool {
+	var conflicts []string
+	for _, t := range q.Tags {
+		if slices.Contains(q.ExcludeTags, t) && !slices.Contains(conflicts, t) {
+			conflicts = append(conflicts, t)
+		}
+	}
+	if len(conflicts) == 0 {
+		return true
+	}
+	switch config.Conf.ConflictingFilterPolicy {
+	case ConflictPolicyExcludeWins:
+		q.Tags = slices.DeleteFunc(q.Tags, func(t string) bool { return slices.Contains(conflicts, t) })
+	case ConflictPolicyIncludeWins:
+		q.ExcludeTags = slices.DeleteFunc(q.ExcludeTags, func(t string) bool { return slices.Contains(conflicts, t) })
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "contradictory_filters", "message": "tags are both included and excluded", "details": gin.H{"tags": conflicts}}})
+		return false
+	}
+	return true
+}
+
+// applyDefaultFilter fills q's filters (tag, tag_match, exclude_tag, q, source) from defaults
+// when the request sent none of them. Any explicit filter replaces the defaults entirely.
+func applyDefaultFilter(q *listQuery, defaults url.Values) {
+	if len(defaults) == 0 || len(q.Tags) > 0 || q.TagMatch != "" || len(q.ExcludeTags) > 0 || q.Q != "" || q.Source != "" {
+		return
+	}
+	q.Tags = defaults["tag"]
+	q.ExcludeTags = defaults["exclude_tag"]
+	q.TagMatch = defaults.Get("tag_match")
+	q.Q = defaults.Get("q")
+	q.Source = defaults.Get("source")
+}
+
+// List handles listing all snippets with pagination, optional tag filter and optional text query (q).
+// Unfiltered requests get config.Conf.ListDefaultFilter, e.g. a curated home feed.
+func (h *Handler) List(c *gin.Context) {
+	q, ok := bindListQuery(c, config.Conf.ListDefaultFilter)
+	if !ok {
+		return
+	}
+	h.list(c, q)
+}
+
+// Mine handles listing the snippets created by the calling client (X-Client-ID).
+// The owner is always taken from the header, never from query parameters,
+// so a client cannot list another client's snippets by passing its ID.
+func (h *Handler) Mine(c *gin.Context) {
+	clientID := callerClientID(c)
+	if clientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "client id is required"}})
+		return
+	}
+	q, ok := bindListQuery(c, nil)
+	if !ok {
+		return
+	}
+	h.list(c, q, repository.WithOwner(clientID))
+}
+
+func (h *Handler) list(c *gin.Context, q listQuery, opts ...repository.ListOption) {
+	ctx := c.Request.Context()
+	// final safety cap, independent of the requested limit and the over-limit policy
+	truncated := false
+	if maxItems := config.Conf.MaxListItems; maxItems > 0 && q.Limit > maxItems {
+		q.Limit = maxItems
+		truncated = true
+	}
+	// full items carry up to 10KB of content each, so they get a tighter page size
+	if q.View == ListViewFull {
+		maxFull := config.Conf.ListFullMaxItems
+		if maxFull <= 0 {
+			maxFull = DefaultListFullMaxItems
+		}
+		if q.Limit > maxFull {
+			q.Limit = maxFull
+			truncated = true
+		}
+		opts = append(opts, repository.WithContent())
+	}
+	// A single tag keeps the plain tag path (and strict-tag checks); several go through WithTags.
+	var tag string
+	switch {
+	case len(q.Tags) == 1:
+		tag = q.Tags[0]
+	case len(q.Tags) > 1:
+		opts = append(opts, repository.WithTags(q.TagMatch, q.Tags...))
+	}
+	if len(q.ExcludeTags) > 0 {
+		opts = append(opts, repository.WithExcludeTags(q.ExcludeTags...))
+	}
+	if q.Q != "" {
+		opts = append(opts, repository.WithQuery(q.Q))
+	}
+	if q.Sort != "" {
+		opts = append(opts, repository.WithSort(q.Sort))
+	}
+	if q.Source != "" {
+		opts = append(opts, repository.WithSource(q.Source))
+	}
+	if !q.CreatedAfter.IsZero() {
+		opts = append(opts, repository.WithCreatedAfter(q.CreatedAfter))
+	}
+	var (
+		items []domain.Snippet
+		err   error
+	)
+	if q.Cursor != "" {
+		cursor, cerr := repository.DecodeCursor(q.Cursor)
+		if cerr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "invalid_cursor", "message": "invalid cursor"}})
+			return
+		}
+		items, err = h.svc.ListSnippetsAfter(ctx, cursor, q.Limit, tag, opts...)
+	} else {
+		items, err = h.svc.ListSnippets(ctx, q.Page, q.Limit, tag, opts...)
+	}
+	if errors.Is(err, service.ErrUnknownTag) {
+		c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "unknown_tag", "message": "unknown tag"}})
+		return
+	}
 	if err != nil {
 		logger.Error(ctx, "failed to list snippets: %s", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
 		return
 	}
-	logger.With(ctx, map[string]any{"count": len(items), "page": q.Page, "limit": q.Limit, "tag": q.Tag}).Debug("snippets listed")
+	total, err := h.svc.CountSnippets(ctx, tag, opts...)
+	if err != nil {
+		logger.Error(ctx, "failed to count snippets: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	logger.With(ctx, map[string]any{"count": len(items), "total": total, "page": q.Page, "limit": q.Limit, "tags": q.Tags, "tag_match": q.TagMatch, "exclude_tags": q.ExcludeTags, "q": q.Q, "sort": q.Sort, "source": q.Source}).Debug("snippets listed")
 	list := make([]domain.SnippetListItemDTO, 0, len(items))
 	for _, s := range items {
-		createdAt := s.CreatedAt.UTC().Format(TimeFormat)
-		var expiresAt *string
-		if !s.ExpiresAt.IsZero() {
-			v := s.ExpiresAt.UTC().Format(TimeFormat)
-			expiresAt = &v
+		item := domain.SnippetListItemDTO{
+			ID:         s.ID,
+			Title:      s.Title,
+			CreatedAt:  s.CreatedAt.UTC().Format(TimeFormat),
+			ExpiresAt:  formatTime(s.ExpiresAt),
+			Tags:       s.Tags,
+			Source:     s.Source,
+			Visibility: s.Visibility,
+			DeletedAt:  formatTime(s.DeletedAt),
+			Deleted:    !s.DeletedAt.IsZero(),
 		}
-		list = append(list, domain.SnippetListItemDTO{
-			ID:        s.ID,
-			CreatedAt: createdAt,
-			ExpiresAt: expiresAt,
-		})
+		// An incremental sync only needs to know which snippets to drop
+		if item.Deleted && !q.CreatedAfter.IsZero() {
+			list = append(list, domain.SnippetListItemDTO{ID: item.ID, CreatedAt: item.CreatedAt, DeletedAt: item.DeletedAt, Deleted: true})
+			continue
+		}
+		if q.View == ListViewFull {
+			item.Content = s.Content
+		}
+		if maxTags := config.Conf.ListMaxTags; maxTags > 0 && len(item.Tags) > maxTags {
+			item.Tags = item.Tags[:maxTags:maxTags]
+			item.TagsTruncated = true
+		}
+		list = append(list, item)
 	}
 	resp := domain.ListSnippetsResponseDTO{
-		Page:  q.Page,
-		Limit: q.Limit,
-		Items: list,
+		Page:           q.Page,
+		Limit:          q.Limit,
+		Items:          list,
+		Total:          total,
+		LimitTruncated: truncated,
+	}
+	var projected []map[string]any
+	if len(q.fields) > 0 {
+		projected = make([]map[string]any, 0, len(list))
+		for _, item := range list {
+			projected = append(projected, projectListItem(item, q.fields))
+		}
+	}
+	writeLinkHeader(c, q, total, listNextCursor(q, items))
+	if q.Meta == ListMetaHeaders {
+		writeListHeaders(c, q, total, listNextCursor(q, items))
+		if projected != nil {
+			c.JSON(http.StatusOK, projected)
+			return
+		}
+		c.JSON(http.StatusOK, list)
+		return
+	}
+	if q.EchoFilters || config.Conf.ListEchoFilters {
+		resp.Filters = echoFilters(q, tag, opts)
+	}
+	if q.Facets {
+		facets, err := h.svc.TagFacets(ctx, tag, opts...)
+		if err != nil {
+			logger.Error(ctx, "failed to compute facets: %s", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+			return
+		}
+		resp.Facets = &domain.FacetsDTO{Tags: facets.Tags, Approximate: facets.Approximate}
+	}
+	resp.NextCursor = listNextCursor(q, items)
+	if projected != nil {
+		c.JSON(http.StatusOK, projectedListResponse{ListSnippetsResponseDTO: resp, Items: projected})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// projectedListResponse is a list response whose items were trimmed with ?fields=. Its Items
+// shadows the embedded DTO's when encoded.
+type projectedListResponse struct {
+	domain.ListSnippetsResponseDTO
+	Items []map[string]any `json:"items"`
+}
+
+// listNextCursor returns the cursor resuming after items, or "" when there is nothing to resume.
+// A full page may have more after it. Cursors resume newest-first order, so relevance-ranked
+// and explicitly sorted offset pages have nothing to resume from.
+func listNextCursor(q listQuery, items []domain.Snippet) string {
+	newestFirst := q.Sort == repository.SortCreatedAtDesc || (q.Sort == "" && q.Q == "")
+	if len(items) == 0 || len(items) != q.Limit || (q.Cursor == "" && !newestFirst) {
+		return ""
+	}
+	last := items[len(items)-1]
+	return repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+}
+
+// writeListHeaders sets the pagination metadata of a ?meta=headers list page. X-Page is omitted
+// for cursor pages and X-Next-Cursor is set when a following page may exist.
+func writeListHeaders(c *gin.Context, q listQuery, total int, nextCursor string) {
+	if q.Cursor == "" {
+		c.Header("X-Page", strconv.Itoa(q.Page))
+	}
+	c.Header("X-Limit", strconv.Itoa(q.Limit))
+	c.Header("X-Total", strconv.Itoa(total))
+	c.Header("X-Total-Pages", strconv.Itoa((total+q.Limit-1)/q.Limit))
+	if nextCursor != "" {
+		c.Header("X-Next-Cursor", nextCursor)
+	}
+}
+
+// writeLinkHeader sets an RFC 8288 Link header with first, prev, next and last page URLs,
+// relative to the request path and keeping all its query parameters. prev is omitted on the
+// first page and next and last on the final one. Cursor pages only link first and, when a
+// following page may exist, next.
+func writeLinkHeader(c *gin.Context, q listQuery, total int, nextCursor string) {
+	link := func(rel string, set map[string]string) string {
+		query := c.Request.URL.Query()
+		query.Del("cursor")
+		query.Del("page")
+		query.Set("limit", strconv.Itoa(q.Limit))
+		for k, v := range set {
+			query.Set(k, v)
+		}
+		u := url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}
+		return fmt.Sprintf("<%s>; rel=%q", u.String(), rel)
+	}
+	page := func(n int) map[string]string { return map[string]string{"page": strconv.Itoa(n)} }
+	links := []string{link("first", page(1))}
+	if q.Cursor != "" {
+		if nextCursor != "" {
+			links = append(links, link("next", map[string]string{"cursor": nextCursor}))
+		}
+		c.Header("Link", strings.Join(links, ", "))
+		return
+	}
+	last := max((total+q.Limit-1)/q.Limit, 1)
+	if q.Page > 1 {
+		links = append(links, link("prev", page(min(q.Page-1, last))))
+	}
+	if q.Page < last {
+		links = append(links, link("next", page(q.Page+1)), link("last", page(last)))
+	}
+	c.Header("Link", strings.Join(links, ", "))
+}
+
+// echoFilters reports the filters a list page was served with, read back from the repository
+// options so the echo matches what the query actually used.
+func echoFilters(q listQuery, tag string, opts []repository.ListOption) *domain.ListFiltersDTO {
+	o := repository.NewListOptions(opts...)
+	f := &domain.ListFiltersDTO{
+		Limit:       q.Limit,
+		Cursor:      q.Cursor != "",
+		Tags:        o.TagSet(tag),
+		Q:           o.Query,
+		ExcludeTags: repository.ListOptions{Tags: o.ExcludeTags}.TagSet(""),
+		Sort:        o.Sort,
+		Source:      o.Source,
+		Owner:       o.OwnerID,
+		View:        q.View,
+		Expiry:      "active",
+	}
+	if !f.Cursor {
+		f.Page = q.Page
+	}
+	if !o.CreatedAfter.IsZero() {
+		f.CreatedAfter = o.CreatedAfter.UTC().Format(TimeFormat)
+	}
+	if len(f.Tags) > 1 {
+		f.TagMatch = repository.TagMatchAll
+		if o.MatchAny() {
+			f.TagMatch = repository.TagMatchAny
+		}
+	}
+	if f.Sort == "" {
+		f.Sort = repository.SortCreatedAtDesc
+		if f.Q != "" {
+			f.Sort = "relevance"
+		}
+	}
+	return f
+}
+
+// Tags handles listing the distinct tags of active snippets, most used first. ?prefix= filters
+// them for typeahead and ?counts=1 returns each tag with its snippet count.
+func (h *Handler) Tags(c *gin.Context) {
+	ctx := c.Request.Context()
+	var q struct {
+		Prefix string `form:"prefix"`
+		Counts bool   `form:"counts"`
+	}
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": err.Error()}})
+		return
+	}
+	tags, err := h.svc.ListTags(ctx, q.Prefix)
+	if err != nil {
+		logger.Error(ctx, "failed to list tags: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	if q.Counts {
+		resp := domain.TagCountsResponseDTO{Tags: make([]domain.TagCountDTO, 0, len(tags))}
+		for _, tc := range tags {
+			resp.Tags = append(resp.Tags, domain.TagCountDTO{Tag: tc.Tag, Count: tc.Count})
+		}
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	resp := domain.TagsResponseDTO{Tags: make([]string, 0, len(tags))}
+	for _, tc := range tags {
+		resp.Tags = append(resp.Tags, tc.Tag)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Count handles counting the active snippets carrying ?tag=, or all of them without it. The
+// count matches what List returns across all pages for the same tag.
+func (h *Handler) Count(c *gin.Context) {
+	ctx := c.Request.Context()
+	tag := strings.TrimSpace(c.Query("tag"))
+	n, err := h.svc.CountSnippets(ctx, tag)
+	if err != nil {
+		logger.Error(ctx, "failed to count snippets: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	c.JSON(http.StatusOK, domain.SnippetCountResponseDTO{Count: n})
+}
+
+// Metadata handles listing the metadata of the most recent active snippets carrying ?tag=,
+// without their content. The service caps how many are returned and flags truncation.
+func (h *Handler) Metadata(c *gin.Context) {
+	ctx := c.Request.Context()
+	tag := strings.TrimSpace(c.Query("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "tag is required"}})
+		return
+	}
+	items, truncated, err := h.svc.SnippetMetadataByTag(ctx, tag)
+	if err != nil {
+		logger.Error(ctx, "failed to list snippet metadata: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	resp := domain.SnippetMetadataResponseDTO{Tag: tag, Items: make([]domain.SnippetMetadataDTO, 0, len(items)), Truncated: truncated}
+	for _, s := range items {
+		resp.Items = append(resp.Items, domain.SnippetMetadataDTO{
+			ID:        s.ID,
+			Title:     s.Title,
+			CreatedAt: s.CreatedAt.UTC().Format(TimeFormat),
+			ExpiresAt: formatTime(s.ExpiresAt),
+			Tags:      s.Tags,
+			Language:  s.Language,
+			Source:    s.Source,
+			Version:   s.Version,
+		})
 	}
 	c.JSON(http.StatusOK, resp)
 }
@@ -128,37 +872,186 @@ func (h *Handler) Get(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "id is required"}})
 		return
 	}
+	previewMode := c.Query("preview")
+	if previewMode != "" && previewMode != "html" && previewMode != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "preview must be html or text"}})
+		return
+	}
+	if !applyIncludeDeleted(c) {
+		return
+	}
+	ctx = c.Request.Context()
+	snippet, meta, ok := h.readSnippet(c, id)
+	if !ok {
+		return
+	}
+	// ?count_view=0 keeps monitoring and internal reads out of the count, except for
+	// view-limited snippets, whose every read was already counted toward the limit
+	if count, err := strconv.ParseBool(c.DefaultQuery("count_view", "1")); !meta.ViewCounted && (err != nil || count) {
+		snippet.Views += h.svc.RecordView(ctx, id)
+	}
+	// readSnippet already set X-Cache, so a 304 still reports HIT/MISS
+	etag := snippetETag(snippet, previewMode)
+	c.Header("ETag", etag)
+	// The expired flag is not part of the ETag, so grace-window reads always send the body
+	if !meta.Expired && etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	resp := toSnippetResponse(snippet)
+	resp.Expired = meta.Expired
+	if previewMode != "" {
+		p, err := renderPreview(snippet, previewMode)
+		if err != nil {
+			logger.Error(ctx, "failed to render preview: %s", err.Error())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+			return
+		}
+		resp.Preview = &p
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// BatchGet handles fetching up to 100 snippets by ID in one request. Unknown, expired and
+// not yet visible snippets are left out of the response instead of failing the batch.
+func (h *Handler) BatchGet(c *gin.Context) {
+	ctx := c.Request.Context()
+	if !requireJSONContentType(c) {
+		return
+	}
+	var req domain.BatchGetRequestDTO
+	if err := bindJSON(c, &req); err != nil {
+		logger.Error(ctx, "failed to bind JSON: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": err.Error()}})
+		return
+	}
+	snippets, err := h.svc.GetSnippetsByIDs(ctx, req.IDs)
+	if err != nil {
+		logger.Error(ctx, "failed to get snippets: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	vars := templateVars(c)
+	resp := domain.BatchGetResponseDTO{Items: make([]domain.SnippetResponseDTO, 0, len(snippets))}
+	for _, snippet := range snippets {
+		if snippet.Templated {
+			snippet.Content, _ = templating.Render(snippet.Content, vars)
+		}
+		resp.Items = append(resp.Items, toSnippetResponse(snippet))
+	}
+	logger.With(ctx, map[string]any{"requested": len(req.IDs), "found": len(resp.Items)}).Debug("snippets batch retrieved")
+	c.JSON(http.StatusOK, resp)
+}
+
+// Raw handles fetching a snippet's content as text/plain, e.g. for curl pipelines.
+// Errors keep the JSON envelope; ?download=1 asks browsers to save the content as <id>.txt.
+func (h *Handler) Raw(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "id is required"}})
+		return
+	}
+	snippet, _, ok := h.readSnippet(c, id)
+	if !ok {
+		return
+	}
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": id + ".txt"}))
+	}
+	body := []byte(snippet.Content)
+	c.Header("Content-Length", strconv.Itoa(len(body)))
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", body)
+}
+
+// readSnippet fetches a snippet for a read endpoint, rendering template variables and
+// setting X-Cache. It writes the error response and returns false on failure.
+func (h *Handler) readSnippet(c *gin.Context, id string) (domain.Snippet, service.SnippetMeta, bool) {
+	ctx := c.Request.Context()
 	snippet, meta, err := h.svc.GetSnippetByID(ctx, id)
 	cacheStatus := string(meta.CacheStatus)
 	if err != nil {
 		if errors.Is(err, service.ErrSnippetNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
-			return
+			return domain.Snippet{}, meta, false
 		}
 		if errors.Is(err, service.ErrSnippetExpired) {
 			c.JSON(http.StatusGone, gin.H{"error": gin.H{"code": "gone", "message": "expired"}})
-			return
+			return domain.Snippet{}, meta, false
+		}
+		if errors.Is(err, service.ErrViewLimitReached) {
+			c.JSON(http.StatusGone, gin.H{"error": gin.H{"code": "gone", "message": "view limit reached"}})
+			return domain.Snippet{}, meta, false
+		}
+		if errors.Is(err, service.ErrSnippetNotYetAvailable) {
+			c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "not_yet_available", "message": "not yet available"}})
+			return domain.Snippet{}, meta, false
 		}
 		logger.Error(ctx, "failed to get snippet: %s", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
-		return
+		return domain.Snippet{}, meta, false
 	}
 	logger.With(ctx, map[string]any{"id": id, "cache": cacheStatus}).Debug("snippet retrieved")
+	if snippet.Templated {
+		var missing []string
+		snippet.Content, missing = templating.Render(snippet.Content, templateVars(c))
+		if len(missing) > 0 && config.Conf.StrictTemplateVars {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "missing_template_vars", "message": "missing template variables", "details": gin.H{"missing": missing}}})
+			return domain.Snippet{}, meta, false
+		}
+	}
 	c.Header("X-Cache", cacheStatus)
-	createdAt := snippet.CreatedAt.UTC().Format(TimeFormat)
-	var expiresAt *string
-	if !snippet.ExpiresAt.IsZero() {
-		v := snippet.ExpiresAt.UTC().Format(TimeFormat)
-		expiresAt = &v
-	}
-	resp := domain.SnippetResponseDTO{
-		ID:        snippet.ID,
-		Content:   snippet.Content,
-		CreatedAt: createdAt,
-		ExpiresAt: expiresAt,
-		Tags:      snippet.Tags,
+	if meta.Expired {
+		c.Header("Warning", fmt.Sprintf(`299 - "snippet expired at %s and will be removed soon"`, snippet.ExpiresAt.UTC().Format(TimeFormat)))
 	}
-	c.JSON(http.StatusOK, resp)
+	return snippet, meta, true
+}
+
+// snippetETag returns a strong ETag over the fields a GET response varies with: the (rendered)
+// content, tags and expiry, plus the preview mode since previews are a different representation.
+func snippetETag(snippet domain.Snippet, previewMode string) string {
+	h := sha256.New()
+	h.Write([]byte(snippet.Content))
+	for _, t := range snippet.Tags {
+		h.Write([]byte{0})
+		h.Write([]byte(t))
+	}
+	fmt.Fprintf(h, "\x00%s\x00%s", snippet.ExpiresAt.UTC().Format(time.RFC3339Nano), previewMode)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header matches etag, using the weak
+// comparison RFC 9110 prescribes for it.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// templateVarPrefix marks query parameters that supply template variables, e.g. ?var.name=value.
+const templateVarPrefix = "var."
+
+// templateVars collects template variables from the request's ?var.<name>= query parameters.
+func templateVars(c *gin.Context) map[string]string {
+	vars := map[string]string{}
+	for key, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(key, templateVarPrefix); ok && name != "" && len(values) > 0 {
+			vars[name] = values[0]
+		}
+	}
+	return vars
+}
+
+// renderPreview builds the first config.Conf.PreviewLines lines of a snippet as text or sanitized HTML.
+// Markdown is only rendered when config.Conf.MarkdownPreview is set.
+func renderPreview(snippet domain.Snippet, mode string) (string, error) {
+	if mode == "text" {
+		return preview.Text(snippet.Content, config.Conf.PreviewLines), nil
+	}
+	return preview.HTML(snippet.Content, snippet.Language, config.Conf.PreviewLines, config.Conf.MarkdownPreview)
 }
 
 // Update handles updating an existing snippet by ID.
@@ -169,14 +1062,46 @@ func (h *Handler) Update(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "id is required"}})
 		return
 	}
+	if !requireJSONContentType(c) {
+		return
+	}
 	var req domain.UpdateSnippetRequestDTO
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := bindJSON(c, &req); err != nil {
 		logger.Error(ctx, "failed to bind JSON: %s", err.Error())
 		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": err.Error()}})
 		return
 	}
 
-	snippet, err := h.svc.UpdateSnippet(ctx, id, req.Content, req.ExpiresIn, req.Tags)
+	snippet, err := h.svc.UpdateSnippet(ctx, id, req.Content, req.ExpiresIn, req.Tags, snippetOptions(req.VisibleFrom, req.Language, req.CacheTTLSeconds, req.Templated, req.Visibility, req.Title)...)
+	h.respondUpdated(c, snippet, err)
+}
+
+// Patch handles a partial update of a snippet: only fields present in the body change.
+func (h *Handler) Patch(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "id is required"}})
+		return
+	}
+	if !requireJSONContentType(c) {
+		return
+	}
+	var req domain.PatchSnippetRequestDTO
+	if err := bindJSON(c, &req); err != nil {
+		logger.Error(ctx, "failed to bind JSON: %s", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": err.Error()}})
+		return
+	}
+
+	patch := service.SnippetPatch{Content: req.Content, ExpiresIn: req.ExpiresIn, Tags: req.Tags}
+	snippet, err := h.svc.PatchSnippet(ctx, id, patch, snippetOptions(req.VisibleFrom, req.Language, req.CacheTTLSeconds, req.Templated, req.Visibility, req.Title)...)
+	h.respondUpdated(c, snippet, err)
+}
+
+// respondUpdated writes the response for a PUT or PATCH.
+func (h *Handler) respondUpdated(c *gin.Context, snippet domain.Snippet, err error) {
+	ctx := c.Request.Context()
 	if err != nil {
 		if errors.Is(err, service.ErrSnippetNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "not found"}})
@@ -186,23 +1111,75 @@ func (h *Handler) Update(c *gin.Context) {
 			c.JSON(http.StatusGone, gin.H{"error": gin.H{"code": "gone", "message": "cannot update expired snippet"}})
 			return
 		}
+		if errors.Is(err, repository.ErrTooManyTags) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "too_many_tags", "message": "too many tags", "details": err.Error()}})
+			return
+		}
+		if errors.Is(err, service.ErrContentTooLarge) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "content_too_large", "message": "content too large", "details": err.Error()}})
+			return
+		}
+		if errors.Is(err, service.ErrTagTooLong) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "tag_too_long", "message": "tag too long", "details": err.Error()}})
+			return
+		}
+		if errors.Is(err, service.ErrPrivateRequiresOwner) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid request", "details": "only snippets created with an X-Client-ID can be private"}})
+			return
+		}
 		logger.Error(ctx, "failed to update snippet: %s", err.Error())
 		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
 		return
 	}
 	logger.With(ctx, map[string]any{"id": snippet.ID, "tags": snippet.Tags}).Info("snippet updated")
-	createdAt := snippet.CreatedAt.UTC().Format(TimeFormat)
-	var expiresAt *string
-	if !snippet.ExpiresAt.IsZero() {
-		v := snippet.ExpiresAt.UTC().Format(TimeFormat)
-		expiresAt = &v
-	}
-	resp := domain.SnippetResponseDTO{
-		ID:        snippet.ID,
-		Content:   snippet.Content,
-		CreatedAt: createdAt,
-		ExpiresAt: expiresAt,
-		Tags:      snippet.Tags,
+	c.JSON(http.StatusOK, toSnippetResponse(snippet))
+}
+
+// Diff handles returning the changes between two versions of a snippet.
+// Responds with JSON by default, or the bare unified diff as text/plain when format=text.
+func (h *Handler) Diff(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	type diffParams struct {
+		From   int    `form:"from" binding:"required,gte=1"`
+		To     int    `form:"to" binding:"required,gte=1"`
+		Format string `form:"format" binding:"omitempty,oneof=json text"`
 	}
-	c.JSON(http.StatusOK, resp)
+	var q diffParams
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": gin.H{"code": "bad_request", "message": "invalid query parameters", "details": err.Error()}})
+		return
+	}
+	diff, err := h.svc.DiffVersions(ctx, id, q.From, q.To)
+	if err != nil {
+		if errors.Is(err, service.ErrVersionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": gin.H{"code": "not_found", "message": "version not found"}})
+			return
+		}
+		if errors.Is(err, service.ErrSnippetExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": gin.H{"code": "gone", "message": "expired"}})
+			return
+		}
+		if errors.Is(err, service.ErrSnippetNotYetAvailable) {
+			c.JSON(http.StatusForbidden, gin.H{"error": gin.H{"code": "not_yet_available", "message": "not yet available"}})
+			return
+		}
+		logger.Error(ctx, "failed to diff snippet: %s", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": gin.H{"code": "internal_error", "message": "internal server error"}})
+		return
+	}
+	if q.Format == "text" {
+		c.String(http.StatusOK, diff.UnifiedDiff)
+		return
+	}
+	c.JSON(http.StatusOK, domain.SnippetDiffResponseDTO{
+		ID:            id,
+		From:          q.From,
+		To:            q.To,
+		Diff:          diff.UnifiedDiff,
+		TagsAdded:     diff.TagsAdded,
+		TagsRemoved:   diff.TagsRemoved,
+		ExpiresAtFrom: formatTime(diff.From.ExpiresAt),
+		ExpiresAtTo:   formatTime(diff.To.ExpiresAt),
+	})
 }
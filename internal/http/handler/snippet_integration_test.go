@@ -0,0 +1,123 @@
+//go:build integration
+
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	miniredis "github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/roguepikachu/bonsai/internal/config"
+	"github.com/roguepikachu/bonsai/internal/domain"
+	cachedrepo "github.com/roguepikachu/bonsai/internal/repository/cached"
+	pgrepo "github.com/roguepikachu/bonsai/internal/repository/postgres"
+	"github.com/roguepikachu/bonsai/internal/service"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+)
+
+func TestSnippetCreate_ConcurrentSameClientID(t *testing.T) {
+	ctx := context.Background()
+	pg, err := tcpostgres.RunContainer(ctx,
+		tcpostgres.WithUsername("bonsai"),
+		tcpostgres.WithPassword("secret"),
+		tcpostgres.WithDatabase("bonsai"),
+	)
+	if err != nil {
+		t.Skipf("skipping: cannot start postgres container (is Docker running?): %v", err)
+	}
+	defer func() { _ = pg.Terminate(context.Background()) }()
+	host, _ := pg.Host(ctx)
+	port, _ := pg.MappedPort(ctx, "5432")
+	pool, err := pgxpool.New(ctx, fmt.Sprintf("postgres://bonsai:secret@%s:%s/bonsai?sslmode=disable", host, port.Port()))
+	if err != nil {
+		t.Fatalf("new pool: %v", err)
+	}
+	defer pool.Close()
+	deadline := time.Now().Add(30 * time.Second)
+	for pool.Ping(ctx) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("timeout waiting for db ready")
+		}
+		time.Sleep(250 * time.Millisecond)
+	}
+	primary := pgrepo.NewSnippetRepository(pool)
+	if err := primary.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	// Warm writes would cache a losing create if the layer did not check the insert result
+	repo := cachedrepo.NewSnippetRepository(primary, redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute,
+		cachedrepo.WithWritePolicy(cachedrepo.WriteWarm))
+
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	config.Conf.AllowClientIDs = true
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/v1/snippets", NewHandler(service.NewService(repo, service.RealClock{})).Create)
+
+	const racers = 8
+	codes := make([]int, racers)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			body := fmt.Sprintf(`{"id":"race","content":"writer-%d"}`, i)
+			req := httptest.NewRequest(http.MethodPost, "/v1/snippets", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	created, conflicts := 0, 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusConflict:
+			conflicts++
+		default:
+			t.Fatalf("unexpected status %d", code)
+		}
+	}
+	if created != 1 || conflicts != racers-1 {
+		t.Fatalf("want exactly one 201 and %d 409s, got %d and %d", racers-1, created, conflicts)
+	}
+
+	stored, err := primary.FindByID(ctx, "race")
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	cachedJSON, err := mr.Get("snippet:race")
+	if err != nil {
+		t.Fatalf("winner should be cached: %v", err)
+	}
+	var cached domain.Snippet
+	if err := json.Unmarshal([]byte(cachedJSON), &cached); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if cached.Content != stored.Content {
+		t.Fatalf("cache holds a losing create: cached %q, stored %q", cached.Content, stored.Content)
+	}
+}
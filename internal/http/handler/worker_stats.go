@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/worker"
+	"github.com/roguepikachu/bonsai/pkg"
+)
+
+// WorkerStatsSource reports background worker state.
+type WorkerStatsSource interface {
+	Running() int
+	State() []worker.JobState
+}
+
+// WorkerStatsHandler exposes the background worker gauge for operators.
+type WorkerStatsHandler struct {
+	src WorkerStatsSource
+}
+
+// NewWorkerStatsHandler constructs a WorkerStatsHandler.
+func NewWorkerStatsHandler(src WorkerStatsSource) *WorkerStatsHandler {
+	return &WorkerStatsHandler{src: src}
+}
+
+// Stats reports the number of running workers and per-job state. Admin only.
+func (h *WorkerStatsHandler) Stats(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	c.JSON(http.StatusOK, pkg.NewResponse(http.StatusOK, gin.H{"running": h.src.Running(), "jobs": h.src.State()}, "ok"))
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StrictAccept rejects requests whose Accept header cannot be satisfied by any of the
+// supported media types with 406 Not Acceptable. A missing Accept header is accepted.
+func StrictAccept(supported ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accept := c.GetHeader("Accept")
+		if accept == "" || acceptsAny(accept, supported) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+			"error": gin.H{"code": "not_acceptable", "message": "not acceptable", "details": gin.H{"supported": supported}},
+		})
+	}
+}
+
+// acceptsAny reports whether any media range in the Accept header matches a supported type.
+func acceptsAny(accept string, supported []string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if q := params["q"]; q == "0" || q == "0.0" || q == "0.00" || q == "0.000" {
+			continue
+		}
+		if mediaRange == "*/*" {
+			return true
+		}
+		for _, s := range supported {
+			if mediaRange == s {
+				return true
+			}
+			if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok && strings.HasPrefix(s, prefix+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStrictAccept(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(StrictAccept("application/json"))
+	r.GET("/x", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+
+	tests := []struct {
+		accept string
+		want   int
+	}{
+		{"", http.StatusOK},
+		{"application/json", http.StatusOK},
+		{"application/json; charset=utf-8", http.StatusOK},
+		{"*/*", http.StatusOK},
+		{"application/*", http.StatusOK},
+		{"text/html, application/json;q=0.5", http.StatusOK},
+		{"application/yaml", http.StatusNotAcceptable},
+		{"text/*", http.StatusNotAcceptable},
+		{"application/json;q=0", http.StatusNotAcceptable},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/x", nil)
+		if tt.accept != "" {
+			req.Header.Set("Accept", tt.accept)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("Accept %q: want %d, got %d", tt.accept, tt.want, w.Code)
+		}
+		if tt.want == http.StatusNotAcceptable && !strings.Contains(w.Body.String(), `"supported":["application/json"]`) {
+			t.Fatalf("406 body should list supported types, got %s", w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+)
+
+// CacheExempt marks requests to the given route templates (e.g. "/v1/snippets/mine") so that
+// the cached repository serves their reads from the primary store without consulting Redis.
+func CacheExempt(routes ...string) gin.HandlerFunc {
+	exempt := make(map[string]bool, len(routes))
+	for _, r := range routes {
+		exempt[r] = true
+	}
+	return func(c *gin.Context) {
+		if exempt[c.FullPath()] {
+			c.Request = c.Request.WithContext(ctxutil.WithCacheBypass(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+)
+
+func TestCacheExempt(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CacheExempt("/v1/snippets/mine"))
+	bypass := map[string]bool{}
+	record := func(c *gin.Context) { bypass[c.Request.URL.Path] = ctxutil.CacheBypass(c.Request.Context()) }
+	r.GET("/v1/snippets/mine", record)
+	r.GET("/v1/snippets/:id", record)
+
+	for _, path := range []string{"/v1/snippets/mine", "/v1/snippets/abc"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if !bypass["/v1/snippets/mine"] {
+		t.Fatalf("expected exempt route to bypass the cache")
+	}
+	if bypass["/v1/snippets/abc"] {
+		t.Fatalf("expected other routes to use the cache")
+	}
+}
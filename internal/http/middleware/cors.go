@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultCORSMaxAge is how long browsers may cache a preflight answer when no max age is configured.
+const DefaultCORSMaxAge = 10 * time.Minute
+
+var (
+	// DefaultCORSMethods are the methods allowed cross-origin when none are configured.
+	DefaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	// DefaultCORSHeaders are the request headers allowed cross-origin when none are configured.
+	DefaultCORSHeaders = []string{"Accept", "Content-Type", "If-None-Match", headerRequestID, headerClientID}
+	// DefaultCORSExposedHeaders are the response headers browsers may read when none are configured.
+	DefaultCORSExposedHeaders = []string{headerRequestID, "X-Cache", "X-Page", "X-Limit", "X-Total", "X-Total-Pages", "X-Next-Cursor", "Link"}
+)
+
+// CORSConfig configures the CORS middleware. Empty lists and a zero MaxAge use the defaults above.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed to call the API, e.g. "https://app.example.com";
+	// "*" allows any origin.
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           time.Duration
+	AllowCredentials bool
+}
+
+// CORS answers cross-origin requests from the configured origins. Preflight requests (OPTIONS
+// with Access-Control-Request-Method) are answered with 204 and not routed further; a preflight
+// from an origin that is not allowed gets 403. The allowed origin is echoed back, and "*" is only
+// sent for a wildcard configuration without credentials, which browsers reject otherwise.
+func CORS(cfg CORSConfig) gin.HandlerFunc {
+	methods := strings.Join(orDefault(cfg.AllowedMethods, DefaultCORSMethods), ", ")
+	headers := strings.Join(orDefault(cfg.AllowedHeaders, DefaultCORSHeaders), ", ")
+	exposed := strings.Join(orDefault(cfg.ExposedHeaders, DefaultCORSExposedHeaders), ", ")
+	maxAge := cfg.MaxAge
+	if maxAge <= 0 {
+		maxAge = DefaultCORSMaxAge
+	}
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		h := c.Writer.Header()
+		h.Add("Vary", "Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+		if !anyOrigin && !slices.Contains(cfg.AllowedOrigins, origin) {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+		if anyOrigin && !cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", exposed)
+			c.Next()
+			return
+		}
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Allow-Headers", headers)
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(maxAge.Seconds())))
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// orDefault returns values, or def when values is empty.
+func orDefault(values, def []string) []string {
+	if len(values) == 0 {
+		return def
+	}
+	return values
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultGzipLevel balances compression speed and ratio.
+const DefaultGzipLevel = 6
+
+// DefaultGzipContentTypes are the response media types compressed when no allow-list is configured.
+var DefaultGzipContentTypes = []string{"application/json", "text/*", "application/yaml", "application/x-yaml"}
+
+// Gzip compresses responses for clients sending Accept-Encoding: gzip, but only when the
+// response Content-Type matches one of contentTypes ("type/subtype" or "type/*"); other
+// responses, e.g. already-compressed binaries, pass through untouched. Levels outside
+// 1-9 use DefaultGzipLevel and an empty allow-list uses DefaultGzipContentTypes.
+func Gzip(level int, contentTypes []string) gin.HandlerFunc {
+	if level < gzip.BestSpeed || level > gzip.BestCompression {
+		level = DefaultGzipLevel
+	}
+	if len(contentTypes) == 0 {
+		contentTypes = DefaultGzipContentTypes
+	}
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+		w := &gzipWriter{ResponseWriter: c.Writer, level: level, contentTypes: contentTypes}
+		c.Writer = w
+		defer w.close()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			q := strings.ReplaceAll(params, " ", "")
+			return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+		}
+	}
+	return false
+}
+
+// gzipWriter decides on the first body write, once handlers have set Content-Type,
+// whether to compress the response.
+type gzipWriter struct {
+	gin.ResponseWriter
+	level        int
+	contentTypes []string
+	decided      bool
+	gz           *gzip.Writer
+}
+
+func (w *gzipWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || !w.allowed(h.Get("Content-Type")) {
+		return
+	}
+	gz, err := gzip.NewWriterLevel(w.ResponseWriter, w.level)
+	if err != nil {
+		return
+	}
+	w.gz = gz
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+}
+
+func (w *gzipWriter) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range w.contentTypes {
+		if t == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	w.decide()
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+func (w *gzipWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush pushes buffered compressed bytes to the client, e.g. for streamed responses.
+func (w *gzipWriter) Flush() {
+	if w.gz != nil {
+		_ = w.gz.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *gzipWriter) close() {
+	if w.gz != nil {
+		_ = w.gz.Close()
+	}
+}
+
+var _ http.Flusher = (*gzipWriter)(nil)
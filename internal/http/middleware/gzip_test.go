@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func gzipRouter(level int, types []string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Gzip(level, types))
+	r.GET("/json", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"content": strings.Repeat("bonsai ", 200)}) })
+	r.GET("/bin", func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/zip", []byte("PK\x03\x04 already compressed"))
+	})
+	return r
+}
+
+func TestGzip_CompressesAllowedTypes(t *testing.T) {
+	r := gzipRouter(0, nil)
+	req := httptest.NewRequest(http.MethodGet, "/json", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("want gzip encoding, got %q", w.Header().Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	body, _ := io.ReadAll(zr)
+	if !strings.Contains(string(body), "bonsai bonsai") {
+		t.Fatalf("unexpected decompressed body %q", body)
+	}
+}
+
+func TestGzip_LevelApplied(t *testing.T) {
+	// gzip records BestSpeed as XFL=4 and BestCompression as XFL=2 in byte 8 of its header
+	for level, xfl := range map[int]byte{gzip.BestSpeed: 4, gzip.BestCompression: 2} {
+		req := httptest.NewRequest(http.MethodGet, "/json", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		w := httptest.NewRecorder()
+		gzipRouter(level, nil).ServeHTTP(w, req)
+		if b := w.Body.Bytes(); len(b) < 10 || b[8] != xfl {
+			t.Fatalf("level %d: want XFL %d in gzip header", level, xfl)
+		}
+	}
+}
+
+func TestGzip_SkipsDisallowedTypes(t *testing.T) {
+	r := gzipRouter(0, []string{"application/json"})
+	req := httptest.NewRequest(http.MethodGet, "/bin", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Header().Get("Content-Encoding") != "" || w.Body.String() != "PK\x03\x04 already compressed" {
+		t.Fatalf("want uncompressed body, got encoding %q", w.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestGzip_NotAccepted(t *testing.T) {
+	for _, ae := range []string{"", "identity", "gzip;q=0"} {
+		req := httptest.NewRequest(http.MethodGet, "/json", nil)
+		req.Header.Set("Accept-Encoding", ae)
+		w := httptest.NewRecorder()
+		gzipRouter(0, nil).ServeHTTP(w, req)
+		if w.Header().Get("Content-Encoding") != "" {
+			t.Fatalf("%q: want no compression", ae)
+		}
+	}
+}
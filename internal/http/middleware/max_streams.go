@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxStreams caps how many requests through it may run at once, for long-lived streaming
+// responses. Requests over the cap get 503 too_many_streams; a slot is released when the
+// handler returns, i.e. when the stream ends. limit <= 0 disables the cap.
+func MaxStreams(limit int) gin.HandlerFunc {
+	if limit <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+	var active atomic.Int64
+	return func(c *gin.Context) {
+		if active.Add(1) > int64(limit) {
+			active.Add(-1)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": gin.H{"code": "too_many_streams", "message": "too many concurrent streams, retry later"}})
+			return
+		}
+		defer active.Add(-1)
+		c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxStreams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	const limit = 3
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := gin.New()
+	r.GET("/stream", MaxStreams(limit), func(c *gin.Context) {
+		started <- struct{}{}
+		<-release
+		c.Status(http.StatusOK)
+	})
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+		return w
+	}
+
+	// Saturate the cap with streams blocked in the handler
+	var wg sync.WaitGroup
+	codes := make(chan int, limit)
+	for range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- get().Code
+		}()
+		<-started
+	}
+
+	w := get()
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"code":"too_many_streams"`) {
+		t.Fatalf("want 503 too_many_streams over the cap, got %d %s", w.Code, w.Body.String())
+	}
+
+	close(release)
+	wg.Wait()
+	close(codes)
+	for code := range codes {
+		if code != http.StatusOK {
+			t.Fatalf("streams within the cap: want 200, got %d", code)
+		}
+	}
+
+	// Ended streams free their slots
+	go func() { <-started }()
+	if w := get(); w.Code != http.StatusOK {
+		t.Fatalf("want 200 once streams ended, got %d", w.Code)
+	}
+}
+
+func TestMaxStreams_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/stream", MaxStreams(0), func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stream", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200 without a cap, got %d", w.Code)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/roguepikachu/bonsai/internal/metrics"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping label cardinality bounded.
+const unmatchedRoute = "unmatched"
+
+// Metrics records request count, latency and in-flight requests labeled by method, route
+// template and status. Requests to the skip paths, e.g. the metrics endpoint itself, are not recorded.
+func Metrics(m *metrics.Metrics, skip ...string) gin.HandlerFunc {
+	skipped := make(map[string]bool, len(skip))
+	for _, p := range skip {
+		skipped[p] = true
+	}
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if skipped[route] {
+			c.Next()
+			return
+		}
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		inFlight := m.InFlight.WithLabelValues(method, route)
+		inFlight.Inc()
+		defer inFlight.Dec()
+		start := time.Now()
+		c.Next()
+		status := strconv.Itoa(c.Writer.Status())
+		m.Requests.WithLabelValues(method, route, status).Inc()
+		m.Duration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/roguepikachu/bonsai/internal/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := metrics.New(prometheus.NewRegistry())
+	r := gin.New()
+	r.Use(Metrics(m, "/metrics"))
+	r.GET("/v1/snippets/:id", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/metrics", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/v1/snippets/a", "/v1/snippets/b", "/nope", "/metrics"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(m.Requests.WithLabelValues("GET", "/v1/snippets/:id", "200")); got != 2 {
+		t.Fatalf("want 2 requests for the route template, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.Requests.WithLabelValues("GET", unmatchedRoute, "404")); got != 1 {
+		t.Fatalf("want 1 unmatched request, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.Requests); got != 2 {
+		t.Fatalf("skipped path must not be recorded, got %d series", got)
+	}
+	if got := testutil.CollectAndCount(m.Duration); got != 2 {
+		t.Fatalf("want 2 latency series, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.InFlight.WithLabelValues("GET", "/v1/snippets/:id")); got != 0 {
+		t.Fatalf("in-flight gauge must return to 0, got %v", got)
+	}
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roguepikachu/bonsai/internal/ratelimit"
+)
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), RateLimit(ratelimit.NewLocal(0.001, 2), "/health"))
+	r.GET("/v1/snippets", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	get := func(path, clientID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Anonymous requests share the remote IP's bucket
+	for range 2 {
+		if w := get("/v1/snippets", ""); w.Code != http.StatusOK {
+			t.Fatalf("within the burst: want 200, got %d", w.Code)
+		}
+	}
+	w := get("/v1/snippets", "")
+	if w.Code != http.StatusTooManyRequests || !strings.Contains(w.Body.String(), `"code":"rate_limited"`) {
+		t.Fatalf("want 429 rate_limited, got %d %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Fatalf("want a Retry-After header")
+	}
+	if w := get("/health", ""); w.Code != http.StatusOK {
+		t.Fatalf("exempt routes are never limited, got %d", w.Code)
+	}
+	if w := get("/v1/snippets", "alice"); w.Code != http.StatusOK {
+		t.Fatalf("want a separate bucket per X-Client-ID, got %d", w.Code)
+	}
+}
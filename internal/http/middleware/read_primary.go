@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+)
+
+// HeaderReadYourWrites asks for a request's database reads to skip the read replica, e.g. right
+// after a client wrote the snippet it is reading.
+const HeaderReadYourWrites = "X-Read-Your-Writes"
+
+// ReadYourWrites routes the database reads of requests carrying a true X-Read-Your-Writes header
+// to the primary, so they see writes a lagging replica has not applied yet.
+func ReadYourWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if on, err := strconv.ParseBool(c.GetHeader(HeaderReadYourWrites)); err == nil && on {
+			c.Request = c.Request.WithContext(ctxutil.WithReadPrimary(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+)
+
+func TestReadYourWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ReadYourWrites())
+	var primary bool
+	r.GET("/v1/snippets/:id", func(c *gin.Context) { primary = ctxutil.ReadPrimary(c.Request.Context()) })
+
+	for _, tt := range []struct {
+		header string
+		want   bool
+	}{{"", false}, {"1", true}, {"true", true}, {"0", false}, {"maybe", false}} {
+		req := httptest.NewRequest(http.MethodGet, "/v1/snippets/abc", nil)
+		if tt.header != "" {
+			req.Header.Set(HeaderReadYourWrites, tt.header)
+		}
+		r.ServeHTTP(httptest.NewRecorder(), req)
+		if primary != tt.want {
+			t.Fatalf("header %q: want read primary %v, got %v", tt.header, tt.want, primary)
+		}
+	}
+}
@@ -3,17 +3,58 @@ package middleware
 
 import (
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
-// RequestLogger logs each HTTP request with useful context for debugging.
-func RequestLogger() gin.HandlerFunc {
+// requestLoggerOptions holds optional RequestLogger behaviour.
+type requestLoggerOptions struct {
+	probeEvery int
+	probePaths map[string]bool
+	probes     atomic.Uint64
+}
+
+// skipProbe reports whether a successful probe request goes unlogged under the sampling rate.
+func (o *requestLoggerOptions) skipProbe() bool {
+	switch {
+	case o.probeEvery == 0 || o.probeEvery == 1:
+		return false
+	case o.probeEvery < 0:
+		return true
+	}
+	return (o.probes.Add(1)-1)%uint64(o.probeEvery) != 0
+}
+
+// RequestLoggerOption configures RequestLogger.
+type RequestLoggerOption func(*requestLoggerOptions)
+
+// WithProbeSampling logs only one in every n successful requests to paths, typically health
+// checks and probes polled every few seconds. n <= 1 logs them all; a negative n skips them.
+// Failed requests to paths are always logged.
+func WithProbeSampling(n int, paths ...string) RequestLoggerOption {
+	return func(o *requestLoggerOptions) {
+		o.probeEvery = n
+		o.probePaths = make(map[string]bool, len(paths))
+		for _, p := range paths {
+			o.probePaths[p] = true
+		}
+	}
+}
+
+// RequestLogger logs each HTTP request with useful context for debugging. The status and
+// size are read from the response writer once the handler chain has run.
+func RequestLogger(opts ...RequestLoggerOption) gin.HandlerFunc {
+	o := &requestLoggerOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
+		probe := o.probePaths[path]
 		raw := c.Request.URL.RawQuery
 		if raw != "" {
 			path = path + "?" + raw
@@ -24,6 +65,9 @@ func RequestLogger() gin.HandlerFunc {
 
 		latency := time.Since(start)
 		status := c.Writer.Status()
+		if probe && status < 400 && o.skipProbe() {
+			return
+		}
 		size := c.Writer.Size()
 		if size < 0 {
 			size = 0
@@ -10,8 +10,89 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
+func TestRequestLogger_Fields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hook := logtest.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), RequestLogger())
+	r.GET("/v1/snippets/:id", func(c *gin.Context) { c.String(http.StatusNotFound, "missing") })
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/snippets/abc?x=1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Client-ID", "alice")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := hook.AllEntries()
+	if len(entries) != 1 {
+		t.Fatalf("want one access log line, got %d", len(entries))
+	}
+	e := entries[0]
+	want := map[string]any{
+		"method":    http.MethodGet,
+		"path":      "/v1/snippets/abc?x=1",
+		"route":     "/v1/snippets/:id",
+		"status":    http.StatusNotFound,
+		"bytes":     len("missing"),
+		"requestId": "req-1",
+		"clientId":  "alice",
+	}
+	for k, v := range want {
+		if e.Data[k] != v {
+			t.Fatalf("field %s: want %v, got %v", k, v, e.Data[k])
+		}
+	}
+	if _, ok := e.Data["latency_ms"]; !ok {
+		t.Fatal("want latency_ms")
+	}
+	if e.Level != logrus.WarnLevel {
+		t.Fatalf("want 4xx logged at warn, got %v", e.Level)
+	}
+}
+
+func TestRequestLogger_ProbeSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hook := logtest.NewGlobal()
+	defer logrus.StandardLogger().ReplaceHooks(make(logrus.LevelHooks))
+	count := func(n int, paths ...string) int {
+		hook.Reset()
+		r := gin.New()
+		r.Use(RequestLogger(WithProbeSampling(n, "/livez")))
+		r.GET("/livez", func(c *gin.Context) { c.Status(http.StatusOK) })
+		r.GET("/v1/snippets", func(c *gin.Context) { c.Status(http.StatusOK) })
+		for _, p := range paths {
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+		}
+		return len(hook.AllEntries())
+	}
+	livez := []string{"/livez", "/livez", "/livez", "/livez", "/livez"}
+
+	if got := count(0, livez...); got != 5 {
+		t.Fatalf("0 logs every probe, got %d", got)
+	}
+	if got := count(2, livez...); got != 3 {
+		t.Fatalf("want one in every 2 probes logged, got %d", got)
+	}
+	if got := count(-1, livez...); got != 0 {
+		t.Fatalf("negative skips probes, got %d", got)
+	}
+	if got := count(-1, "/v1/snippets", "/v1/snippets"); got != 2 {
+		t.Fatalf("other routes are always logged, got %d", got)
+	}
+	r := gin.New()
+	r.Use(RequestLogger(WithProbeSampling(-1, "/readyz")))
+	r.GET("/readyz", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	hook.Reset()
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if len(hook.AllEntries()) != 1 {
+		t.Fatal("failed probes are always logged")
+	}
+}
+
 func TestRequestLogger_OK(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
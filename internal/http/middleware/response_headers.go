@@ -0,0 +1,17 @@
+package middleware
+
+import "github.com/gin-gonic/gin"
+
+// ResponseHeaders stamps the given headers on every response. Headers already
+// present, or set later by handlers, take precedence.
+func ResponseHeaders(headers map[string]string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		h := c.Writer.Header()
+		for k, v := range headers {
+			if h.Get(k) == "" {
+				h.Set(k, v)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultMaxURILength is the default cap on the request URI (path plus query) in bytes.
+const DefaultMaxURILength = 8192
+
+// MaxURILength rejects requests whose request URI is longer than maxLength bytes with
+// 414 URI Too Long. A maxLength of 0 or less uses DefaultMaxURILength.
+func MaxURILength(maxLength int) gin.HandlerFunc {
+	if maxLength <= 0 {
+		maxLength = DefaultMaxURILength
+	}
+	return func(c *gin.Context) {
+		uri := c.Request.RequestURI
+		if uri == "" {
+			uri = c.Request.URL.RequestURI()
+		}
+		if len(uri) > maxLength {
+			c.AbortWithStatusJSON(http.StatusRequestURITooLong, gin.H{
+				"error": gin.H{"code": "uri_too_long", "message": "request URI too long", "details": gin.H{"max_length": maxLength}},
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMaxURILength(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxURILength(64))
+	r.GET("/v1/snippets/:id", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+
+	tests := []struct {
+		name string
+		uri  string
+		want int
+	}{
+		{"normal id", "/v1/snippets/abc123?preview=text", http.StatusOK},
+		{"long id", "/v1/snippets/" + strings.Repeat("a", 1000), http.StatusRequestURITooLong},
+		{"long query", "/v1/snippets/abc?q=" + strings.Repeat("x", 100), http.StatusRequestURITooLong},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.uri, nil))
+		if w.Code != tt.want {
+			t.Fatalf("%s: want %d, got %d", tt.name, tt.want, w.Code)
+		}
+		if tt.want == http.StatusRequestURITooLong && !strings.Contains(w.Body.String(), `"code":"uri_too_long"`) {
+			t.Fatalf("%s: want uri_too_long envelope, got %s", tt.name, w.Body.String())
+		}
+	}
+}
+
+func TestMaxURILength_DefaultIsGenerous(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(MaxURILength(0))
+	r.GET("/x", func(c *gin.Context) { c.Status(http.StatusOK) })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/x?q="+strings.Repeat("x", 4000), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("want 200 under default limit, got %d", w.Code)
+	}
+}
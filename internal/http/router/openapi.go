@@ -0,0 +1,40 @@
+package router
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec is the hand-maintained OpenAPI 3.0 description of the core routes. Keep it in
+// step with NewRouter; TestRouter_OpenAPIPathsMatchRoutes fails when they drift apart.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsPage renders openAPISpec with Swagger UI, loaded from a CDN.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Bonsai API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>window.ui = SwaggerUIBundle({url: "` + OpenAPIPath + `", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// serveOpenAPI serves the embedded OpenAPI document.
+func serveOpenAPI(c *gin.Context) {
+	c.Data(http.StatusOK, gin.MIMEJSON, openAPISpec)
+}
+
+// serveDocs serves the Swagger UI page.
+func serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPage))
+}
@@ -2,10 +2,15 @@
 package router
 
 import (
+	"net/http"
+	"time"
+
 	"github.com/gin-gonic/gin"
 
+	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/internal/http/handler"
 	"github.com/roguepikachu/bonsai/internal/http/middleware"
+	"github.com/roguepikachu/bonsai/internal/metrics"
 )
 
 const (
@@ -18,27 +23,192 @@ const (
 	LivenessPath = BasePath + "/livez"
 	// ReadinessPath checks dependencies and returns 200/503 accordingly.
 	ReadinessPath = BasePath + "/readyz"
+	// StartupPath returns 200 once one-time startup work has finished, 503 before.
+	StartupPath = BasePath + "/startupz"
+	// CacheStatsPath reports cache hits, misses and hit ratio since start. Admin only.
+	CacheStatsPath = BasePath + "/cache/stats"
+	// WorkerStatsPath reports running background workers per job type. Admin only.
+	WorkerStatsPath = BasePath + "/workers"
+	// CacheRefreshPath reloads one snippet from Postgres into the cache. Like every route
+	// under BasePath/admin/, its handler requires the admin token.
+	CacheRefreshPath = BasePath + "/admin/cache/refresh/:id"
+	// OpenAPIPath serves the OpenAPI 3.0 description of the API.
+	OpenAPIPath = BasePath + "/openapi.json"
+	// DocsPath serves Swagger UI for OpenAPIPath when config.Conf.APIDocs is set.
+	DocsPath = BasePath + "/docs"
+	// MetricsPath serves Prometheus metrics. It is outside BasePath by scraper convention.
+	MetricsPath = "/metrics"
 )
 
+// options collects optional routes and instrumentation before the router is built,
+// so that middleware they need is installed ahead of every route.
+type options struct {
+	routes    []func(*gin.Engine)
+	streaming []streamingRoute
+	metrics   *metrics.Metrics
+	limiter   middleware.RateLimiter
+}
+
+// streamingRoute is a long-lived streaming endpoint registered behind the stream cap.
+type streamingRoute struct {
+	method, path string
+	handler      gin.HandlerFunc
+}
+
+// Option registers optional routes or instrumentation on the router.
+type Option func(*options)
+
+// withRoutes registers extra routes after the core API routes.
+func withRoutes(register func(*gin.Engine)) Option {
+	return func(o *options) { o.routes = append(o.routes, register) }
+}
+
+// WithCacheStats exposes cache hit/miss counters at CacheStatsPath.
+func WithCacheStats(h *handler.CacheStatsHandler) Option {
+	return withRoutes(func(r *gin.Engine) { r.GET(CacheStatsPath, h.Stats) })
+}
+
+// WithWorkerStats exposes the background worker gauge at WorkerStatsPath.
+func WithWorkerStats(h *handler.WorkerStatsHandler) Option {
+	return withRoutes(func(r *gin.Engine) { r.GET(WorkerStatsPath, h.Stats) })
+}
+
+// WithCacheRefresh exposes the admin cache rehydration endpoint at CacheRefreshPath.
+func WithCacheRefresh(h *handler.CacheRefreshHandler) Option {
+	return withRoutes(func(r *gin.Engine) { r.POST(CacheRefreshPath, h.Refresh) })
+}
+
+// WithStreamingRoute registers a long-lived streaming endpoint, e.g. an export or feed. All
+// streaming routes share one cap of config.Conf.MaxStreamingConns concurrent responses.
+func WithStreamingRoute(method, path string, handler gin.HandlerFunc) Option {
+	return func(o *options) {
+		o.streaming = append(o.streaming, streamingRoute{method: method, path: path, handler: handler})
+	}
+}
+
+// WithRateLimit limits each client's requests with l. Health and probe endpoints are exempt.
+func WithRateLimit(l middleware.RateLimiter) Option {
+	return func(o *options) { o.limiter = l }
+}
+
+// WithMetrics records request metrics for every route except MetricsPath and serves m there.
+func WithMetrics(m *metrics.Metrics) Option {
+	return func(o *options) { o.metrics = m }
+}
+
+// supportedMediaTypes lists the response media types the API can produce.
+func supportedMediaTypes() []string {
+	types := []string{gin.MIMEJSON}
+	if v := config.Conf.JSONVendorContentType; v != "" {
+		types = append(types, v)
+	}
+	return types
+}
+
+// registerProbes adds the liveness, readiness and startup probes.
+func registerProbes(router *gin.Engine, healthHandler *handler.HealthHandler) {
+	router.GET(LivenessPath, healthHandler.Liveness)
+	router.GET(ReadinessPath, healthHandler.Readiness)
+	router.GET(StartupPath, healthHandler.Startup)
+}
+
+// NewStartupRouter returns a router serving only the health endpoints and probes, for use while
+// the server starts up. Every other route answers 503 until the full router replaces it.
+func NewStartupRouter(healthHandler *handler.HealthHandler) *gin.Engine {
+	router := gin.New()
+	router.Use(middleware.RequestIDMiddleware())
+	router.Use(middleware.Recovery())
+	router.GET(HealthPath, handler.Health)
+	registerProbes(router, healthHandler)
+	router.NoRoute(func(c *gin.Context) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": gin.H{"code": "starting", "message": "server is starting"}})
+	})
+	return router
+}
+
 // NewRouter initializes and returns the main Gin engine with all routes.
-func NewRouter(snippetHandler *handler.Handler, healthHandler *handler.HealthHandler) *gin.Engine {
+func NewRouter(snippetHandler *handler.Handler, healthHandler *handler.HealthHandler, opts ...Option) *gin.Engine {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
 	router := gin.New()
-	// Middlewares: request id, request logging, panic recovery
+	// Middlewares: request id, metrics, request logging, panic recovery
 	router.Use(middleware.RequestIDMiddleware())
-	router.Use(middleware.RequestLogger())
+	if o.metrics != nil {
+		// Ahead of Recovery so that panics are recorded as 500s
+		router.Use(middleware.Metrics(o.metrics, MetricsPath))
+		// Registered before the remaining middleware: promhttp negotiates its own format and
+		// compression, so StrictAccept and Gzip must not apply
+		router.GET(MetricsPath, gin.WrapH(o.metrics.Handler()))
+	}
+	router.Use(middleware.RequestLogger(middleware.WithProbeSampling(config.Conf.AccessLogProbeSample,
+		HealthPath, LivenessPath, ReadinessPath, StartupPath)))
 	router.Use(middleware.Recovery())
+	// Ahead of content negotiation so preflights are answered before StrictAccept sees them
+	if len(config.Conf.CORSAllowedOrigins) > 0 {
+		router.Use(middleware.CORS(middleware.CORSConfig{
+			AllowedOrigins:   config.Conf.CORSAllowedOrigins,
+			AllowedMethods:   config.Conf.CORSAllowedMethods,
+			AllowedHeaders:   config.Conf.CORSAllowedHeaders,
+			MaxAge:           time.Duration(config.Conf.CORSMaxAgeSeconds) * time.Second,
+			AllowCredentials: config.Conf.CORSAllowCredentials,
+		}))
+	}
+	// Limited 429s still carry CORS headers so browsers can read them
+	if o.limiter != nil {
+		router.Use(middleware.RateLimit(o.limiter, HealthPath, LivenessPath, ReadinessPath, StartupPath))
+	}
+	router.Use(middleware.MaxURILength(config.Conf.MaxURILength))
+	if len(config.Conf.ExtraResponseHeaders) > 0 {
+		router.Use(middleware.ResponseHeaders(config.Conf.ExtraResponseHeaders))
+	}
+	router.Use(middleware.ReadYourWrites())
+	if len(config.Conf.CacheExemptRoutes) > 0 {
+		router.Use(middleware.CacheExempt(config.Conf.CacheExemptRoutes...))
+	}
+	if config.Conf.Gzip {
+		router.Use(middleware.Gzip(config.Conf.GzipLevel, config.Conf.GzipContentTypes))
+	}
+	if config.Conf.StrictAccept {
+		router.Use(middleware.StrictAccept(supportedMediaTypes()...))
+	}
 	// Legacy health
 	router.GET(HealthPath, handler.Health)
 	// Kubernetes-style probes
 	if healthHandler != nil {
-		router.GET(LivenessPath, healthHandler.Liveness)
-		router.GET(ReadinessPath, healthHandler.Readiness)
+		registerProbes(router, healthHandler)
 	}
 
 	router.POST(BasePath+"/snippets", snippetHandler.Create)
 	router.GET(BasePath+"/snippets", snippetHandler.List)
+	router.GET(BasePath+"/snippets/mine", snippetHandler.Mine)
+	router.GET(BasePath+"/snippets/metadata", snippetHandler.Metadata)
+	router.GET(BasePath+"/snippets/count", snippetHandler.Count)
 	router.GET(BasePath+"/snippets/:id", snippetHandler.Get)
 	router.PUT(BasePath+"/snippets/:id", snippetHandler.Update)
+	router.PATCH(BasePath+"/snippets/:id", snippetHandler.Patch)
+	router.DELETE(BasePath+"/snippets/:id", snippetHandler.Delete)
+	router.POST(BasePath+"/snippets/:id/restore", snippetHandler.Restore)
+	router.GET(BasePath+"/snippets/:id/raw", snippetHandler.Raw)
+	router.GET(BasePath+"/snippets/:id/diff", snippetHandler.Diff)
+	router.POST(BasePath+"/snippets/import", snippetHandler.Import)
+	router.POST(BasePath+"/snippets/batch-get", snippetHandler.BatchGet)
+	router.GET(BasePath+"/tags", snippetHandler.Tags)
+	router.GET(OpenAPIPath, serveOpenAPI)
+	if config.Conf.APIDocs {
+		router.GET(DocsPath, serveDocs)
+	}
+
+	if len(o.streaming) > 0 {
+		streams := middleware.MaxStreams(config.Conf.MaxStreamingConns)
+		for _, s := range o.streaming {
+			router.Handle(s.method, s.path, streams, s.handler)
+		}
+	}
+	for _, register := range o.routes {
+		register(router)
+	}
 
 	return router
 }
@@ -7,13 +7,21 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/roguepikachu/bonsai/internal/config"
 	"github.com/roguepikachu/bonsai/internal/domain"
 	h "github.com/roguepikachu/bonsai/internal/http/handler"
+	"github.com/roguepikachu/bonsai/internal/metrics"
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/internal/repository/fake"
 	"github.com/roguepikachu/bonsai/internal/service"
+	"github.com/roguepikachu/bonsai/internal/worker"
 )
 
 // test service implementing handler.SnippetService
@@ -25,7 +33,7 @@ type testSvc struct {
 	createdSnippets  []domain.Snippet
 }
 
-func (t *testSvc) CreateSnippet(_ context.Context, content string, expiresIn int, tags []string) (domain.Snippet, error) {
+func (t *testSvc) CreateSnippet(_ context.Context, content string, expiresIn int, tags []string, _ ...service.SnippetOption) (domain.Snippet, error) {
 	if t.shouldFailCreate {
 		return domain.Snippet{}, service.ErrSnippetNotFound
 	}
@@ -46,7 +54,23 @@ func (t *testSvc) CreateSnippet(_ context.Context, content string, expiresIn int
 	return s, nil
 }
 
-func (t *testSvc) ListSnippets(_ context.Context, _ int, _ int, _ string) ([]domain.Snippet, error) {
+func (t *testSvc) ListSnippetsAfter(ctx context.Context, _ repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	return t.ListSnippets(ctx, 1, limit, tag, opts...)
+}
+
+func (t *testSvc) CountSnippets(_ context.Context, _ string, _ ...repository.ListOption) (int, error) {
+	return len(t.snippets), nil
+}
+
+func (t *testSvc) TagFacets(_ context.Context, _ string, _ ...repository.ListOption) (service.TagFacets, error) {
+	return service.TagFacets{}, nil
+}
+
+func (t *testSvc) SnippetMetadataByTag(_ context.Context, _ string) ([]domain.Snippet, bool, error) {
+	return nil, false, nil
+}
+
+func (t *testSvc) ListSnippets(_ context.Context, _ int, _ int, _ string, _ ...repository.ListOption) ([]domain.Snippet, error) {
 	if t.shouldFailList {
 		return nil, service.ErrSnippetNotFound
 	}
@@ -60,6 +84,22 @@ func (t *testSvc) ListSnippets(_ context.Context, _ int, _ int, _ string) ([]dom
 	return result, nil
 }
 
+func (t *testSvc) RecordView(_ context.Context, _ string) int64 { return 0 }
+
+func (t *testSvc) ListTags(_ context.Context, _ string) ([]repository.TagCount, error) {
+	return nil, nil
+}
+
+func (t *testSvc) GetSnippetsByIDs(_ context.Context, ids []string) ([]domain.Snippet, error) {
+	var found []domain.Snippet
+	for _, id := range ids {
+		if s, ok := t.snippets[id]; ok {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
 func (t *testSvc) GetSnippetByID(_ context.Context, id string) (domain.Snippet, service.SnippetMeta, error) {
 	if t.shouldFailGet {
 		return domain.Snippet{}, service.SnippetMeta{CacheStatus: service.CacheMiss}, service.ErrSnippetNotFound
@@ -72,7 +112,22 @@ func (t *testSvc) GetSnippetByID(_ context.Context, id string) (domain.Snippet,
 	return domain.Snippet{}, service.SnippetMeta{CacheStatus: service.CacheMiss}, service.ErrSnippetNotFound
 }
 
-func (t *testSvc) UpdateSnippet(_ context.Context, id string, content string, expiresIn int, tags []string) (domain.Snippet, error) {
+func (t *testSvc) PatchSnippet(_ context.Context, id string, patch service.SnippetPatch, _ ...service.SnippetOption) (domain.Snippet, error) {
+	existing, ok := t.snippets[id]
+	if !ok {
+		return domain.Snippet{}, service.ErrSnippetNotFound
+	}
+	if patch.Content != nil {
+		existing.Content = *patch.Content
+	}
+	if patch.Tags != nil {
+		existing.Tags = *patch.Tags
+	}
+	t.snippets[id] = existing
+	return existing, nil
+}
+
+func (t *testSvc) UpdateSnippet(_ context.Context, id string, content string, expiresIn int, tags []string, _ ...service.SnippetOption) (domain.Snippet, error) {
 	if t.snippets == nil {
 		return domain.Snippet{}, service.ErrSnippetNotFound
 	}
@@ -95,6 +150,26 @@ func (t *testSvc) UpdateSnippet(_ context.Context, id string, content string, ex
 	return existing, nil
 }
 
+func (t *testSvc) ImportSnippet(_ context.Context, _ service.ImportRecord) (domain.Snippet, error) {
+	return domain.Snippet{}, nil
+}
+
+func (t *testSvc) DeleteSnippet(_ context.Context, id string) error {
+	if _, ok := t.snippets[id]; !ok {
+		return service.ErrSnippetNotFound
+	}
+	delete(t.snippets, id)
+	return nil
+}
+
+func (t *testSvc) RestoreSnippet(_ context.Context, _ string) (domain.Snippet, error) {
+	return domain.Snippet{}, service.ErrSnippetNotFound
+}
+
+func (t *testSvc) DiffVersions(_ context.Context, _ string, _, _ int) (service.SnippetDiff, error) {
+	return service.SnippetDiff{}, service.ErrVersionNotFound
+}
+
 func TestNewRouter_RoutesBasic(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	r := NewRouter(h.NewHandler(&testSvc{}), h.NewHealthHandler(nil, nil))
@@ -391,8 +466,8 @@ func TestRouter_HTTPMethods(t *testing.T) {
 		{"PATCH not allowed", http.MethodPatch, "/v1/snippets", http.StatusNotFound},
 		{"GET snippet by ID", http.MethodGet, "/v1/snippets/test", http.StatusNotFound},
 		{"POST on ID not allowed", http.MethodPost, "/v1/snippets/test", http.StatusNotFound},
-		{"PUT on ID allowed", http.MethodPut, "/v1/snippets/test", http.StatusBadRequest}, // Will return 400 because of missing body
-		{"DELETE on ID not allowed", http.MethodDelete, "/v1/snippets/test", http.StatusNotFound},
+		{"PUT on ID allowed", http.MethodPut, "/v1/snippets/test", http.StatusBadRequest},            // Will return 400 because of missing body
+		{"DELETE on ID is admin only", http.MethodDelete, "/v1/snippets/test", http.StatusForbidden}, // no ADMIN_TOKEN configured
 	}
 
 	for _, tt := range tests {
@@ -507,3 +582,427 @@ func TestRouter_Panic(t *testing.T) {
 		}
 	}
 }
+
+func TestRouter_MineReturnsOnlyCallerSnippets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewService(fake.NewSnippetRepository(), service.RealClock{})
+	r := NewRouter(h.NewHandler(svc), nil)
+
+	create := func(clientID, content string) {
+		t.Helper()
+		body := `{"content":"` + content + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/v1/snippets", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Client-ID", clientID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create want 201, got %d", w.Code)
+		}
+	}
+	mine := func(clientID string) []string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/v1/snippets/mine", nil)
+		req.Header.Set("X-Client-ID", clientID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("mine want 200, got %d", w.Code)
+		}
+		var resp domain.ListSnippetsResponseDTO
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		ids := make([]string, 0, len(resp.Items))
+		for _, it := range resp.Items {
+			ids = append(ids, it.ID)
+		}
+		return ids
+	}
+
+	create("alice", "a1")
+	create("alice", "a2")
+	create("bob", "b1")
+
+	if got := mine("alice"); len(got) != 2 {
+		t.Fatalf("alice want 2 snippets, got %d", len(got))
+	}
+	bobs := mine("bob")
+	if len(bobs) != 1 {
+		t.Fatalf("bob want 1 snippet, got %d", len(bobs))
+	}
+	for _, id := range mine("alice") {
+		if id == bobs[0] {
+			t.Fatalf("alice should not see bob's snippet %s", id)
+		}
+	}
+	if got := mine("carol"); len(got) != 0 {
+		t.Fatalf("carol want 0 snippets, got %d", len(got))
+	}
+
+	// The request ID middleware assigns anonymous callers a client ID, which owns nothing
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/snippets/mine", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("without X-Client-ID: want 400, got %d", w.Code)
+	}
+}
+
+func TestRouter_PrivateSnippetRequiresClientID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewService(fake.NewSnippetRepository(), service.RealClock{})
+	r := NewRouter(h.NewHandler(svc), nil)
+	create := func(clientID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/snippets", strings.NewReader(`{"content":"x","visibility":"private"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if clientID != "" {
+			req.Header.Set("X-Client-ID", clientID)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// The request ID middleware assigns a client ID, but a generated one cannot own a snippet
+	if w := create(""); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "X-Client-ID") {
+		t.Fatalf("without X-Client-ID: want 400, got %d %s", w.Code, w.Body.String())
+	}
+	if w := create("alice"); w.Code != http.StatusCreated {
+		t.Fatalf("with X-Client-ID: want 201, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRouter_MaxContentBytesAboveDefault(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	svc := service.NewServiceWithOptions(fake.NewSnippetRepository(), service.RealClock{}, service.WithMaxContentBytes(32*1024))
+	r := NewRouter(h.NewHandler(svc), nil)
+	send := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	large := strings.Repeat("a", 20*1024)
+
+	w := send(http.MethodPost, "/v1/snippets", `{"content":"`+large+`"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create above the default limit: want 201, got %d %s", w.Code, w.Body.String())
+	}
+	var created domain.SnippetResponseDTO
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if w := send(http.MethodPut, "/v1/snippets/"+created.ID, `{"content":"`+large+`b"}`); w.Code != http.StatusOK {
+		t.Fatalf("update: want 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodPatch, "/v1/snippets/"+created.ID, `{"content":"`+large+`c"}`); w.Code != http.StatusOK {
+		t.Fatalf("patch: want 200, got %d %s", w.Code, w.Body.String())
+	}
+	if w := send(http.MethodPost, "/v1/snippets/import", `{"snippets":[{"content":"`+large+`"}]}`); w.Code >= 300 {
+		t.Fatalf("import: want success, got %d %s", w.Code, w.Body.String())
+	}
+
+	// The service's byte limit still applies
+	w = send(http.MethodPost, "/v1/snippets", `{"content":"`+strings.Repeat("a", 33*1024)+`"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "content_too_large") {
+		t.Fatalf("above MaxContentBytes: want 400 content_too_large, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRouter_StrictAccept(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+
+	get := func(r *gin.Engine) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/snippets", nil)
+		req.Header.Set("Accept", "application/yaml")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Lenient (default): JSON is served regardless of Accept
+	config.Conf.StrictAccept = false
+	w := get(NewRouter(h.NewHandler(&testSvc{}), nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("lenient want 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("lenient want JSON content type, got %q", ct)
+	}
+
+	// Strict: unsupported Accept yields 406
+	config.Conf.StrictAccept = true
+	w = get(NewRouter(h.NewHandler(&testSvc{}), nil))
+	if w.Code != http.StatusNotAcceptable {
+		t.Fatalf("strict want 406, got %d", w.Code)
+	}
+}
+
+func TestRouter_ExtraResponseHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	config.Conf.ExtraResponseHeaders = map[string]string{"X-Bonsai-Region": "us-east-1"}
+
+	r := NewRouter(h.NewHandler(&testSvc{}), nil)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, HealthPath, nil))
+	if got := w.Header().Get("X-Bonsai-Region"); got != "us-east-1" {
+		t.Fatalf("want X-Bonsai-Region on health response, got %q", got)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("content type must be untouched, got %q", ct)
+	}
+}
+
+func TestRouter_CORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	const origin = "https://app.example.com"
+	do := func(r *gin.Engine, method, origin string, preflight bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/snippets", nil)
+		req.Header.Set("Origin", origin)
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "content-type,x-client-id")
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Disabled by default: no CORS headers and no preflight handling
+	w := do(NewRouter(h.NewHandler(&testSvc{}), nil), http.MethodOptions, origin, true)
+	if w.Code == http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("want CORS disabled, got %d %v", w.Code, w.Header())
+	}
+
+	config.Conf.CORSAllowedOrigins = []string{origin}
+	config.Conf.CORSAllowCredentials = true
+	config.Conf.CORSMaxAgeSeconds = 300
+	config.Conf.StrictAccept = true
+	r := NewRouter(h.NewHandler(&testSvc{}), nil)
+
+	w = do(r, http.MethodOptions, origin, true)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("preflight: want 204, got %d", w.Code)
+	}
+	for header, want := range map[string]string{
+		"Access-Control-Allow-Origin":      origin,
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Max-Age":           "300",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("preflight %s: want %q, got %q", header, want, got)
+		}
+	}
+	if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost) || !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "X-Client-ID") {
+		t.Errorf("preflight: want POST and X-Client-ID allowed, got %v", w.Header())
+	}
+
+	w = do(r, http.MethodGet, origin, false)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != origin || !strings.Contains(w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID") {
+		t.Fatalf("actual request: want 200 with CORS headers, got %d %v", w.Code, w.Header())
+	}
+	if w := do(r, http.MethodGet, "https://evil.example.com", false); w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("want no CORS headers for an unlisted origin, got %v", w.Header())
+	}
+	if w := do(r, http.MethodOptions, "https://evil.example.com", true); w.Code != http.StatusForbidden {
+		t.Fatalf("want 403 preflight for an unlisted origin, got %d", w.Code)
+	}
+
+	// A wildcard echoes the origin while credentials are allowed, and sends * otherwise
+	config.Conf.CORSAllowedOrigins = []string{"*"}
+	if got := do(NewRouter(h.NewHandler(&testSvc{}), nil), http.MethodGet, origin, false).Header().Get("Access-Control-Allow-Origin"); got != origin {
+		t.Fatalf("wildcard with credentials: want echoed origin, got %q", got)
+	}
+	config.Conf.CORSAllowCredentials = false
+	if got := do(NewRouter(h.NewHandler(&testSvc{}), nil), http.MethodGet, origin, false).Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Fatalf("wildcard without credentials: want *, got %q", got)
+	}
+}
+
+func TestRouter_Metrics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	prev := config.Conf
+	t.Cleanup(func() { config.Conf = prev })
+	// Scrapers send Accept: text/plain or OpenMetrics, which strict negotiation would reject
+	config.Conf.StrictAccept = true
+	m := metrics.New(prometheus.NewRegistry())
+	r := NewRouter(h.NewHandler(&testSvc{}), h.NewHealthHandler(nil, nil), WithMetrics(m))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v1/snippets", nil))
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, MetricsPath, nil)
+		req.Header.Set("Accept", "text/plain")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("metrics: want 200, got %d", w.Code)
+		}
+		if !strings.Contains(w.Body.String(), `bonsai_http_requests_total{method="GET",route="/v1/snippets",status="200"} 1`) {
+			t.Fatalf("metrics output missing list request counter:\n%s", w.Body.String())
+		}
+	}
+	if got := testutil.CollectAndCount(m.Requests); got != 1 {
+		t.Fatalf("scrapes must not count themselves, got %d series", got)
+	}
+}
+
+func TestRouter_OpenAPIPathsMatchRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := NewRouter(h.NewHandler(&testSvc{}), h.NewHealthHandler(nil, nil))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, OpenAPIPath, nil))
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("want 200 JSON, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	var spec struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("unmarshal spec: %v", err)
+	}
+	if !strings.HasPrefix(spec.OpenAPI, "3.0") {
+		t.Fatalf("want an OpenAPI 3.0 document, got %q", spec.OpenAPI)
+	}
+	documented := map[string]bool{}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			documented[strings.ToUpper(method)+" "+path] = true
+		}
+	}
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		// gin's :id is OpenAPI's {id}
+		segments := strings.Split(route.Path, "/")
+		for i, s := range segments {
+			if name, ok := strings.CutPrefix(s, ":"); ok {
+				segments[i] = "{" + name + "}"
+			}
+		}
+		registered[route.Method+" "+strings.Join(segments, "/")] = true
+	}
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("route %s is not documented", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("documented %s is not a route", route)
+		}
+	}
+}
+
+type adminStub struct{}
+
+func (adminStub) SnippetStats() (hits, misses uint64) { return 1, 1 }
+
+func (adminStub) ListStats() (hits, misses uint64) { return 1, 1 }
+
+func (adminStub) Refresh(_ context.Context, id string) (domain.Snippet, error) {
+	return domain.Snippet{ID: id, Content: "secret", CreatedAt: time.Now()}, nil
+}
+
+func (adminStub) Running() int { return 1 }
+
+func (adminStub) State() []worker.JobState {
+	return []worker.JobState{{Name: "purge", Running: 1, Limit: 1}}
+}
+
+func TestRouter_AdminRoutesRequireToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := config.Conf
+	t.Cleanup(func() { config.Conf = orig })
+	config.Conf.AdminToken = "secret"
+	r := NewRouter(h.NewHandler(&testSvc{}), h.NewHealthHandler(nil, nil),
+		WithCacheStats(h.NewCacheStatsHandler(adminStub{})),
+		WithWorkerStats(h.NewWorkerStatsHandler(adminStub{})),
+		WithCacheRefresh(h.NewCacheRefreshHandler(adminStub{})))
+
+	var checked int
+	for _, route := range r.Routes() {
+		if !strings.HasPrefix(route.Path, BasePath+"/admin/") && route.Path != CacheStatsPath && route.Path != WorkerStatsPath {
+			continue
+		}
+		segments := strings.Split(route.Path, "/")
+		for i, s := range segments {
+			if strings.HasPrefix(s, ":") {
+				segments[i] = "x"
+			}
+		}
+		path := strings.Join(segments, "/")
+		for _, auth := range []string{"", "Bearer wrong"} {
+			req := httptest.NewRequest(route.Method, path, nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("%s %s with auth %q: want 401, got %d", route.Method, path, auth, w.Code)
+			}
+		}
+		checked++
+	}
+	if checked < 3 {
+		t.Fatalf("want the admin routes registered, checked %d", checked)
+	}
+}
+
+func TestRouter_Docs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		NewRouter(h.NewHandler(&testSvc{}), nil).ServeHTTP(w, httptest.NewRequest(http.MethodGet, DocsPath, nil))
+		return w
+	}
+	if w := get(); w.Code != http.StatusNotFound {
+		t.Fatalf("docs off by default: want 404, got %d", w.Code)
+	}
+	config.Conf.APIDocs = true
+	t.Cleanup(func() { config.Conf.APIDocs = false })
+	w := get()
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), OpenAPIPath) {
+		t.Fatalf("want the Swagger UI page pointing at the spec, got %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestStartupRouter_ProbesBeforeFullRouter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var started atomic.Bool
+	hh := h.NewHealthHandler(nil, nil, h.WithStartupFlag(&started))
+	get := func(r *gin.Engine, path string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	boot := NewStartupRouter(hh)
+	for path, want := range map[string]int{
+		LivenessPath:       http.StatusOK,
+		ReadinessPath:      http.StatusServiceUnavailable,
+		HealthPath:         http.StatusOK,
+		StartupPath:        http.StatusServiceUnavailable,
+		"/v1/snippets/abc": http.StatusServiceUnavailable,
+	} {
+		if code := get(boot, path); code != want {
+			t.Fatalf("startup router %s: want %d, got %d", path, want, code)
+		}
+	}
+
+	started.Store(true)
+	full := NewRouter(h.NewHandler(&testSvc{}), hh)
+	for _, path := range []string{StartupPath, ReadinessPath} {
+		if code := get(full, path); code != http.StatusOK {
+			t.Fatalf("after startup %s: want 200, got %d", path, code)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+// Package langdetect guesses a snippet's language from its content with cheap heuristics.
+package langdetect
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// sniffBytes caps how much content the heuristics look at.
+const sniffBytes = 4096
+
+// rule marks content as language when every pattern matches.
+type rule struct {
+	language string
+	patterns []*regexp.Regexp
+}
+
+// rules are tried in order; the first full match wins. Earlier rules are more specific.
+var rules = []rule{
+	{"go", []*regexp.Regexp{regexp.MustCompile(`(?m)^package \w+\s*$`)}},
+	{"go", []*regexp.Regexp{regexp.MustCompile(`(?m)^func (\(\w+ \*?\w+\) )?\w+\(`), regexp.MustCompile(`:=`)}},
+	{"php", []*regexp.Regexp{regexp.MustCompile(`^<\?php`)}},
+	{"html", []*regexp.Regexp{regexp.MustCompile(`(?i)^\s*(<!doctype html|<html)`)}},
+	{"c", []*regexp.Regexp{regexp.MustCompile(`(?m)^#include\s*[<"]`)}},
+	{"python", []*regexp.Regexp{regexp.MustCompile(`(?m)^\s*def \w+\(.*\):\s*$`)}},
+	{"python", []*regexp.Regexp{regexp.MustCompile(`(?m)^(from [\w.]+ )?import \w+`), regexp.MustCompile(`(?m):\s*$`)}},
+	{"rust", []*regexp.Regexp{regexp.MustCompile(`(?m)^\s*(pub )?fn \w+`), regexp.MustCompile(`(let|->|::)`)}},
+	{"javascript", []*regexp.Regexp{regexp.MustCompile(`(?m)\b(function\s*\w*\(|const \w+ = |=> |require\(|console\.log\()`)}},
+	{"sql", []*regexp.Regexp{regexp.MustCompile(`(?is)^\s*(select .+ from |insert into |update \w+ set |create table )`)}},
+	{"markdown", []*regexp.Regexp{regexp.MustCompile("(?m)^(#{1,6} \\S|```)")}},
+}
+
+// shebangs maps interpreters named on a #! line to languages.
+var shebangs = map[string]string{
+	"sh":      "shell",
+	"bash":    "shell",
+	"zsh":     "shell",
+	"python":  "python",
+	"python3": "python",
+	"node":    "javascript",
+	"ruby":    "ruby",
+	"perl":    "perl",
+}
+
+// Detect returns the language content most likely is, or "" when no heuristic matches.
+// Results use the same names clients pass as language, e.g. "go" or "markdown".
+func Detect(content string) string {
+	if len(content) > sniffBytes {
+		content = content[:sniffBytes]
+	}
+	if strings.TrimSpace(content) == "" {
+		return ""
+	}
+	if lang := fromShebang(content); lang != "" {
+		return lang
+	}
+	if trimmed := strings.TrimSpace(content); (strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[")) && json.Valid([]byte(trimmed)) {
+		return "json"
+	}
+	for _, r := range rules {
+		if matchesAll(content, r.patterns) {
+			return r.language
+		}
+	}
+	return ""
+}
+
+func fromShebang(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content, "\n")
+	fields := strings.Fields(strings.TrimPrefix(line, "#!"))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := fields[0][strings.LastIndex(fields[0], "/")+1:]
+	// #!/usr/bin/env python3
+	if interp == "env" && len(fields) > 1 {
+		interp = fields[1]
+	}
+	return shebangs[interp]
+}
+
+func matchesAll(content string, patterns []*regexp.Regexp) bool {
+	for _, p := range patterns {
+		if !p.MatchString(content) {
+			return false
+		}
+	}
+	return true
+}
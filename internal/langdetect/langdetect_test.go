@@ -0,0 +1,29 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"go package", "package main\n\nfunc main() {}\n", "go"},
+		{"python def", "def add(a, b):\n    return a + b\n", "python"},
+		{"shebang env", "#!/usr/bin/env python3\nprint('hi')\n", "python"},
+		{"shebang bash", "#!/bin/bash\necho hi\n", "shell"},
+		{"json", `{"a": [1, 2]}`, "json"},
+		{"markdown", "# Title\n\nSome text.\n", "markdown"},
+		{"sql", "SELECT id FROM snippets WHERE id = 1;", "sql"},
+		{"javascript", "const x = () => 1;\nconsole.log(x());\n", "javascript"},
+		{"plain text", "just some notes\nnothing special\n", ""},
+		{"empty", "   \n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.content); got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+// Package metrics defines the Prometheus metrics Bonsai exports at /metrics.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the collectors recorded by the HTTP middleware and the cached repository.
+type Metrics struct {
+	gatherer prometheus.Gatherer
+	// Requests counts completed requests by method, route and status.
+	Requests *prometheus.CounterVec
+	// Duration observes request latency in seconds by method, route and status.
+	Duration *prometheus.HistogramVec
+	// InFlight gauges requests being served by method and route.
+	InFlight *prometheus.GaugeVec
+	// CacheHits and CacheMisses count cache lookups by kind: snippet (FindByID) or list
+	// (list pages, counts and tags).
+	CacheHits   *prometheus.CounterVec
+	CacheMisses *prometheus.CounterVec
+	// ShadowMismatches counts sampled cache hits whose Postgres value differed, by kind (snippet or list).
+	ShadowMismatches *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them with reg. Pass a fresh prometheus.NewRegistry()
+// in tests to read counter values in isolation.
+func New(reg *prometheus.Registry) *Metrics {
+	m := &Metrics{
+		gatherer: reg,
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bonsai_http_requests_total",
+			Help: "HTTP requests served, by method, route and status.",
+		}, []string{"method", "route", "status"}),
+		Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "bonsai_http_request_duration_seconds",
+			Help:    "HTTP request latency, by method, route and status.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		InFlight: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "bonsai_http_requests_in_flight",
+			Help: "HTTP requests currently being served, by method and route.",
+		}, []string{"method", "route"}),
+		CacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bonsai_cache_hits_total",
+			Help: "Cache lookups served from Redis, by kind.",
+		}, []string{"kind"}),
+		CacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bonsai_cache_misses_total",
+			Help: "Cache lookups that fell back to Postgres, by kind.",
+		}, []string{"kind"}),
+		ShadowMismatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "bonsai_cache_shadow_mismatches_total",
+			Help: "Shadow-read cache hits that disagreed with Postgres, by kind.",
+		}, []string{"kind"}),
+	}
+	reg.MustRegister(m.Requests, m.Duration, m.InFlight, m.CacheHits, m.CacheMisses, m.ShadowMismatches)
+	return m
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.gatherer, promhttp.HandlerOpts{})
+}
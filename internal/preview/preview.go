@@ -0,0 +1,46 @@
+// Package preview renders short snippet previews for UI cards.
+package preview
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+)
+
+// DefaultLines is the number of content lines included in a preview when no limit is configured.
+const DefaultLines = 10
+
+// Markdown is the language whose HTML preview is rendered rather than escaped.
+const Markdown = "markdown"
+
+// policy strips scripts, event handlers and unsafe URLs from rendered markdown.
+var policy = bluemonday.UGCPolicy()
+
+// Text returns the first n lines of content verbatim.
+func Text(content string, n int) string {
+	if n <= 0 {
+		n = DefaultLines
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if len(lines) <= n {
+		return content
+	}
+	return strings.Join(lines[:n], "")
+}
+
+// HTML returns the first n lines of content as sanitized HTML. Markdown is rendered when
+// renderMarkdown is set; everything else is escaped inside a <pre><code> block.
+func HTML(content, language string, n int, renderMarkdown bool) (string, error) {
+	head := Text(content, n)
+	if renderMarkdown && strings.EqualFold(language, Markdown) {
+		var buf bytes.Buffer
+		if err := goldmark.Convert([]byte(head), &buf); err != nil {
+			return "", err
+		}
+		return policy.Sanitize(buf.String()), nil
+	}
+	return "<pre><code>" + html.EscapeString(head) + "</code></pre>", nil
+}
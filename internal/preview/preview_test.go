@@ -0,0 +1,47 @@
+package preview
+
+import "testing"
+
+func TestText_FirstLines(t *testing.T) {
+	content := "one\ntwo\nthree\nfour\n"
+	if got := Text(content, 2); got != "one\ntwo\n" {
+		t.Fatalf("want first two lines, got %q", got)
+	}
+	if got := Text(content, 10); got != content {
+		t.Fatalf("want whole content, got %q", got)
+	}
+}
+
+func TestHTML_MarkdownSanitized(t *testing.T) {
+	content := "# Title\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))\n"
+	got, err := HTML(content, "markdown", 10, true)
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	want := "<h1>Title</h1>\n\n<p>x</p>\n"
+	if got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestHTML_CodeEscaped(t *testing.T) {
+	content := "if a < b {\n\treturn\n}\nfmt.Println()\n"
+	got, err := HTML(content, "go", 2, true)
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	want := "<pre><code>if a &lt; b {\n\treturn\n</code></pre>"
+	if got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestHTML_MarkdownDisabled(t *testing.T) {
+	got, err := HTML("# Title\n", "markdown", 10, false)
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	if got != "<pre><code># Title\n</code></pre>" {
+		t.Fatalf("want escaped source when markdown is disabled, got %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+// Package ratelimit implements token-bucket rate limiting, shared across replicas through Redis
+// with an in-process fallback.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter decides whether a request identified by key may proceed.
+type Limiter interface {
+	// Allow takes one token from key's bucket. When the bucket is empty it reports false and how
+	// long until a token is available.
+	Allow(ctx context.Context, key string) (bool, time.Duration, error)
+}
+
+// maxLocalKeys bounds how many buckets a Local limiter tracks before sweeping idle ones.
+const maxLocalKeys = 10000
+
+// Local is an in-process token-bucket Limiter. Buckets start full, hold at most burst tokens and
+// refill at rate tokens per second.
+type Local struct {
+	rate  float64
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLocal returns a Local limiter allowing rate requests per second with bursts of up to burst.
+// burst < 1 uses rate rounded up.
+func NewLocal(rate float64, burst int) *Local {
+	return &Local{rate: rate, burst: burstOrRate(rate, burst), now: time.Now, buckets: make(map[string]*bucket)}
+}
+
+// burstOrRate returns burst, or rate rounded up (at least 1) when burst is unset.
+func burstOrRate(rate float64, burst int) float64 {
+	if burst >= 1 {
+		return float64(burst)
+	}
+	return max(math.Ceil(rate), 1)
+}
+
+// Allow implements Limiter. It never returns an error.
+func (l *Local) Allow(_ context.Context, key string) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxLocalKeys {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	return false, l.wait(b.tokens), nil
+}
+
+// wait is how long a bucket holding tokens needs to refill to one token.
+func (l *Local) wait(tokens float64) time.Duration {
+	return time.Duration((1 - tokens) / l.rate * float64(time.Second))
+}
+
+// sweep drops buckets that have refilled completely, which behave like new ones.
+func (l *Local) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
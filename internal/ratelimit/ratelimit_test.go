@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLocal_TokenBucket(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	l := NewLocal(2, 3)
+	l.now = func() time.Time { return now }
+
+	for i := range 3 {
+		if ok, _, _ := l.Allow(ctx, "a"); !ok {
+			t.Fatalf("request %d within the burst was limited", i+1)
+		}
+	}
+	ok, wait, err := l.Allow(ctx, "a")
+	if ok || err != nil || wait != 500*time.Millisecond {
+		t.Fatalf("want limited with a 500ms wait, got %v %s %v", ok, wait, err)
+	}
+	if ok, _, _ := l.Allow(ctx, "b"); !ok {
+		t.Fatalf("buckets must be per key")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if ok, _, _ := l.Allow(ctx, "a"); !ok {
+		t.Fatalf("want a token refilled after 500ms at 2/s")
+	}
+	if ok, _, _ := l.Allow(ctx, "a"); ok {
+		t.Fatalf("want the refilled token used up")
+	}
+}
+
+func TestLocal_DefaultBurst(t *testing.T) {
+	l := NewLocal(1.5, 0)
+	if l.burst != 2 {
+		t.Fatalf("want burst rounded up from the rate, got %v", l.burst)
+	}
+}
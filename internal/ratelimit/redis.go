@@ -0,0 +1,81 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// keyPrefix prefixes the Redis hashes holding each client's bucket.
+const keyPrefix = "ratelimit:"
+
+// tokenBucketScript refills the bucket at KEYS[1] for the time passed since its last use and
+// takes a token if one is available. ARGV: rate (tokens/s), burst, now (ms). It returns 1 and 0
+// when allowed, or 0 and the milliseconds until the next token. Idle buckets expire once they
+// would have refilled completely.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(now - ts, 0) * rate / 1000)
+local allowed, wait = 0, 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate))
+return {allowed, wait}
+`)
+
+// Redis is a token-bucket Limiter whose buckets live in Redis, so every replica draws from the
+// same bucket per key. When Redis fails, requests are limited by an in-process bucket instead.
+type Redis struct {
+	client   *redis.Client
+	rate     float64
+	burst    float64
+	now      func() time.Time
+	fallback *Local
+}
+
+// NewRedis returns a Redis limiter allowing rate requests per second with bursts of up to burst.
+// burst < 1 uses rate rounded up.
+func NewRedis(client *redis.Client, rate float64, burst int) *Redis {
+	return &Redis{
+		client:   client,
+		rate:     rate,
+		burst:    burstOrRate(rate, burst),
+		now:      time.Now,
+		fallback: NewLocal(rate, burst),
+	}
+}
+
+// Allow implements Limiter. Redis errors are logged and the request is decided by the
+// in-process fallback, so an unavailable Redis neither blocks nor unlimits traffic.
+func (r *Redis) Allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	allowed, wait, err := r.allow(ctx, key)
+	if err != nil {
+		logger.WithField(ctx, "error", err.Error()).Warn("rate limit check failed; using in-process limiter")
+		return r.fallback.Allow(ctx, key)
+	}
+	return allowed, wait, nil
+}
+
+func (r *Redis) allow(ctx context.Context, key string) (bool, time.Duration, error) {
+	res, err := tokenBucketScript.Run(ctx, r.client, []string{keyPrefix + key}, r.rate, r.burst, r.now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return false, 0, err
+	}
+	if len(res) != 2 {
+		return false, 0, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+	return res[0] == 1, time.Duration(max(res[1], 0)) * time.Millisecond, nil
+}
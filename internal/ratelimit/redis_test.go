@@ -0,0 +1,62 @@
+//go:build integration
+
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRedis_SharedBucket(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	// Two replicas sharing one Redis draw from the same bucket
+	a := NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), 1, 2)
+	b := NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr()}), 1, 2)
+	a.now, b.now = clock, clock
+
+	for i, l := range []*Redis{a, b} {
+		if ok, _, err := l.Allow(ctx, "client:x"); !ok || err != nil {
+			t.Fatalf("request %d within the burst: got %v, %v", i+1, ok, err)
+		}
+	}
+	ok, wait, err := a.Allow(ctx, "client:x")
+	if ok || err != nil || wait != time.Second {
+		t.Fatalf("want limited with a 1s wait, got %v %s %v", ok, wait, err)
+	}
+	if ttl := mr.TTL(keyPrefix + "client:x"); ttl <= 0 || ttl > 2*time.Second {
+		t.Fatalf("want idle buckets to expire after a full refill, got ttl %s", ttl)
+	}
+
+	now = now.Add(time.Second)
+	if ok, _, _ := b.Allow(ctx, "client:x"); !ok {
+		t.Fatalf("want a token refilled after 1s")
+	}
+}
+
+func TestRedis_FallsBackWhenUnavailable(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	l := NewRedis(redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1}), 1, 1)
+	mr.Close()
+
+	if ok, _, err := l.Allow(ctx, "ip:1.2.3.4"); !ok || err != nil {
+		t.Fatalf("want the first request allowed by the fallback, got %v, %v", ok, err)
+	}
+	if ok, _, err := l.Allow(ctx, "ip:1.2.3.4"); ok || err != nil {
+		t.Fatalf("want the fallback to keep limiting, got %v, %v", ok, err)
+	}
+}
@@ -4,171 +4,792 @@ package cached
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand"
+	"net/url"
+	"slices"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/metrics"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 	"github.com/roguepikachu/bonsai/pkg/logger"
+	"golang.org/x/sync/singleflight"
 )
 
-// key helpers
-func keySnippet(id string) string { return "snippet:" + id }
-func keyList(page, limit int, tag string) string {
+// key helpers; every key starts with the configured prefix, see WithKeyPrefix. List pages,
+// counts and tag counts also carry the list cache epoch, see invalidateListKeys.
+func (r *SnippetRepository) keySnippet(id string) string { return r.keyPrefix + "snippet:" + id }
+func (r *SnippetRepository) keyList(epoch int64, page, limit int, tag string) string {
 	if tag != "" {
-		return fmt.Sprintf("snippets:p%d:l%d:t:%s", page, limit, tag)
+		return fmt.Sprintf("%ssnippets:e%d:p%d:l%d:t:%s", r.keyPrefix, epoch, page, limit, keyPart(tag))
 	}
-	return fmt.Sprintf("snippets:p%d:l%d", page, limit)
+	return fmt.Sprintf("%ssnippets:e%d:p%d:l%d", r.keyPrefix, epoch, page, limit)
 }
 
+// keyEpoch names the list cache epoch counter.
+func (r *SnippetRepository) keyEpoch() string { return r.keyPrefix + "snippets:epoch" }
+
+// scanPattern matches every key starting with prefix, escaping glob characters in it.
+func scanPattern(prefix string) string {
+	var b strings.Builder
+	for _, c := range prefix {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String() + "*"
+}
+
+// keyPart escapes a caller-supplied value for use in a key, so that separators inside it such
+// as ":o:" or "," cannot make two different filters share a cache entry.
+func keyPart(s string) string { return url.QueryEscape(s) }
+
+// keyParts escapes each value with keyPart and joins them with commas.
+func keyParts(values []string) string {
+	escaped := make([]string, len(values))
+	for i, v := range values {
+		escaped[i] = keyPart(v)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// keyListWithOptions extends keyList with any optional filters so that
+// differently-filtered pages never share a cache entry.
+func (r *SnippetRepository) keyListWithOptions(epoch int64, page, limit int, tag string, o repository.ListOptions) string {
+	k := r.keyList(epoch, page, limit, tag)
+	if o.OwnerID != "" {
+		k += ":o:" + keyPart(o.OwnerID)
+	}
+	if o.Query != "" {
+		k += ":q:" + keyPart(o.Query)
+	}
+	if o.Sort != "" {
+		k += ":s:" + keyPart(o.Sort)
+	}
+	if o.Source != "" {
+		k += ":src:" + keyPart(o.Source)
+	}
+	if !o.CreatedAfter.IsZero() {
+		k += fmt.Sprintf(":ca:%d", o.CreatedAfter.UnixNano())
+	}
+	if o.Content {
+		k += ":full"
+	}
+	return k + keyTagsSuffix(o)
+}
+
+// keyTagsSuffix encodes a multi-tag filter as its match mode and sorted tag set,
+// so ?tag=a&tag=b and ?tag=b&tag=a share an entry but all/any never do. Excluded tags
+// follow as their own sorted set.
+func keyTagsSuffix(o repository.ListOptions) string {
+	var k string
+	if len(o.Tags) > 0 {
+		match := repository.TagMatchAll
+		if o.MatchAny() {
+			match = repository.TagMatchAny
+		}
+		k = ":tags:" + match + ":" + keyParts(o.TagSet(""))
+	}
+	if len(o.ExcludeTags) > 0 {
+		k += ":xtags:" + keyParts(repository.ListOptions{Tags: o.ExcludeTags}.TagSet(""))
+	}
+	return k
+}
+
+// keyTags names the cached tag counts. It carries the epoch like list pages so that any write
+// clears it.
+func (r *SnippetRepository) keyTags(epoch int64) string {
+	return fmt.Sprintf("%ssnippets:e%d:tags", r.keyPrefix, epoch)
+}
+
+// tagsTTL bounds how stale cached tag counts get from expiries, which do not invalidate them.
+const tagsTTL = time.Minute
+
+// keyCount names the cached total for a tag and options. It carries the epoch like list pages
+// so that list invalidation clears it too.
+func (r *SnippetRepository) keyCount(epoch int64, tag string, o repository.ListOptions) string {
+	k := fmt.Sprintf("%ssnippets:e%d:count", r.keyPrefix, epoch)
+	if tag != "" {
+		k += ":t:" + keyPart(tag)
+	}
+	if o.OwnerID != "" {
+		k += ":o:" + keyPart(o.OwnerID)
+	}
+	if o.Query != "" {
+		k += ":q:" + keyPart(o.Query)
+	}
+	if o.Source != "" {
+		k += ":src:" + keyPart(o.Source)
+	}
+	if !o.CreatedAfter.IsZero() {
+		k += fmt.Sprintf(":ca:%d", o.CreatedAfter.UnixNano())
+	}
+	return k + keyTagsSuffix(o)
+}
+
+// WritePolicy controls whether writes populate the snippet cache.
+type WritePolicy int
+
+const (
+	// WriteDefault caches snippets on insert and invalidates them on update.
+	WriteDefault WritePolicy = iota
+	// WriteWarm caches snippets on both insert and update, so the first read is a hit.
+	WriteWarm
+	// WriteLazy never caches on write; the first read is a miss that fills the cache.
+	WriteLazy
+)
+
 // SnippetRepository is a cache-aside repository combining Redis with a primary store.
 type SnippetRepository struct {
 	primary repository.SnippetRepository
 	redis   *redis.Client
 	ttl     time.Duration
+	// maxTTL caps per-snippet cache TTL overrides; defaults to ttl.
+	maxTTL      time.Duration
+	writePolicy WritePolicy
+	// missCacheProbability is the chance a snippet read on a miss is written back to Redis.
+	missCacheProbability float64
+	// shadowFraction is the share of cache hits also read from primary to detect divergence.
+	shadowFraction float64
+	// rand returns a number in [0, 1) used to roll against missCacheProbability and shadowFraction.
+	rand func() float64
+	// pipelineBatchSize caps how many commands multi-key operations send per pipelined round-trip.
+	pipelineBatchSize int
+	// snippetHits and snippetMisses count FindByID and FindByIDs cache lookups since process start;
+	// listHits and listMisses count List, Count and ListTags lookups.
+	snippetHits   atomic.Uint64
+	snippetMisses atomic.Uint64
+	listHits      atomic.Uint64
+	listMisses    atomic.Uint64
+	// metrics, when set, mirrors hits and misses into Prometheus counters labelled by lookup kind.
+	metrics *metrics.Metrics
+	// flights coalesces concurrent misses for the same snippet or list key into one primary read.
+	flights singleflight.Group
+	// channel, when set, is the pub/sub channel writes are broadcast on; origin tags this instance's messages.
+	channel string
+	origin  string
+	// keyPrefix starts every Redis key, so environments sharing one Redis do not collide.
+	keyPrefix string
+	// warmPage is the list page Warm preloads.
+	warmPage warmListPage
+	// writeBehind, when set, queues the cache writes of inserts and updates for RunWriteBehind.
+	writeBehind *writeBehind
+}
+
+// Option configures the cached repository.
+type Option func(*SnippetRepository)
+
+// WithWritePolicy sets how inserts and updates populate the snippet cache.
+func WithWritePolicy(p WritePolicy) Option { return func(r *SnippetRepository) { r.writePolicy = p } }
+
+// WithMissCacheProbability writes a snippet read on a cache miss back to Redis only with probability p,
+// reducing write amplification when Redis is near capacity. p is clamped to [0, 1]; the default is 1.
+func WithMissCacheProbability(p float64) Option {
+	return func(r *SnippetRepository) { r.missCacheProbability = min(max(p, 0), 1) }
+}
+
+// WithMaxTTL caps per-snippet cache TTL overrides (Snippet.CacheTTLSeconds). Defaults to the repository TTL.
+func WithMaxTTL(d time.Duration) Option {
+	return func(r *SnippetRepository) {
+		if d > 0 {
+			r.maxTTL = d
+		}
+	}
+}
+
+// DefaultPipelineBatchSize is the number of commands sent per pipelined round-trip by default.
+const DefaultPipelineBatchSize = 100
+
+// WithPipelineBatchSize sets how many commands multi-key operations send per pipelined round-trip.
+// Values below 1 are ignored.
+func WithPipelineBatchSize(n int) Option {
+	return func(r *SnippetRepository) {
+		if n > 0 {
+			r.pipelineBatchSize = n
+		}
+	}
+}
+
+// WithKeyPrefix starts every cache key with prefix, e.g. "staging:" turns snippet:<id> into
+// staging:snippet:<id>, so environments can share one Redis. The default is no prefix.
+func WithKeyPrefix(prefix string) Option { return func(r *SnippetRepository) { r.keyPrefix = prefix } }
+
+// WithMetrics mirrors cache hits and misses into m's Prometheus counters.
+func WithMetrics(m *metrics.Metrics) Option { return func(r *SnippetRepository) { r.metrics = m } }
+
+// WithRand overrides the source of the miss-caching roll, for deterministic tests.
+func WithRand(fn func() float64) Option {
+	return func(r *SnippetRepository) {
+		if fn != nil {
+			r.rand = fn
+		}
+	}
 }
 
 // NewSnippetRepository creates a new cached repository.
-func NewSnippetRepository(primary repository.SnippetRepository, redis *redis.Client, ttl time.Duration) *SnippetRepository {
-	return &SnippetRepository{primary: primary, redis: redis, ttl: ttl}
+func NewSnippetRepository(primary repository.SnippetRepository, redis *redis.Client, ttl time.Duration, opts ...Option) *SnippetRepository {
+	r := &SnippetRepository{primary: primary, redis: redis, ttl: ttl, maxTTL: ttl, missCacheProbability: 1, rand: rand.Float64, pipelineBatchSize: DefaultPipelineBatchSize}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-// Insert writes through to primary and populates cache.
-func (r *SnippetRepository) Insert(ctx context.Context, s domain.Snippet) error {
-	if err := r.primary.Insert(ctx, s); err != nil {
-		return err
+// Lookup kinds counted by recordHit and recordMiss; they label the Prometheus cache counters.
+const (
+	lookupSnippet = "snippet"
+	lookupList    = "list"
+)
+
+// Stats returns the number of cache hits and misses since start, snippet and list lookups combined.
+func (r *SnippetRepository) Stats() (hits, misses uint64) {
+	return r.snippetHits.Load() + r.listHits.Load(), r.snippetMisses.Load() + r.listMisses.Load()
+}
+
+// SnippetStats returns the cache hits and misses of FindByID and FindByIDs since start.
+func (r *SnippetRepository) SnippetStats() (hits, misses uint64) {
+	return r.snippetHits.Load(), r.snippetMisses.Load()
+}
+
+// ListStats returns the cache hits and misses of List, Count and ListTags since start.
+func (r *SnippetRepository) ListStats() (hits, misses uint64) {
+	return r.listHits.Load(), r.listMisses.Load()
+}
+
+// recordHit counts a cache lookup of the given kind served from Redis.
+func (r *SnippetRepository) recordHit(kind string) {
+	if kind == lookupSnippet {
+		r.snippetHits.Add(1)
+	} else {
+		r.listHits.Add(1)
 	}
-	// cache the snippet
-	data, _ := json.Marshal(s)
+	if r.metrics != nil {
+		r.metrics.CacheHits.WithLabelValues(kind).Inc()
+	}
+}
+
+// recordMiss counts a cache lookup of the given kind that fell back to primary.
+func (r *SnippetRepository) recordMiss(kind string) {
+	if kind == lookupSnippet {
+		r.snippetMisses.Add(1)
+	} else {
+		r.listMisses.Add(1)
+	}
+	if r.metrics != nil {
+		r.metrics.CacheMisses.WithLabelValues(kind).Inc()
+	}
+}
+
+// snippetTTL returns the cache TTL for s: its own override capped at maxTTL, or the repository TTL,
+// never outliving the snippet's own expiry.
+func (r *SnippetRepository) snippetTTL(s domain.Snippet) time.Duration {
 	exp := r.ttl
+	if s.CacheTTLSeconds > 0 {
+		exp = time.Duration(s.CacheTTLSeconds) * time.Second
+		if r.maxTTL > 0 && exp > r.maxTTL {
+			exp = r.maxTTL
+		}
+	}
 	if !s.ExpiresAt.IsZero() {
 		if until := time.Until(s.ExpiresAt); until > 0 && (exp == 0 || until < exp) {
 			exp = until
 		}
 	}
-	if err := r.redis.Set(ctx, keySnippet(s.ID), data, exp).Err(); err != nil {
+	return exp
+}
+
+// shared runs fn once per key across concurrent callers and hands every caller its result,
+// errors included. The shared call is detached from the first caller's cancellation so one
+// aborted request cannot fail the others; each caller still stops waiting when its own ctx ends.
+func (r *SnippetRepository) shared(ctx context.Context, key string, fn func(context.Context) (any, error)) (any, error) {
+	ch := r.flights.DoChan(key, func() (any, error) { return fn(context.WithoutCancel(ctx)) })
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// cacheSnippet stores s in Redis best-effort. A snippet that already expired is not cached, so
+// the cache cannot serve it after its expiry.
+func (r *SnippetRepository) cacheSnippet(ctx context.Context, s domain.Snippet) {
+	if !s.ExpiresAt.IsZero() && !time.Now().Before(s.ExpiresAt) {
+		logger.WithField(ctx, "id", s.ID).Debug("skipped caching expired snippet")
+		return
+	}
+	data, _ := json.Marshal(s)
+	exp := r.snippetTTL(s)
+	if err := r.redis.Set(ctx, r.keySnippet(s.ID), data, exp).Err(); err != nil {
 		logger.With(ctx, map[string]any{"id": s.ID, "ttl": exp.String()}).Warn("failed to set snippet in cache")
 	} else {
-		logger.With(ctx, map[string]any{"id": s.ID, "ttl": exp.String()}).Debug("cached snippet after insert")
+		logger.With(ctx, map[string]any{"id": s.ID, "ttl": exp.String()}).Debug("cached snippet")
+	}
+}
+
+// evictSnippet removes the cached snippet best-effort, cancelling any queued write of it.
+func (r *SnippetRepository) evictSnippet(ctx context.Context, id string) {
+	r.cancelPending(id)
+	if err := r.redis.Del(ctx, r.keySnippet(id)).Err(); err != nil {
+		logger.With(ctx, map[string]any{"id": id}).Warn("failed to delete snippet from cache")
+	} else {
+		logger.With(ctx, map[string]any{"id": id}).Debug("invalidated cached snippet")
+	}
+}
+
+// Insert writes through to primary and populates cache unless the write policy is lazy; under
+// WithWriteBehind the cache write is queued.
+// A failed insert, e.g. repository.ErrDuplicateID, leaves the cache untouched.
+func (r *SnippetRepository) Insert(ctx context.Context, s domain.Snippet) error {
+	if err := r.primary.Insert(ctx, s); err != nil {
+		return err
+	}
+	if r.writePolicy != WriteLazy {
+		r.populate(ctx, s)
 	}
 	// bust list caches best-effort
 	if err := r.invalidateListKeys(ctx); err != nil {
 		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
 	}
+	r.publishInvalidation(ctx, s.ID, false)
 	return nil
 }
 
 // FindByID attempts Redis then falls back to primary.
 func (r *SnippetRepository) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
-	val, err := r.redis.Get(ctx, keySnippet(id)).Result()
+	s, _, err := r.FindByIDCached(ctx, id)
+	return s, err
+}
+
+// FindByIDCached is FindByID that also reports whether the snippet was served from Redis.
+func (r *SnippetRepository) FindByIDCached(ctx context.Context, id string) (domain.Snippet, bool, error) {
+	if ctxutil.CacheBypass(ctx) {
+		s, err := r.primary.FindByID(ctx, id)
+		return s, false, err
+	}
+	val, err := r.redis.Get(ctx, r.keySnippet(id)).Result()
 	if err == nil && val != "" {
 		var s domain.Snippet
 		if jsonErr := json.Unmarshal([]byte(val), &s); jsonErr == nil {
 			logger.WithField(ctx, "id", id).Debug("cache hit: snippet")
-			return s, nil
+			r.recordHit(lookupSnippet)
+			if r.sampleShadow() {
+				r.shadowSnippet(ctx, s)
+			}
+			return s, true, nil
 		}
 	}
 	logger.WithField(ctx, "id", id).Debug("cache miss: snippet")
-	s, err := r.primary.FindByID(ctx, id)
+	r.recordMiss(lookupSnippet)
+	// Concurrent misses share one primary read; errors such as repository.ErrNotFound reach
+	// every waiter and nothing is cached for them.
+	v, err := r.shared(ctx, r.keySnippet(id), func(ctx context.Context) (any, error) {
+		s, err := r.primary.FindByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		r.cacheOnMiss(ctx, s)
+		return s, nil
+	})
 	if err != nil {
-		return domain.Snippet{}, err
+		return domain.Snippet{}, false, err
 	}
-	data, _ := json.Marshal(s)
-	exp := r.ttl
-	if !s.ExpiresAt.IsZero() {
-		if until := time.Until(s.ExpiresAt); until > 0 && (exp == 0 || until < exp) {
-			exp = until
+	s := v.(domain.Snippet)
+	s.Tags = slices.Clone(s.Tags)
+	return s, false, nil
+}
+
+// cacheOnMiss writes a snippet read from primary back to Redis, subject to missCacheProbability.
+func (r *SnippetRepository) cacheOnMiss(ctx context.Context, s domain.Snippet) {
+	if r.missCacheProbability >= 1 || r.rand() < r.missCacheProbability {
+		r.cacheSnippet(ctx, s)
+	} else {
+		logger.WithField(ctx, "id", s.ID).Debug("skipped caching snippet on miss")
+	}
+}
+
+// FindByIDs serves cached snippets with a single MGET and loads the rest from primary in one
+// call, caching what it loads. If Redis fails every ID is loaded from primary.
+func (r *SnippetRepository) FindByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error) {
+	if len(ids) == 0 || ctxutil.CacheBypass(ctx) {
+		return r.primary.FindByIDs(ctx, ids)
+	}
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.keySnippet(id)
+	}
+	vals, err := r.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		logger.With(ctx, map[string]any{"ids": len(ids), "error": err.Error()}).Warn("failed to read snippets from cache")
+		vals = nil
+	}
+	found := make([]domain.Snippet, 0, len(ids))
+	var missing []string
+	for i, id := range ids {
+		if i < len(vals) {
+			if val, ok := vals[i].(string); ok {
+				var s domain.Snippet
+				if jsonErr := json.Unmarshal([]byte(val), &s); jsonErr == nil {
+					r.recordHit(lookupSnippet)
+					found = append(found, s)
+					continue
+				}
+			}
 		}
+		r.recordMiss(lookupSnippet)
+		missing = append(missing, id)
 	}
-	if err := r.redis.Set(ctx, keySnippet(s.ID), data, exp).Err(); err != nil {
-		logger.With(ctx, map[string]any{"id": s.ID, "ttl": exp.String()}).Warn("failed to set snippet in cache")
+	logger.With(ctx, map[string]any{"hits": len(found), "misses": len(missing)}).Debug("batch cache lookup")
+	if len(missing) == 0 {
+		return found, nil
 	}
-	return s, nil
+	loaded, err := r.primary.FindByIDs(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, s := range loaded {
+		// expired snippets are returned for the caller to reject but never cached
+		if s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt) {
+			r.cacheOnMiss(ctx, s)
+		}
+	}
+	return append(found, loaded...), nil
 }
 
-// List caches the page results keyed by page/limit/tag.
-func (r *SnippetRepository) List(ctx context.Context, page, limit int, tag string) ([]domain.Snippet, error) {
-	k := keyList(page, limit, tag)
+// List caches the page results keyed by page/limit/tag and any list options.
+// Pages listed without repository.WithContent are cached and returned without content,
+// so full pages live under their own keys.
+func (r *SnippetRepository) List(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	if ctxutil.CacheBypass(ctx) {
+		return r.primary.List(ctx, page, limit, tag, opts...)
+	}
+	o := repository.NewListOptions(opts...)
+	epoch, err := r.epoch(ctx)
+	if err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to read list cache epoch, reading primary")
+		r.recordMiss(lookupList)
+		items, err := r.primary.List(ctx, page, limit, tag, opts...)
+		if err != nil {
+			return nil, err
+		}
+		return visibleListItems(items, o), nil
+	}
+	k := r.keyListWithOptions(epoch, page, limit, tag, o)
 	if val, err := r.redis.Get(ctx, k).Result(); err == nil && val != "" {
 		var items []domain.Snippet
 		if jsonErr := json.Unmarshal([]byte(val), &items); jsonErr == nil {
 			logger.With(ctx, map[string]any{"key": k}).Debug("cache hit: list")
+			r.recordHit(lookupList)
+			if r.sampleShadow() {
+				r.shadowList(ctx, k, items, o, page, limit, tag, opts...)
+			}
 			return items, nil
 		}
 	}
 	logger.With(ctx, map[string]any{"key": k}).Debug("cache miss: list")
-	items, err := r.primary.List(ctx, page, limit, tag)
+	r.recordMiss(lookupList)
+	v, err := r.shared(ctx, k, func(ctx context.Context) (any, error) {
+		return r.loadList(ctx, k, o, page, limit, tag, opts...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(v.([]domain.Snippet)), nil
+}
+
+// loadList reads a list page from primary, drops expired and hidden snippets and caches it under k.
+func (r *SnippetRepository) loadList(ctx context.Context, k string, o repository.ListOptions, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	items, err := r.primary.List(ctx, page, limit, tag, opts...)
 	if err != nil {
 		return nil, err
 	}
-	// eliminate already expired ones just in case
+	filtered := visibleListItems(items, o)
+	data, _ := json.Marshal(filtered)
+	if err := r.redis.Set(ctx, k, data, r.ttl).Err(); err != nil {
+		logger.With(ctx, map[string]any{"key": k, "ttl": r.ttl.String()}).Warn("failed to set list in cache")
+	}
+	return filtered, nil
+}
+
+// visibleListItems drops expired and not yet visible snippets from a primary page, in place,
+// strips content unless o asks for it and restores newest-first order where it applies.
+func visibleListItems(items []domain.Snippet, o repository.ListOptions) []domain.Snippet {
+	// eliminate already expired or not yet visible ones just in case
 	now := time.Now()
 	filtered := items[:0]
 	for _, s := range items {
-		if s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt) {
+		if (s.ExpiresAt.IsZero() || now.Before(s.ExpiresAt)) && s.IsVisibleAt(now) {
+			if !o.Content {
+				s.Content = ""
+			}
 			filtered = append(filtered, s)
 		}
 	}
-	// ensure order by CreatedAt desc (primary should already do this)
-	sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].CreatedAt.After(filtered[j].CreatedAt) })
-	data, _ := json.Marshal(filtered)
-	if err := r.redis.Set(ctx, k, data, r.ttl).Err(); err != nil {
-		logger.With(ctx, map[string]any{"key": k, "ttl": r.ttl.String()}).Warn("failed to set list in cache")
+	// ensure order by CreatedAt desc (primary should already do this); search results and explicit sorts keep the primary's order
+	if o.Query == "" && o.Sort == "" {
+		sort.SliceStable(filtered, func(i, j int) bool { return filtered[i].CreatedAt.After(filtered[j].CreatedAt) })
 	}
-	return filtered, nil
+	return filtered
+}
+
+// ListAfter reads cursor pages straight from the primary store. Each token is usually
+// requested once, so caching them would mostly add keys that are never read again.
+func (r *SnippetRepository) ListAfter(ctx context.Context, cursor repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	return r.primary.ListAfter(ctx, cursor, limit, tag, opts...)
 }
 
+// Count returns the cached total for the filters, falling back to the primary store.
+func (r *SnippetRepository) Count(ctx context.Context, tag string, opts ...repository.ListOption) (int, error) {
+	if ctxutil.CacheBypass(ctx) {
+		return r.primary.Count(ctx, tag, opts...)
+	}
+	epoch, err := r.epoch(ctx)
+	if err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to read list cache epoch, reading primary")
+		r.recordMiss(lookupList)
+		return r.primary.Count(ctx, tag, opts...)
+	}
+	k := r.keyCount(epoch, tag, repository.NewListOptions(opts...))
+	if n, err := r.redis.Get(ctx, k).Int(); err == nil {
+		logger.With(ctx, map[string]any{"key": k}).Debug("cache hit: count")
+		r.recordHit(lookupList)
+		return n, nil
+	}
+	logger.With(ctx, map[string]any{"key": k}).Debug("cache miss: count")
+	r.recordMiss(lookupList)
+	n, err := r.primary.Count(ctx, tag, opts...)
+	if err != nil {
+		return 0, err
+	}
+	if err := r.redis.Set(ctx, k, n, r.ttl).Err(); err != nil {
+		logger.With(ctx, map[string]any{"key": k, "ttl": r.ttl.String()}).Warn("failed to set count in cache")
+	}
+	return n, nil
+}
+
+// invalidateListKeys clears every cached list page, count and tag count in one INCR of the epoch
+// their keys embed. The old keys are never read again and expire with their TTL, so the cost does
+// not grow with the number of cached pages.
 func (r *SnippetRepository) invalidateListKeys(ctx context.Context) error {
-	// scan-and-delete keys with prefix snippets:
-	var cursor uint64
-	for {
-		keys, next, err := r.redis.Scan(ctx, cursor, "snippets:*", 100).Result()
-		if err != nil {
-			return err
-		}
-		if len(keys) > 0 {
-			// filter only list keys
-			listKeys := make([]string, 0, len(keys))
-			for _, k := range keys {
-				if strings.HasPrefix(k, "snippets:") && !strings.HasPrefix(k, "snippet:") {
-					listKeys = append(listKeys, k)
-				}
-			}
-			if len(listKeys) > 0 {
-				if err := r.redis.Del(ctx, listKeys...).Err(); err != nil {
-					logger.With(ctx, map[string]any{"keys": listKeys, "error": err.Error()}).Warn("failed to delete list cache keys")
-				} else {
-					logger.With(ctx, map[string]any{"keys": listKeys}).Debug("invalidated list cache keys")
-				}
+	pipe := r.redis.TxPipeline()
+	pipe.SetNX(ctx, r.keyEpoch(), epochSeed(), 0)
+	incr := pipe.Incr(ctx, r.keyEpoch())
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("bump list cache epoch: %w", err)
+	}
+	logger.With(ctx, map[string]any{"epoch": incr.Val()}).Debug("invalidated list cache keys")
+	return nil
+}
+
+// epoch returns the current list cache epoch.
+func (r *SnippetRepository) epoch(ctx context.Context) (int64, error) {
+	n, err := r.redis.Get(ctx, r.keyEpoch()).Int64()
+	if !errors.Is(err, redis.Nil) {
+		return n, err
+	}
+	if err := r.redis.SetNX(ctx, r.keyEpoch(), epochSeed(), 0).Err(); err != nil {
+		return 0, err
+	}
+	return r.redis.Get(ctx, r.keyEpoch()).Int64()
+}
+
+// epochSeed starts a missing epoch counter from the clock rather than zero, so a counter lost to
+// eviction or a flush never returns to an epoch whose pages may still be cached.
+func epochSeed() int64 { return time.Now().UnixNano() }
+
+// deleteKeys deletes keys with one DEL per key, sending pipelineBatchSize commands per round-trip.
+// Failed deletions are logged per key; a failed round-trip aborts and is returned.
+func (r *SnippetRepository) deleteKeys(ctx context.Context, keys []string) error {
+	for start := 0; start < len(keys); start += r.pipelineBatchSize {
+		batch := keys[start:min(start+r.pipelineBatchSize, len(keys))]
+		pipe := r.redis.Pipeline()
+		cmds := make([]*redis.IntCmd, len(batch))
+		for i, k := range batch {
+			cmds[i] = pipe.Del(ctx, k)
+		}
+		_, err := pipe.Exec(ctx)
+		failed := 0
+		for i, cmd := range cmds {
+			if cmd.Err() != nil {
+				failed++
+				logger.With(ctx, map[string]any{"key": batch[i], "error": cmd.Err().Error()}).Warn("failed to delete cache key")
 			}
 		}
-		if next == 0 {
-			break
+		if err != nil && failed == len(batch) {
+			return fmt.Errorf("pipeline del: %w", err)
 		}
-		cursor = next
+		logger.With(ctx, map[string]any{"keys": len(batch) - failed}).Debug("deleted cache keys")
 	}
 	return nil
 }
 
-// Update writes through to primary and invalidates cache.
+// Update writes through to primary, then refreshes the cached snippet under
+// WriteWarm (evicting it and queuing the write under WithWriteBehind) or invalidates it otherwise.
 func (r *SnippetRepository) Update(ctx context.Context, s domain.Snippet) error {
 	if err := r.primary.Update(ctx, s); err != nil {
 		return err
 	}
-	// invalidate the cached snippet
-	if err := r.redis.Del(ctx, keySnippet(s.ID)).Err(); err != nil {
-		logger.With(ctx, map[string]any{"id": s.ID}).Warn("failed to delete snippet from cache")
-	} else {
-		logger.With(ctx, map[string]any{"id": s.ID}).Debug("invalidated cached snippet after update")
+	switch {
+	case r.writePolicy != WriteWarm:
+		r.evictSnippet(ctx, s.ID)
+	case r.writeBehind != nil:
+		// Evict now so reads do not serve the old version until the queued write lands
+		r.evictSnippet(ctx, s.ID)
+		r.populate(ctx, s)
+	default:
+		r.cacheSnippet(ctx, s)
 	}
 	// bust list caches best-effort
 	if err := r.invalidateListKeys(ctx); err != nil {
 		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
 	}
+	r.publishInvalidation(ctx, s.ID, true)
+	return nil
+}
+
+// SoftDelete marks the snippet deleted in primary, then evicts it and invalidates list caches
+// so it stops being served.
+func (r *SnippetRepository) SoftDelete(ctx context.Context, id string, at time.Time) error {
+	if err := r.primary.SoftDelete(ctx, id, at); err != nil {
+		return err
+	}
+	r.evictDeleted(ctx, id)
+	return nil
+}
+
+// Restore clears the deletion mark in primary and invalidates list caches; the snippet is cached
+// again on its next read.
+func (r *SnippetRepository) Restore(ctx context.Context, id string) error {
+	if err := r.primary.Restore(ctx, id); err != nil {
+		return err
+	}
+	r.evictDeleted(ctx, id)
+	return nil
+}
+
+// DeleteByID permanently removes the snippet from primary, then evicts it and invalidates list
+// caches. It fails with errors.ErrUnsupported when primary cannot remove single snippets.
+func (r *SnippetRepository) DeleteByID(ctx context.Context, id string) error {
+	hd, ok := r.primary.(repository.HardDeleter)
+	if !ok {
+		return fmt.Errorf("hard delete: %w", errors.ErrUnsupported)
+	}
+	if err := hd.DeleteByID(ctx, id); err != nil {
+		return err
+	}
+	r.evictDeleted(ctx, id)
 	return nil
 }
 
+// evictDeleted drops a deleted or restored snippet and every list page from the cache.
+func (r *SnippetRepository) evictDeleted(ctx context.Context, id string) {
+	r.evictSnippet(ctx, id)
+	if err := r.invalidateListKeys(ctx); err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
+	}
+	r.publishInvalidation(ctx, id, true)
+}
+
+// Refresh reloads a snippet from primary and rewrites its cache entry, e.g. after the row was
+// changed out-of-band in Postgres. A snippet that is gone or expired is evicted and
+// repository.ErrNotFound is returned. List caches are invalidated either way. The read bypasses
+// read replicas, which may not have seen the change yet.
+func (r *SnippetRepository) Refresh(ctx context.Context, id string) (domain.Snippet, error) {
+	s, err := r.primary.FindByID(ctxutil.WithReadPrimary(ctx), id)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return domain.Snippet{}, err
+	}
+	if err := r.invalidateListKeys(ctx); err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
+	}
+	r.publishInvalidation(ctx, id, true)
+	if err != nil || (!s.ExpiresAt.IsZero() && !time.Now().Before(s.ExpiresAt)) {
+		r.evictSnippet(ctx, id)
+		return domain.Snippet{}, repository.ErrNotFound
+	}
+	r.cacheSnippet(ctx, s)
+	return s, nil
+}
+
+// ListTags caches the tag counts for at most tagsTTL; writes invalidate them with list pages.
+func (r *SnippetRepository) ListTags(ctx context.Context) ([]repository.TagCount, error) {
+	if ctxutil.CacheBypass(ctx) {
+		return r.primary.ListTags(ctx)
+	}
+	epoch, err := r.epoch(ctx)
+	if err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to read list cache epoch, reading primary")
+		r.recordMiss(lookupList)
+		return r.primary.ListTags(ctx)
+	}
+	k := r.keyTags(epoch)
+	if val, err := r.redis.Get(ctx, k).Result(); err == nil && val != "" {
+		var tags []repository.TagCount
+		if jsonErr := json.Unmarshal([]byte(val), &tags); jsonErr == nil {
+			logger.Debug(ctx, "cache hit: tags")
+			r.recordHit(lookupList)
+			return tags, nil
+		}
+	}
+	logger.Debug(ctx, "cache miss: tags")
+	r.recordMiss(lookupList)
+	v, err := r.shared(ctx, k, func(ctx context.Context) (any, error) {
+		tags, err := r.primary.ListTags(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ttl := tagsTTL
+		if r.ttl > 0 && r.ttl < ttl {
+			ttl = r.ttl
+		}
+		data, _ := json.Marshal(tags)
+		if err := r.redis.Set(ctx, k, data, ttl).Err(); err != nil {
+			logger.With(ctx, map[string]any{"key": k, "ttl": ttl.String()}).Warn("failed to set tags in cache")
+		}
+		return tags, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(v.([]repository.TagCount)), nil
+}
+
+// FindByContentHash is not cached and always reads from primary.
+func (r *SnippetRepository) FindByContentHash(ctx context.Context, hash string) (domain.Snippet, error) {
+	return r.primary.FindByContentHash(ctx, hash)
+}
+
+// TagExists is not cached and always reads from primary.
+func (r *SnippetRepository) TagExists(ctx context.Context, tag string) (bool, error) {
+	return r.primary.TagExists(ctx, tag)
+}
+
+// FindVersion is not cached and always reads from primary.
+func (r *SnippetRepository) FindVersion(ctx context.Context, id string, version int) (domain.SnippetVersion, error) {
+	return r.primary.FindVersion(ctx, id, version)
+}
+
+// DeleteExpired purges expired snippets from primary and, when any were removed, invalidates list
+// caches. Cached snippets need no eviction since their TTL never outlives the snippet's expiry.
+func (r *SnippetRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	n, err := r.primary.DeleteExpired(ctx)
+	if err != nil || n == 0 {
+		return n, err
+	}
+	if err := r.invalidateListKeys(ctx); err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
+	}
+	r.publishInvalidation(ctx, "", false)
+	return n, nil
+}
+
 var _ repository.SnippetRepository = (*SnippetRepository)(nil)
@@ -7,14 +7,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	miniredis "github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/metrics"
 	"github.com/roguepikachu/bonsai/internal/repository"
 	"github.com/roguepikachu/bonsai/internal/repository/fake"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 )
 
 func TestCachedRepository_Roundtrip(t *testing.T) {
@@ -29,7 +35,7 @@ func TestCachedRepository_Roundtrip(t *testing.T) {
 	repo := NewSnippetRepository(primary, rcli, time.Minute)
 
 	now := time.Now().UTC()
-	s := domain.Snippet{ID: "id1", Content: "hello", CreatedAt: now}
+	s := domain.Snippet{ID: "id1", Content: "hello", CreatedAt: now, Title: "Greeting"}
 	if err := repo.Insert(ctx, s); err != nil {
 		t.Fatalf("insert: %v", err)
 	}
@@ -39,8 +45,8 @@ func TestCachedRepository_Roundtrip(t *testing.T) {
 	if err != nil {
 		t.Fatalf("find: %v", err)
 	}
-	if got.ID != "id1" {
-		t.Fatalf("wrong id: %s", got.ID)
+	if got.ID != "id1" || got.Title != "Greeting" {
+		t.Fatalf("wrong snippet: %+v", got)
 	}
 
 	// list populates list cache
@@ -53,7 +59,7 @@ func TestCachedRepository_Roundtrip(t *testing.T) {
 	}
 
 	// ensure snippet is stored in cache JSON
-	k := keySnippet("id1")
+	k := repo.keySnippet("id1")
 	gotStr, gerr := rcli.Get(ctx, k).Result()
 	if gerr != nil {
 		t.Fatalf("cache get: %v", gerr)
@@ -62,8 +68,8 @@ func TestCachedRepository_Roundtrip(t *testing.T) {
 	if err := json.Unmarshal([]byte(gotStr), &cached); err != nil {
 		t.Fatalf("unmarshal: %v", err)
 	}
-	if cached.ID != "id1" {
-		t.Fatalf("cache mismatch")
+	if cached.ID != "id1" || cached.Title != "Greeting" {
+		t.Fatalf("cache mismatch: %+v", cached)
 	}
 }
 
@@ -142,7 +148,10 @@ func TestCachedRepository_ExpiredSnippet(t *testing.T) {
 		t.Fatalf("insert: %v", err)
 	}
 
-	// Should be cached with short TTL
+	// Cached for the ~2s the snippet has left, not the repository's hour
+	if ttl := mr.TTL(repo.keySnippet("exp1")); ttl <= 0 || ttl > 2*time.Second {
+		t.Fatalf("want a TTL of at most 2s, got %v", ttl)
+	}
 	got, err := repo.FindByID(ctx, "exp1")
 	if err != nil {
 		t.Fatalf("find: %v", err)
@@ -155,10 +164,28 @@ func TestCachedRepository_ExpiredSnippet(t *testing.T) {
 	mr.FastForward(3 * time.Second)
 
 	// Should be gone from cache now
-	_, err = rcli.Get(ctx, keySnippet("exp1")).Result()
+	_, err = rcli.Get(ctx, repo.keySnippet("exp1")).Result()
 	if !errors.Is(err, redis.Nil) {
 		t.Fatalf("expected key to expire in cache, got %v", err)
 	}
+
+	// A snippet that has already expired is never cached, on write or on a miss
+	gone := domain.Snippet{ID: "exp2", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)}
+	if err := repo.Insert(ctx, gone); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	_, _ = repo.FindByID(ctx, "exp2")
+	if mr.Exists(repo.keySnippet("exp2")) {
+		t.Fatal("expired snippet must not be cached")
+	}
+
+	// Snippets without an expiry keep the repository TTL
+	if err := repo.Insert(ctx, domain.Snippet{ID: "forever", CreatedAt: now}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if ttl := mr.TTL(repo.keySnippet("forever")); ttl != time.Hour {
+		t.Fatalf("want the repository TTL, got %v", ttl)
+	}
 }
 
 func TestCachedRepository_List_Empty(t *testing.T) {
@@ -181,7 +208,7 @@ func TestCachedRepository_List_Empty(t *testing.T) {
 	}
 
 	// Check cache was populated
-	k := keyList(1, 10, "")
+	k := repo.keyList(currentEpoch(t, repo), 1, 10, "")
 	val, err := rcli.Get(ctx, k).Result()
 	if err != nil {
 		t.Fatalf("cache get: %v", err)
@@ -232,8 +259,8 @@ func TestCachedRepository_List_WithTag(t *testing.T) {
 	}
 
 	// Check cache key is unique per tag
-	kGo := keyList(1, 10, "go")
-	kPython := keyList(1, 10, "python")
+	kGo := repo.keyList(currentEpoch(t, repo), 1, 10, "go")
+	kPython := repo.keyList(currentEpoch(t, repo), 1, 10, "python")
 	if kGo == kPython {
 		t.Fatalf("cache keys should differ by tag")
 	}
@@ -291,9 +318,9 @@ func TestCachedRepository_List_Pagination(t *testing.T) {
 	}
 
 	// Ensure different pages are cached separately
-	k1 := keyList(1, 10, "")
-	k2 := keyList(2, 10, "")
-	k3 := keyList(3, 10, "")
+	k1 := repo.keyList(currentEpoch(t, repo), 1, 10, "")
+	k2 := repo.keyList(currentEpoch(t, repo), 2, 10, "")
+	k3 := repo.keyList(currentEpoch(t, repo), 3, 10, "")
 	if k1 == k2 || k2 == k3 || k1 == k3 {
 		t.Fatalf("cache keys should differ by page")
 	}
@@ -392,6 +419,45 @@ func TestCachedRepository_List_OrderByCreatedAt(t *testing.T) {
 	}
 }
 
+func TestCachedRepository_List_EqualTimestampsFillIdentically(t *testing.T) {
+	ctx := context.Background()
+	primary := fake.NewSnippetRepository()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		if err := repo.Insert(ctx, domain.Snippet{ID: id, Content: id, CreatedAt: now}); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+
+	for _, sort := range []string{"", repository.SortCreatedAtAsc, repository.SortExpiresAtAsc} {
+		key := repo.keyListWithOptions(currentEpoch(t, repo), 1, 3, "", repository.NewListOptions(repository.WithSort(sort)))
+		var first string
+		for fill := range 10 {
+			if _, err := repo.List(ctx, 1, 3, "", repository.WithSort(sort)); err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			got, err := rcli.Get(ctx, key).Result()
+			if err != nil {
+				t.Fatalf("sort %q: list page not cached: %v", sort, err)
+			}
+			if fill == 0 {
+				first = got
+			} else if got != first {
+				t.Fatalf("sort %q: fill %d differs from the first:\n%s\n%s", sort, fill, got, first)
+			}
+			rcli.Del(ctx, key)
+		}
+	}
+}
+
 func TestCachedRepository_InvalidateListCache(t *testing.T) {
 	ctx := context.Background()
 	primary := fake.NewSnippetRepository()
@@ -435,6 +501,40 @@ func TestCachedRepository_InvalidateListCache(t *testing.T) {
 	}
 }
 
+func TestCachedRepository_Count_CachedAndInvalidated(t *testing.T) {
+	ctx := context.Background()
+	primary := fake.NewSnippetRepository()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	now := time.Now().UTC()
+	if err := repo.Insert(ctx, domain.Snippet{ID: "s1", CreatedAt: now, Tags: []string{"go"}}); err != nil {
+		t.Fatalf("insert s1: %v", err)
+	}
+	if n, err := repo.Count(ctx, "go"); err != nil || n != 1 {
+		t.Fatalf("count: want 1, got %d (%v)", n, err)
+	}
+	countKey := repo.keyCount(currentEpoch(t, repo), "go", repository.ListOptions{})
+	if !mr.Exists(countKey) {
+		t.Fatalf("expected count cached under %s", countKey)
+	}
+
+	if err := repo.Insert(ctx, domain.Snippet{ID: "s2", CreatedAt: now, Tags: []string{"go"}}); err != nil {
+		t.Fatalf("insert s2: %v", err)
+	}
+	if mr.Exists(repo.keyCount(currentEpoch(t, repo), "go", repository.ListOptions{})) {
+		t.Fatalf("expected insert to invalidate the cached count")
+	}
+	if n, err := repo.Count(ctx, "go"); err != nil || n != 2 {
+		t.Fatalf("count after insert: want 2, got %d (%v)", n, err)
+	}
+}
+
 func TestCachedRepository_RedisError_Fallback(t *testing.T) {
 	ctx := context.Background()
 	primary := fake.NewSnippetRepository()
@@ -470,37 +570,130 @@ func TestCachedRepository_RedisError_Fallback(t *testing.T) {
 }
 
 func TestCachedRepository_KeyHelpers(t *testing.T) {
+	repo := NewSnippetRepository(nil, nil, time.Minute)
+
 	// Test snippet key
-	k1 := keySnippet("test-id")
+	k1 := repo.keySnippet("test-id")
 	if k1 != "snippet:test-id" {
 		t.Fatalf("expected 'snippet:test-id', got %s", k1)
 	}
 
 	// Test list key without tag
-	k2 := keyList(1, 10, "")
-	if k2 != "snippets:p1:l10" {
-		t.Fatalf("expected 'snippets:p1:l10', got %s", k2)
+	k2 := repo.keyList(7, 1, 10, "")
+	if k2 != "snippets:e7:p1:l10" {
+		t.Fatalf("expected 'snippets:e7:p1:l10', got %s", k2)
 	}
 
 	// Test list key with tag
-	k3 := keyList(2, 20, "golang")
-	if k3 != "snippets:p2:l20:t:golang" {
-		t.Fatalf("expected 'snippets:p2:l20:t:golang', got %s", k3)
+	k3 := repo.keyList(7, 2, 20, "golang")
+	if k3 != "snippets:e7:p2:l20:t:golang" {
+		t.Fatalf("expected 'snippets:e7:p2:l20:t:golang', got %s", k3)
 	}
 
 	// Test different pages have different keys
-	k4 := keyList(1, 10, "")
-	k5 := keyList(2, 10, "")
+	k4 := repo.keyList(7, 1, 10, "")
+	k5 := repo.keyList(7, 2, 10, "")
 	if k4 == k5 {
 		t.Fatalf("different pages should have different keys")
 	}
 
 	// Test different limits have different keys
-	k6 := keyList(1, 10, "")
-	k7 := keyList(1, 20, "")
+	k6 := repo.keyList(7, 1, 10, "")
+	k7 := repo.keyList(7, 1, 20, "")
 	if k6 == k7 {
 		t.Fatalf("different limits should have different keys")
 	}
+
+	// Test different epochs have different keys
+	if k6 == repo.keyList(8, 1, 10, "") {
+		t.Fatalf("different epochs should have different keys")
+	}
+
+	// Test different sorts have different keys
+	k8 := repo.keyListWithOptions(7, 1, 10, "", repository.NewListOptions(repository.WithSort(repository.SortExpiresAtAsc)))
+	if k8 == k6 || k8 != "snippets:e7:p1:l10:s:expires_at" {
+		t.Fatalf("expected sort in key, got %s", k8)
+	}
+
+	// Test multi-tag keys use the sorted tag set and match mode
+	k9 := repo.keyListWithOptions(7, 1, 10, "", repository.NewListOptions(repository.WithTags("", "web", "go")))
+	k10 := repo.keyListWithOptions(7, 1, 10, "", repository.NewListOptions(repository.WithTags(repository.TagMatchAll, "go", "web")))
+	k11 := repo.keyListWithOptions(7, 1, 10, "", repository.NewListOptions(repository.WithTags(repository.TagMatchAny, "go", "web")))
+	if k9 != k10 || k9 != "snippets:e7:p1:l10:tags:all:go,web" || k10 == k11 {
+		t.Fatalf("unexpected multi-tag keys: %s %s %s", k9, k10, k11)
+	}
+
+	// Excluded tags get their own sorted set, apart from included ones
+	k12 := repo.keyListWithOptions(7, 1, 10, "go", repository.NewListOptions(repository.WithExcludeTags("web", "cli")))
+	if k12 != "snippets:e7:p1:l10:t:go:xtags:cli,web" || k12 == repo.keyListWithOptions(7, 1, 10, "go", repository.NewListOptions(repository.WithTags("", "cli", "web"))) {
+		t.Fatalf("unexpected exclude-tag key: %s", k12)
+	}
+
+	// Test the count, tags and views keys
+	if k := repo.keyCount(7, "go", repository.ListOptions{}); k != "snippets:e7:count:t:go" {
+		t.Fatalf("unexpected count key: %s", k)
+	}
+	if repo.keyTags(7) != "snippets:e7:tags" || repo.keyViews("a") != "snippet:views:a" {
+		t.Fatalf("unexpected tags or views key: %s %s", repo.keyTags(7), repo.keyViews("a"))
+	}
+
+	// Test every key carries a configured prefix
+	prefixed := NewSnippetRepository(nil, nil, time.Minute, WithKeyPrefix("staging:"))
+	for got, want := range map[string]string{
+		prefixed.keySnippet("test-id"):       "staging:snippet:test-id",
+		prefixed.keyList(7, 2, 20, "golang"): "staging:snippets:e7:p2:l20:t:golang",
+		prefixed.keyListWithOptions(7, 1, 10, "", repository.NewListOptions(repository.WithSort(repository.SortExpiresAtAsc))): "staging:snippets:e7:p1:l10:s:expires_at",
+		prefixed.keyCount(7, "go", repository.ListOptions{}):                                                                   "staging:snippets:e7:count:t:go",
+		prefixed.keyTags(7):    "staging:snippets:e7:tags",
+		prefixed.keyViews("a"): "staging:snippet:views:a",
+	} {
+		if got != want {
+			t.Fatalf("want %s, got %s", want, got)
+		}
+	}
+	if p := scanPattern("env[1]*:snippets:"); p != `env\[1\]\*:snippets:*` {
+		t.Fatalf("unexpected scan pattern: %s", p)
+	}
+}
+
+func TestCachedRepository_KeyPrefixIsolatesEnvironments(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	items := []domain.Snippet{{ID: "a", CreatedAt: now}}
+	staging := NewSnippetRepository(fake.NewSnippetRepository(fake.WithItems(items...)), rcli, time.Minute, WithKeyPrefix("staging:"))
+	prod := NewSnippetRepository(fake.NewSnippetRepository(fake.WithItems(items...)), rcli, time.Minute)
+
+	for _, repo := range []*SnippetRepository{staging, prod} {
+		if _, err := repo.FindByID(ctx, "a"); err != nil {
+			t.Fatalf("find: %v", err)
+		}
+		if _, err := repo.List(ctx, 1, 10, ""); err != nil {
+			t.Fatalf("list: %v", err)
+		}
+	}
+	stagingPage, prodPage := staging.keyList(currentEpoch(t, staging), 1, 10, ""), prod.keyList(currentEpoch(t, prod), 1, 10, "")
+	for _, k := range []string{"staging:snippet:a", stagingPage, "snippet:a", prodPage} {
+		if !mr.Exists(k) {
+			t.Fatalf("want %s cached", k)
+		}
+	}
+
+	// A write in one environment only invalidates its own list pages
+	if err := staging.Insert(ctx, domain.Snippet{ID: "b", CreatedAt: now}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if staging.keyList(currentEpoch(t, staging), 1, 10, "") == stagingPage {
+		t.Fatal("want the staging list page invalidated")
+	}
+	if prod.keyList(currentEpoch(t, prod), 1, 10, "") != prodPage {
+		t.Fatal("a staging write must not invalidate unprefixed list pages")
+	}
 }
 
 func TestCachedRepository_TTLHandling(t *testing.T) {
@@ -525,7 +718,7 @@ func TestCachedRepository_TTLHandling(t *testing.T) {
 	}
 
 	// Check TTL in redis
-	ttl1, err := rcli.TTL(ctx, keySnippet("ttl-test")).Result()
+	ttl1, err := rcli.TTL(ctx, repo1.keySnippet("ttl-test")).Result()
 	if err != nil {
 		t.Fatalf("get TTL: %v", err)
 	}
@@ -539,7 +732,7 @@ func TestCachedRepository_TTLHandling(t *testing.T) {
 		t.Fatalf("insert repo2: %v", err)
 	}
 
-	ttl2, err := rcli.TTL(ctx, keySnippet("ttl-test")).Result()
+	ttl2, err := rcli.TTL(ctx, repo1.keySnippet("ttl-test")).Result()
 	if err != nil {
 		t.Fatalf("get TTL: %v", err)
 	}
@@ -547,3 +740,1209 @@ func TestCachedRepository_TTLHandling(t *testing.T) {
 		t.Fatalf("expected TTL around 1h, got %v", ttl2)
 	}
 }
+
+func TestCachedRepository_WritePolicy(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s := domain.Snippet{ID: "policy", Content: "v1", CreatedAt: time.Now().UTC()}
+
+	t.Run("warm", func(t *testing.T) {
+		mr.FlushAll()
+		repo := NewSnippetRepository(fake.NewSnippetRepository(), rcli, time.Minute, WithWritePolicy(WriteWarm))
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		if _, hit, err := repo.FindByIDCached(ctx, s.ID); err != nil || !hit {
+			t.Fatalf("want first read to be a HIT, hit=%v err=%v", hit, err)
+		}
+		updated := s
+		updated.Content = "v2"
+		if err := repo.Update(ctx, updated); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		got, hit, err := repo.FindByIDCached(ctx, s.ID)
+		if err != nil || !hit || got.Content != "v2" {
+			t.Fatalf("want HIT with updated content after update, hit=%v content=%q err=%v", hit, got.Content, err)
+		}
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		mr.FlushAll()
+		repo := NewSnippetRepository(fake.NewSnippetRepository(), rcli, time.Minute, WithWritePolicy(WriteLazy))
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		if _, hit, err := repo.FindByIDCached(ctx, s.ID); err != nil || hit {
+			t.Fatalf("want first read to be a MISS, hit=%v err=%v", hit, err)
+		}
+		if _, hit, err := repo.FindByIDCached(ctx, s.ID); err != nil || !hit {
+			t.Fatalf("want second read to be a HIT, hit=%v err=%v", hit, err)
+		}
+		if err := repo.Update(ctx, s); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+		if _, hit, _ := repo.FindByIDCached(ctx, s.ID); hit {
+			t.Fatalf("want MISS after update under lazy policy")
+		}
+	})
+}
+
+func TestCachedRepository_CacheStats(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	repo := NewSnippetRepository(fake.NewSnippetRepository(), rcli, time.Minute, WithWritePolicy(WriteLazy))
+	_ = repo.Insert(ctx, domain.Snippet{ID: "s", Content: "x", CreatedAt: time.Now()})
+
+	// Each lookup advances exactly one counter: the first of a key misses and fills the cache
+	steps := []struct {
+		name                 string
+		lookup               func()
+		wantHits, wantMisses uint64
+	}{
+		{"snippet miss", func() { _, _ = repo.FindByID(ctx, "s") }, 0, 1},
+		{"snippet hit", func() { _, _ = repo.FindByID(ctx, "s") }, 1, 1},
+		{"snippet hit again", func() { _, _ = repo.FindByID(ctx, "s") }, 2, 1},
+		{"list miss", func() { _, _ = repo.List(ctx, 1, 10, "") }, 2, 2},
+		{"list hit", func() { _, _ = repo.List(ctx, 1, 10, "") }, 3, 2},
+	}
+	for _, st := range steps {
+		st.lookup()
+		if hits, misses := repo.Stats(); hits != st.wantHits || misses != st.wantMisses {
+			t.Fatalf("after %s: want %d hits and %d misses, got %d/%d", st.name, st.wantHits, st.wantMisses, hits, misses)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = repo.FindByID(ctx, "s") // hit
+		}()
+	}
+	wg.Wait()
+
+	hits, misses := repo.Stats()
+	if hits != 23 || misses != 2 {
+		t.Fatalf("want 23 hits and 2 misses, got %d/%d", hits, misses)
+	}
+	if hits, misses := repo.SnippetStats(); hits != 22 || misses != 1 {
+		t.Fatalf("want 22 snippet hits and 1 miss, got %d/%d", hits, misses)
+	}
+	if hits, misses := repo.ListStats(); hits != 1 || misses != 1 {
+		t.Fatalf("want 1 list hit and 1 miss, got %d/%d", hits, misses)
+	}
+}
+
+func TestCachedRepository_MissCacheProbability(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	roll := 0.9
+	repo := NewSnippetRepository(fake.NewSnippetRepository(), rcli, time.Minute,
+		WithWritePolicy(WriteLazy), WithMissCacheProbability(0.5), WithRand(func() float64 { return roll }))
+	_ = repo.Insert(ctx, domain.Snippet{ID: "s", Content: "x", CreatedAt: time.Now()})
+
+	// roll above the probability: served from primary but not cached
+	if _, hit, err := repo.FindByIDCached(ctx, "s"); err != nil || hit {
+		t.Fatalf("want MISS, hit=%v err=%v", hit, err)
+	}
+	if mr.Exists(repo.keySnippet("s")) {
+		t.Fatalf("want caching skipped when roll %.1f >= 0.5", roll)
+	}
+
+	// roll below the probability: written back
+	roll = 0.1
+	if _, hit, err := repo.FindByIDCached(ctx, "s"); err != nil || hit {
+		t.Fatalf("want MISS, hit=%v err=%v", hit, err)
+	}
+	if _, hit, err := repo.FindByIDCached(ctx, "s"); err != nil || !hit {
+		t.Fatalf("want HIT after cached miss, hit=%v err=%v", hit, err)
+	}
+}
+
+func TestCachedRepository_PerSnippetTTL(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	repo := NewSnippetRepository(fake.NewSnippetRepository(), rcli, 10*time.Minute, WithMaxTTL(time.Hour))
+
+	tests := []struct {
+		id      string
+		seconds int
+		want    time.Duration
+	}{
+		{"default", 0, 10 * time.Minute},
+		{"short", 30, 30 * time.Second},
+		{"long", 1800, 30 * time.Minute},
+		{"capped", 86400, time.Hour},
+	}
+	for _, tt := range tests {
+		s := domain.Snippet{ID: tt.id, Content: "x", CreatedAt: time.Now(), CacheTTLSeconds: tt.seconds}
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert %s: %v", tt.id, err)
+		}
+		if got := mr.TTL(repo.keySnippet(tt.id)); got != tt.want {
+			t.Fatalf("%s: want TTL %v, got %v", tt.id, tt.want, got)
+		}
+	}
+
+	// the remaining expiry still bounds an override
+	s := domain.Snippet{ID: "expiring", Content: "x", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Minute), CacheTTLSeconds: 1800}
+	_ = repo.Insert(ctx, s)
+	if got := mr.TTL(repo.keySnippet("expiring")); got > time.Minute {
+		t.Fatalf("want TTL bounded by expiry, got %v", got)
+	}
+}
+
+// roundTripCounter counts single commands and pipelines sent to Redis.
+type roundTripCounter struct {
+	single, pipelines int
+}
+
+func (c *roundTripCounter) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	c.single++
+	return ctx, nil
+}
+
+func (c *roundTripCounter) AfterProcess(context.Context, redis.Cmder) error { return nil }
+
+func (c *roundTripCounter) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	c.pipelines++
+	return ctx, nil
+}
+
+func (c *roundTripCounter) AfterProcessPipeline(context.Context, []redis.Cmder) error { return nil }
+
+// currentEpoch returns the list cache epoch stored in Redis for repo.
+func currentEpoch(t *testing.T, repo *SnippetRepository) int64 {
+	t.Helper()
+	epoch, err := repo.epoch(context.Background())
+	if err != nil {
+		t.Fatalf("epoch: %v", err)
+	}
+	return epoch
+}
+
+func TestCachedRepository_InvalidateListKeys_Epoch(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	counter := &roundTripCounter{}
+	rcli.AddHook(counter)
+	primary := fake.NewSnippetRepository()
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	for page := 1; page <= 25; page++ {
+		if _, err := repo.List(ctx, page, 10, ""); err != nil {
+			t.Fatalf("list page %d: %v", page, err)
+		}
+	}
+	before := currentEpoch(t, repo)
+	if before < time.Now().Add(-time.Hour).UnixNano() {
+		t.Fatalf("want the epoch seeded from the clock, got %d", before)
+	}
+
+	// Invalidation is one round-trip however many pages are cached
+	*counter = roundTripCounter{}
+	if err := repo.invalidateListKeys(ctx); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if counter.single+counter.pipelines != 1 {
+		t.Fatalf("want one round-trip, got %d single %d pipelines", counter.single, counter.pipelines)
+	}
+	if after := currentEpoch(t, repo); after != before+1 {
+		t.Fatalf("want epoch %d, got %d", before+1, after)
+	}
+	// Old pages are orphaned and left to their TTL
+	if !mr.Exists(repo.keyList(before, 1, 10, "")) || mr.Exists(repo.keyList(before+1, 1, 10, "")) {
+		t.Fatal("want the old page left to expire and none under the new epoch")
+	}
+
+	// A list after an insert reads under the new epoch and sees the new snippet
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 0 {
+		t.Fatalf("want an empty page, got %d", len(items))
+	}
+	if err := repo.Insert(ctx, domain.Snippet{ID: "new", CreatedAt: time.Now()}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 1 || items[0].ID != "new" {
+		t.Fatalf("want the inserted snippet listed, got %+v", items)
+	}
+
+	// A lost counter is reseeded above any epoch used before
+	mr.Del(repo.keyEpoch())
+	if got := currentEpoch(t, repo); got <= before+2 {
+		t.Fatalf("want the reseeded epoch above %d, got %d", before+2, got)
+	}
+}
+
+func TestCachedRepository_AddViews_PipelinedEviction(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	counter := &roundTripCounter{}
+	rcli.AddHook(counter)
+	var items []domain.Snippet
+	for i := 0; i < 25; i++ {
+		items = append(items, domain.Snippet{ID: fmt.Sprintf("s%d", i), CreatedAt: time.Now()})
+	}
+	repo := NewSnippetRepository(fake.NewSnippetRepository(fake.WithItems(items...)), rcli, time.Minute, WithPipelineBatchSize(10))
+
+	views := make(map[string]int64)
+	for _, s := range items {
+		if _, err := repo.FindByID(ctx, s.ID); err != nil {
+			t.Fatalf("find %s: %v", s.ID, err)
+		}
+		views[s.ID] = 1
+	}
+
+	*counter = roundTripCounter{}
+	if err := repo.AddViews(ctx, views); err != nil {
+		t.Fatalf("add views: %v", err)
+	}
+	if got := len(mr.Keys()); got != 0 {
+		t.Fatalf("want all snippets evicted, %d left: %v", got, mr.Keys())
+	}
+	// 25 keys in batches of 10: three pipelined round-trips instead of 25 DELs
+	if counter.pipelines != 3 || counter.single != 0 {
+		t.Fatalf("want 3 pipelined round-trips, got %d pipelines %d single", counter.pipelines, counter.single)
+	}
+}
+
+func TestCachedRepository_CacheBypass(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	counter := &roundTripCounter{}
+	rcli.AddHook(counter)
+	primary := fake.NewSnippetRepository()
+	_ = primary.Insert(context.Background(), domain.Snippet{ID: "s1", CreatedAt: time.Now()})
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	// exempt route: reads never consult Redis
+	bypass := ctxutil.WithCacheBypass(context.Background())
+	if _, err := repo.FindByID(bypass, "s1"); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if items, err := repo.List(bypass, 1, 10, ""); err != nil || len(items) != 1 {
+		t.Fatalf("list: want 1 item, got %d (%v)", len(items), err)
+	}
+	if _, err := repo.Count(bypass, ""); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if counter.single != 0 || counter.pipelines != 0 {
+		t.Fatalf("want no Redis commands for exempt reads, got %d single %d pipelines", counter.single, counter.pipelines)
+	}
+
+	// normal route: reads go through Redis
+	if _, err := repo.FindByID(context.Background(), "s1"); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if counter.single == 0 {
+		t.Fatalf("want Redis commands for normal reads")
+	}
+}
+
+func TestCachedRepository_Refresh(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository()
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	s := domain.Snippet{ID: "r1", Content: "old", CreatedAt: time.Now()}
+	if err := repo.Insert(ctx, s); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Change the row behind the cache's back
+	s.Content = "new"
+	if err := primary.Update(ctx, s); err != nil {
+		t.Fatalf("primary update: %v", err)
+	}
+	if got, _ := repo.FindByID(ctx, "r1"); got.Content != "old" {
+		t.Fatalf("want stale cached content before refresh, got %q", got.Content)
+	}
+
+	got, err := repo.Refresh(ctx, "r1")
+	if err != nil || got.Content != "new" {
+		t.Fatalf("refresh: want new content, got %q, %v", got.Content, err)
+	}
+	got, hit, err := repo.FindByIDCached(ctx, "r1")
+	if err != nil || !hit || got.Content != "new" {
+		t.Fatalf("want cache hit with new content, got %q hit=%v err=%v", got.Content, hit, err)
+	}
+
+	// Expired in primary: evicted and reported as not found
+	s.ExpiresAt = time.Now().Add(-time.Second)
+	if err := primary.Update(ctx, s); err != nil {
+		t.Fatalf("primary update: %v", err)
+	}
+	if _, err := repo.Refresh(ctx, "r1"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound for expired snippet, got %v", err)
+	}
+	if mr.Exists(repo.keySnippet("r1")) {
+		t.Fatalf("expired snippet should be evicted")
+	}
+	if _, err := repo.Refresh(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound for missing snippet, got %v", err)
+	}
+}
+
+// laggingPrimary serves stale content unless the read is routed to the primary.
+type laggingPrimary struct {
+	repository.SnippetRepository
+	stale domain.Snippet
+}
+
+func (p *laggingPrimary) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	if !ctxutil.ReadPrimary(ctx) {
+		return p.stale, nil
+	}
+	return p.SnippetRepository.FindByID(ctx, id)
+}
+
+func TestCachedRepository_Refresh_ReadsPrimary(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	s := domain.Snippet{ID: "r1", Content: "new", CreatedAt: time.Now()}
+	primary := &laggingPrimary{
+		SnippetRepository: fake.NewSnippetRepository(fake.WithItems(s)),
+		stale:             domain.Snippet{ID: "r1", Content: "old", CreatedAt: s.CreatedAt},
+	}
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	if got, err := repo.Refresh(ctx, "r1"); err != nil || got.Content != "new" {
+		t.Fatalf("want the primary's content, got %q, %v", got.Content, err)
+	}
+	if got, hit, err := repo.FindByIDCached(ctx, "r1"); err != nil || !hit || got.Content != "new" {
+		t.Fatalf("want the primary's content cached, got %q hit=%v err=%v", got.Content, hit, err)
+	}
+}
+
+func TestCachedRepository_PrometheusCounters(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	m := metrics.New(prometheus.NewRegistry())
+	primary := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "p1", Content: "x", CreatedAt: time.Now()}))
+	repo := NewSnippetRepository(primary, rcli, time.Minute, WithMetrics(m))
+
+	// miss then hit for the snippet, miss for the list page
+	for i := 0; i < 2; i++ {
+		if _, err := repo.FindByID(ctx, "p1"); err != nil {
+			t.Fatalf("find: %v", err)
+		}
+	}
+	if _, err := repo.List(ctx, 1, 10, ""); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := testutil.ToFloat64(m.CacheHits.WithLabelValues("snippet")); got != 1 {
+		t.Fatalf("want 1 snippet hit, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.CacheMisses.WithLabelValues("snippet")); got != 1 {
+		t.Fatalf("want 1 snippet miss, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.CacheMisses.WithLabelValues("list")); got != 1 {
+		t.Fatalf("want 1 list miss, got %v", got)
+	}
+	if hits, misses := repo.Stats(); hits != 1 || misses != 2 {
+		t.Fatalf("Stats must agree, got hits=%d misses=%d", hits, misses)
+	}
+}
+
+func TestCachedRepository_DeleteExpiredInvalidatesLists(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	clock := now
+	primary := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return clock }), fake.WithItems(
+		domain.Snippet{ID: "e1", CreatedAt: now, ExpiresAt: now.Add(time.Minute)},
+		domain.Snippet{ID: "k1", CreatedAt: now.Add(-time.Second)},
+	))
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 2 {
+		t.Fatalf("want 2 items cached, got %d", len(items))
+	}
+	// Nothing expired yet: the cached page must survive
+	if n, err := repo.DeleteExpired(ctx); err != nil || n != 0 {
+		t.Fatalf("want 0 purged, got %d, %v", n, err)
+	}
+	if !mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 10, "")) {
+		t.Fatalf("list cache should be kept when nothing was purged")
+	}
+
+	clock = now.Add(2 * time.Minute)
+	if n, err := repo.DeleteExpired(ctx); err != nil || n != 1 {
+		t.Fatalf("want 1 purged, got %d, %v", n, err)
+	}
+	if mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 10, "")) {
+		t.Fatalf("list cache should be invalidated after a purge")
+	}
+}
+
+func TestCachedRepository_SoftDeleteAndRestoreInvalidate(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now},
+		domain.Snippet{ID: "b", CreatedAt: now.Add(-time.Second)},
+	))
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	if _, err := repo.FindByID(ctx, "a"); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 2 {
+		t.Fatalf("want 2 items cached, got %d", len(items))
+	}
+	if err := repo.SoftDelete(ctx, "a", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if mr.Exists(repo.keySnippet("a")) || mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 10, "")) {
+		t.Fatal("soft delete should evict the snippet and list pages")
+	}
+	if _, err := repo.FindByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 1 {
+		t.Fatalf("want 1 item after delete, got %d", len(items))
+	}
+	// Including deleted snippets bypasses the cache, which only holds live ones
+	if s, err := repo.FindByID(ctxutil.WithIncludeDeleted(ctx), "a"); err != nil || s.DeletedAt.IsZero() {
+		t.Fatalf("want deleted snippet with include-deleted context, got %+v, %v", s, err)
+	}
+	if mr.Exists(repo.keySnippet("a")) {
+		t.Fatal("deleted snippet must not be cached")
+	}
+
+	if err := repo.Restore(ctx, "a"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 10, "")) {
+		t.Fatal("restore should invalidate list pages")
+	}
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 2 {
+		t.Fatalf("want 2 items after restore, got %d", len(items))
+	}
+}
+
+func TestCachedRepository_List_ContentView(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "s1", Content: "hello", CreatedAt: time.Now()}))
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	// Summary first, so a shared key would serve the content-less page to the full view
+	for i := 0; i < 2; i++ {
+		if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 1 || items[0].Content != "" {
+			t.Fatalf("summary list: want no content, got %+v", items)
+		}
+		if items, _ := repo.List(ctx, 1, 10, "", repository.WithContent()); len(items) != 1 || items[0].Content != "hello" {
+			t.Fatalf("full list: want content, got %+v", items)
+		}
+	}
+	summaryKey := repo.keyList(currentEpoch(t, repo), 1, 10, "")
+	fullKey := repo.keyListWithOptions(currentEpoch(t, repo), 1, 10, "", repository.NewListOptions(repository.WithContent()))
+	if summaryKey == fullKey || !mr.Exists(summaryKey) || !mr.Exists(fullKey) {
+		t.Fatalf("want separate cache entries, got %q and %q", summaryKey, fullKey)
+	}
+}
+
+// gatedPrimary counts primary reads and holds them until release is closed.
+type gatedPrimary struct {
+	repository.SnippetRepository
+	finds, lists atomic.Int32
+	release      chan struct{}
+}
+
+func (g *gatedPrimary) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	g.finds.Add(1)
+	<-g.release
+	return g.SnippetRepository.FindByID(ctx, id)
+}
+
+func (g *gatedPrimary) List(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	g.lists.Add(1)
+	<-g.release
+	return g.SnippetRepository.List(ctx, page, limit, tag, opts...)
+}
+
+func TestCachedRepository_CoalescesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := &gatedPrimary{
+		SnippetRepository: fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "hot", Content: "x", CreatedAt: time.Now()})),
+		release:           make(chan struct{}),
+	}
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if s, err := repo.FindByID(ctx, "hot"); err != nil || s.ID != "hot" {
+				errs <- fmt.Errorf("find hot: %+v, %v", s, err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := repo.FindByID(ctx, "missing"); !errors.Is(err, repository.ErrNotFound) {
+				errs <- fmt.Errorf("find missing: want ErrNotFound, got %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if items, err := repo.List(ctx, 1, 10, ""); err != nil || len(items) != 1 {
+				errs <- fmt.Errorf("list: %+v, %v", items, err)
+			}
+		}()
+	}
+	// Let every caller miss and join a flight before the primary answers
+	time.Sleep(100 * time.Millisecond)
+	close(primary.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if n := primary.finds.Load(); n != 2 {
+		t.Fatalf("want one primary read per snippet ID, got %d", n)
+	}
+	if n := primary.lists.Load(); n != 1 {
+		t.Fatalf("want one primary list read, got %d", n)
+	}
+	if mr.Exists(repo.keySnippet("missing")) {
+		t.Fatalf("a not-found result must not be cached")
+	}
+}
+
+func TestCachedRepository_InvalidationPubSub(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	const channel = "test:invalidate"
+	primary := fake.NewSnippetRepository()
+	newInstance := func() *SnippetRepository {
+		rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		return NewSnippetRepository(primary, rcli, time.Minute, WithWritePolicy(WriteWarm), WithInvalidationChannel(channel))
+	}
+	a, b := newInstance(), newInstance()
+
+	subCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 2)
+	for _, inst := range []*SnippetRepository{a, b} {
+		go func(inst *SnippetRepository) { done <- inst.SubscribeInvalidations(subCtx) }(inst)
+	}
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	waitFor("subscribers", func() bool { return mr.PubSubNumSub(channel)[channel] == 2 })
+
+	s := domain.Snippet{ID: "shared", Content: "v1", CreatedAt: time.Now()}
+	if err := a.Insert(ctx, s); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	// Inserts leave the writer's warmed snippet alone, on a and on its peer
+	time.Sleep(50 * time.Millisecond)
+	if !mr.Exists(a.keySnippet("shared")) {
+		t.Fatalf("an insert must not evict the writer's warmed snippet")
+	}
+
+	// a re-warms the snippet on update and ignores its own broadcast, so only b can evict it
+	s.Content = "v2"
+	if err := a.Update(ctx, s); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	waitFor("peer eviction", func() bool { return !mr.Exists(a.keySnippet("shared")) })
+	if got, _ := b.FindByID(ctx, "shared"); got.Content != "v2" {
+		t.Fatalf("want v2 after invalidation, got %q", got.Content)
+	}
+
+	stop()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("subscriber: %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("subscriber did not stop on cancel")
+		}
+	}
+}
+
+// recordingPrimary records the IDs batch lookups ask the primary store for.
+type recordingPrimary struct {
+	repository.SnippetRepository
+	asked [][]string
+}
+
+func (p *recordingPrimary) FindByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error) {
+	p.asked = append(p.asked, ids)
+	return p.SnippetRepository.FindByIDs(ctx, ids)
+}
+
+func TestCachedRepository_FindByIDs_PartialHit(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := &recordingPrimary{SnippetRepository: fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "hot", Content: "cached", CreatedAt: now},
+		domain.Snippet{ID: "cold", Content: "stored", CreatedAt: now},
+		domain.Snippet{ID: "gone", Content: "expired", CreatedAt: now, ExpiresAt: now.Add(-time.Minute)},
+	))}
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+	if _, err := repo.FindByID(ctx, "hot"); err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	hits0, misses0 := repo.Stats()
+
+	got, err := repo.FindByIDs(ctx, []string{"hot", "cold", "gone", "missing"})
+	if err != nil {
+		t.Fatalf("find by ids: %v", err)
+	}
+	byID := map[string]string{}
+	for _, s := range got {
+		byID[s.ID] = s.Content
+	}
+	if len(got) != 3 || byID["hot"] != "cached" || byID["cold"] != "stored" || byID["gone"] != "expired" {
+		t.Fatalf("want hot, cold and gone, got %+v", got)
+	}
+	if len(primary.asked) != 1 || fmt.Sprint(primary.asked[0]) != "[cold gone missing]" {
+		t.Fatalf("want one primary lookup for the misses only, got %v", primary.asked)
+	}
+	if hits, misses := repo.Stats(); hits-hits0 != 1 || misses-misses0 != 3 {
+		t.Fatalf("want 1 hit and 3 misses, got %d and %d", hits-hits0, misses-misses0)
+	}
+	if !mr.Exists(repo.keySnippet("cold")) {
+		t.Fatalf("loaded snippet should be re-cached")
+	}
+	if mr.Exists(repo.keySnippet("gone")) || mr.Exists(repo.keySnippet("missing")) {
+		t.Fatalf("expired and missing snippets must not be cached")
+	}
+
+	// Everything readable is now cached, so a repeat skips the primary store
+	if _, err := repo.FindByIDs(ctx, []string{"hot", "cold"}); err != nil {
+		t.Fatalf("find by ids: %v", err)
+	}
+	if len(primary.asked) != 1 {
+		t.Fatalf("want full hit without primary lookup, got %v", primary.asked)
+	}
+}
+
+func TestCachedRepository_ShadowReads(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "s1", Content: "fresh", CreatedAt: now, Version: 2},
+		domain.Snippet{ID: "s2", Content: "other", CreatedAt: now.Add(-time.Second)},
+	))
+	m := metrics.New(prometheus.NewRegistry())
+	repo := NewSnippetRepository(primary, rcli, time.Minute, WithShadowReads(1), WithMetrics(m))
+
+	// Consistent entries produce no mismatch
+	if _, err := repo.FindByID(ctx, "s2"); err != nil {
+		t.Fatalf("fill: %v", err)
+	}
+	if _, err := repo.FindByID(ctx, "s2"); err != nil {
+		t.Fatalf("hit: %v", err)
+	}
+	if _, err := repo.List(ctx, 1, 10, ""); err != nil {
+		t.Fatalf("fill list: %v", err)
+	}
+	if _, err := repo.List(ctx, 1, 10, ""); err != nil {
+		t.Fatalf("hit list: %v", err)
+	}
+	if n := testutil.ToFloat64(m.ShadowMismatches.WithLabelValues("snippet")) + testutil.ToFloat64(m.ShadowMismatches.WithLabelValues("list")); n != 0 {
+		t.Fatalf("want no mismatches for consistent entries, got %v", n)
+	}
+
+	// Seed divergent entries: a stale version of s1 and a page missing s1
+	stale, _ := json.Marshal(domain.Snippet{ID: "s1", Content: "stale", CreatedAt: now, Version: 1})
+	mr.Set(repo.keySnippet("s1"), string(stale))
+	page, _ := json.Marshal([]domain.Snippet{{ID: "s2", CreatedAt: now.Add(-time.Second)}})
+	mr.Set(repo.keyList(currentEpoch(t, repo), 1, 10, ""), string(page))
+
+	got, err := repo.FindByID(ctx, "s1")
+	if err != nil || got.Content != "stale" {
+		t.Fatalf("want the cached value served, got %q, %v", got.Content, err)
+	}
+	items, err := repo.List(ctx, 1, 10, "")
+	if err != nil || len(items) != 1 || items[0].ID != "s2" {
+		t.Fatalf("want the cached page served, got %+v, %v", items, err)
+	}
+	if n := testutil.ToFloat64(m.ShadowMismatches.WithLabelValues("snippet")); n != 1 {
+		t.Fatalf("want 1 snippet mismatch, got %v", n)
+	}
+	if n := testutil.ToFloat64(m.ShadowMismatches.WithLabelValues("list")); n != 1 {
+		t.Fatalf("want 1 list mismatch, got %v", n)
+	}
+}
+
+func TestCachedRepository_ListTags(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now, Tags: []string{"go"}},
+	))
+	repo := NewSnippetRepository(primary, rcli, time.Hour)
+
+	if got, err := repo.ListTags(ctx); err != nil || fmt.Sprint(got) != "[{go 1}]" {
+		t.Fatalf("want [{go 1}], got %v, %v", got, err)
+	}
+	if !mr.Exists(repo.keyTags(currentEpoch(t, repo))) {
+		t.Fatalf("tag counts should be cached")
+	}
+	if ttl := mr.TTL(repo.keyTags(currentEpoch(t, repo))); ttl <= 0 || ttl > tagsTTL {
+		t.Fatalf("want TTL within %v, got %v", tagsTTL, ttl)
+	}
+
+	// A write to the primary alone is not seen until the cached entry goes
+	_ = primary.Insert(ctx, domain.Snippet{ID: "b", CreatedAt: now, Tags: []string{"go", "web"}})
+	if got, _ := repo.ListTags(ctx); fmt.Sprint(got) != "[{go 1}]" {
+		t.Fatalf("want cached [{go 1}], got %v", got)
+	}
+
+	if err := repo.Insert(ctx, domain.Snippet{ID: "c", CreatedAt: now, Tags: []string{"web"}}); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if mr.Exists(repo.keyTags(currentEpoch(t, repo))) {
+		t.Fatalf("a write should invalidate cached tag counts")
+	}
+	if got, _ := repo.ListTags(ctx); fmt.Sprint(got) != "[{go 2} {web 2}]" {
+		t.Fatalf("want [{go 2} {web 2}] after invalidation, got %v", got)
+	}
+}
+
+// failingViewsPrimary fails every AddViews call.
+type failingViewsPrimary struct{ repository.SnippetRepository }
+
+func (failingViewsPrimary) AddViews(context.Context, map[string]int64) error {
+	return errors.New("db down")
+}
+
+func TestCachedRepository_ViewCounts(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "a", CreatedAt: time.Now()}))
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	if _, _, err := repo.FindByIDCached(ctx, "a"); err != nil {
+		t.Fatalf("warm cache: %v", err)
+	}
+	for want := int64(1); want <= 3; want++ {
+		if n, err := repo.IncrementView(ctx, "a"); err != nil || n != want {
+			t.Fatalf("want %d pending views, got %d, %v", want, n, err)
+		}
+	}
+
+	// A failed flush leaves the counts for the next one
+	failing := NewSnippetRepository(failingViewsPrimary{primary}, rcli, time.Minute)
+	if _, err := failing.FlushViews(ctx); err == nil {
+		t.Fatalf("want flush error")
+	}
+	if got, _ := mr.Get(repo.keyViews("a")); got != "3" {
+		t.Fatalf("want 3 pending views kept, got %q", got)
+	}
+
+	if n, err := repo.FlushViews(ctx); err != nil || n != 1 {
+		t.Fatalf("want 1 snippet flushed, got %d, %v", n, err)
+	}
+	if mr.Exists(repo.keyViews("a")) {
+		t.Fatalf("flushed counter should be removed")
+	}
+	if mr.Exists(repo.keySnippet("a")) {
+		t.Fatalf("cached snippet should be evicted once its views are flushed")
+	}
+	if s, _ := repo.FindByID(ctx, "a"); s.Views != 3 {
+		t.Fatalf("want 3 stored views, got %d", s.Views)
+	}
+	if n, err := repo.FlushViews(ctx); err != nil || n != 0 {
+		t.Fatalf("want nothing left to flush, got %d, %v", n, err)
+	}
+
+	mr.Close()
+	if _, err := repo.IncrementView(ctx, "a"); err == nil {
+		t.Fatalf("want error with redis down")
+	}
+}
+
+func TestCachedRepository_IncrementViewWithin(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	repo := NewSnippetRepository(fake.NewSnippetRepository(), redis.NewClient(&redis.Options{Addr: mr.Addr()}), time.Minute)
+
+	var (
+		wg      sync.WaitGroup
+		counted atomic.Int32
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok, err := repo.IncrementViewWithin(ctx, "a", 5); err == nil && ok {
+				counted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := counted.Load(); n != 5 {
+		t.Fatalf("want exactly 5 reads counted, got %d", n)
+	}
+	if got, _ := mr.Get(repo.keyViews("a")); got != "5" {
+		t.Fatalf("want 5 pending views, got %q", got)
+	}
+}
+
+// hardDeletePrimary adds a context-aware DeleteByID to the fake store.
+type hardDeletePrimary struct{ *fake.SnippetRepository }
+
+func (p hardDeletePrimary) DeleteByID(ctx context.Context, id string) error {
+	if _, err := p.FindByID(ctxutil.WithIncludeDeleted(ctx), id); err != nil {
+		return err
+	}
+	p.SnippetRepository.DeleteByID(id)
+	return nil
+}
+
+func TestCachedRepository_DeleteByIDInvalidates(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := hardDeletePrimary{fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now},
+		domain.Snippet{ID: "b", CreatedAt: now.Add(-time.Second)},
+	))}
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	if _, err := repo.FindByID(ctx, "a"); err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if items, _ := repo.List(ctx, 1, 10, ""); len(items) != 2 {
+		t.Fatalf("want 2 items cached, got %d", len(items))
+	}
+	if err := repo.DeleteByID(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if mr.Exists(repo.keySnippet("a")) || mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 10, "")) {
+		t.Fatal("delete should evict the snippet and list pages")
+	}
+	if _, err := repo.FindByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	if err := repo.DeleteByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound deleting twice, got %v", err)
+	}
+
+	plain := NewSnippetRepository(fake.NewSnippetRepository(), rcli, time.Minute)
+	if err := plain.DeleteByID(ctx, "b"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("want ErrUnsupported without a hard-deleting primary, got %v", err)
+	}
+}
+
+func TestCachedRepository_WriteBehind(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository()
+	repo := NewSnippetRepository(primary, rcli, time.Minute, WithWritePolicy(WriteWarm), WithWriteBehind(16))
+
+	workerCtx, stop := context.WithCancel(ctx)
+	done := make(chan error, 1)
+	go func() { done <- repo.RunWriteBehind(workerCtx) }()
+
+	cachedTitle := func(id string) string {
+		raw, err := rcli.Get(ctx, repo.keySnippet(id)).Result()
+		if err != nil {
+			return ""
+		}
+		var s domain.Snippet
+		_ = json.Unmarshal([]byte(raw), &s)
+		return s.Title
+	}
+	eventually := func(id, title string) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for cachedTitle(id) != title {
+			if time.Now().After(deadline) {
+				t.Fatalf("cache never showed %q for %s, has %q", title, id, cachedTitle(id))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	s := domain.Snippet{ID: "wb", Title: "v1", CreatedAt: time.Now()}
+	if err := repo.Insert(ctx, s); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := primary.FindByID(ctx, "wb"); err != nil {
+		t.Fatalf("primary must have the snippet when Insert returns: %v", err)
+	}
+	eventually("wb", "v1")
+
+	s.Title = "v2"
+	if err := repo.Update(ctx, s); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	eventually("wb", "v2")
+
+	stop()
+	if err := <-done; err != nil {
+		t.Fatalf("worker: %v", err)
+	}
+}
+
+func TestCachedRepository_WriteBehindQueue(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository()
+	repo := NewSnippetRepository(primary, rcli, time.Minute, WithWriteBehind(2))
+
+	// No worker yet: the third write does not fit and is dropped, but still reaches primary
+	for _, id := range []string{"a", "b", "c"} {
+		if err := repo.Insert(ctx, domain.Snippet{ID: id, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	if n, _ := primary.Count(ctx, ""); n != 3 {
+		t.Fatalf("want every insert in primary, got %d", n)
+	}
+	// Deleting b before its queued write lands must not let the write resurrect it
+	if err := repo.SoftDelete(ctx, "b", time.Now()); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	// A cancelled worker still drains the queue before returning
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := repo.RunWriteBehind(stopped); err != nil {
+		t.Fatalf("drain: %v", err)
+	}
+	if !mr.Exists(repo.keySnippet("a")) {
+		t.Fatal("want the queued write drained on shutdown")
+	}
+	if mr.Exists(repo.keySnippet("b")) {
+		t.Fatal("eviction must cancel the queued write")
+	}
+	if mr.Exists(repo.keySnippet("c")) {
+		t.Fatal("want the write that did not fit dropped")
+	}
+
+	plain := NewSnippetRepository(primary, rcli, time.Minute)
+	if err := plain.RunWriteBehind(ctx); err == nil {
+		t.Fatal("want an error without WithWriteBehind")
+	}
+}
+
+func TestCachedRepository_Warm(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	now := time.Now()
+	primary := fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "newest", Content: "a", CreatedAt: now},
+		domain.Snippet{ID: "newer", Content: "b", CreatedAt: now.Add(-time.Minute), ExpiresAt: now.Add(time.Minute)},
+		domain.Snippet{ID: "old", Content: "c", CreatedAt: now.Add(-time.Hour)},
+	))
+	repo := NewSnippetRepository(primary, rcli, time.Hour, WithWarmListPage(20))
+
+	if err := repo.Warm(ctx, 2); err != nil {
+		t.Fatalf("warm: %v", err)
+	}
+	for _, id := range []string{"newest", "newer"} {
+		if !mr.Exists(repo.keySnippet(id)) {
+			t.Fatalf("want %s warmed", id)
+		}
+	}
+	if mr.Exists(repo.keySnippet("old")) {
+		t.Fatal("want only the n most recent snippets warmed")
+	}
+	if ttl := mr.TTL(repo.keySnippet("newer")); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("want the warmed TTL bounded by expiry, got %v", ttl)
+	}
+	if !mr.Exists(repo.keyList(currentEpoch(t, repo), 1, 20, "")) {
+		t.Fatal("want the default list page warmed")
+	}
+
+	// Warmed entries are hits, content included
+	if s, cached, err := repo.FindByIDCached(ctx, "newest"); err != nil || !cached || s.Content != "a" {
+		t.Fatalf("want a cache hit with content, got cached=%v %+v (%v)", cached, s, err)
+	}
+	if hits, misses := repo.Stats(); hits != 1 || misses != 0 {
+		t.Fatalf("warming must not count as lookups, got %d/%d", hits, misses)
+	}
+
+	// A Redis failure is reported to the caller
+	mr.Close()
+	if err := repo.Warm(ctx, 2); err == nil {
+		t.Fatal("want an error when Redis is down")
+	}
+}
+
+func TestCachedRepository_ListKeysEscapeFilterValues(t *testing.T) {
+	ctx := context.Background()
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("miniredis: %v", err)
+	}
+	defer mr.Close()
+	rcli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	primary := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{
+		ID: "secret", Content: "alice only", Tags: []string{"go"}, OwnerID: "alice",
+		Visibility: domain.VisibilityPrivate, CreatedAt: time.Now(),
+	}))
+	repo := NewSnippetRepository(primary, rcli, time.Minute)
+
+	// Alice's own page is cached under a key carrying her owner filter
+	mine, err := repo.List(ctx, 1, 10, "go", repository.WithOwner("alice"))
+	if err != nil || len(mine) != 1 {
+		t.Fatalf("alice's list: want her private snippet, got %d (%v)", len(mine), err)
+	}
+	if n, err := repo.Count(ctx, "go", repository.WithOwner("alice")); err != nil || n != 1 {
+		t.Fatalf("alice's count: want 1, got %d (%v)", n, err)
+	}
+
+	// A public request whose tag spells out the same separators must not reuse that entry
+	public, err := repo.List(ctx, 1, 10, "go:o:alice")
+	if err != nil || len(public) != 0 {
+		t.Fatalf("public list for tag %q: want no snippets, got %d (%v)", "go:o:alice", len(public), err)
+	}
+	if n, err := repo.Count(ctx, "go:o:alice"); err != nil || n != 0 {
+		t.Fatalf("public count for tag %q: want 0, got %d (%v)", "go:o:alice", n, err)
+	}
+
+	epoch := currentEpoch(t, repo)
+	collisions := []struct{ a, b string }{
+		{repo.keyListWithOptions(epoch, 1, 10, "go:o:alice", repository.ListOptions{}), repo.keyListWithOptions(epoch, 1, 10, "go", repository.ListOptions{OwnerID: "alice"})},
+		{repo.keyCount(epoch, "go:o:alice", repository.ListOptions{}), repo.keyCount(epoch, "go", repository.ListOptions{OwnerID: "alice"})},
+		{repo.keyListWithOptions(epoch, 1, 10, "", repository.ListOptions{Tags: []string{"a,b"}}), repo.keyListWithOptions(epoch, 1, 10, "", repository.ListOptions{Tags: []string{"a", "b"}})},
+		{repo.keyListWithOptions(epoch, 1, 10, "", repository.ListOptions{Query: "x:src:import"}), repo.keyListWithOptions(epoch, 1, 10, "", repository.ListOptions{Query: "x", Source: "import"})},
+	}
+	for _, c := range collisions {
+		if c.a == c.b {
+			t.Fatalf("different filters share the key %q", c.a)
+		}
+	}
+}
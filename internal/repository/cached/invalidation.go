@@ -0,0 +1,96 @@
+package cached
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// DefaultInvalidationChannel is the Redis pub/sub channel invalidations are broadcast on by default.
+const DefaultInvalidationChannel = "bonsai:invalidate"
+
+// invalidation is the message broadcast to other instances after a write.
+type invalidation struct {
+	// Origin identifies the publishing instance, which skips its own messages.
+	Origin string `json:"origin"`
+	// ID is the written snippet; empty for writes that only affect lists, e.g. purges.
+	ID string `json:"id,omitempty"`
+	// Evict asks peers to drop the cached snippet too. Inserts leave it, since the ID is new
+	// and the writer may just have warmed it.
+	Evict bool `json:"evict,omitempty"`
+}
+
+// WithInvalidationChannel broadcasts every write on the given Redis pub/sub channel so that other
+// instances running SubscribeInvalidations drop the affected snippet and list keys. An empty name
+// uses DefaultInvalidationChannel.
+func WithInvalidationChannel(name string) Option {
+	return func(r *SnippetRepository) {
+		if name == "" {
+			name = DefaultInvalidationChannel
+		}
+		r.channel, r.origin = name, uuid.New().String()
+	}
+}
+
+// publishInvalidation broadcasts a write best-effort; it is a no-op without an invalidation channel.
+func (r *SnippetRepository) publishInvalidation(ctx context.Context, id string, evict bool) {
+	if r.channel == "" {
+		return
+	}
+	data, _ := json.Marshal(invalidation{Origin: r.origin, ID: id, Evict: evict})
+	if err := r.redis.Publish(ctx, r.channel, data).Err(); err != nil {
+		logger.With(ctx, map[string]any{"channel": r.channel, "id": id, "error": err.Error()}).Warn("failed to publish cache invalidation")
+	}
+}
+
+// SubscribeInvalidations applies invalidations broadcast by other instances until ctx is cancelled,
+// then unsubscribes and returns nil. It has the shape of a worker.Job and requires
+// WithInvalidationChannel.
+func (r *SnippetRepository) SubscribeInvalidations(ctx context.Context) error {
+	if r.channel == "" {
+		return fmt.Errorf("subscribe invalidations: no channel configured")
+	}
+	sub := r.redis.Subscribe(ctx, r.channel)
+	defer func() { _ = sub.Close() }()
+	// wait for the subscription to be confirmed so no message published after startup is missed
+	if _, err := sub.Receive(ctx); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("subscribe %s: %w", r.channel, err)
+	}
+	logger.WithField(ctx, "channel", r.channel).Info("subscribed to cache invalidations")
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+			r.applyInvalidation(ctx, msg.Payload)
+		}
+	}
+}
+
+// applyInvalidation drops the keys a peer's write made stale.
+func (r *SnippetRepository) applyInvalidation(ctx context.Context, payload string) {
+	var inv invalidation
+	if err := json.Unmarshal([]byte(payload), &inv); err != nil {
+		logger.With(ctx, map[string]any{"channel": r.channel, "error": err.Error()}).Warn("ignoring malformed cache invalidation")
+		return
+	}
+	if inv.Origin == r.origin {
+		return
+	}
+	if inv.Evict && inv.ID != "" {
+		r.evictSnippet(ctx, inv.ID)
+	}
+	if err := r.invalidateListKeys(ctx); err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error()}).Warn("failed to invalidate list cache keys")
+	}
+}
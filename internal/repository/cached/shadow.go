@@ -0,0 +1,78 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// WithShadowReads re-reads a fraction p of cache hits from primary and logs and counts any
+// divergence, while still serving the cached value. It surfaces cache bugs in production at
+// the cost of a primary read on sampled hits. p is clamped to [0, 1]; the default 0 disables it.
+func WithShadowReads(p float64) Option {
+	return func(r *SnippetRepository) { r.shadowFraction = min(max(p, 0), 1) }
+}
+
+// sampleShadow rolls whether a cache hit gets a shadow read.
+func (r *SnippetRepository) sampleShadow() bool {
+	return r.shadowFraction > 0 && (r.shadowFraction >= 1 || r.rand() < r.shadowFraction)
+}
+
+// shadowSnippet compares a cached snippet with primary's copy.
+func (r *SnippetRepository) shadowSnippet(ctx context.Context, cached domain.Snippet) {
+	stored, err := r.primary.FindByID(ctx, cached.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		r.recordShadowMismatch(ctx, lookupSnippet, r.keySnippet(cached.ID), "missing from primary")
+	case err != nil:
+		logger.With(ctx, map[string]any{"id": cached.ID, "error": err.Error()}).Warn("shadow read failed")
+	case snippetsDiffer(cached, stored, true):
+		r.recordShadowMismatch(ctx, lookupSnippet, r.keySnippet(cached.ID), "snippet differs")
+	}
+}
+
+// shadowList compares a cached list page with a fresh page from primary.
+func (r *SnippetRepository) shadowList(ctx context.Context, k string, cached []domain.Snippet, o repository.ListOptions, page, limit int, tag string, opts ...repository.ListOption) {
+	items, err := r.primary.List(ctx, page, limit, tag, opts...)
+	if err != nil {
+		logger.With(ctx, map[string]any{"key": k, "error": err.Error()}).Warn("shadow read failed")
+		return
+	}
+	fresh := visibleListItems(items, o)
+	if len(fresh) != len(cached) {
+		r.recordShadowMismatch(ctx, lookupList, k, "page length differs")
+		return
+	}
+	for i := range fresh {
+		if fresh[i].ID != cached[i].ID || snippetsDiffer(cached[i], fresh[i], o.Content) {
+			r.recordShadowMismatch(ctx, lookupList, k, "page items differ")
+			return
+		}
+	}
+}
+
+// snippetsDiffer compares the fields a client sees. Times are compared as instants since the
+// cache and Postgres may report different locations.
+func snippetsDiffer(a, b domain.Snippet, withContent bool) bool {
+	return (withContent && a.Content != b.Content) ||
+		!slices.Equal(a.Tags, b.Tags) ||
+		!a.ExpiresAt.Equal(b.ExpiresAt) ||
+		!a.VisibleFrom.Equal(b.VisibleFrom) ||
+		a.Version != b.Version ||
+		a.Templated != b.Templated ||
+		a.Language != b.Language ||
+		a.Visibility != b.Visibility ||
+		a.Title != b.Title
+}
+
+// recordShadowMismatch logs and counts a cache entry that disagreed with primary.
+func (r *SnippetRepository) recordShadowMismatch(ctx context.Context, kind, key, reason string) {
+	logger.With(ctx, map[string]any{"kind": kind, "key": key, "reason": reason}).Warn("cache shadow read mismatch")
+	if r.metrics != nil {
+		r.metrics.ShadowMismatches.WithLabelValues(kind).Inc()
+	}
+}
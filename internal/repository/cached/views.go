@@ -0,0 +1,129 @@
+package cached
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// viewsKeyPrefix prefixes the per-snippet counters of reads not yet flushed to primary,
+// after the configured key prefix.
+const viewsKeyPrefix = "snippet:views:"
+
+func (r *SnippetRepository) keyViews(id string) string { return r.keyPrefix + viewsKeyPrefix + id }
+
+// viewIncrementTimeout bounds how long a read waits on Redis to count itself.
+const viewIncrementTimeout = 50 * time.Millisecond
+
+// IncrementView counts one read of a snippet with an atomic INCR and returns how many reads are
+// pending for it since the last flush. It gives up after a short timeout so a slow or
+// unreachable Redis does not hold up the read.
+func (r *SnippetRepository) IncrementView(ctx context.Context, id string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, viewIncrementTimeout)
+	defer cancel()
+	return r.redis.Incr(ctx, r.keyViews(id)).Result()
+}
+
+// AddViews adds view counts in primary and evicts the affected snippets, so cached copies do not
+// lag the stored counts once their pending reads are flushed.
+func (r *SnippetRepository) AddViews(ctx context.Context, views map[string]int64) error {
+	if err := r.primary.AddViews(ctx, views); err != nil || len(views) == 0 {
+		return err
+	}
+	keys := make([]string, 0, len(views))
+	for id := range views {
+		keys = append(keys, r.keySnippet(id))
+	}
+	if err := r.deleteKeys(ctx, keys); err != nil {
+		logger.With(ctx, map[string]any{"error": err.Error(), "snippets": len(keys)}).Warn("failed to evict snippets after adding views")
+	}
+	return nil
+}
+
+// limitedViewScript counts a read of a view-limited snippet unless its pending reads already
+// reached ARGV[1], so concurrent reads cannot overshoot the limit. It returns the pending count
+// and 1 when the read was refused.
+var limitedViewScript = redis.NewScript(`
+local n = tonumber(redis.call('GET', KEYS[1]) or '0')
+if n >= tonumber(ARGV[1]) then
+  return {n, 1}
+end
+return {redis.call('INCR', KEYS[1]), 0}
+`)
+
+// IncrementViewWithin counts one read of a snippet if fewer than allowed reads are pending,
+// checking and incrementing in one round trip. It reports the pending count and whether the
+// read was counted.
+func (r *SnippetRepository) IncrementViewWithin(ctx context.Context, id string, allowed int64) (int64, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, viewIncrementTimeout)
+	defer cancel()
+	res, err := limitedViewScript.Run(ctx, r.redis, []string{r.keyViews(id)}, allowed).Int64Slice()
+	if err != nil {
+		return 0, false, err
+	}
+	if len(res) != 2 {
+		return 0, false, fmt.Errorf("unexpected view script result %v", res)
+	}
+	return res[0], res[1] == 0, nil
+}
+
+// settleViewsScript subtracts flushed reads from a counter, dropping it once nothing is pending.
+var settleViewsScript = redis.NewScript(`
+local n = redis.call('DECRBY', KEYS[1], ARGV[1])
+if n <= 0 then
+  redis.call('DEL', KEYS[1])
+end
+return n
+`)
+
+// FlushViews moves pending view counters from Redis to primary and returns how many snippets
+// were updated. Counters are only decremented once primary stored their counts, so a read
+// never sees fewer views than it should: at worst a flushed count is briefly counted twice,
+// which keeps view limits conservative. A failed flush leaves the counters for the next one.
+func (r *SnippetRepository) FlushViews(ctx context.Context) (int, error) {
+	views := make(map[string]int64)
+	var cursor uint64
+	for {
+		keys, next, err := r.redis.Scan(ctx, cursor, scanPattern(r.keyPrefix+viewsKeyPrefix), 100).Result()
+		if err != nil {
+			return 0, err
+		}
+		for _, k := range keys {
+			val, err := r.redis.Get(ctx, k).Result()
+			if errors.Is(err, redis.Nil) {
+				continue
+			}
+			if err != nil {
+				return 0, err
+			}
+			n, err := strconv.ParseInt(val, 10, 64)
+			if err != nil || n <= 0 {
+				continue
+			}
+			views[strings.TrimPrefix(k, r.keyPrefix+viewsKeyPrefix)] = n
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	if len(views) == 0 {
+		return 0, nil
+	}
+	if err := r.AddViews(ctx, views); err != nil {
+		return 0, err
+	}
+	ctx = context.WithoutCancel(ctx)
+	for id, n := range views {
+		if err := settleViewsScript.Run(ctx, r.redis, []string{r.keyViews(id)}, n).Err(); err != nil {
+			logger.With(ctx, map[string]any{"id": id, "error": err.Error()}).Error("failed to settle flushed view count; it will be counted again")
+		}
+	}
+	return len(views), nil
+}
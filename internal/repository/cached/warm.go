@@ -0,0 +1,76 @@
+package cached
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// DefaultWarmListLimit is the page size of the list page Warm preloads unless WithWarmListPage
+// says otherwise; it matches the API's default page size.
+const DefaultWarmListLimit = 20
+
+// warmListPage is the list request Warm preloads.
+type warmListPage struct {
+	limit int
+	opts  []repository.ListOption
+}
+
+// WithWarmListPage sets the first-page list request Warm preloads. It should match what a plain
+// GET /v1/snippets asks for, e.g. the default page size and order. limit below 1 keeps
+// DefaultWarmListLimit.
+func WithWarmListPage(limit int, opts ...repository.ListOption) Option {
+	return func(r *SnippetRepository) {
+		if limit < 1 {
+			limit = DefaultWarmListLimit
+		}
+		r.warmPage = warmListPage{limit: limit, opts: opts}
+	}
+}
+
+// Warm preloads the n most recent snippets and the first page of the default list into Redis,
+// e.g. after a deploy so the first requests do not all miss. It returns the first failed primary
+// read or snippet write, including ctx ending; whatever was cached by then stays cached.
+func (r *SnippetRepository) Warm(ctx context.Context, n int) error {
+	start := time.Now()
+	warmed := 0
+	if n > 0 {
+		items, err := r.primary.List(ctx, 1, n, "", repository.WithContent())
+		if err != nil {
+			return fmt.Errorf("warm snippets: %w", err)
+		}
+		items = visibleListItems(items, repository.ListOptions{Content: true})
+		for i := 0; i < len(items); i += r.pipelineBatchSize {
+			batch := items[i:min(i+r.pipelineBatchSize, len(items))]
+			pipe := r.redis.Pipeline()
+			for _, s := range batch {
+				data, _ := json.Marshal(s)
+				pipe.Set(ctx, r.keySnippet(s.ID), data, r.snippetTTL(s))
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return fmt.Errorf("warm snippets: %w", err)
+			}
+			warmed += len(batch)
+		}
+	}
+
+	page := r.warmPage
+	if page.limit < 1 {
+		page.limit = DefaultWarmListLimit
+	}
+	epoch, err := r.epoch(ctx)
+	if err != nil {
+		return fmt.Errorf("warm list: %w", err)
+	}
+	o := repository.NewListOptions(page.opts...)
+	k := r.keyListWithOptions(epoch, 1, page.limit, "", o)
+	if _, err := r.loadList(ctx, k, o, 1, page.limit, "", page.opts...); err != nil {
+		return fmt.Errorf("warm list: %w", err)
+	}
+	logger.With(ctx, map[string]any{"snippets": warmed, "list_key": k, "took": time.Since(start).String()}).Info("cache warmed")
+	return nil
+}
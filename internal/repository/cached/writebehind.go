@@ -0,0 +1,129 @@
+package cached
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// DefaultWriteBehindBuffer is how many cache writes WithWriteBehind queues by default.
+const DefaultWriteBehindBuffer = 1024
+
+// writeBehindDrainTimeout bounds how long RunWriteBehind keeps draining queued writes on shutdown.
+const writeBehindDrainTimeout = 5 * time.Second
+
+// cacheWrite is a queued write of a snippet into Redis. seq matches pending[id] while the write
+// is still the latest one queued for the snippet and no eviction has happened since.
+type cacheWrite struct {
+	ctx context.Context
+	s   domain.Snippet
+	seq uint64
+}
+
+// writeBehind queues cache writes made by Insert and Update so they leave the request path.
+type writeBehind struct {
+	queue chan cacheWrite
+	// mu guards pending and seq; it is only held briefly.
+	mu      sync.Mutex
+	pending map[string]uint64
+	seq     uint64
+	// applyMu is held while a queued write is checked and applied, so an eviction either cancels
+	// the write or runs after it.
+	applyMu sync.Mutex
+}
+
+// WithWriteBehind makes Insert and Update queue their cache writes for RunWriteBehind instead of
+// writing Redis before returning, so a write responds as soon as primary commits. Only cache
+// writes are deferred: primary writes and invalidations stay synchronous, and a full queue drops
+// the cache write with a warning. size below 1 uses DefaultWriteBehindBuffer.
+func WithWriteBehind(size int) Option {
+	return func(r *SnippetRepository) {
+		if size < 1 {
+			size = DefaultWriteBehindBuffer
+		}
+		r.writeBehind = &writeBehind{queue: make(chan cacheWrite, size), pending: make(map[string]uint64)}
+	}
+}
+
+// populate caches s after a write: queued under WithWriteBehind, immediately otherwise.
+func (r *SnippetRepository) populate(ctx context.Context, s domain.Snippet) {
+	wb := r.writeBehind
+	if wb == nil {
+		r.cacheSnippet(ctx, s)
+		return
+	}
+	wb.mu.Lock()
+	defer wb.mu.Unlock()
+	wb.seq++
+	select {
+	case wb.queue <- cacheWrite{ctx: context.WithoutCancel(ctx), s: s, seq: wb.seq}:
+		wb.pending[s.ID] = wb.seq
+	default:
+		// An older queued write for the snippet is now stale; leave the entry to the next read.
+		delete(wb.pending, s.ID)
+		logger.With(ctx, map[string]any{"id": s.ID, "buffer": cap(wb.queue)}).Warn("cache write-behind queue full, dropping cache write")
+	}
+}
+
+// cancelPending drops any queued cache write for id, waiting for one being applied to finish.
+// Callers evict the snippet afterwards.
+func (r *SnippetRepository) cancelPending(id string) {
+	wb := r.writeBehind
+	if wb == nil {
+		return
+	}
+	wb.applyMu.Lock()
+	wb.mu.Lock()
+	delete(wb.pending, id)
+	wb.mu.Unlock()
+	wb.applyMu.Unlock()
+}
+
+// apply writes w to Redis unless a newer write or an eviction for the snippet superseded it.
+func (r *SnippetRepository) apply(w cacheWrite) {
+	wb := r.writeBehind
+	wb.applyMu.Lock()
+	defer wb.applyMu.Unlock()
+	wb.mu.Lock()
+	current := wb.pending[w.s.ID] == w.seq
+	if current {
+		delete(wb.pending, w.s.ID)
+	}
+	wb.mu.Unlock()
+	if current {
+		r.cacheSnippet(w.ctx, w.s)
+	}
+}
+
+// RunWriteBehind applies queued cache writes until ctx is cancelled, then drains what is still
+// queued (for at most a few seconds) and returns nil. It has the shape of a worker.Job and
+// requires WithWriteBehind.
+func (r *SnippetRepository) RunWriteBehind(ctx context.Context) error {
+	wb := r.writeBehind
+	if wb == nil {
+		return fmt.Errorf("run write-behind: not enabled")
+	}
+	for {
+		select {
+		case w := <-wb.queue:
+			r.apply(w)
+		case <-ctx.Done():
+			deadline := time.After(writeBehindDrainTimeout)
+			for {
+				select {
+				case w := <-wb.queue:
+					r.apply(w)
+				case <-deadline:
+					logger.WithField(ctx, "dropped", len(wb.queue)).Warn("cache write-behind drain timed out")
+					return nil
+				default:
+					return nil
+				}
+			}
+		}
+	}
+}
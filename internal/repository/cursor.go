@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned by DecodeCursor for tokens it did not produce.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last snippet seen in a listing ordered by created_at DESC, id DESC.
+// ListAfter returns the snippets strictly after it in that order; the zero Cursor starts at the top.
+type Cursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// IsZero reports whether the cursor starts at the top of the listing.
+func (c Cursor) IsZero() bool { return c.CreatedAt.IsZero() && c.ID == "" }
+
+// Encode returns the cursor as an opaque URL-safe token.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by Cursor.Encode.
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
@@ -9,13 +9,15 @@ import (
 
 	"github.com/roguepikachu/bonsai/internal/domain"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 )
 
 // SnippetRepository is an in-memory fake implementing repository.SnippetRepository.
 // It's intentionally simple and not concurrency-safe (tests typically run single-threaded).
 type SnippetRepository struct {
-	byID map[string]domain.Snippet
-	now  func() time.Time
+	byID     map[string]domain.Snippet
+	versions map[string][]domain.SnippetVersion
+	now      func() time.Time
 }
 
 // Option configures the fake repository.
@@ -35,41 +37,53 @@ func WithItems(items ...domain.Snippet) Option {
 
 // NewSnippetRepository creates a new in-memory fake repo.
 func NewSnippetRepository(opts ...Option) *SnippetRepository {
-	r := &SnippetRepository{byID: make(map[string]domain.Snippet), now: time.Now}
+	r := &SnippetRepository{byID: make(map[string]domain.Snippet), versions: make(map[string][]domain.SnippetVersion), now: time.Now}
 	for _, opt := range opts {
 		opt(r)
 	}
 	return r
 }
 
-// Insert stores or overwrites the given snippet by its ID.
+// Insert stores or overwrites the given snippet by its ID and records its first version.
 func (r *SnippetRepository) Insert(_ context.Context, s domain.Snippet) error {
 	r.byID[s.ID] = s
+	r.versions[s.ID] = []domain.SnippetVersion{domain.VersionOf(s, s.CreatedAt)}
 	return nil
 }
 
-// FindByID returns a snippet by ID or repository.ErrNotFound if missing.
-func (r *SnippetRepository) FindByID(_ context.Context, id string) (domain.Snippet, error) {
-	if s, ok := r.byID[id]; ok {
+// FindByID returns a snippet by ID or repository.ErrNotFound if missing or soft-deleted.
+func (r *SnippetRepository) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	if s, ok := r.live(ctx, id); ok {
 		return s, nil
 	}
 	return domain.Snippet{}, repository.ErrNotFound
 }
 
-// List returns non-expired snippets filtered by tag and paginated.
-func (r *SnippetRepository) List(_ context.Context, page, limit int, tag string) ([]domain.Snippet, error) {
-	now := r.now()
-	items := make([]domain.Snippet, 0, len(r.byID))
-	for _, s := range r.byID {
-		if !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt) {
-			continue
-		}
-		if tag != "" && !containsTag(s.Tags, tag) {
-			continue
+// FindByIDs returns the stored snippets among ids, skipping unknown and soft-deleted ones.
+func (r *SnippetRepository) FindByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error) {
+	var found []domain.Snippet
+	for _, id := range ids {
+		if s, ok := r.live(ctx, id); ok {
+			found = append(found, s)
 		}
-		items = append(items, s)
 	}
-	sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+	return found, nil
+}
+
+// live returns the stored snippet unless it is soft-deleted and ctx does not include deleted ones.
+func (r *SnippetRepository) live(ctx context.Context, id string) (domain.Snippet, bool) {
+	s, ok := r.byID[id]
+	if !ok || (!s.DeletedAt.IsZero() && !ctxutil.IncludeDeleted(ctx)) {
+		return domain.Snippet{}, false
+	}
+	return s, true
+}
+
+// List returns non-expired snippets filtered by tag (and owner or content substring, if set) and paginated.
+func (r *SnippetRepository) List(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	o := repository.NewListOptions(opts...)
+	items := r.filter(ctx, tag, o)
+	sort.SliceStable(items, func(i, j int) bool { return sortLess(o.Sort, items[i], items[j]) })
 	if page < 1 {
 		page = 1
 	}
@@ -87,6 +101,119 @@ func (r *SnippetRepository) List(_ context.Context, page, limit int, tag string)
 	return items[start:end], nil
 }
 
+// ListAfter returns up to limit snippets after cursor, ordered by created_at DESC, id DESC.
+func (r *SnippetRepository) ListAfter(ctx context.Context, cursor repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	items := r.filter(ctx, tag, repository.NewListOptions(opts...))
+	sort.Slice(items, func(i, j int) bool { return cursorBefore(items[j], items[i]) })
+	if limit < 1 {
+		limit = 1
+	}
+	res := make([]domain.Snippet, 0, limit)
+	for _, s := range items {
+		if !cursor.IsZero() && !cursorBefore(s, domain.Snippet{ID: cursor.ID, CreatedAt: cursor.CreatedAt}) {
+			continue
+		}
+		if len(res) == limit {
+			break
+		}
+		res = append(res, s)
+	}
+	return res, nil
+}
+
+// sortLess orders two snippets like the postgres repository orders a sort value.
+// Snippets without expiry sort last by expiry in either direction, and ties fall back to
+// (created_at, id) so equal timestamps always sort the same way.
+func sortLess(sortBy string, a, b domain.Snippet) bool {
+	switch sortBy {
+	case repository.SortCreatedAtAsc:
+		return cursorBefore(a, b)
+	case repository.SortExpiresAtAsc, repository.SortExpiresAtDesc:
+		if a.ExpiresAt.Equal(b.ExpiresAt) {
+			return cursorBefore(b, a)
+		}
+		if a.ExpiresAt.IsZero() || b.ExpiresAt.IsZero() {
+			return b.ExpiresAt.IsZero()
+		}
+		if sortBy == repository.SortExpiresAtAsc {
+			return a.ExpiresAt.Before(b.ExpiresAt)
+		}
+		return a.ExpiresAt.After(b.ExpiresAt)
+	default:
+		return cursorBefore(b, a)
+	}
+}
+
+// cursorBefore reports whether a sorts below b in (created_at, id) order.
+func cursorBefore(a, b domain.Snippet) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// Count returns how many snippets List would return across all pages for the same filters.
+func (r *SnippetRepository) Count(ctx context.Context, tag string, opts ...repository.ListOption) (int, error) {
+	return len(r.filter(ctx, tag, repository.NewListOptions(opts...))), nil
+}
+
+// filter returns the active, visible, live snippets matching tag and list options, unordered.
+func (r *SnippetRepository) filter(ctx context.Context, tag string, o repository.ListOptions) []domain.Snippet {
+	now := r.now()
+	tags := o.TagSet(tag)
+	includeDeleted := ctxutil.IncludeDeleted(ctx)
+	items := make([]domain.Snippet, 0, len(r.byID))
+	for _, s := range r.byID {
+		if !s.DeletedAt.IsZero() && !includeDeleted {
+			continue
+		}
+		if !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt) {
+			continue
+		}
+		if !s.IsVisibleAt(now) {
+			continue
+		}
+		if len(tags) > 0 && !matchTags(s.Tags, tags, o.MatchAny()) {
+			continue
+		}
+		if len(o.ExcludeTags) > 0 && matchTags(s.Tags, o.ExcludeTags, true) {
+			continue
+		}
+		if o.OwnerID != "" && s.OwnerID != o.OwnerID {
+			continue
+		}
+		if o.OwnerID == "" && s.Visibility == domain.VisibilityPrivate {
+			continue
+		}
+		if o.Source != "" && s.Source != o.Source {
+			continue
+		}
+		if !o.CreatedAfter.IsZero() && !s.CreatedAt.After(o.CreatedAfter) &&
+			!(includeDeleted && s.DeletedAt.After(o.CreatedAfter)) {
+			continue
+		}
+		if q := strings.ToLower(o.Query); q != "" && !strings.Contains(strings.ToLower(s.Content), q) && !strings.Contains(strings.ToLower(s.Title), q) {
+			continue
+		}
+		items = append(items, s)
+	}
+	return items
+}
+
+// matchTags reports whether have contains all (or, with matchAny, at least one) of want.
+func matchTags(have, want []string, matchAny bool) bool {
+	for _, w := range want {
+		found := containsTag(have, w)
+		if found && matchAny {
+			return true
+		}
+		if !found && !matchAny {
+			return false
+		}
+	}
+	return !matchAny
+}
+
 func containsTag(tags []string, want string) bool {
 	for _, t := range tags {
 		if strings.EqualFold(t, want) {
@@ -96,21 +223,141 @@ func containsTag(tags []string, want string) bool {
 	return false
 }
 
-// Update modifies an existing snippet by its ID.
+// Update modifies an existing, live snippet by its ID.
 func (r *SnippetRepository) Update(_ context.Context, s domain.Snippet) error {
 	existing, ok := r.byID[s.ID]
-	if !ok {
+	if !ok || !existing.DeletedAt.IsZero() {
 		return repository.ErrNotFound
 	}
 	// Preserve the original CreatedAt timestamp
 	s.CreatedAt = existing.CreatedAt
 	r.byID[s.ID] = s
+	r.versions[s.ID] = append(r.versions[s.ID], domain.VersionOf(s, r.now()))
+	return nil
+}
+
+// FindVersion returns a recorded version of a snippet or repository.ErrNotFound.
+func (r *SnippetRepository) FindVersion(_ context.Context, id string, version int) (domain.SnippetVersion, error) {
+	for _, v := range r.versions[id] {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return domain.SnippetVersion{}, repository.ErrNotFound
+}
+
+// FindByContentHash returns the newest non-expired snippet with the given content hash.
+func (r *SnippetRepository) FindByContentHash(_ context.Context, hash string) (domain.Snippet, error) {
+	now := r.now()
+	var (
+		found domain.Snippet
+		ok    bool
+	)
+	for _, s := range r.byID {
+		if s.ContentHash != hash {
+			continue
+		}
+		if !s.ExpiresAt.IsZero() && !now.Before(s.ExpiresAt) {
+			continue
+		}
+		if !s.DeletedAt.IsZero() {
+			continue
+		}
+		if !ok || s.CreatedAt.After(found.CreatedAt) {
+			found, ok = s, true
+		}
+	}
+	if !ok {
+		return domain.Snippet{}, repository.ErrNotFound
+	}
+	return found, nil
+}
+
+// ListTags counts active snippets per tag, most used first and ties by tag.
+func (r *SnippetRepository) ListTags(_ context.Context) ([]repository.TagCount, error) {
+	counts := map[string]int{}
+	for _, s := range r.filter(context.Background(), "", repository.ListOptions{}) {
+		seen := map[string]bool{}
+		for _, t := range s.Tags {
+			if !seen[t] {
+				seen[t] = true
+				counts[t]++
+			}
+		}
+	}
+	res := make([]repository.TagCount, 0, len(counts))
+	for t, n := range counts {
+		res = append(res, repository.TagCount{Tag: t, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Tag < res[j].Tag
+	})
+	return res, nil
+}
+
+// TagExists reports whether any stored snippet, expired or not, carries the tag.
+func (r *SnippetRepository) TagExists(_ context.Context, tag string) (bool, error) {
+	for _, s := range r.byID {
+		if containsTag(s.Tags, tag) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DeleteExpired removes snippets whose expiry is before now, with their versions.
+func (r *SnippetRepository) DeleteExpired(_ context.Context) (int64, error) {
+	now := r.now()
+	var n int64
+	for id, s := range r.byID {
+		if !s.ExpiresAt.IsZero() && s.ExpiresAt.Before(now) {
+			r.DeleteByID(id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// AddViews adds read counts to existing snippets.
+func (r *SnippetRepository) AddViews(_ context.Context, views map[string]int64) error {
+	for id, n := range views {
+		if s, ok := r.byID[id]; ok {
+			s.Views += n
+			r.byID[id] = s
+		}
+	}
+	return nil
+}
+
+// SoftDelete marks a live snippet deleted at the given time.
+func (r *SnippetRepository) SoftDelete(_ context.Context, id string, at time.Time) error {
+	s, ok := r.byID[id]
+	if !ok || !s.DeletedAt.IsZero() {
+		return repository.ErrNotFound
+	}
+	s.DeletedAt = at
+	r.byID[id] = s
+	return nil
+}
+
+// Restore clears the deletion mark of a soft-deleted snippet.
+func (r *SnippetRepository) Restore(_ context.Context, id string) error {
+	s, ok := r.byID[id]
+	if !ok || s.DeletedAt.IsZero() {
+		return repository.ErrNotFound
+	}
+	s.DeletedAt = time.Time{}
+	r.byID[id] = s
 	return nil
 }
 
 // DeleteByID removes a snippet by ID (for testing purposes).
 func (r *SnippetRepository) DeleteByID(id string) {
 	delete(r.byID, id)
+	delete(r.versions, id)
 }
 
 var _ repository.SnippetRepository = (*SnippetRepository)(nil)
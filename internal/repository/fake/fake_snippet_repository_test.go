@@ -9,6 +9,7 @@ import (
 
 	"github.com/roguepikachu/bonsai/internal/domain"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 )
 
 func TestFakeRepo_List_FilterAndExpiry(t *testing.T) {
@@ -30,6 +31,53 @@ func TestFakeRepo_List_FilterAndExpiry(t *testing.T) {
 	}
 }
 
+func TestFakeRepo_Count_ExcludesExpired(t *testing.T) {
+	r := NewSnippetRepository()
+	now := time.Now()
+	_ = r.Insert(context.Background(), domain.Snippet{ID: "1", CreatedAt: now, Tags: []string{"go"}})
+	_ = r.Insert(context.Background(), domain.Snippet{ID: "2", CreatedAt: now, Tags: []string{"go"}})
+	_ = r.Insert(context.Background(), domain.Snippet{ID: "3", CreatedAt: now, Tags: []string{"go"}, ExpiresAt: now.Add(-time.Minute)})
+	_ = r.Insert(context.Background(), domain.Snippet{ID: "4", CreatedAt: now, Tags: []string{"web"}})
+
+	if n, _ := r.Count(context.Background(), "go"); n != 2 {
+		t.Fatalf("want 2 active go snippets, got %d", n)
+	}
+	if n, _ := r.Count(context.Background(), ""); n != 3 {
+		t.Fatalf("want 3 active snippets, got %d", n)
+	}
+}
+
+func TestFakeRepo_ListAfter_StableUnderInserts(t *testing.T) {
+	ctx := context.Background()
+	r := NewSnippetRepository()
+	now := time.Now()
+	// b and c share a timestamp, so the id breaks the tie
+	_ = r.Insert(ctx, domain.Snippet{ID: "a", CreatedAt: now.Add(-3 * time.Second)})
+	_ = r.Insert(ctx, domain.Snippet{ID: "b", CreatedAt: now.Add(-2 * time.Second)})
+	_ = r.Insert(ctx, domain.Snippet{ID: "c", CreatedAt: now.Add(-2 * time.Second)})
+	_ = r.Insert(ctx, domain.Snippet{ID: "d", CreatedAt: now.Add(-time.Second)})
+
+	first, _ := r.ListAfter(ctx, repository.Cursor{}, 2, "")
+	if len(first) != 2 || first[0].ID != "d" || first[1].ID != "c" {
+		t.Fatalf("want [d c], got %v", ids(first))
+	}
+	// a snippet created between pages must not shift the next page
+	_ = r.Insert(ctx, domain.Snippet{ID: "e", CreatedAt: now})
+	last := first[len(first)-1]
+	second, _ := r.ListAfter(ctx, repository.Cursor{CreatedAt: last.CreatedAt, ID: last.ID}, 2, "")
+	if len(second) != 2 || second[0].ID != "b" || second[1].ID != "a" {
+		t.Fatalf("want [b a], got %v", ids(second))
+	}
+}
+
+func ids(items []domain.Snippet) []string {
+	out := make([]string, 0, len(items))
+	for _, s := range items {
+		out = append(out, s.ID)
+	}
+	return out
+}
+
 func TestFakeRepo_List_PaginationBounds(t *testing.T) {
 	r := NewSnippetRepository()
 	now := time.Now()
@@ -273,6 +321,49 @@ func TestFakeRepo_List_MultipleTagFilter(t *testing.T) {
 	}
 }
 
+// tagFilterSnippets are the snippets the tag filter tests list.
+func tagFilterSnippets(now time.Time) Option {
+	return WithItems(
+		domain.Snippet{ID: "go1", CreatedAt: now, Tags: []string{"go", "backend"}},
+		domain.Snippet{ID: "go2", CreatedAt: now.Add(-time.Hour), Tags: []string{"go", "cli"}},
+		domain.Snippet{ID: "py1", CreatedAt: now.Add(-2 * time.Hour), Tags: []string{"python", "backend"}},
+		domain.Snippet{ID: "js1", CreatedAt: now.Add(-3 * time.Hour), Tags: []string{"javascript", "frontend"}},
+		domain.Snippet{ID: "go3", CreatedAt: now.Add(-4 * time.Hour), Tags: []string{"go"}},
+	)
+}
+
+func TestFakeRepo_List_TagMatch(t *testing.T) {
+	r := NewSnippetRepository(tagFilterSnippets(time.Now()))
+	ctx := context.Background()
+
+	// Several tags: all of them, or any of them
+	all, _ := r.List(ctx, 1, 10, "", repository.WithTags(repository.TagMatchAll, "go", "backend"))
+	if fmt.Sprint(ids(all)) != "[go1]" {
+		t.Fatalf("expected [go1] for go AND backend, got %v", ids(all))
+	}
+	anyOf, _ := r.List(ctx, 1, 10, "", repository.WithTags(repository.TagMatchAny, "cli", "frontend"))
+	if fmt.Sprint(ids(anyOf)) != "[go2 js1]" {
+		t.Fatalf("expected [go2 js1] for cli OR frontend, got %v", ids(anyOf))
+	}
+	if n, _ := r.Count(ctx, "", repository.WithTags(repository.TagMatchAny, "python", "javascript")); n != 2 {
+		t.Fatalf("expected count 2 for python OR javascript, got %d", n)
+	}
+}
+
+func TestFakeRepo_List_ExcludeTags(t *testing.T) {
+	r := NewSnippetRepository(tagFilterSnippets(time.Now()))
+	ctx := context.Background()
+
+	// Excluded tags drop snippets carrying any of them
+	notBackend, _ := r.List(ctx, 1, 10, "go", repository.WithExcludeTags("backend", "cli"))
+	if fmt.Sprint(ids(notBackend)) != "[go3]" {
+		t.Fatalf("expected [go3] for go without backend or cli, got %v", ids(notBackend))
+	}
+	if n, _ := r.Count(ctx, "", repository.WithExcludeTags("go")); n != 2 {
+		t.Fatalf("expected count 2 without go, got %d", n)
+	}
+}
+
 func TestFakeRepo_WithOptions(t *testing.T) {
 	now := time.Now()
 	customTime := now.Add(-24 * time.Hour)
@@ -320,6 +411,34 @@ func TestFakeRepo_WithOptions(t *testing.T) {
 	}
 }
 
+func TestFakeRepo_List_Sort(t *testing.T) {
+	r := NewSnippetRepository()
+	ctx := context.Background()
+	now := time.Now()
+	_ = r.Insert(ctx, domain.Snippet{ID: "soon", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(time.Hour)})
+	_ = r.Insert(ctx, domain.Snippet{ID: "later", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(2 * time.Hour)})
+	_ = r.Insert(ctx, domain.Snippet{ID: "never", CreatedAt: now})
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"never", "later", "soon"}},
+		{repository.SortCreatedAtAsc, []string{"soon", "later", "never"}},
+		{repository.SortExpiresAtAsc, []string{"soon", "later", "never"}},
+		{repository.SortExpiresAtDesc, []string{"later", "soon", "never"}},
+	}
+	for _, tt := range tests {
+		got, err := r.List(ctx, 1, 10, "", repository.WithSort(tt.sort))
+		if err != nil {
+			t.Fatalf("list %q: %v", tt.sort, err)
+		}
+		if fmt.Sprint(ids(got)) != fmt.Sprint(tt.want) {
+			t.Fatalf("sort %q: want %v, got %v", tt.sort, tt.want, ids(got))
+		}
+	}
+}
+
 func TestFakeRepo_List_OrderByCreatedAtDesc(t *testing.T) {
 	r := NewSnippetRepository()
 	ctx := context.Background()
@@ -1149,3 +1268,143 @@ func TestContainsTag(t *testing.T) {
 		})
 	}
 }
+
+func TestFakeRepo_List_QueryAndTagCombined(t *testing.T) {
+	r := NewSnippetRepository()
+	now := time.Now()
+	ctx := context.Background()
+	_ = r.Insert(ctx, domain.Snippet{ID: "both", Content: "spawn a Goroutine", CreatedAt: now, Tags: []string{"go"}})
+	_ = r.Insert(ctx, domain.Snippet{ID: "tag-only", Content: "channels", CreatedAt: now, Tags: []string{"go"}})
+	_ = r.Insert(ctx, domain.Snippet{ID: "text-only", Content: "goroutine leak", CreatedAt: now, Tags: []string{"rust"}})
+	_ = r.Insert(ctx, domain.Snippet{ID: "expired", Content: "goroutine", CreatedAt: now, Tags: []string{"go"}, ExpiresAt: now.Add(-time.Minute)})
+
+	got, err := r.List(ctx, 1, 10, "go", repository.WithQuery("goroutine"))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != "both" {
+		t.Fatalf("want only snippet matching tag and text, got %+v", got)
+	}
+
+	// Each constraint alone matches more
+	byTag, _ := r.List(ctx, 1, 10, "go")
+	if len(byTag) != 2 {
+		t.Fatalf("want 2 tag matches, got %d", len(byTag))
+	}
+	byText, _ := r.List(ctx, 1, 10, "", repository.WithQuery("goroutine"))
+	if len(byText) != 2 {
+		t.Fatalf("want 2 text matches, got %d", len(byText))
+	}
+}
+
+func TestFakeRepo_List_SourceFilter(t *testing.T) {
+	now := time.Now()
+	r := NewSnippetRepository(WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now, Source: domain.SourceAPI},
+		domain.Snippet{ID: "i", CreatedAt: now.Add(-time.Minute), Source: domain.SourceImport},
+		domain.Snippet{ID: "b", CreatedAt: now.Add(-2 * time.Minute), Source: domain.SourceBatch},
+	))
+	ctx := context.Background()
+	got, err := r.List(ctx, 1, 10, "", repository.WithSource(domain.SourceImport))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if fmt.Sprint(ids(got)) != "[i]" {
+		t.Fatalf("want [i], got %v", ids(got))
+	}
+	if n, _ := r.Count(ctx, "", repository.WithSource(domain.SourceAPI)); n != 1 {
+		t.Fatalf("want count 1, got %d", n)
+	}
+}
+
+func TestFakeRepo_ListTags(t *testing.T) {
+	now := time.Now()
+	r := NewSnippetRepository(WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now, Tags: []string{"go", "web", "go"}},
+		domain.Snippet{ID: "b", CreatedAt: now, Tags: []string{"go", "cli"}},
+		domain.Snippet{ID: "c", CreatedAt: now, Tags: []string{"web"}},
+		domain.Snippet{ID: "expired", CreatedAt: now, Tags: []string{"go", "old"}, ExpiresAt: now.Add(-time.Minute)},
+	))
+	got, err := r.ListTags(context.Background())
+	if err != nil {
+		t.Fatalf("list tags: %v", err)
+	}
+	if fmt.Sprint(got) != "[{go 2} {web 2} {cli 1}]" {
+		t.Fatalf("want counts by use then tag, excluding expired, got %v", got)
+	}
+}
+
+func TestFakeRepo_SoftDeleteAndRestore(t *testing.T) {
+	now := time.Now()
+	r := NewSnippetRepository(WithItems(
+		domain.Snippet{ID: "a", CreatedAt: now, Tags: []string{"go"}},
+		domain.Snippet{ID: "b", CreatedAt: now.Add(-time.Minute), Tags: []string{"go"}},
+	))
+	ctx := context.Background()
+	if err := r.SoftDelete(ctx, "a", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if err := r.SoftDelete(ctx, "a", now); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := r.FindByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	got, _ := r.List(ctx, 1, 10, "go")
+	if fmt.Sprint(ids(got)) != "[b]" {
+		t.Fatalf("want [b] listed, got %v", ids(got))
+	}
+	if err := r.Update(ctx, domain.Snippet{ID: "a"}); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound updating a deleted snippet, got %v", err)
+	}
+
+	admin := ctxutil.WithIncludeDeleted(ctx)
+	s, err := r.FindByID(admin, "a")
+	if err != nil || !s.DeletedAt.Equal(now) {
+		t.Fatalf("want deleted snippet with DeletedAt, got %+v, %v", s, err)
+	}
+	if n, _ := r.Count(admin, "go"); n != 2 {
+		t.Fatalf("want 2 counted including deleted, got %d", n)
+	}
+
+	if err := r.Restore(ctx, "a"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := r.Restore(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound restoring a live snippet, got %v", err)
+	}
+	if s, err := r.FindByID(ctx, "a"); err != nil || !s.DeletedAt.IsZero() {
+		t.Fatalf("want restored snippet live, got %+v, %v", s, err)
+	}
+}
+
+func TestFakeRepo_List_CreatedAfter(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-time.Hour)
+	r := NewSnippetRepository(WithItems(
+		domain.Snippet{ID: "old", CreatedAt: now.Add(-2 * time.Hour)},
+		domain.Snippet{ID: "new", CreatedAt: now.Add(-time.Minute)},
+		domain.Snippet{ID: "gone", CreatedAt: now.Add(-2 * time.Hour)},
+		domain.Snippet{ID: "gone-long-ago", CreatedAt: now.Add(-3 * time.Hour)},
+	))
+	ctx := context.Background()
+	if err := r.SoftDelete(ctx, "gone", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if err := r.SoftDelete(ctx, "gone-long-ago", now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	got, _ := r.List(ctx, 1, 10, "", repository.WithCreatedAfter(since))
+	if fmt.Sprint(ids(got)) != "[new]" {
+		t.Fatalf("want only snippets created since, got %v", ids(got))
+	}
+	admin := ctxutil.WithIncludeDeleted(ctx)
+	got, _ = r.List(admin, 1, 10, "", repository.WithCreatedAfter(since))
+	if fmt.Sprint(ids(got)) != "[new gone]" {
+		t.Fatalf("want snippets created or deleted since, got %v", ids(got))
+	}
+	if n, _ := r.Count(admin, "", repository.WithCreatedAfter(since)); n != 2 {
+		t.Fatalf("want 2 counted, got %d", n)
+	}
+}
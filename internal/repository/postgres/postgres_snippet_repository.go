@@ -6,23 +6,107 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/roguepikachu/bonsai/internal/domain"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 	"github.com/roguepikachu/bonsai/pkg/logger"
 )
 
+// DefaultMaxTags is how many tags a snippet may carry unless WithMaxTags says otherwise.
+const DefaultMaxTags = 256
+
 // SnippetRepository implements repository.SnippetRepository using Postgres.
 type SnippetRepository struct {
 	pool *pgxpool.Pool
+	// replica, when set, serves FindByID, FindByIDs, List, ListAfter and Count; see reader.
+	replica *pgxpool.Pool
+	maxTags int
+	// purgeGrace delays DeleteExpired until snippets have been expired this long.
+	purgeGrace time.Duration
+}
+
+// Option configures SnippetRepository.
+type Option func(*SnippetRepository)
+
+// WithMaxTags caps how many tags Insert and Update accept; values below 1 use DefaultMaxTags.
+func WithMaxTags(n int) Option {
+	return func(r *SnippetRepository) {
+		if n > 0 {
+			r.maxTags = n
+		}
+	}
+}
+
+// WithPurgeGrace makes DeleteExpired keep expired snippets until they have been expired for
+// grace, so reads within a grace window can still find them.
+func WithPurgeGrace(grace time.Duration) Option {
+	return func(r *SnippetRepository) { r.purgeGrace = max(grace, 0) }
+}
+
+// WithReplica routes reads to a replica pool; writes and every other query keep using the
+// primary. Reads made with ctxutil.WithReadPrimary also use the primary. A nil pool is ignored.
+func WithReplica(replica *pgxpool.Pool) Option {
+	return func(r *SnippetRepository) { r.replica = replica }
 }
 
 // NewSnippetRepository creates a new Postgres-backed snippet repository.
-func NewSnippetRepository(pool *pgxpool.Pool) *SnippetRepository {
-	return &SnippetRepository{pool: pool}
+func NewSnippetRepository(pool *pgxpool.Pool, opts ...Option) *SnippetRepository {
+	r := &SnippetRepository{pool: pool, maxTags: DefaultMaxTags}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// reader returns the pool for a routed read: the replica unless none is configured or ctx asks
+// for the primary.
+func (r *SnippetRepository) reader(ctx context.Context) *pgxpool.Pool {
+	if r.replica == nil || ctxutil.ReadPrimary(ctx) {
+		return r.pool
+	}
+	return r.replica
+}
+
+// marshalTags encodes a tag set for the tags column, rejecting sets over the configured cap
+// with repository.ErrTooManyTags before they reach the database.
+func (r *SnippetRepository) marshalTags(tags []string) (string, error) {
+	if len(tags) > r.maxTags {
+		return "", fmt.Errorf("%d tags, at most %d allowed: %w", len(tags), r.maxTags, repository.ErrTooManyTags)
+	}
+	b, err := json.Marshal(tags)
+	if err != nil {
+		return "", fmt.Errorf("marshal tags: %w", err)
+	}
+	return string(b), nil
+}
+
+// programLimitExceeded is the SQLSTATE Postgres reports when a value exceeds an internal size limit.
+const programLimitExceeded = "54000"
+
+// writeError wraps a failed snippet write, reporting Postgres size limits hit by the tag set,
+// such as an oversized index row, as repository.ErrTooManyTags.
+func writeError(op string, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == programLimitExceeded {
+		return fmt.Errorf("%s: %s: %w", op, pgErr.Message, repository.ErrTooManyTags)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// Now returns the database's current time, which expiry filters compare against.
+func (r *SnippetRepository) Now(ctx context.Context) (time.Time, error) {
+	var now time.Time
+	if err := r.pool.QueryRow(ctx, `SELECT NOW()`).Scan(&now); err != nil {
+		return time.Time{}, fmt.Errorf("select now: %w", err)
+	}
+	return now, nil
 }
 
 // EnsureSchema creates required tables if they don't exist.
@@ -44,11 +128,53 @@ CREATE TABLE IF NOT EXISTS snippets (
 		return fmt.Errorf("create table: %w", err)
 	}
 
+	const createVersionsTable = `
+CREATE TABLE IF NOT EXISTS snippet_versions (
+    snippet_id TEXT NOT NULL,
+    version INT NOT NULL,
+    content TEXT NOT NULL,
+    tags JSONB NOT NULL DEFAULT '[]'::jsonb,
+    expires_at TIMESTAMPTZ NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (snippet_id, version)
+);`
+	if _, err := r.pool.Exec(ctx, createVersionsTable); err != nil {
+		return fmt.Errorf("create versions table: %w", err)
+	}
+
+	// Additive column migrations for tables created by older versions
+	columns := []string{
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS owner_id TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS visible_from TIMESTAMPTZ NULL`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS language TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS cache_ttl_seconds INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS templated BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT 'api'`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS views BIGINT NOT NULL DEFAULT 0`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS max_views INT NULL`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS visibility TEXT NOT NULL DEFAULT 'public'`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', content)) STORED`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS title TEXT NULL`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS title_tsv tsvector GENERATED ALWAYS AS (to_tsvector('simple', COALESCE(title, ''))) STORED`,
+		`ALTER TABLE snippets ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ NULL`,
+	}
+	for _, column := range columns {
+		if _, err := r.pool.Exec(ctx, column); err != nil {
+			return fmt.Errorf("alter table: %w", err)
+		}
+	}
+
 	// Create indices separately - ignore errors as they might already exist
 	indices := []string{
 		`CREATE INDEX IF NOT EXISTS idx_snippets_created_at ON snippets (created_at DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_snippets_expires_at ON snippets (expires_at)`,
 		`CREATE INDEX IF NOT EXISTS idx_snippets_tags_gin ON snippets USING GIN (tags)`,
+		`CREATE INDEX IF NOT EXISTS idx_snippets_owner_id ON snippets (owner_id, created_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_snippets_content_hash ON snippets (content_hash)`,
+		`CREATE INDEX IF NOT EXISTS idx_snippets_tsv_gin ON snippets USING GIN (tsv)`,
+		`CREATE INDEX IF NOT EXISTS idx_snippets_title_tsv_gin ON snippets USING GIN (title_tsv)`,
 	}
 
 	for _, index := range indices {
@@ -61,99 +187,298 @@ CREATE TABLE IF NOT EXISTS snippets (
 	return nil
 }
 
-// Insert adds a new snippet to Postgres.
-func (r *SnippetRepository) Insert(ctx context.Context, s domain.Snippet) error {
-	var expires *time.Time
-	if !s.ExpiresAt.IsZero() {
-		expires = &s.ExpiresAt
+// snippetColumns is the column list selected for every snippet read, in scanSnippet order.
+const snippetColumns = "id, content, tags, created_at, expires_at, owner_id, content_hash, visible_from, version, language, cache_ttl_seconds, templated, source, views, max_views, visibility, title, deleted_at"
+
+// metadataColumns selects the same columns as snippetColumns with an empty content, for list
+// reads that do not need it.
+var metadataColumns = strings.Replace(snippetColumns, "content,", "'' AS content,", 1)
+
+// listColumns returns the columns a list read selects: content only with repository.WithContent.
+func listColumns(o repository.ListOptions) string {
+	if o.Content {
+		return snippetColumns
+	}
+	return metadataColumns
+}
+
+// scanSnippet scans a row selected with snippetColumns or metadataColumns into a domain.Snippet.
+func scanSnippet(row pgx.Row) (domain.Snippet, error) {
+	var (
+		s          domain.Snippet
+		tagsRaw    []byte
+		expiresPtr *time.Time
+		visiblePtr *time.Time
+		maxViews   *int
+		title      *string
+		deletedPtr *time.Time
+	)
+	if err := row.Scan(&s.ID, &s.Content, &tagsRaw, &s.CreatedAt, &expiresPtr, &s.OwnerID, &s.ContentHash, &visiblePtr, &s.Version, &s.Language, &s.CacheTTLSeconds, &s.Templated, &s.Source, &s.Views, &maxViews, &s.Visibility, &title, &deletedPtr); err != nil {
+		return domain.Snippet{}, err
+	}
+	if expiresPtr != nil {
+		s.ExpiresAt = *expiresPtr
+	}
+	if visiblePtr != nil {
+		s.VisibleFrom = *visiblePtr
+	}
+	if maxViews != nil {
+		s.MaxViews = *maxViews
+	}
+	if title != nil {
+		s.Title = *title
 	}
-	tagsJSON, err := json.Marshal(s.Tags)
+	if deletedPtr != nil {
+		s.DeletedAt = *deletedPtr
+	}
+	if len(tagsRaw) > 0 {
+		if err := json.Unmarshal(tagsRaw, &s.Tags); err != nil {
+			return domain.Snippet{}, fmt.Errorf("unmarshal tags: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// liveOnly returns the SQL condition leaving out soft-deleted snippets, or "" when ctx asks to
+// include them.
+func liveOnly(ctx context.Context) string {
+	if ctxutil.IncludeDeleted(ctx) {
+		return ""
+	}
+	return " AND deleted_at IS NULL"
+}
+
+// nullableTime maps the zero time to SQL NULL.
+func nullableTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// visibilityOrPublic stores an unset visibility as public.
+func visibilityOrPublic(v string) string {
+	if v == "" {
+		return domain.VisibilityPublic
+	}
+	return v
+}
+
+// nullableString maps "" to SQL NULL.
+func nullableString(v string) *string {
+	if v == "" {
+		return nil
+	}
+	return &v
+}
+
+// nullableInt maps 0 to SQL NULL.
+func nullableInt(n int) *int {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
+
+// insertVersion records a snippet version within tx; re-recording an existing version is a no-op.
+func insertVersion(ctx context.Context, tx pgx.Tx, v domain.SnippetVersion) error {
+	tagsJSON, err := json.Marshal(v.Tags)
 	if err != nil {
 		return fmt.Errorf("marshal tags: %w", err)
 	}
 	const q = `
-INSERT INTO snippets (id, content, tags, created_at, expires_at)
-VALUES ($1, $2, $3::jsonb, $4, $5)
+INSERT INTO snippet_versions (snippet_id, version, content, tags, expires_at, created_at)
+VALUES ($1, $2, $3, $4::jsonb, $5, $6)
+ON CONFLICT (snippet_id, version) DO NOTHING
+`
+	if _, err := tx.Exec(ctx, q, v.SnippetID, v.Version, v.Content, string(tagsJSON), nullableTime(v.ExpiresAt), v.CreatedAt); err != nil {
+		return fmt.Errorf("insert version: %w", err)
+	}
+	return nil
+}
+
+// Insert adds a new snippet to Postgres and records its first version.
+func (r *SnippetRepository) Insert(ctx context.Context, s domain.Snippet) error {
+	tagsJSON, err := r.marshalTags(s.Tags)
+	if err != nil {
+		return err
+	}
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	const q = `
+INSERT INTO snippets (id, content, tags, created_at, expires_at, owner_id, content_hash, visible_from, version, language, cache_ttl_seconds, templated, source, max_views, visibility, title)
+VALUES ($1, $2, $3::jsonb, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
 ON CONFLICT (id) DO NOTHING
 `
-	ct, err := r.pool.Exec(ctx, q, s.ID, s.Content, string(tagsJSON), s.CreatedAt, expires)
+	ct, err := tx.Exec(ctx, q, s.ID, s.Content, tagsJSON, s.CreatedAt, nullableTime(s.ExpiresAt), s.OwnerID, s.ContentHash, nullableTime(s.VisibleFrom), s.Version, s.Language, s.CacheTTLSeconds, s.Templated, s.Source, nullableInt(s.MaxViews), visibilityOrPublic(s.Visibility), nullableString(s.Title))
 	if err != nil {
-		return fmt.Errorf("insert snippet: %w", err)
+		return writeError("insert snippet", err)
 	}
 	if ct.RowsAffected() == 0 {
-		// Treat as success for idempotency, or could return an error indicating duplicate.
-		return nil
+		// The primary key decides concurrent creates of one ID: exactly one insert wins.
+		return fmt.Errorf("insert snippet %s: %w", s.ID, repository.ErrDuplicateID)
+	}
+	if err := insertVersion(ctx, tx, domain.VersionOf(s, s.CreatedAt)); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
 	}
 	return nil
 }
 
-// FindByID retrieves a snippet by its ID from Postgres.
+// FindByID retrieves a snippet by its ID from Postgres. Soft-deleted snippets are not found
+// unless ctx includes them.
 func (r *SnippetRepository) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
-	const q = `
-SELECT id, content, tags, created_at, expires_at
-FROM snippets
-WHERE id = $1
-`
-	var (
-		s          domain.Snippet
-		tagsRaw    []byte
-		expiresPtr *time.Time
-	)
-	err := r.pool.QueryRow(ctx, q, id).Scan(&s.ID, &s.Content, &tagsRaw, &s.CreatedAt, &expiresPtr)
+	q := `SELECT ` + snippetColumns + ` FROM snippets WHERE id = $1` + liveOnly(ctx)
+	s, err := scanSnippet(r.reader(ctx).QueryRow(ctx, q, id))
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return domain.Snippet{}, repository.ErrNotFound
 		}
 		return domain.Snippet{}, fmt.Errorf("query snippet: %w", err)
 	}
-	if expiresPtr != nil {
-		s.ExpiresAt = *expiresPtr
+	return s, nil
+}
+
+// FindByIDs retrieves the existing snippets among ids with a single query.
+func (r *SnippetRepository) FindByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error) {
+	if len(ids) == 0 {
+		return nil, nil
 	}
-	if len(tagsRaw) > 0 {
-		if err := json.Unmarshal(tagsRaw, &s.Tags); err != nil {
-			return domain.Snippet{}, fmt.Errorf("unmarshal tags: %w", err)
+	q := `SELECT ` + snippetColumns + ` FROM snippets WHERE id = ANY($1)` + liveOnly(ctx)
+	return r.querySnippets(ctx, len(ids), q, ids)
+}
+
+// sortClauses whitelists the ORDER BY clause for each repository sort value; user input
+// never reaches the SQL text. Snippets without expiry sort last in either direction. Every
+// clause ends in id, so snippets with equal timestamps always come back in the same order and
+// cached pages of the same query are identical.
+var sortClauses = map[string]string{
+	repository.SortCreatedAtAsc:  " ORDER BY created_at ASC, id ASC",
+	repository.SortCreatedAtDesc: " ORDER BY created_at DESC, id DESC",
+	repository.SortExpiresAtAsc:  " ORDER BY expires_at ASC NULLS LAST, created_at DESC, id DESC",
+	repository.SortExpiresAtDesc: " ORDER BY expires_at DESC NULLS LAST, created_at DESC, id DESC",
+}
+
+// listWhere builds the WHERE clause and args shared by List and Count: active, visible, live
+// snippets filtered by tag and list options. queryArg is the $n index of the text query, or 0.
+func listWhere(ctx context.Context, tag string, o repository.ListOptions) (where string, args []any, queryArg int) {
+	where = `
+WHERE (expires_at IS NULL OR expires_at > NOW())
+  AND (visible_from IS NULL OR visible_from <= NOW())
+` + liveOnly(ctx)
+	args = make([]any, 0, 5)
+	if tags := o.TagSet(tag); len(tags) > 1 && o.MatchAny() {
+		// tags ?| ARRAY['a','b']: any of the tags
+		args = append(args, tags)
+		where += fmt.Sprintf(" AND tags ?| $%d::text[]", len(args))
+	} else if len(tags) > 0 {
+		// tags @> '["a","b"]'::jsonb: all of the tags
+		tagJSON, _ := json.Marshal(tags)
+		args = append(args, string(tagJSON))
+		where += fmt.Sprintf(" AND tags @> $%d::jsonb", len(args))
+	}
+	if len(o.ExcludeTags) > 0 {
+		// NOT tags ?| ARRAY['a','b']: none of the tags
+		args = append(args, o.ExcludeTags)
+		where += fmt.Sprintf(" AND NOT (tags ?| $%d::text[])", len(args))
+	}
+	// Private snippets are only listed for their owner
+	if o.OwnerID != "" {
+		args = append(args, o.OwnerID)
+		where += fmt.Sprintf(" AND owner_id = $%d", len(args))
+	} else {
+		where += " AND visibility = 'public'"
+	}
+	if o.Source != "" {
+		args = append(args, o.Source)
+		where += fmt.Sprintf(" AND source = $%d", len(args))
+	}
+	if !o.CreatedAfter.IsZero() {
+		args = append(args, o.CreatedAfter)
+		if ctxutil.IncludeDeleted(ctx) {
+			// Deletions since the last sync are reported as well
+			where += fmt.Sprintf(" AND (created_at > $%[1]d OR deleted_at > $%[1]d)", len(args))
+		} else {
+			where += fmt.Sprintf(" AND created_at > $%d", len(args))
 		}
 	}
-	return s, nil
+	switch {
+	case o.Query == "":
+	case utf8.RuneCountInString(o.Query) < minFullTextQueryLen:
+		// Short fragments rarely form whole lexemes, so match them as substrings (unranked).
+		args = append(args, "%"+likeEscaper.Replace(o.Query)+"%")
+		where += fmt.Sprintf(" AND (content ILIKE $%[1]d OR title ILIKE $%[1]d)", len(args))
+	default:
+		args = append(args, o.Query)
+		queryArg = len(args)
+		where += fmt.Sprintf(" AND (tsv @@ plainto_tsquery('simple', $%[1]d) OR title_tsv @@ plainto_tsquery('simple', $%[1]d))", queryArg)
+	}
+	return where, args, queryArg
 }
 
-// List returns a paginated list of snippets, optionally filtered by a tag. Excludes expired.
-func (r *SnippetRepository) List(ctx context.Context, page, limit int, tag string) ([]domain.Snippet, error) {
+// minFullTextQueryLen is the shortest query, in characters, searched via the tsvector index;
+// shorter ones fall back to ILIKE.
+const minFullTextQueryLen = 3
+
+// likeEscaper escapes LIKE wildcards so a query matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// List returns a paginated list of snippets, optionally filtered by a tag and list options.
+// Excludes expired snippets and those not yet visible. Content is only read with repository.WithContent.
+func (r *SnippetRepository) List(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	o := repository.NewListOptions(opts...)
 	offset := (page - 1) * limit
-	base := `
-SELECT id, content, tags, created_at, expires_at
-FROM snippets
-WHERE (expires_at IS NULL OR expires_at > NOW())
-`
-	var rows pgx.Rows
-	var err error
-	if tag != "" {
-		// tags @> '["tag"]'::jsonb
-		q := base + " AND tags @> $1::jsonb ORDER BY created_at DESC LIMIT $2 OFFSET $3"
-		tagJSON, _ := json.Marshal([]string{tag})
-		rows, err = r.pool.Query(ctx, q, string(tagJSON), limit, offset)
-	} else {
-		q := base + " ORDER BY created_at DESC LIMIT $1 OFFSET $2"
-		rows, err = r.pool.Query(ctx, q, limit, offset)
+	where, args, queryArg := listWhere(ctx, tag, o)
+	q := `
+SELECT ` + listColumns(o) + `
+FROM snippets` + where
+	order := sortClauses[repository.SortCreatedAtDesc]
+	if clause, ok := sortClauses[o.Sort]; ok {
+		order = clause
+	} else if queryArg > 0 {
+		// A title match outranks the same match in content
+		order = fmt.Sprintf(" ORDER BY ts_rank(setweight(title_tsv, 'A') || tsv, plainto_tsquery('simple', $%d)) DESC, created_at DESC, id DESC", queryArg)
 	}
+	args = append(args, limit, offset)
+	q += order + fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	return r.querySnippets(ctx, limit, q, args...)
+}
+
+// ListAfter returns up to limit snippets after cursor in (created_at, id) descending order.
+// A text query still filters results, but they are keyset-ordered rather than ranked.
+func (r *SnippetRepository) ListAfter(ctx context.Context, cursor repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	o := repository.NewListOptions(opts...)
+	where, args, _ := listWhere(ctx, tag, o)
+	q := `
+SELECT ` + listColumns(o) + `
+FROM snippets` + where
+	if !cursor.IsZero() {
+		args = append(args, cursor.CreatedAt, cursor.ID)
+		q += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", len(args)-1, len(args))
+	}
+	args = append(args, limit)
+	q += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+	return r.querySnippets(ctx, limit, q, args...)
+}
+
+// querySnippets runs a snippet SELECT on the read pool and scans up to sizeHint rows.
+func (r *SnippetRepository) querySnippets(ctx context.Context, sizeHint int, q string, args ...any) ([]domain.Snippet, error) {
+	rows, err := r.reader(ctx).Query(ctx, q, args...)
 	if err != nil {
 		return nil, fmt.Errorf("list snippets: %w", err)
 	}
 	defer rows.Close()
-	res := make([]domain.Snippet, 0, limit)
+	res := make([]domain.Snippet, 0, sizeHint)
 	for rows.Next() {
-		var s domain.Snippet
-		var tagsRaw []byte
-		var expiresPtr *time.Time
-		if err := rows.Scan(&s.ID, &s.Content, &tagsRaw, &s.CreatedAt, &expiresPtr); err != nil {
+		s, err := scanSnippet(rows)
+		if err != nil {
 			return nil, fmt.Errorf("scan snippet: %w", err)
 		}
-		if expiresPtr != nil {
-			s.ExpiresAt = *expiresPtr
-		}
-		if len(tagsRaw) > 0 {
-			_ = json.Unmarshal(tagsRaw, &s.Tags)
-		}
 		res = append(res, s)
 	}
 	if rows.Err() != nil {
@@ -162,24 +487,65 @@ WHERE (expires_at IS NULL OR expires_at > NOW())
 	return res, nil
 }
 
-// Update modifies an existing snippet in Postgres.
+// Count returns how many snippets List would return across all pages for the same filters.
+func (r *SnippetRepository) Count(ctx context.Context, tag string, opts ...repository.ListOption) (int, error) {
+	where, args, _ := listWhere(ctx, tag, repository.NewListOptions(opts...))
+	var n int
+	if err := r.reader(ctx).QueryRow(ctx, `SELECT COUNT(*) FROM snippets`+where, args...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count snippets: %w", err)
+	}
+	return n, nil
+}
+
+// Update modifies an existing snippet in Postgres and records the new version.
 func (r *SnippetRepository) Update(ctx context.Context, s domain.Snippet) error {
-	var expires *time.Time
-	if !s.ExpiresAt.IsZero() {
-		expires = &s.ExpiresAt
+	tagsJSON, err := r.marshalTags(s.Tags)
+	if err != nil {
+		return err
 	}
-	tagsJSON, err := json.Marshal(s.Tags)
+	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("marshal tags: %w", err)
+		return fmt.Errorf("begin: %w", err)
 	}
+	defer func() { _ = tx.Rollback(ctx) }()
 	const q = `
 UPDATE snippets 
-SET content = $2, tags = $3::jsonb, expires_at = $4
-WHERE id = $1
+SET content = $2, tags = $3::jsonb, expires_at = $4, content_hash = $5, visible_from = $6, version = $7, language = $8, cache_ttl_seconds = $9, templated = $10, visibility = $11, title = $12
+WHERE id = $1 AND deleted_at IS NULL
 `
-	ct, err := r.pool.Exec(ctx, q, s.ID, s.Content, string(tagsJSON), expires)
+	ct, err := tx.Exec(ctx, q, s.ID, s.Content, tagsJSON, nullableTime(s.ExpiresAt), s.ContentHash, nullableTime(s.VisibleFrom), s.Version, s.Language, s.CacheTTLSeconds, s.Templated, visibilityOrPublic(s.Visibility), nullableString(s.Title))
+	if err != nil {
+		return writeError("update snippet", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	if err := insertVersion(ctx, tx, domain.VersionOf(s, time.Now())); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// SoftDelete marks a live snippet deleted at the given time, keeping the row and its versions.
+func (r *SnippetRepository) SoftDelete(ctx context.Context, id string, at time.Time) error {
+	ct, err := r.pool.Exec(ctx, `UPDATE snippets SET deleted_at = $2 WHERE id = $1 AND deleted_at IS NULL`, id, at)
+	if err != nil {
+		return fmt.Errorf("soft delete snippet: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+// Restore clears deleted_at on a soft-deleted snippet.
+func (r *SnippetRepository) Restore(ctx context.Context, id string) error {
+	ct, err := r.pool.Exec(ctx, `UPDATE snippets SET deleted_at = NULL WHERE id = $1 AND deleted_at IS NOT NULL`, id)
 	if err != nil {
-		return fmt.Errorf("update snippet: %w", err)
+		return fmt.Errorf("restore snippet: %w", err)
 	}
 	if ct.RowsAffected() == 0 {
 		return repository.ErrNotFound
@@ -187,4 +553,149 @@ WHERE id = $1
 	return nil
 }
 
-var _ repository.SnippetRepository = (*SnippetRepository)(nil)
+// DeleteByID permanently removes a snippet, deleted or not, together with its versions.
+func (r *SnippetRepository) DeleteByID(ctx context.Context, id string) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	ct, err := tx.Exec(ctx, `DELETE FROM snippets WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete snippet: %w", err)
+	}
+	if ct.RowsAffected() == 0 {
+		return repository.ErrNotFound
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM snippet_versions WHERE snippet_id = $1`, id); err != nil {
+		return fmt.Errorf("delete snippet versions: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	return nil
+}
+
+// FindVersion returns a recorded version of a snippet.
+func (r *SnippetRepository) FindVersion(ctx context.Context, id string, version int) (domain.SnippetVersion, error) {
+	const q = `
+SELECT snippet_id, version, content, tags, expires_at, created_at
+FROM snippet_versions
+WHERE snippet_id = $1 AND version = $2
+`
+	var (
+		v          domain.SnippetVersion
+		tagsRaw    []byte
+		expiresPtr *time.Time
+	)
+	err := r.pool.QueryRow(ctx, q, id, version).Scan(&v.SnippetID, &v.Version, &v.Content, &tagsRaw, &expiresPtr, &v.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.SnippetVersion{}, repository.ErrNotFound
+		}
+		return domain.SnippetVersion{}, fmt.Errorf("query version: %w", err)
+	}
+	if expiresPtr != nil {
+		v.ExpiresAt = *expiresPtr
+	}
+	if len(tagsRaw) > 0 {
+		if err := json.Unmarshal(tagsRaw, &v.Tags); err != nil {
+			return domain.SnippetVersion{}, fmt.Errorf("unmarshal tags: %w", err)
+		}
+	}
+	return v, nil
+}
+
+// FindByContentHash returns the newest non-expired snippet with the given content hash.
+func (r *SnippetRepository) FindByContentHash(ctx context.Context, hash string) (domain.Snippet, error) {
+	const q = `
+SELECT id
+FROM snippets
+WHERE content_hash = $1 AND (expires_at IS NULL OR expires_at > NOW()) AND deleted_at IS NULL
+ORDER BY created_at DESC
+LIMIT 1
+`
+	var id string
+	if err := r.pool.QueryRow(ctx, q, hash).Scan(&id); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.Snippet{}, repository.ErrNotFound
+		}
+		return domain.Snippet{}, fmt.Errorf("query snippet by hash: %w", err)
+	}
+	return r.FindByID(ctx, id)
+}
+
+// TagExists reports whether any snippet, expired or not, carries the tag.
+func (r *SnippetRepository) TagExists(ctx context.Context, tag string) (bool, error) {
+	tagJSON, _ := json.Marshal([]string{tag})
+	var exists bool
+	if err := r.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM snippets WHERE tags @> $1::jsonb)`, string(tagJSON)).Scan(&exists); err != nil {
+		return false, fmt.Errorf("tag exists: %w", err)
+	}
+	return exists, nil
+}
+
+// ListTags counts active snippets per tag by expanding the JSONB tag arrays.
+func (r *SnippetRepository) ListTags(ctx context.Context) ([]repository.TagCount, error) {
+	const q = `
+SELECT t, COUNT(DISTINCT id)
+FROM snippets, jsonb_array_elements_text(tags) AS t
+WHERE (expires_at IS NULL OR expires_at > NOW())
+  AND (visible_from IS NULL OR visible_from <= NOW())
+  AND visibility = 'public'
+  AND deleted_at IS NULL
+GROUP BY t
+ORDER BY COUNT(DISTINCT id) DESC, t`
+	rows, err := r.pool.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	defer rows.Close()
+	var res []repository.TagCount
+	for rows.Next() {
+		var tc repository.TagCount
+		if err := rows.Scan(&tc.Tag, &tc.Count); err != nil {
+			return nil, fmt.Errorf("scan tag: %w", err)
+		}
+		res = append(res, tc)
+	}
+	return res, rows.Err()
+}
+
+// AddViews adds flushed view counts to their snippets in one batch. Snippets deleted since the
+// views were counted are skipped.
+func (r *SnippetRepository) AddViews(ctx context.Context, views map[string]int64) error {
+	if len(views) == 0 {
+		return nil
+	}
+	var b pgx.Batch
+	for id, n := range views {
+		b.Queue(`UPDATE snippets SET views = views + $1 WHERE id = $2`, n, id)
+	}
+	if err := r.pool.SendBatch(ctx, &b).Close(); err != nil {
+		return fmt.Errorf("add views: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes expired snippets past the purge grace and their recorded versions in
+// one statement.
+func (r *SnippetRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	const q = `
+WITH purged AS (
+    DELETE FROM snippets WHERE expires_at IS NOT NULL AND expires_at < NOW() - $1 * INTERVAL '1 second' RETURNING id
+), purged_versions AS (
+    DELETE FROM snippet_versions WHERE snippet_id IN (SELECT id FROM purged)
+)
+SELECT COUNT(*) FROM purged`
+	var n int64
+	if err := r.pool.QueryRow(ctx, q, r.purgeGrace.Seconds()).Scan(&n); err != nil {
+		return 0, fmt.Errorf("delete expired: %w", err)
+	}
+	return n, nil
+}
+
+var (
+	_ repository.SnippetRepository = (*SnippetRepository)(nil)
+	_ repository.HardDeleter       = (*SnippetRepository)(nil)
+)
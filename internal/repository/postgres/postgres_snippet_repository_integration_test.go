@@ -4,12 +4,17 @@ package postgres
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/internal/selftest"
+	"github.com/roguepikachu/bonsai/internal/service"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
 )
 
@@ -140,3 +145,571 @@ func domainSnippet(id string, created time.Time, expires *time.Time, tags []stri
 	}
 	return s
 }
+
+// listFixture starts Postgres and inserts the snippets TestPostgresRepository_CRUDAndList
+// lists: a1 (go, notes), b2 (go) and c3 (rust, expiring), one second apart.
+func listFixture(ctx context.Context, t *testing.T) (*SnippetRepository, func()) {
+	t.Helper()
+	pool, cleanup := startPostgres(ctx, t)
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		cleanup()
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	exp := now.Add(10 * time.Minute)
+	for _, s := range []domain.Snippet{
+		domainSnippet("a1", now, nil, []string{"go", "notes"}),
+		domainSnippet("b2", now.Add(1*time.Second), nil, []string{"go"}),
+		domainSnippet("c3", now.Add(2*time.Second), &exp, []string{"rust"}),
+	} {
+		if err := repo.Insert(ctx, s); err != nil {
+			cleanup()
+			t.Fatalf("insert %s: %v", s.ID, err)
+		}
+	}
+	return repo, cleanup
+}
+
+func TestPostgresRepository_ListContent(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, cleanup := listFixture(ctx, t)
+	defer cleanup()
+
+	// Content is only read when asked for
+	all, err := repo.List(ctx, 1, 10, "")
+	if err != nil {
+		t.Fatalf("list all: %v", err)
+	}
+	if len(all) != 3 || all[0].Content != "" || len(all[0].Tags) == 0 {
+		t.Fatalf("want metadata without content, got %+v", all)
+	}
+	full, err := repo.List(ctx, 1, 10, "", repository.WithContent())
+	if err != nil {
+		t.Fatalf("list full: %v", err)
+	}
+	if full[0].Content != "content-c3" {
+		t.Fatalf("want content with WithContent, got %q", full[0].Content)
+	}
+}
+
+func TestPostgresRepository_ListShortQuery(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, cleanup := listFixture(ctx, t)
+	defer cleanup()
+
+	// Short queries fall back to a case-insensitive substring match
+	short, err := repo.List(ctx, 1, 10, "", repository.WithQuery("B2"))
+	if err != nil {
+		t.Fatalf("list short query: %v", err)
+	}
+	if len(short) != 1 || short[0].ID != "b2" {
+		t.Fatalf("want only b2 for short query, got %d items", len(short))
+	}
+}
+
+func TestPostgresRepository_ListExcludeTags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	repo, cleanup := listFixture(ctx, t)
+	defer cleanup()
+
+	// Excluded tags drop snippets carrying any of them
+	goNoNotes, err := repo.List(ctx, 1, 10, "go", repository.WithExcludeTags("notes", "rust"))
+	if err != nil {
+		t.Fatalf("list go without notes: %v", err)
+	}
+	if len(goNoNotes) != 1 || goNoNotes[0].ID != "b2" {
+		t.Fatalf("want only b2 for go without notes, got %d items", len(goNoNotes))
+	}
+	if n, err := repo.Count(ctx, "", repository.WithExcludeTags("go")); err != nil || n != 1 {
+		t.Fatalf("want count 1 without go, got %d (%v)", n, err)
+	}
+}
+
+func TestPostgresRepository_DeleteExpired(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	past, future := now.Add(-time.Minute), now.Add(time.Hour)
+	for _, s := range []domain.Snippet{
+		domainSnippet("keep", now, nil, nil),
+		domainSnippet("later", now, &future, nil),
+		domainSnippet("gone", now.Add(-time.Hour), &past, nil),
+	} {
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert %s: %v", s.ID, err)
+		}
+	}
+
+	n, err := repo.DeleteExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 purged, got %d, %v", n, err)
+	}
+	if _, err := repo.FindByID(ctx, "gone"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("purged snippet should be gone, got %v", err)
+	}
+	if _, err := repo.FindVersion(ctx, "gone", 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("purged snippet's versions should be gone, got %v", err)
+	}
+	for _, id := range []string{"keep", "later"} {
+		if _, err := repo.FindByID(ctx, id); err != nil {
+			t.Fatalf("%s should survive, got %v", id, err)
+		}
+	}
+}
+
+func TestPostgresRepository_DeleteExpired_PurgeGrace(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool, WithPurgeGrace(10*time.Minute))
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	recent, old := now.Add(-time.Minute), now.Add(-time.Hour)
+	for _, s := range []domain.Snippet{
+		domainSnippet("in-grace", now.Add(-time.Hour), &recent, nil),
+		domainSnippet("past-grace", now.Add(-2*time.Hour), &old, nil),
+	} {
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert %s: %v", s.ID, err)
+		}
+	}
+	n, err := repo.DeleteExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("want 1 purged, got %d, %v", n, err)
+	}
+	if _, err := repo.FindByID(ctx, "in-grace"); err != nil {
+		t.Fatalf("snippet within the grace window should survive, got %v", err)
+	}
+}
+
+func TestPostgresRepository_FindByIDs(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	for _, id := range []string{"a", "b", "c"} {
+		if err := repo.Insert(ctx, domainSnippet(id, now, nil, []string{"batch"})); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+
+	got, err := repo.FindByIDs(ctx, []string{"c", "missing", "a"})
+	if err != nil {
+		t.Fatalf("find by ids: %v", err)
+	}
+	ids := map[string]bool{}
+	for _, s := range got {
+		ids[s.ID] = true
+		if len(s.Tags) != 1 || s.Tags[0] != "batch" {
+			t.Fatalf("%s: want tags scanned, got %v", s.ID, s.Tags)
+		}
+	}
+	if len(got) != 2 || !ids["a"] || !ids["c"] {
+		t.Fatalf("want a and c, got %+v", got)
+	}
+	if got, err := repo.FindByIDs(ctx, nil); err != nil || len(got) != 0 {
+		t.Fatalf("empty ids: want nothing, got %+v, %v", got, err)
+	}
+}
+
+// offsetClock is the real clock shifted by a fixed offset, simulating a drifted app host.
+type offsetClock struct{ offset time.Duration }
+
+func (c offsetClock) Now() time.Time { return time.Now().Add(c.offset) }
+
+func TestPostgresRepository_ClockSkew(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if _, err := selftest.CheckClockSkew(ctx, repo, service.RealClock{}, time.Second); err != nil {
+		t.Fatalf("aligned clocks: %v", err)
+	}
+	skew, err := selftest.CheckClockSkew(ctx, repo, offsetClock{offset: time.Hour}, time.Second)
+	if !errors.Is(err, selftest.ErrClockSkew) {
+		t.Fatalf("offset clock: want ErrClockSkew, got %v", err)
+	}
+	if skew > -59*time.Minute {
+		t.Fatalf("want about -1h skew with the app ahead, got %s", skew)
+	}
+}
+
+func TestPostgresRepository_ListTags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	past := now.Add(-time.Minute)
+	for _, s := range []domain.Snippet{
+		domainSnippet("a", now, nil, []string{"go", "web"}),
+		domainSnippet("b", now, nil, []string{"go"}),
+		domainSnippet("expired", now.Add(-time.Hour), &past, []string{"go", "old"}),
+	} {
+		if err := repo.Insert(ctx, s); err != nil {
+			t.Fatalf("insert %s: %v", s.ID, err)
+		}
+	}
+
+	got, err := repo.ListTags(ctx)
+	if err != nil {
+		t.Fatalf("list tags: %v", err)
+	}
+	if fmt.Sprint(got) != "[{go 2} {web 1}]" {
+		t.Fatalf("want [{go 2} {web 1}], got %v", got)
+	}
+}
+
+func TestPostgresRepository_AddViews(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	limited := domainSnippet("a", time.Now().UTC(), nil, nil)
+	limited.MaxViews = 10
+	if err := repo.Insert(ctx, limited); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	for _, views := range []map[string]int64{{"a": 3, "gone": 1}, {"a": 2}} {
+		if err := repo.AddViews(ctx, views); err != nil {
+			t.Fatalf("add views: %v", err)
+		}
+	}
+	s, err := repo.FindByID(ctx, "a")
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if s.Views != 5 || s.MaxViews != 10 {
+		t.Fatalf("want 5 of 10 views, got %d of %d", s.Views, s.MaxViews)
+	}
+}
+
+func TestPostgresRepository_TooManyTags(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool, WithMaxTags(3))
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	tags := []string{"a", "b", "c", "d"}
+	if err := repo.Insert(ctx, domainSnippet("big", now, nil, tags)); !errors.Is(err, repository.ErrTooManyTags) {
+		t.Fatalf("insert: want ErrTooManyTags, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, "big"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("rejected snippet must not be stored, got %v", err)
+	}
+
+	s := domainSnippet("ok", now, nil, tags[:3])
+	if err := repo.Insert(ctx, s); err != nil {
+		t.Fatalf("insert at the cap: %v", err)
+	}
+	s.Tags, s.Version = tags, 2
+	if err := repo.Update(ctx, s); !errors.Is(err, repository.ErrTooManyTags) {
+		t.Fatalf("update: want ErrTooManyTags, got %v", err)
+	}
+}
+
+func TestPostgresRepository_Title(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	titled := domainSnippet("titled", now, nil, nil)
+	titled.Title = "Kubernetes rollout"
+	if err := repo.Insert(ctx, titled); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if err := repo.Insert(ctx, domainSnippet("plain", now.Add(-time.Minute), nil, nil)); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+
+	for _, q := range []string{"kubernetes", "ro"} {
+		list, err := repo.List(ctx, 1, 10, "", repository.WithQuery(q))
+		if err != nil {
+			t.Fatalf("list %q: %v", q, err)
+		}
+		if len(list) != 1 || list[0].Title != titled.Title {
+			t.Fatalf("query %q: want the titled snippet, got %+v", q, list)
+		}
+	}
+
+	titled.Title, titled.Version = "", 2
+	if err := repo.Update(ctx, titled); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	s, err := repo.FindByID(ctx, "titled")
+	if err != nil || s.Title != "" {
+		t.Fatalf("want title cleared, got %q, %v", s.Title, err)
+	}
+}
+
+func TestPostgresRepository_EqualTimestampOrder(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC()
+	for _, id := range []string{"c", "a", "e", "b", "d"} {
+		if err := repo.Insert(ctx, domainSnippet(id, now, nil, nil)); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	for sort, want := range map[string]string{
+		"":                           "edcba",
+		repository.SortCreatedAtAsc:  "abcde",
+		repository.SortExpiresAtDesc: "edcba",
+	} {
+		var got string
+		for page := 1; page <= 3; page++ {
+			list, err := repo.List(ctx, page, 2, "", repository.WithSort(sort))
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			for _, s := range list {
+				got += s.ID
+			}
+		}
+		if got != want {
+			t.Fatalf("sort %q: want pages in id order %s, got %s", sort, want, got)
+		}
+	}
+}
+
+func TestPostgresRepository_SoftDelete(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Insert(ctx, domainSnippet(id, now, nil, []string{"go"})); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	if err := repo.SoftDelete(ctx, "a", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if err := repo.SoftDelete(ctx, "a", now); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	if found, _ := repo.FindByIDs(ctx, []string{"a", "b"}); len(found) != 1 || found[0].ID != "b" {
+		t.Fatalf("want only b found, got %+v", found)
+	}
+	if items, _ := repo.List(ctx, 1, 10, "go"); len(items) != 1 || items[0].ID != "b" {
+		t.Fatalf("want only b listed, got %+v", items)
+	}
+	if n, _ := repo.Count(ctx, "go"); n != 1 {
+		t.Fatalf("want count 1, got %d", n)
+	}
+	if err := repo.Update(ctx, domainSnippet("a", now, nil, nil)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound updating a deleted snippet, got %v", err)
+	}
+
+	admin := ctxutil.WithIncludeDeleted(ctx)
+	deleted, err := repo.FindByID(admin, "a")
+	if err != nil || !deleted.DeletedAt.Equal(now) {
+		t.Fatalf("want deleted snippet with deleted_at, got %+v, %v", deleted, err)
+	}
+	if n, _ := repo.Count(admin, "go"); n != 2 {
+		t.Fatalf("want count 2 including deleted, got %d", n)
+	}
+
+	if err := repo.Restore(ctx, "a"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if err := repo.Restore(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound restoring a live snippet, got %v", err)
+	}
+	if s, err := repo.FindByID(ctx, "a"); err != nil || !s.DeletedAt.IsZero() {
+		t.Fatalf("want restored snippet live, got %+v, %v", s, err)
+	}
+}
+
+func TestPostgresRepository_ListCreatedAfter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	since := now.Add(-time.Hour)
+	for id, created := range map[string]time.Time{"old": now.Add(-2 * time.Hour), "new": now.Add(-time.Minute), "gone": now.Add(-2 * time.Hour)} {
+		if err := repo.Insert(ctx, domainSnippet(id, created, nil, nil)); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	if err := repo.SoftDelete(ctx, "gone", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+
+	if items, _ := repo.List(ctx, 1, 10, "", repository.WithCreatedAfter(since)); len(items) != 1 || items[0].ID != "new" {
+		t.Fatalf("want only new listed, got %+v", items)
+	}
+	admin := ctxutil.WithIncludeDeleted(ctx)
+	items, err := repo.List(admin, 1, 10, "", repository.WithCreatedAfter(since))
+	if err != nil || len(items) != 2 || items[0].ID != "new" || items[1].ID != "gone" || items[1].DeletedAt.IsZero() {
+		t.Fatalf("want new and the deleted gone, got %+v, %v", items, err)
+	}
+	if n, _ := repo.Count(admin, "", repository.WithCreatedAfter(since)); n != 2 {
+		t.Fatalf("want count 2, got %d", n)
+	}
+}
+
+func TestPostgresRepository_DeleteByID(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	repo := NewSnippetRepository(pool)
+	if err := repo.EnsureSchema(ctx); err != nil {
+		t.Fatalf("ensure schema: %v", err)
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	for _, id := range []string{"a", "b"} {
+		if err := repo.Insert(ctx, domainSnippet(id, now, nil, []string{"go"})); err != nil {
+			t.Fatalf("insert %s: %v", id, err)
+		}
+	}
+	if err := repo.DeleteByID(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.FindByID(ctxutil.WithIncludeDeleted(ctx), "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	if _, err := repo.FindVersion(ctx, "a", 1); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want versions removed, got %v", err)
+	}
+	if err := repo.DeleteByID(ctx, "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, "b"); err != nil {
+		t.Fatalf("want b untouched, got %v", err)
+	}
+
+	// Soft-deleted snippets can still be removed for good
+	if err := repo.SoftDelete(ctx, "b", now); err != nil {
+		t.Fatalf("soft delete: %v", err)
+	}
+	if err := repo.DeleteByID(ctx, "b"); err != nil {
+		t.Fatalf("delete soft-deleted: %v", err)
+	}
+}
+
+func TestPostgresRepository_ReplicaRouting(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	pool, cleanup := startPostgres(ctx, t)
+	defer cleanup()
+
+	// A second database in the same server stands in for a replica that has not caught up
+	if _, err := pool.Exec(ctx, `CREATE DATABASE replica`); err != nil {
+		t.Fatalf("create replica database: %v", err)
+	}
+	cfg := pool.Config()
+	cfg.ConnConfig.Database = "replica"
+	replicaPool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		t.Fatalf("replica pool: %v", err)
+	}
+	defer replicaPool.Close()
+	primary, replica := NewSnippetRepository(pool), NewSnippetRepository(replicaPool)
+	for _, r := range []*SnippetRepository{primary, replica} {
+		if err := r.EnsureSchema(ctx); err != nil {
+			t.Fatalf("ensure schema: %v", err)
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	if err := replica.Insert(ctx, domainSnippet("r", now, nil, []string{"go"})); err != nil {
+		t.Fatalf("insert into replica: %v", err)
+	}
+
+	repo := NewSnippetRepository(pool, WithReplica(replicaPool))
+	if err := repo.Insert(ctx, domainSnippet("w", now, nil, []string{"go"})); err != nil {
+		t.Fatalf("insert: %v", err)
+	}
+	if _, err := primary.FindByID(ctx, "w"); err != nil {
+		t.Fatalf("want the write on the primary, got %v", err)
+	}
+	if _, err := repo.FindByID(ctx, "w"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want reads from the replica, which lacks the write, got %v", err)
+	}
+	if items, _ := repo.List(ctx, 1, 10, "go"); len(items) != 1 || items[0].ID != "r" {
+		t.Fatalf("want the replica's snippet listed, got %+v", items)
+	}
+	if n, _ := repo.Count(ctx, "go"); n != 1 {
+		t.Fatalf("want the replica's count, got %d", n)
+	}
+
+	fresh := ctxutil.WithReadPrimary(ctx)
+	if _, err := repo.FindByID(fresh, "w"); err != nil {
+		t.Fatalf("want read-your-writes from the primary, got %v", err)
+	}
+	if items, _ := repo.List(fresh, 1, 10, "go"); len(items) != 1 || items[0].ID != "w" {
+		t.Fatalf("want the primary's snippet listed, got %+v", items)
+	}
+	// Writes always go to the primary, even for snippets only the replica has
+	if err := repo.Update(ctx, domainSnippet("r", now, nil, nil)); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want updates on the primary, got %v", err)
+	}
+}
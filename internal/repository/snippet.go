@@ -4,6 +4,8 @@ package repository
 import (
 	"context"
 	"errors"
+	"sort"
+	"time"
 
 	"github.com/roguepikachu/bonsai/internal/domain"
 )
@@ -11,10 +13,170 @@ import (
 // ErrNotFound is returned when a requested entity is not found in the repository.
 var ErrNotFound = errors.New("not found")
 
-// SnippetRepository defines methods for snippet data access.
+// ErrDuplicateID is returned by Insert when a snippet with the same ID already exists.
+var ErrDuplicateID = errors.New("duplicate id")
+
+// ErrTooManyTags is returned by Insert and Update when a snippet's tag set exceeds what the store accepts.
+var ErrTooManyTags = errors.New("too many tags")
+
+// ListOptions holds optional filters applied by List on top of page, limit and tag.
+type ListOptions struct {
+	// OwnerID restricts results to snippets created by the given client, including their private
+	// snippets. Without it private snippets are left out.
+	OwnerID string
+	// Query restricts results to snippets whose content or title matches the text query.
+	Query string
+	// Tags filters by several tags at once, combined according to TagMatch. It is merged with
+	// List's single tag argument; see TagSet.
+	Tags []string
+	// TagMatch is TagMatchAll (default) or TagMatchAny.
+	TagMatch string
+	// ExcludeTags leaves out snippets carrying any of these tags.
+	ExcludeTags []string
+	// Sort orders results by one of the Sort* values; empty means newest first,
+	// or by relevance when Query is set.
+	Sort string
+	// Source restricts results to snippets created through the given path, e.g. domain.SourceImport.
+	Source string
+	// Content asks for snippet content in the results. Without it stores may leave Content empty.
+	Content bool
+	// CreatedAfter restricts results to snippets created after it. When deleted snippets are
+	// included (see ctxutil.WithIncludeDeleted), snippets deleted after it are kept too, so an
+	// incremental sync also learns about deletions.
+	CreatedAfter time.Time
+}
+
+// Sort values accepted by WithSort. A leading "-" means descending.
+const (
+	SortCreatedAtAsc  = "created_at"
+	SortCreatedAtDesc = "-created_at"
+	SortExpiresAtAsc  = "expires_at"
+	SortExpiresAtDesc = "-expires_at"
+)
+
+// TagMatch values accepted by WithTags.
+const (
+	// TagMatchAll keeps snippets carrying every tag.
+	TagMatchAll = "all"
+	// TagMatchAny keeps snippets carrying at least one of the tags.
+	TagMatchAny = "any"
+)
+
+// TagSet returns tag together with o.Tags, de-duplicated and sorted.
+func (o ListOptions) TagSet(tag string) []string {
+	seen := make(map[string]bool, len(o.Tags)+1)
+	set := make([]string, 0, len(o.Tags)+1)
+	for _, t := range append([]string{tag}, o.Tags...) {
+		if t != "" && !seen[t] {
+			seen[t] = true
+			set = append(set, t)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+// MatchAny reports whether a multi-tag filter keeps snippets with any rather than all of the tags.
+func (o ListOptions) MatchAny() bool { return o.TagMatch == TagMatchAny }
+
+// ValidSort reports whether s is empty or one of the Sort* values.
+func ValidSort(s string) bool {
+	switch s {
+	case "", SortCreatedAtAsc, SortCreatedAtDesc, SortExpiresAtAsc, SortExpiresAtDesc:
+		return true
+	}
+	return false
+}
+
+// ListOption configures ListOptions.
+type ListOption func(*ListOptions)
+
+// WithOwner restricts List results to snippets owned by the given client ID.
+func WithOwner(id string) ListOption { return func(o *ListOptions) { o.OwnerID = id } }
+
+// WithQuery restricts List results to snippets matching a text query, ranked by relevance.
+func WithQuery(q string) ListOption { return func(o *ListOptions) { o.Query = q } }
+
+// WithTags filters List results by several tags; match is TagMatchAll or TagMatchAny
+// (empty means all).
+func WithTags(match string, tags ...string) ListOption {
+	return func(o *ListOptions) { o.TagMatch, o.Tags = match, tags }
+}
+
+// WithExcludeTags leaves snippets carrying any of the tags out of List results.
+func WithExcludeTags(tags ...string) ListOption {
+	return func(o *ListOptions) { o.ExcludeTags = tags }
+}
+
+// WithSort orders List results by one of the Sort* values.
+func WithSort(sort string) ListOption { return func(o *ListOptions) { o.Sort = sort } }
+
+// WithSource restricts List results to snippets created through the given path.
+func WithSource(source string) ListOption { return func(o *ListOptions) { o.Source = source } }
+
+// WithCreatedAfter restricts List results to snippets created (or, with deleted snippets
+// included, deleted) after t.
+func WithCreatedAfter(t time.Time) ListOption { return func(o *ListOptions) { o.CreatedAfter = t } }
+
+// WithContent asks List to return snippet content, e.g. for full-view list pages.
+func WithContent() ListOption { return func(o *ListOptions) { o.Content = true } }
+
+// NewListOptions applies the given options to a zero ListOptions.
+func NewListOptions(opts ...ListOption) ListOptions {
+	var o ListOptions
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&o)
+		}
+	}
+	return o
+}
+
+// TagCount is a tag with the number of active snippets carrying it.
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// SnippetRepository defines methods for snippet data access. Reads leave out soft-deleted
+// snippets unless the context asks for them with ctxutil.WithIncludeDeleted.
 type SnippetRepository interface {
 	Insert(ctx context.Context, s domain.Snippet) error
 	FindByID(ctx context.Context, id string) (domain.Snippet, error)
-	List(ctx context.Context, page, limit int, tag string) ([]domain.Snippet, error)
+	// FindByIDs returns the snippets among ids that exist, expired or not, in no particular order.
+	// Missing IDs are skipped rather than reported as ErrNotFound.
+	FindByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error)
+	List(ctx context.Context, page, limit int, tag string, opts ...ListOption) ([]domain.Snippet, error)
+	// ListAfter returns up to limit snippets after cursor, ordered by created_at DESC, id DESC.
+	// Unlike offset pages it neither repeats nor skips snippets when others are created concurrently.
+	ListAfter(ctx context.Context, cursor Cursor, limit int, tag string, opts ...ListOption) ([]domain.Snippet, error)
+	// Count returns how many snippets List would return across all pages for the same filters.
+	Count(ctx context.Context, tag string, opts ...ListOption) (int, error)
 	Update(ctx context.Context, s domain.Snippet) error
+	// FindByContentHash returns the newest non-expired snippet with the given content hash.
+	FindByContentHash(ctx context.Context, hash string) (domain.Snippet, error)
+	// ListTags returns every tag carried by active (unexpired, visible) public snippets with how
+	// many carry it, most used first and ties by tag.
+	ListTags(ctx context.Context) ([]TagCount, error)
+	// TagExists reports whether any snippet, active or expired, has ever carried the tag.
+	TagExists(ctx context.Context, tag string) (bool, error)
+	// FindVersion returns a recorded version of a snippet. Insert and Update record versions.
+	FindVersion(ctx context.Context, id string, version int) (domain.SnippetVersion, error)
+	// AddViews adds read counts to snippets by ID, skipping IDs that no longer exist.
+	AddViews(ctx context.Context, views map[string]int64) error
+	// SoftDelete marks a live snippet deleted at the given time; ErrNotFound when it is missing
+	// or already deleted.
+	SoftDelete(ctx context.Context, id string, at time.Time) error
+	// Restore clears the deletion mark of a soft-deleted snippet; ErrNotFound when it is missing
+	// or not deleted.
+	Restore(ctx context.Context, id string) error
+	// DeleteExpired permanently removes snippets whose expiry has passed, with their versions,
+	// and returns how many snippets were removed.
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// HardDeleter is implemented by stores that can permanently remove a single snippet.
+type HardDeleter interface {
+	// DeleteByID removes the snippet and its versions; ErrNotFound when it does not exist.
+	DeleteByID(ctx context.Context, id string) error
 }
@@ -0,0 +1,47 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultMaxClockSkew is the largest app/database clock difference CheckClockSkew accepts by default.
+const DefaultMaxClockSkew = time.Second
+
+// ErrClockSkew is returned when the app clock drifts too far from the database clock. Expiry is
+// computed from the app clock but filtered with the database's NOW(), so skew makes snippets
+// expire early or late.
+var ErrClockSkew = errors.New("clock skew exceeds limit")
+
+// DBClock reports the database's current time, e.g. SELECT NOW().
+type DBClock interface {
+	Now(ctx context.Context) (time.Time, error)
+}
+
+// Clock is the app clock being checked; service.RealClock satisfies it.
+type Clock interface {
+	Now() time.Time
+}
+
+// CheckClockSkew compares clock with db's time and returns the measured skew (positive when the
+// database is ahead). The app time is taken halfway through the query to cancel out the round trip.
+// It returns ErrClockSkew when the skew exceeds limit; a non-positive limit uses DefaultMaxClockSkew.
+func CheckClockSkew(ctx context.Context, db DBClock, clock Clock, limit time.Duration) (time.Duration, error) {
+	if limit <= 0 {
+		limit = DefaultMaxClockSkew
+	}
+	before := clock.Now()
+	dbNow, err := db.Now(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("clock check: %w", err)
+	}
+	after := clock.Now()
+	appNow := before.Add(after.Sub(before) / 2)
+	skew := dbNow.Sub(appNow)
+	if skew > limit || skew < -limit {
+		return skew, fmt.Errorf("app and database clocks differ by %s, limit %s: %w", skew, limit, ErrClockSkew)
+	}
+	return skew, nil
+}
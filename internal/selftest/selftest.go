@@ -0,0 +1,58 @@
+// Package selftest runs an end-to-end write/read check against the snippet store at startup.
+package selftest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+)
+
+// IDPrefix and Tag mark self-test snippets so they are easy to spot and clean up.
+const (
+	IDPrefix = "selftest-"
+	Tag      = "bonsai-selftest"
+)
+
+// ErrContentMismatch is returned when a snippet reads back with different content than was written.
+var ErrContentMismatch = errors.New("content mismatch")
+
+// ttl bounds how long a self-test snippet can outlive a run when the store cannot delete it.
+const ttl = 5 * time.Second
+
+// Run creates a throwaway snippet, reads it back twice (so a caching repository serves
+// the second read from its cache) and removes it, returning the first step that fails.
+// Repositories without delete support get a snippet that expires within seconds instead.
+func Run(ctx context.Context, repo repository.SnippetRepository) error {
+	now := time.Now().UTC()
+	want := domain.Snippet{
+		ID:        IDPrefix + uuid.NewString(),
+		Content:   "bonsai startup self-test " + now.Format(time.RFC3339Nano),
+		Tags:      []string{Tag},
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+		Version:   1,
+	}
+	if err := repo.Insert(ctx, want); err != nil {
+		return fmt.Errorf("self-test create: %w", err)
+	}
+	for i := 1; i <= 2; i++ {
+		got, err := repo.FindByID(ctx, want.ID)
+		if err != nil {
+			return fmt.Errorf("self-test read %d: %w", i, err)
+		}
+		if got.Content != want.Content {
+			return fmt.Errorf("self-test read %d: %w", i, ErrContentMismatch)
+		}
+	}
+	if d, ok := repo.(repository.HardDeleter); ok {
+		if err := d.DeleteByID(ctx, want.ID); err != nil {
+			return fmt.Errorf("self-test delete: %w", err)
+		}
+	}
+	return nil
+}
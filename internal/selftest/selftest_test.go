@@ -0,0 +1,119 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/internal/repository/fake"
+)
+
+// spyRepo records the self-test's calls and can fail one of them.
+type spyRepo struct {
+	*fake.SnippetRepository
+	calls     []string
+	insertErr error
+	deleted   string
+}
+
+func (s *spyRepo) Insert(ctx context.Context, sn domain.Snippet) error {
+	s.calls = append(s.calls, "insert")
+	if s.insertErr != nil {
+		return s.insertErr
+	}
+	return s.SnippetRepository.Insert(ctx, sn)
+}
+
+func (s *spyRepo) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	s.calls = append(s.calls, "find")
+	return s.SnippetRepository.FindByID(ctx, id)
+}
+
+func (s *spyRepo) DeleteByID(_ context.Context, id string) error {
+	s.calls = append(s.calls, "delete")
+	s.deleted = id
+	return nil
+}
+
+func TestRun_CreateReadDelete(t *testing.T) {
+	repo := &spyRepo{SnippetRepository: fake.NewSnippetRepository()}
+	if err := Run(context.Background(), repo); err != nil {
+		t.Fatalf("self-test: %v", err)
+	}
+	if got := strings.Join(repo.calls, ","); got != "insert,find,find,delete" {
+		t.Fatalf("want insert,find,find,delete, got %s", got)
+	}
+	if !strings.HasPrefix(repo.deleted, IDPrefix) {
+		t.Fatalf("want self-test ID deleted, got %q", repo.deleted)
+	}
+}
+
+func TestRun_FailsFast(t *testing.T) {
+	boom := errors.New("read-only transaction")
+	repo := &spyRepo{SnippetRepository: fake.NewSnippetRepository(), insertErr: boom}
+	err := Run(context.Background(), repo)
+	if !errors.Is(err, boom) {
+		t.Fatalf("want insert error, got %v", err)
+	}
+	if len(repo.calls) != 1 {
+		t.Fatalf("want to stop after the failed create, got calls %v", repo.calls)
+	}
+}
+
+func TestRun_ReadMissing(t *testing.T) {
+	// a store that accepts writes but loses them, e.g. a misrouted replica
+	repo := &lossyRepo{SnippetRepository: fake.NewSnippetRepository()}
+	if err := Run(context.Background(), repo); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want not found, got %v", err)
+	}
+}
+
+type lossyRepo struct{ *fake.SnippetRepository }
+
+func (lossyRepo) Insert(context.Context, domain.Snippet) error { return nil }
+
+// fixedDB reports a fixed database time or an error.
+type fixedDB struct {
+	now time.Time
+	err error
+}
+
+func (f fixedDB) Now(context.Context) (time.Time, error) { return f.now, f.err }
+
+type stubClock struct{ t time.Time }
+
+func (c stubClock) Now() time.Time { return c.t }
+
+func TestCheckClockSkew(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 4, 12, 0, 0, 0, time.UTC)
+	for _, tt := range []struct {
+		name    string
+		dbAhead time.Duration
+		limit   time.Duration
+		wantErr bool
+	}{
+		{"aligned", 0, 0, false},
+		{"within default", 900 * time.Millisecond, 0, false},
+		{"db ahead", 2 * time.Second, 0, true},
+		{"db behind", -2 * time.Second, 0, true},
+		{"within custom limit", 4 * time.Second, 5 * time.Second, false},
+	} {
+		skew, err := CheckClockSkew(ctx, fixedDB{now: now.Add(tt.dbAhead)}, stubClock{t: now}, tt.limit)
+		if skew != tt.dbAhead {
+			t.Fatalf("%s: want skew %s, got %s", tt.name, tt.dbAhead, skew)
+		}
+		if errors.Is(err, ErrClockSkew) != tt.wantErr {
+			t.Fatalf("%s: want skew error %v, got %v", tt.name, tt.wantErr, err)
+		}
+	}
+
+	boom := errors.New("connection refused")
+	if _, err := CheckClockSkew(ctx, fixedDB{err: boom}, stubClock{t: now}, 0); !errors.Is(err, boom) || errors.Is(err, ErrClockSkew) {
+		t.Fatalf("want the database error, got %v", err)
+	}
+}
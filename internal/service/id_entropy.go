@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// DefaultIDCollisionRisk is the highest acceptable chance that any two generated short IDs collide.
+const DefaultIDCollisionRisk = 0.01
+
+// ErrIDEntropy is returned by CheckIDEntropy when the collision risk exceeds the threshold.
+var ErrIDEntropy = errors.New("short id collision risk too high")
+
+// IDCollisionRisk estimates the chance that count random IDs of length characters drawn from the
+// base62 ID alphabet contain at least one collision, using the birthday approximation
+// 1 - e^(-n(n-1)/2N).
+func IDCollisionRisk(length int, count int64) float64 {
+	if length <= 0 || count < 2 {
+		return 0
+	}
+	space := math.Pow(float64(len(idAlphabet)), float64(length))
+	n := float64(count)
+	return -math.Expm1(-n * (n - 1) / (2 * space))
+}
+
+// RecommendedIDLength returns the shortest ID length keeping the collision risk for count IDs at
+// or below threshold.
+func RecommendedIDLength(count int64, threshold float64) int {
+	length := 1
+	for IDCollisionRisk(length, count) > threshold && length < 64 {
+		length++
+	}
+	return length
+}
+
+// CheckIDEntropy returns the collision risk of count IDs of the given length, and ErrIDEntropy
+// naming a safer length when it exceeds threshold (0 uses DefaultIDCollisionRisk).
+func CheckIDEntropy(length int, count int64, threshold float64) (float64, error) {
+	if threshold <= 0 {
+		threshold = DefaultIDCollisionRisk
+	}
+	risk := IDCollisionRisk(length, count)
+	if risk > threshold {
+		return risk, fmt.Errorf("%w: %.4g for %d snippets with %d-character ids, use at least %d characters",
+			ErrIDEntropy, risk, count, length, RecommendedIDLength(count, threshold))
+	}
+	return risk, nil
+}
+
+// IDLength is the length of generated IDs when they are short random strings, or 0 for UUIDs,
+// whose collision risk is negligible.
+func (s *Service) IDLength() int { return s.idLength }
+
+// CheckIDEntropy counts the stored snippets and checks the collision risk of the service's short
+// IDs against threshold. It is a no-op for UUIDs.
+func (s *Service) CheckIDEntropy(ctx context.Context, threshold float64) (float64, error) {
+	if s.idLength == 0 {
+		return 0, nil
+	}
+	n, err := s.repo.Count(ctx, "")
+	if err != nil {
+		return 0, fmt.Errorf("count snippets: %w", err)
+	}
+	return CheckIDEntropy(s.idLength, int64(n), threshold)
+}
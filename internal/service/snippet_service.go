@@ -3,13 +3,26 @@ package service
 
 import (
 	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
+	"github.com/pmezard/go-difflib/difflib"
 	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/langdetect"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
+	"github.com/roguepikachu/bonsai/pkg/logger"
+	"golang.org/x/sync/singleflight"
 )
 
 // NewService creates a new Service with the given SnippetRepository and Clock.
@@ -22,23 +35,323 @@ type Service struct {
 	repo  repository.SnippetRepository
 	clock Clock
 	idGen func() string
+	// duplicateHint enables the non-blocking duplicate_of hint on create.
+	duplicateHint bool
+	// strictTag reports ErrUnknownTag when listing by a tag no snippet has ever carried.
+	strictTag bool
+	// gets, when non-nil, coalesces concurrent GetSnippetByID reads for the same ID.
+	gets *singleflight.Group
+	// customIDGen is set when the ID generator was overridden, e.g. for short IDs.
+	customIDGen bool
+	// idLength is the length of generated short IDs, or 0 when unknown or UUIDs.
+	idLength int
+	// idCollisionCheck, when non-nil, overrides whether generated IDs are checked for existence before insert.
+	idCollisionCheck *bool
+	// importDropInvalidExpiry clears an imported expiry that is not after created_at instead of rejecting it.
+	importDropInvalidExpiry bool
+	// reviveOnUpdate lets UpdateSnippet rewrite an expired snippet instead of returning ErrSnippetExpired.
+	reviveOnUpdate bool
+	// detectLanguage fills an unset language from the content on create and update.
+	detectLanguage bool
+	// facetWindow caps how many matching snippets TagFacets scans; 0 uses DefaultFacetWindow.
+	facetWindow int
+	// metadataMaxItems caps how many snippets SnippetMetadataByTag returns; 0 uses DefaultMetadataMaxItems.
+	metadataMaxItems int
+	// expiryJitter perturbs TTL-based expiries by up to ±expiryJitter of the TTL; 0 disables it.
+	expiryJitter float64
+	// rand returns a number in [0, 1) used to pick each snippet's jitter.
+	rand func() float64
+	// expiryRounding rounds TTL-based expiries up to a multiple of itself; 0 keeps them exact.
+	expiryRounding time.Duration
+	// readGrace keeps expired snippets readable by ID for this long after they expire; 0 disables it.
+	readGrace time.Duration
+	// countViews makes RecordView count reads when the repository supports it.
+	countViews bool
+	// hardDelete makes DeleteSnippet remove rows instead of soft-deleting them.
+	hardDelete bool
+	// maxContentBytes caps snippet content size in bytes; 0 uses DefaultMaxContentBytes.
+	maxContentBytes int
+	// maxTags caps how many tags a snippet may carry; 0 uses DefaultMaxTags.
+	maxTags int
+	// maxTagLength caps each tag's length in characters; 0 uses DefaultMaxTagLength.
+	maxTagLength int
 }
 
 // Error variables
 var (
-	ErrSnippetNotFound = errors.New("snippet not found")
-	ErrSnippetExpired  = errors.New("snippet expired")
+	ErrSnippetNotFound        = errors.New("snippet not found")
+	ErrSnippetExpired         = errors.New("snippet expired")
+	ErrSnippetNotYetAvailable = errors.New("snippet not yet available")
+	ErrUnknownTag             = errors.New("unknown tag")
+	ErrVersionNotFound        = errors.New("snippet version not found")
+	ErrInvalidExpiry          = errors.New("expires_at must be after created_at")
+	ErrIDCollision            = errors.New("could not generate a unique snippet id")
+	ErrDuplicateID            = errors.New("snippet id already exists")
+	ErrViewLimitReached       = errors.New("snippet view limit reached")
+	ErrPrivateRequiresOwner   = errors.New("private snippets require a client id")
+	ErrContentTooLarge        = errors.New("snippet content too large")
+	ErrTagTooLong             = errors.New("tag too long")
+	// ErrTooManyTags is repository.ErrTooManyTags, so callers match one error whichever layer rejected the tags.
+	ErrTooManyTags = repository.ErrTooManyTags
 )
 
+// DefaultMaxContentBytes is the content size limit, in bytes, when none is configured.
+const DefaultMaxContentBytes = 10240
+
 // Option configures Service.
 type Option func(*Service)
 
-// WithIDGenerator overrides the snippet ID generator.
-func WithIDGenerator(f func() string) Option { return func(s *Service) { s.idGen = f } }
+// WithIDGenerator overrides the snippet ID generator. Generated IDs are checked for
+// collisions before insert unless WithIDCollisionCheck(false) is given.
+func WithIDGenerator(f func() string) Option {
+	return func(s *Service) { s.idGen, s.customIDGen, s.idLength = f, true, 0 }
+}
+
+// WithIDCollisionCheck forces the pre-insert existence check for generated IDs on or off.
+// By default it runs only for custom (e.g. short) generators and is skipped for UUIDs.
+func WithIDCollisionCheck(enabled bool) Option {
+	return func(s *Service) { s.idCollisionCheck = &enabled }
+}
+
+// SnippetOption sets optional fields on a snippet being created or updated.
+type SnippetOption func(*domain.Snippet)
+
+// WithVisibleFrom schedules publication of the snippet at t.
+func WithVisibleFrom(t time.Time) SnippetOption {
+	return func(s *domain.Snippet) { s.VisibleFrom = t }
+}
+
+// WithLanguage sets the snippet's content language.
+func WithLanguage(language string) SnippetOption {
+	return func(s *domain.Snippet) { s.Language = language }
+}
+
+// WithCacheTTL hints how many seconds the snippet may be cached.
+func WithCacheTTL(seconds int) SnippetOption {
+	return func(s *domain.Snippet) { s.CacheTTLSeconds = seconds }
+}
+
+// WithTemplated marks the snippet's content as a template with {{var}} placeholders.
+func WithTemplated(templated bool) SnippetOption {
+	return func(s *domain.Snippet) { s.Templated = templated }
+}
+
+// WithID uses a client-supplied ID instead of generating one. Concurrent creates of the same ID
+// are decided by the repository: one succeeds and the others fail with ErrDuplicateID.
+func WithID(id string) SnippetOption {
+	return func(s *domain.Snippet) { s.ID = id }
+}
+
+// WithMaxViews makes the snippet gone once it was read n times; 0 means unlimited.
+func WithMaxViews(n int) SnippetOption {
+	return func(s *domain.Snippet) { s.MaxViews = n }
+}
+
+// WithVisibility sets who can read the snippet, domain.VisibilityPublic or domain.VisibilityPrivate.
+func WithVisibility(visibility string) SnippetOption {
+	return func(s *domain.Snippet) { s.Visibility = visibility }
+}
+
+// WithTitle sets the snippet's title; "" clears it.
+func WithTitle(title string) SnippetOption {
+	return func(s *domain.Snippet) { s.Title = title }
+}
+
+// WithSource records which path created the snippet, e.g. domain.SourceFork; creates default to domain.SourceAPI.
+func WithSource(source string) SnippetOption {
+	return func(s *domain.Snippet) { s.Source = source }
+}
+
+// WithSeededIDGenerator produces deterministic short IDs from a PRNG seeded with seed.
+// Intended for reproducible tests and fixtures only; production uses UUIDs or WithShortIDs.
+func WithSeededIDGenerator(seed int64) Option {
+	return func(s *Service) {
+		s.idGen, s.customIDGen, s.idLength = seededIDGenerator(seed), true, seededIDLength
+	}
+}
+
+// WithShortIDs generates random base62 IDs of the given length (0 uses DefaultShortIDLength)
+// instead of UUIDs, for shorter share URLs. Taken IDs are regenerated like those of any custom generator.
+func WithShortIDs(length int) Option {
+	if length <= 0 {
+		length = DefaultShortIDLength
+	}
+	return func(s *Service) {
+		s.idGen, s.customIDGen, s.idLength = shortIDGenerator(length, cryptorand.Reader), true, length
+	}
+}
+
+// WithGetCoalescing makes concurrent GetSnippetByID calls for the same ID share one repository read.
+func WithGetCoalescing(enabled bool) Option {
+	return func(s *Service) {
+		if enabled {
+			s.gets = &singleflight.Group{}
+		} else {
+			s.gets = nil
+		}
+	}
+}
+
+// WithStrictTag makes ListSnippets return ErrUnknownTag for tags no snippet, active or expired, has ever carried.
+func WithStrictTag(enabled bool) Option { return func(s *Service) { s.strictTag = enabled } }
+
+// WithDuplicateHint enables looking up identical content on create and reporting it via Snippet.DuplicateOf.
+func WithDuplicateHint(enabled bool) Option { return func(s *Service) { s.duplicateHint = enabled } }
+
+// WithImportDropInvalidExpiry makes ImportSnippet drop an expiry that is not after created_at
+// instead of rejecting the record with ErrInvalidExpiry.
+func WithImportDropInvalidExpiry(enabled bool) Option {
+	return func(s *Service) { s.importDropInvalidExpiry = enabled }
+}
+
+// WithReviveOnUpdate lets UpdateSnippet revive an expired snippet, resetting its expiry from
+// the new expires_in, instead of returning ErrSnippetExpired.
+func WithReviveOnUpdate(enabled bool) Option { return func(s *Service) { s.reviveOnUpdate = enabled } }
+
+// WithFacetWindow caps how many of the most recent matching snippets TagFacets scans.
+// Values below 1 use DefaultFacetWindow.
+func WithFacetWindow(n int) Option { return func(s *Service) { s.facetWindow = n } }
+
+// WithMetadataMaxItems caps how many snippets SnippetMetadataByTag returns.
+// Values below 1 use DefaultMetadataMaxItems.
+func WithMetadataMaxItems(n int) Option { return func(s *Service) { s.metadataMaxItems = n } }
+
+// WithMaxContentBytes caps snippet content at n bytes on create, import and update.
+// Values below 1 use DefaultMaxContentBytes.
+func WithMaxContentBytes(n int) Option { return func(s *Service) { s.maxContentBytes = n } }
+
+// checkContentSize returns ErrContentTooLarge when content exceeds the byte limit. Multibyte
+// characters count with their full UTF-8 length.
+func (s *Service) checkContentSize(content string) error {
+	limit := s.maxContentBytes
+	if limit < 1 {
+		limit = DefaultMaxContentBytes
+	}
+	if len(content) > limit {
+		return fmt.Errorf("%d bytes, limit %d: %w", len(content), limit, ErrContentTooLarge)
+	}
+	return nil
+}
+
+// Tag limits used when none are configured.
+const (
+	DefaultMaxTags      = 256
+	DefaultMaxTagLength = 64
+)
+
+// WithMaxTags caps how many tags a snippet may carry after normalization.
+// Values below 1 use DefaultMaxTags.
+func WithMaxTags(n int) Option { return func(s *Service) { s.maxTags = n } }
+
+// WithMaxTagLength caps each tag's length in characters after normalization.
+// Values below 1 use DefaultMaxTagLength.
+func WithMaxTagLength(n int) Option { return func(s *Service) { s.maxTagLength = n } }
+
+// normalizeTags trims and lowercases tags, dropping empty ones and duplicates while keeping
+// first-seen order, then applies the tag limits. Nil or empty input is returned as is.
+func (s *Service) normalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return tags, nil
+	}
+	maxTags, maxLength := s.maxTags, s.maxTagLength
+	if maxTags < 1 {
+		maxTags = DefaultMaxTags
+	}
+	if maxLength < 1 {
+		maxLength = DefaultMaxTagLength
+	}
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if utf8.RuneCountInString(t) > maxLength {
+			return nil, fmt.Errorf("%q is longer than %d characters: %w", t, maxLength, ErrTagTooLong)
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	if len(normalized) > maxTags {
+		return nil, fmt.Errorf("%d tags, at most %d allowed: %w", len(normalized), maxTags, ErrTooManyTags)
+	}
+	return normalized, nil
+}
+
+// WithLanguageDetection fills an unset snippet language from its content on create and update.
+// An explicitly provided language is never overridden.
+func WithLanguageDetection(enabled bool) Option {
+	return func(s *Service) { s.detectLanguage = enabled }
+}
+
+// WithExpiryJitter spreads TTL-based expiries (create and update expires_in) uniformly within
+// ±fraction of the requested TTL, so snippets created together do not all expire together.
+// fraction is clamped to [0, 1]; 0 (the default) keeps expiries exact. Imported absolute
+// expiries are never jittered.
+func WithExpiryJitter(fraction float64) Option {
+	return func(s *Service) { s.expiryJitter = min(max(fraction, 0), 1) }
+}
+
+// Expiry rounding modes accepted by ParseExpiryRounding.
+const (
+	ExpiryRoundingNone   = "none"
+	ExpiryRoundingMinute = "minute"
+	ExpiryRoundingHour   = "hour"
+)
+
+// ParseExpiryRounding maps an expiry rounding mode to the boundary expiries are rounded up to;
+// "" and ExpiryRoundingNone return 0.
+func ParseExpiryRounding(mode string) (time.Duration, error) {
+	switch mode {
+	case "", ExpiryRoundingNone:
+		return 0, nil
+	case ExpiryRoundingMinute:
+		return time.Minute, nil
+	case ExpiryRoundingHour:
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unknown expiry rounding %q, want none, minute or hour", mode)
+	}
+}
+
+// WithExpiryRounding rounds TTL-based expiries (create and update expires_in) up to the next
+// multiple of unit, e.g. time.Minute, so displayed expiries fall on clean boundaries. Rounding
+// applies after jitter; 0 (the default) keeps expiries exact. Imported absolute expiries are
+// never rounded.
+func WithExpiryRounding(unit time.Duration) Option {
+	return func(s *Service) { s.expiryRounding = max(unit, 0) }
+}
+
+// WithReadGrace keeps an expired snippet readable through GetSnippetByID for grace after its
+// expiry, flagged with SnippetMeta.Expired, before reads fail with ErrSnippetExpired. Lists,
+// counts and batch reads still leave expired snippets out. 0 (the default) expires reads
+// immediately.
+func WithReadGrace(grace time.Duration) Option {
+	return func(s *Service) { s.readGrace = max(grace, 0) }
+}
+
+// WithRand overrides the source of expiry jitter, for deterministic tests. fn must be safe
+// for concurrent use if the service is.
+func WithRand(fn func() float64) Option {
+	return func(s *Service) {
+		if fn != nil {
+			s.rand = fn
+		}
+	}
+}
+
+// WithViewCounting makes RecordView count snippet reads through repositories that implement
+// IncrementView, such as the Redis-cached repository.
+func WithViewCounting(enabled bool) Option { return func(s *Service) { s.countViews = enabled } }
+
+// WithHardDelete makes DeleteSnippet permanently remove snippets instead of marking them
+// deleted. The repository must implement repository.HardDeleter.
+func WithHardDelete(enabled bool) Option { return func(s *Service) { s.hardDelete = enabled } }
 
 // NewServiceWithOptions creates a Service with additional options for testability.
 func NewServiceWithOptions(repo repository.SnippetRepository, clock Clock, opts ...Option) *Service {
-	s := &Service{repo: repo, clock: clock, idGen: generateID}
+	s := &Service{repo: repo, clock: clock, idGen: generateID, rand: rand.Float64}
 	for _, opt := range opts {
 		opt(s)
 	}
@@ -50,25 +363,205 @@ func generateID() string {
 	return uuid.New().String()
 }
 
-// CreateSnippet creates a new snippet with content, expiry, and tags.
-func (s *Service) CreateSnippet(ctx context.Context, content string, expiresIn int, tags []string) (domain.Snippet, error) {
-	now := s.clock.Now()
-	var expiresAt time.Time
-	if expiresIn > 0 {
-		expiresAt = now.Add(time.Duration(expiresIn) * time.Second)
-	} else {
-		expiresAt = time.Time{} // zero value, means no expiry
+// seededIDLength is the length of IDs produced by seededIDGenerator.
+const seededIDLength = 10
+
+const idAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// seededIDGenerator returns a concurrency-safe generator yielding the same ID sequence for the same seed.
+func seededIDGenerator(seed int64) func() string {
+	var mu sync.Mutex
+	rng := rand.New(rand.NewSource(seed))
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		b := make([]byte, seededIDLength)
+		for i := range b {
+			b[i] = idAlphabet[rng.Intn(len(idAlphabet))]
+		}
+		return string(b)
 	}
+}
+
+// DefaultShortIDLength is the length of IDs generated by WithShortIDs when none is configured.
+const DefaultShortIDLength = 10
+
+// shortIDGenerator returns a concurrency-safe generator of random base62 IDs drawn from r.
+func shortIDGenerator(length int, r io.Reader) func() string {
+	var mu sync.Mutex
+	return func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		id := make([]byte, 0, length)
+		buf := make([]byte, length)
+		for len(id) < length {
+			if _, err := io.ReadFull(r, buf); err != nil {
+				panic(fmt.Sprintf("short id: %v", err))
+			}
+			for _, c := range buf {
+				// 248 is the largest multiple of 62 below 256; higher bytes would bias the alphabet
+				if c < 248 && len(id) < length {
+					id = append(id, idAlphabet[int(c)%len(idAlphabet)])
+				}
+			}
+		}
+		return string(id)
+	}
+}
+
+// maxIDAttempts bounds how often a taken ID is regenerated before giving up.
+const maxIDAttempts = 5
+
+// checksIDCollisions reports whether generated IDs are checked for existence before insert.
+func (s *Service) checksIDCollisions() bool {
+	if s.idCollisionCheck != nil {
+		return *s.idCollisionCheck
+	}
+	return s.customIDGen
+}
+
+// newID generates a snippet ID, regenerating it while it is already taken when collision checks apply.
+func (s *Service) newID(ctx context.Context) (string, error) {
 	gen := s.idGen
 	if gen == nil {
 		gen = generateID
 	}
+	if !s.checksIDCollisions() {
+		return gen(), nil
+	}
+	for attempt := 0; attempt < maxIDAttempts; attempt++ {
+		id := gen()
+		_, err := s.repo.FindByID(ctx, id)
+		if errors.Is(err, repository.ErrNotFound) {
+			return id, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("id collision check: %w", err)
+		}
+		logger.WithField(ctx, "id", id).Warn("generated snippet id already taken, regenerating")
+	}
+	return "", ErrIDCollision
+}
+
+// hashContent returns the hex-encoded SHA-256 of content.
+func hashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSnippet creates a new snippet with content, expiry, and tags.
+func (s *Service) CreateSnippet(ctx context.Context, content string, expiresIn int, tags []string, opts ...SnippetOption) (domain.Snippet, error) {
+	if err := s.checkContentSize(content); err != nil {
+		return domain.Snippet{}, err
+	}
+	tags, err := s.normalizeTags(tags)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
+	now := s.clock.Now()
+	expiresAt := s.expiryAfter(now, expiresIn)
+	snippet := domain.Snippet{
+		Content:     content,
+		Tags:        tags,
+		CreatedAt:   now,
+		ExpiresAt:   expiresAt,
+		OwnerID:     ctxutil.ClientID(ctx),
+		ContentHash: hashContent(content),
+		Version:     1,
+		Source:      domain.SourceAPI,
+		Visibility:  domain.VisibilityPublic,
+	}
+	for _, opt := range opts {
+		opt(&snippet)
+	}
+	if snippet.Visibility == domain.VisibilityPrivate && snippet.OwnerID == "" {
+		return domain.Snippet{}, ErrPrivateRequiresOwner
+	}
+	if snippet.ID == "" {
+		id, err := s.newID(ctx)
+		if err != nil {
+			return domain.Snippet{}, err
+		}
+		snippet.ID = id
+	}
+	s.fillLanguage(&snippet)
+	var duplicateOf string
+	if s.duplicateHint {
+		// Best-effort: a failed lookup must never block the create.
+		// Someone else's private snippet must not be revealed through the hint.
+		existing, err := s.repo.FindByContentHash(ctx, snippet.ContentHash)
+		switch {
+		case err == nil:
+			if existing.VisibleTo(snippet.OwnerID) {
+				duplicateOf = existing.ID
+			}
+		case !errors.Is(err, repository.ErrNotFound):
+			logger.WithField(ctx, "error", err.Error()).Warn("duplicate lookup failed")
+		}
+	}
+	if err := s.repo.Insert(ctx, snippet); err != nil {
+		if errors.Is(err, repository.ErrDuplicateID) {
+			return domain.Snippet{}, fmt.Errorf("%s: %w", snippet.ID, ErrDuplicateID)
+		}
+		return domain.Snippet{}, err
+	}
+	snippet.DuplicateOf = duplicateOf
+	return snippet, nil
+}
+
+// fillLanguage detects the snippet's language from its content when enabled and unset.
+func (s *Service) fillLanguage(snippet *domain.Snippet) {
+	if s.detectLanguage && snippet.Language == "" {
+		snippet.Language = langdetect.Detect(snippet.Content)
+	}
+}
+
+// ImportRecord is a snippet carrying explicit timestamps, e.g. exported from another instance.
+type ImportRecord struct {
+	Content   string
+	Tags      []string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// ImportSnippet stores a snippet keeping its original timestamps. A zero CreatedAt means now and a
+// zero ExpiresAt means no expiry. An expiry not after CreatedAt is rejected with ErrInvalidExpiry,
+// or dropped when import auto-correction is enabled.
+func (s *Service) ImportSnippet(ctx context.Context, rec ImportRecord) (domain.Snippet, error) {
+	if err := s.checkContentSize(rec.Content); err != nil {
+		return domain.Snippet{}, err
+	}
+	tags, err := s.normalizeTags(rec.Tags)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
+	createdAt := rec.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = s.clock.Now()
+	}
+	expiresAt := rec.ExpiresAt
+	if !expiresAt.IsZero() && !expiresAt.After(createdAt) {
+		if !s.importDropInvalidExpiry {
+			return domain.Snippet{}, fmt.Errorf("expires_at %s, created_at %s: %w",
+				expiresAt.UTC().Format(time.RFC3339), createdAt.UTC().Format(time.RFC3339), ErrInvalidExpiry)
+		}
+		logger.With(ctx, map[string]any{"created_at": createdAt, "expires_at": expiresAt}).Warn("dropping imported expiry before creation")
+		expiresAt = time.Time{}
+	}
+	id, err := s.newID(ctx)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
 	snippet := domain.Snippet{
-		ID:        gen(),
-		Content:   content,
-		Tags:      tags,
-		CreatedAt: now,
-		ExpiresAt: expiresAt,
+		ID:          id,
+		Content:     rec.Content,
+		Tags:        tags,
+		CreatedAt:   createdAt,
+		ExpiresAt:   expiresAt,
+		OwnerID:     ctxutil.ClientID(ctx),
+		ContentHash: hashContent(rec.Content),
+		Version:     1,
+		Source:      domain.SourceImport,
 	}
 	if err := s.repo.Insert(ctx, snippet); err != nil {
 		return domain.Snippet{}, err
@@ -83,8 +576,94 @@ const (
 	ServiceMaxLimit     = 100
 )
 
+// ListSnippetsAfter returns the page of snippets following cursor, newest first.
+// Limits are capped like ListSnippets.
+func (s *Service) ListSnippetsAfter(ctx context.Context, cursor repository.Cursor, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
+	if limit > ServiceMaxLimit {
+		limit = ServiceMaxLimit
+	}
+	if limit < 1 {
+		limit = ServiceDefaultLimit
+	}
+	return s.repo.ListAfter(ctx, cursor, limit, tag, opts...)
+}
+
+// CountSnippets returns how many active snippets match the tag and options across all pages.
+func (s *Service) CountSnippets(ctx context.Context, tag string, opts ...repository.ListOption) (int, error) {
+	return s.repo.Count(ctx, tag, opts...)
+}
+
+// DefaultFacetWindow is how many matching snippets TagFacets scans when no window is configured.
+const DefaultFacetWindow = 1000
+
+// TagFacets holds tag counts over the snippets matching a list filter.
+type TagFacets struct {
+	// Tags maps each tag to the number of scanned snippets carrying it.
+	Tags map[string]int
+	// Scanned is how many snippets were counted.
+	Scanned int
+	// Approximate is true when more snippets matched than the facet window, so only the
+	// most recent ones were counted.
+	Approximate bool
+}
+
+// TagFacets counts tags over the most recent snippets matching the tag and options, scanning at
+// most the configured facet window so the cost stays bounded on large corpora.
+func (s *Service) TagFacets(ctx context.Context, tag string, opts ...repository.ListOption) (TagFacets, error) {
+	window := s.facetWindow
+	if window < 1 {
+		window = DefaultFacetWindow
+	}
+	// One extra row tells whether the window was exceeded. The scan is a one-off, so keep it out of the cache.
+	opts = append(opts[:len(opts):len(opts)], repository.WithSort(repository.SortCreatedAtDesc))
+	items, err := s.repo.List(ctxutil.WithCacheBypass(ctx), 1, window+1, tag, opts...)
+	if err != nil {
+		return TagFacets{}, err
+	}
+	facets := TagFacets{Tags: make(map[string]int)}
+	if len(items) > window {
+		items = items[:window]
+		facets.Approximate = true
+	}
+	for _, snippet := range items {
+		for _, t := range snippet.Tags {
+			facets.Tags[t]++
+		}
+	}
+	facets.Scanned = len(items)
+	return facets, nil
+}
+
+// DefaultMetadataMaxItems is how many snippets SnippetMetadataByTag returns when no cap is configured.
+const DefaultMetadataMaxItems = 1000
+
+// SnippetMetadataByTag returns the most recent active public snippets carrying tag, without
+// their content, up to the configured cap. truncated reports that more snippets matched.
+func (s *Service) SnippetMetadataByTag(ctx context.Context, tag string) (items []domain.Snippet, truncated bool, err error) {
+	limit := s.metadataMaxItems
+	if limit < 1 {
+		limit = DefaultMetadataMaxItems
+	}
+	// One extra row tells whether the cap was exceeded. Keyset reads are not cached, and without
+	// repository.WithContent the store skips reading content.
+	items, err = s.repo.ListAfter(ctx, repository.Cursor{}, limit+1, tag)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(items) > limit {
+		items, truncated = items[:limit], true
+	}
+	// Stores may return content anyway; never hand it out from here.
+	for i := range items {
+		items[i].Content = ""
+	}
+	return items, truncated, nil
+}
+
 // ListSnippets returns a list of snippets with pagination and optional tag filtering.
-func (s *Service) ListSnippets(ctx context.Context, page, limit int, tag string) ([]domain.Snippet, error) {
+// Limits above ServiceMaxLimit are capped; whether such requests reach the service is
+// decided by the HTTP layer's over-limit policy.
+func (s *Service) ListSnippets(ctx context.Context, page, limit int, tag string, opts ...repository.ListOption) ([]domain.Snippet, error) {
 	if limit > ServiceMaxLimit {
 		limit = ServiceMaxLimit
 	}
@@ -94,7 +673,41 @@ func (s *Service) ListSnippets(ctx context.Context, page, limit int, tag string)
 	if page < 1 {
 		page = ServiceDefaultPage
 	}
-	return s.repo.List(ctx, page, limit, tag)
+	items, err := s.repo.List(ctx, page, limit, tag, opts...)
+	if err != nil {
+		return nil, err
+	}
+	// Only an empty page needs the existence check, keeping strict mode cheap.
+	if s.strictTag && tag != "" && len(items) == 0 {
+		exists, err := s.repo.TagExists(ctx, tag)
+		if err != nil {
+			return nil, fmt.Errorf("tag exists: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("%q: %w", tag, ErrUnknownTag)
+		}
+	}
+	return items, nil
+}
+
+// ListTags returns the tags of active snippets with their counts, most used first. A non-empty
+// prefix keeps only tags starting with it, ignoring case, e.g. for typeahead.
+func (s *Service) ListTags(ctx context.Context, prefix string) ([]repository.TagCount, error) {
+	tags, err := s.repo.ListTags(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list tags: %w", err)
+	}
+	if prefix == "" {
+		return tags, nil
+	}
+	prefix = strings.ToLower(prefix)
+	matched := make([]repository.TagCount, 0, len(tags))
+	for _, tc := range tags {
+		if strings.HasPrefix(strings.ToLower(tc.Tag), prefix) {
+			matched = append(matched, tc)
+		}
+	}
+	return matched, nil
 }
 
 // CacheStatus is a typed cache status string.
@@ -110,13 +723,62 @@ const (
 // SnippetMeta holds metadata about a snippet fetch.
 type SnippetMeta struct {
 	CacheStatus CacheStatus
+	// ViewCounted is set when the read was already counted toward the snippet's view limit;
+	// the returned Views then includes it and reads still pending a flush.
+	ViewCounted bool
+	// Expired is set when the snippet expired but was read within the grace window.
+	Expired bool
+}
+
+// cacheStatusFinder is implemented by repositories that can report whether a read was served from cache.
+type cacheStatusFinder interface {
+	FindByIDCached(ctx context.Context, id string) (domain.Snippet, bool, error)
+}
+
+// findByID reads a snippet, reporting a cache hit when the repository supports it.
+func (s *Service) findByID(ctx context.Context, id string) (domain.Snippet, CacheStatus, error) {
+	if f, ok := s.repo.(cacheStatusFinder); ok {
+		snippet, hit, err := f.FindByIDCached(ctx, id)
+		if hit {
+			return snippet, CacheHit, err
+		}
+		return snippet, CacheMiss, err
+	}
+	snippet, err := s.repo.FindByID(ctx, id)
+	return snippet, CacheMiss, err
+}
+
+// sharedFind is the result shared between coalesced reads.
+type sharedFind struct {
+	snippet domain.Snippet
+	status  CacheStatus
+}
+
+// findByIDShared is findByID, coalesced per ID when get coalescing is enabled.
+func (s *Service) findByIDShared(ctx context.Context, id string) (domain.Snippet, CacheStatus, error) {
+	if s.gets == nil {
+		return s.findByID(ctx, id)
+	}
+	// cache-exempt reads must not share a result that may have come from the cache
+	key := id
+	if ctxutil.CacheBypass(ctx) {
+		key = "bypass:" + id
+	}
+	v, err, _ := s.gets.Do(key, func() (any, error) {
+		snippet, status, err := s.findByID(ctx, id)
+		return sharedFind{snippet: snippet, status: status}, err
+	})
+	res, _ := v.(sharedFind)
+	if res.status == "" {
+		res.status = CacheMiss
+	}
+	return res.snippet, res.status, err
 }
 
 // GetSnippetByID fetches a snippet by ID, returns metadata.
 func (s *Service) GetSnippetByID(ctx context.Context, id string) (domain.Snippet, SnippetMeta, error) {
-	// For demo, always MISS. Replace with real cache logic if needed.
-	snippet, err := s.repo.FindByID(ctx, id)
-	meta := SnippetMeta{CacheStatus: CacheMiss}
+	snippet, status, err := s.findByIDShared(ctx, id)
+	meta := SnippetMeta{CacheStatus: status}
 	if err != nil {
 		// Only translate not found at the service boundary
 		if errors.Is(err, repository.ErrNotFound) {
@@ -125,43 +787,297 @@ func (s *Service) GetSnippetByID(ctx context.Context, id string) (domain.Snippet
 		// All other errors are just wrapped
 		return domain.Snippet{}, meta, fmt.Errorf("find by id: %w", err)
 	}
-	if !snippet.ExpiresAt.IsZero() && s.clock.Now().After(snippet.ExpiresAt) {
-		return domain.Snippet{}, meta, fmt.Errorf("expired: %w", ErrSnippetExpired)
+	// Private snippets look absent to everyone but their owner, even when expired
+	if !snippet.VisibleTo(ctxutil.ClientID(ctx)) {
+		return domain.Snippet{}, meta, fmt.Errorf("private: %w", ErrSnippetNotFound)
+	}
+	if now := s.clock.Now(); !snippet.ExpiresAt.IsZero() && now.After(snippet.ExpiresAt) {
+		if now.After(snippet.ExpiresAt.Add(s.readGrace)) {
+			return domain.Snippet{}, meta, fmt.Errorf("expired: %w", ErrSnippetExpired)
+		}
+		meta.Expired = true
+	}
+	if !snippet.IsVisibleAt(s.clock.Now()) {
+		return domain.Snippet{}, meta, fmt.Errorf("scheduled: %w", ErrSnippetNotYetAvailable)
+	}
+	if snippet.MaxViews > 0 {
+		pending, err := s.countLimitedView(ctx, snippet)
+		if err != nil {
+			return domain.Snippet{}, meta, err
+		}
+		snippet.Views += pending
+		meta.ViewCounted = true
 	}
 	return snippet, meta, nil
 }
 
-// UpdateSnippet updates an existing snippet with new content, expiry, and tags.
-func (s *Service) UpdateSnippet(ctx context.Context, id string, content string, expiresIn int, tags []string) (domain.Snippet, error) {
-	// First check if snippet exists
-	existing, err := s.repo.FindByID(ctx, id)
+// viewLimiter is implemented by repositories that can check a view limit and count a read atomically.
+type viewLimiter interface {
+	IncrementViewWithin(ctx context.Context, id string, allowed int64) (int64, bool, error)
+}
+
+// countLimitedView counts a read of a view-limited snippet and returns its pending reads, or
+// ErrViewLimitReached once the stored and pending reads reach the limit. Limits are enforced
+// whether or not view counting is enabled. Without a usable counter only stored views are checked.
+func (s *Service) countLimitedView(ctx context.Context, snippet domain.Snippet) (int64, error) {
+	allowed := int64(snippet.MaxViews) - snippet.Views
+	if allowed <= 0 {
+		return 0, fmt.Errorf("max views: %w", ErrViewLimitReached)
+	}
+	vl, ok := s.repo.(viewLimiter)
+	if !ok {
+		return 0, nil
+	}
+	pending, counted, err := vl.IncrementViewWithin(ctx, snippet.ID, allowed)
+	if err != nil {
+		logger.With(ctx, map[string]any{"id": snippet.ID, "error": err.Error()}).Warn("failed to count view of view-limited snippet")
+		return 0, nil
+	}
+	if !counted {
+		return 0, fmt.Errorf("max views: %w", ErrViewLimitReached)
+	}
+	return pending, nil
+}
+
+// GetSnippetsByIDs fetches several snippets at once, in the order of ids with duplicates dropped.
+// Unknown, expired, not yet visible, view-limited and other clients' private snippets are
+// skipped rather than reported as errors.
+func (s *Service) GetSnippetsByIDs(ctx context.Context, ids []string) ([]domain.Snippet, error) {
+	unique := make([]string, 0, len(ids))
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	found, err := s.repo.FindByIDs(ctx, unique)
+	if err != nil {
+		return nil, fmt.Errorf("find by ids: %w", err)
+	}
+	byID := make(map[string]domain.Snippet, len(found))
+	for _, snippet := range found {
+		byID[snippet.ID] = snippet
+	}
+	now := s.clock.Now()
+	clientID := ctxutil.ClientID(ctx)
+	res := make([]domain.Snippet, 0, len(found))
+	for _, id := range unique {
+		snippet, ok := byID[id]
+		if ok && !snippet.VisibleTo(clientID) {
+			continue
+		}
+		// View-limited snippets are left out since only single reads count toward their limit
+		if !ok || (!snippet.ExpiresAt.IsZero() && now.After(snippet.ExpiresAt)) || !snippet.IsVisibleAt(now) || snippet.MaxViews > 0 {
+			continue
+		}
+		res = append(res, snippet)
+	}
+	return res, nil
+}
+
+// viewCounter is implemented by repositories that count reads pending a flush to storage.
+type viewCounter interface {
+	IncrementView(ctx context.Context, id string) (int64, error)
+}
+
+// RecordView counts one read of a snippet and returns its reads not yet reflected in
+// Snippet.Views. Counting is best-effort: failures are logged and report 0 pending reads.
+func (s *Service) RecordView(ctx context.Context, id string) int64 {
+	vc, ok := s.repo.(viewCounter)
+	if !s.countViews || !ok {
+		return 0
+	}
+	pending, err := vc.IncrementView(ctx, id)
+	if err != nil {
+		logger.With(ctx, map[string]any{"id": id, "error": err.Error()}).Warn("failed to count snippet view")
+		return 0
+	}
+	return pending
+}
+
+// GetSnippetByIDRaw fetches a snippet by ID without enforcing expiry or scheduled visibility.
+// It is meant for admin tooling such as export and audit and must not back the public Get route.
+func (s *Service) GetSnippetByIDRaw(ctx context.Context, id string) (domain.Snippet, error) {
+	snippet, err := s.repo.FindByID(ctx, id)
 	if err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
 			return domain.Snippet{}, fmt.Errorf("%w", ErrSnippetNotFound)
 		}
 		return domain.Snippet{}, fmt.Errorf("find by id: %w", err)
 	}
+	return snippet, nil
+}
+
+// DeleteSnippet deletes a snippet: by default it is soft-deleted and can be restored with
+// RestoreSnippet, with WithHardDelete it is removed for good. Deleting a missing or already
+// deleted snippet reports ErrSnippetNotFound.
+func (s *Service) DeleteSnippet(ctx context.Context, id string) error {
+	var err error
+	if s.hardDelete {
+		hd, ok := s.repo.(repository.HardDeleter)
+		if !ok {
+			return fmt.Errorf("hard delete: %w", errors.ErrUnsupported)
+		}
+		err = hd.DeleteByID(ctx, id)
+	} else {
+		err = s.repo.SoftDelete(ctx, id, s.clock.Now())
+	}
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return fmt.Errorf("%w", ErrSnippetNotFound)
+		}
+		return fmt.Errorf("delete snippet: %w", err)
+	}
+	return nil
+}
 
-	// Check if snippet is expired
-	if !existing.ExpiresAt.IsZero() && s.clock.Now().After(existing.ExpiresAt) {
+// RestoreSnippet clears the deletion mark of a soft-deleted snippet and returns it. Snippets
+// that are missing, live or hard-deleted report ErrSnippetNotFound.
+func (s *Service) RestoreSnippet(ctx context.Context, id string) (domain.Snippet, error) {
+	if err := s.repo.Restore(ctx, id); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.Snippet{}, fmt.Errorf("%w", ErrSnippetNotFound)
+		}
+		return domain.Snippet{}, fmt.Errorf("restore snippet: %w", err)
+	}
+	return s.GetSnippetByIDRaw(ctxutil.WithReadPrimary(ctx), id)
+}
+
+// UpdateSnippet updates an existing snippet with new content, expiry, and tags.
+func (s *Service) UpdateSnippet(ctx context.Context, id string, content string, expiresIn int, tags []string, opts ...SnippetOption) (domain.Snippet, error) {
+	if err := s.checkContentSize(content); err != nil {
+		return domain.Snippet{}, err
+	}
+	tags, err := s.normalizeTags(tags)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
+	existing, err := s.findForUpdate(ctx, id, true)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
+	return s.saveUpdate(ctx, existing, content, s.expiryFrom(expiresIn), tags, opts...)
+}
+
+// SnippetPatch holds the fields of a partial update; nil fields keep their current value.
+type SnippetPatch struct {
+	Content   *string
+	ExpiresIn *int
+	Tags      *[]string
+}
+
+// PatchSnippet updates only the fields set in patch (and opts), keeping the rest of the
+// snippet, including its expiry, unchanged. Reviving an expired snippet requires a new expiry.
+func (s *Service) PatchSnippet(ctx context.Context, id string, patch SnippetPatch, opts ...SnippetOption) (domain.Snippet, error) {
+	if patch.Content != nil {
+		if err := s.checkContentSize(*patch.Content); err != nil {
+			return domain.Snippet{}, err
+		}
+	}
+	if patch.Tags != nil {
+		tags, err := s.normalizeTags(*patch.Tags)
+		if err != nil {
+			return domain.Snippet{}, err
+		}
+		patch.Tags = &tags
+	}
+	existing, err := s.findForUpdate(ctx, id, patch.ExpiresIn != nil)
+	if err != nil {
+		return domain.Snippet{}, err
+	}
+	content, expiresAt, tags := existing.Content, existing.ExpiresAt, existing.Tags
+	if patch.Content != nil {
+		content = *patch.Content
+	}
+	if patch.ExpiresIn != nil {
+		expiresAt = s.expiryFrom(*patch.ExpiresIn)
+	}
+	if patch.Tags != nil {
+		tags = *patch.Tags
+	}
+	return s.saveUpdate(ctx, existing, content, expiresAt, tags, opts...)
+}
+
+// findForUpdate loads the snippet an update applies to, from the primary database so the new
+// version builds on the latest one. Expired snippets are rejected unless reviving is enabled and
+// the update sets a new expiry (canRevive).
+func (s *Service) findForUpdate(ctx context.Context, id string, canRevive bool) (domain.Snippet, error) {
+	existing, err := s.repo.FindByID(ctxutil.WithReadPrimary(ctx), id)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.Snippet{}, fmt.Errorf("%w", ErrSnippetNotFound)
+		}
+		return domain.Snippet{}, fmt.Errorf("find by id: %w", err)
+	}
+	if !existing.VisibleTo(ctxutil.ClientID(ctx)) {
+		return domain.Snippet{}, fmt.Errorf("private: %w", ErrSnippetNotFound)
+	}
+	// Check if snippet is expired; reviving rewrites the expiry
+	revive := s.reviveOnUpdate && canRevive
+	if !revive && !existing.ExpiresAt.IsZero() && s.clock.Now().After(existing.ExpiresAt) {
 		return domain.Snippet{}, fmt.Errorf("cannot update expired snippet: %w", ErrSnippetExpired)
 	}
+	return existing, nil
+}
 
-	now := s.clock.Now()
-	var expiresAt time.Time
-	if expiresIn > 0 {
-		expiresAt = now.Add(time.Duration(expiresIn) * time.Second)
-	} else {
-		expiresAt = time.Time{} // zero value, means no expiry
+// expiryFrom converts expires_in seconds to an absolute expiry; 0 means no expiry.
+func (s *Service) expiryFrom(expiresIn int) time.Time {
+	return s.expiryAfter(s.clock.Now(), expiresIn)
+}
+
+// expiryAfter returns the expiry expiresIn seconds after now, jittered and rounded as configured.
+func (s *Service) expiryAfter(now time.Time, expiresIn int) time.Time {
+	if expiresIn <= 0 {
+		return time.Time{} // zero value, means no expiry
 	}
+	expiresAt := now.Add(s.jitterTTL(time.Duration(expiresIn) * time.Second))
+	if s.expiryRounding > 0 {
+		if rounded := expiresAt.Truncate(s.expiryRounding); rounded.Before(expiresAt) {
+			expiresAt = rounded.Add(s.expiryRounding)
+		}
+	}
+	return expiresAt
+}
 
+// jitterTTL perturbs ttl uniformly within ±expiryJitter of itself, keeping at least a second.
+func (s *Service) jitterTTL(ttl time.Duration) time.Duration {
+	if s.expiryJitter == 0 || s.rand == nil {
+		return ttl
+	}
+	offset := time.Duration(float64(ttl) * s.expiryJitter * (2*s.rand() - 1))
+	return max(ttl+offset, time.Second)
+}
+
+// saveUpdate writes the next version of existing with the given fields, preserving the rest.
+func (s *Service) saveUpdate(ctx context.Context, existing domain.Snippet, content string, expiresAt time.Time, tags []string, opts ...SnippetOption) (domain.Snippet, error) {
 	updatedSnippet := domain.Snippet{
-		ID:        id,
-		Content:   content,
-		Tags:      tags,
-		CreatedAt: existing.CreatedAt, // preserve original creation time
-		ExpiresAt: expiresAt,
+		ID:          existing.ID,
+		Content:     content,
+		Tags:        tags,
+		CreatedAt:   existing.CreatedAt, // preserve original creation time
+		ExpiresAt:   expiresAt,
+		OwnerID:     existing.OwnerID,
+		ContentHash: hashContent(content),
+		VisibleFrom: existing.VisibleFrom,
+		Version:     max(existing.Version, 1) + 1,
+		Language:    existing.Language,
+		// preserve the cache TTL hint unless the caller sets a new one
+		CacheTTLSeconds: existing.CacheTTLSeconds,
+		Templated:       existing.Templated,
+		Source:          existing.Source,
+		Views:           existing.Views,
+		MaxViews:        existing.MaxViews,
+		Visibility:      existing.Visibility,
+		Title:           existing.Title,
+	}
+	for _, opt := range opts {
+		opt(&updatedSnippet)
 	}
+	if updatedSnippet.Visibility == domain.VisibilityPrivate && updatedSnippet.OwnerID == "" {
+		return domain.Snippet{}, ErrPrivateRequiresOwner
+	}
+	s.fillLanguage(&updatedSnippet)
 
 	if err := s.repo.Update(ctx, updatedSnippet); err != nil {
 		if errors.Is(err, repository.ErrNotFound) {
@@ -172,3 +1088,87 @@ func (s *Service) UpdateSnippet(ctx context.Context, id string, content string,
 
 	return updatedSnippet, nil
 }
+
+// SnippetDiff describes the changes between two versions of a snippet.
+type SnippetDiff struct {
+	From, To    domain.SnippetVersion
+	UnifiedDiff string
+	TagsAdded   []string
+	TagsRemoved []string
+}
+
+// DiffVersions returns a unified content diff plus tag and expiry changes between two versions.
+func (s *Service) DiffVersions(ctx context.Context, id string, from, to int) (SnippetDiff, error) {
+	// Versions do not record visibility or deletion, so check both on the snippet itself and only
+	// read versions once it was found and is visible to the caller. A soft-deleted snippet is only
+	// found when ctx includes deleted ones, and its history goes with it
+	snippet, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return SnippetDiff{}, fmt.Errorf("snippet: %w", ErrVersionNotFound)
+	}
+	if err != nil {
+		return SnippetDiff{}, fmt.Errorf("find by id: %w", err)
+	}
+	if !snippet.VisibleTo(ctxutil.ClientID(ctx)) {
+		return SnippetDiff{}, fmt.Errorf("private: %w", ErrVersionNotFound)
+	}
+	// Same expiry and schedule rules as GetSnippetByID, so history never outlives the snippet
+	now := s.clock.Now()
+	if !snippet.ExpiresAt.IsZero() && now.After(snippet.ExpiresAt.Add(s.readGrace)) {
+		return SnippetDiff{}, fmt.Errorf("expired: %w", ErrSnippetExpired)
+	}
+	if !snippet.IsVisibleAt(now) {
+		return SnippetDiff{}, fmt.Errorf("scheduled: %w", ErrSnippetNotYetAvailable)
+	}
+	fromV, err := s.findVersion(ctx, id, from)
+	if err != nil {
+		return SnippetDiff{}, err
+	}
+	toV, err := s.findVersion(ctx, id, to)
+	if err != nil {
+		return SnippetDiff{}, err
+	}
+	unified, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromV.Content),
+		B:        difflib.SplitLines(toV.Content),
+		FromFile: fmt.Sprintf("v%d", from),
+		ToFile:   fmt.Sprintf("v%d", to),
+		Context:  3,
+	})
+	if err != nil {
+		return SnippetDiff{}, fmt.Errorf("diff: %w", err)
+	}
+	return SnippetDiff{
+		From:        fromV,
+		To:          toV,
+		UnifiedDiff: unified,
+		TagsAdded:   tagsMissingFrom(toV.Tags, fromV.Tags),
+		TagsRemoved: tagsMissingFrom(fromV.Tags, toV.Tags),
+	}, nil
+}
+
+func (s *Service) findVersion(ctx context.Context, id string, version int) (domain.SnippetVersion, error) {
+	v, err := s.repo.FindVersion(ctx, id, version)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return domain.SnippetVersion{}, fmt.Errorf("version %d: %w", version, ErrVersionNotFound)
+		}
+		return domain.SnippetVersion{}, fmt.Errorf("find version: %w", err)
+	}
+	return v, nil
+}
+
+// tagsMissingFrom returns the tags in a that are not in b, preserving order.
+func tagsMissingFrom(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, t := range b {
+		seen[t] = true
+	}
+	var out []string
+	for _, t := range a {
+		if !seen[t] {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -1,16 +1,22 @@
 package service
 
 import (
+	"bytes"
 	"context"
+	cryptorand "crypto/rand"
 	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/roguepikachu/bonsai/internal/domain"
 	"github.com/roguepikachu/bonsai/internal/repository"
+	"github.com/roguepikachu/bonsai/internal/repository/fake"
+	ctxutil "github.com/roguepikachu/bonsai/internal/utils"
 )
 
 const (
@@ -26,6 +32,8 @@ type fakeRepo struct {
 	inserted     []domain.Snippet
 	findByID     map[string]domain.Snippet
 	listSnippets []domain.Snippet
+	tags         []repository.TagCount
+	count        int // overrides len(listSnippets) in Count when set
 	listArgs     struct {
 		page, limit int
 		tag         string
@@ -66,7 +74,23 @@ func (f *fakeRepo) FindByID(_ context.Context, id string) (domain.Snippet, error
 	return domain.Snippet{}, repository.ErrNotFound
 }
 
-func (f *fakeRepo) List(_ context.Context, page, limit int, tag string) ([]domain.Snippet, error) {
+func (f *fakeRepo) FindByIDs(_ context.Context, ids []string) ([]domain.Snippet, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	f.findCall++
+	if f.findErr != nil {
+		return nil, f.findErr
+	}
+	var found []domain.Snippet
+	for _, id := range ids {
+		if s, ok := f.findByID[id]; ok {
+			found = append(found, s)
+		}
+	}
+	return found, nil
+}
+
+func (f *fakeRepo) List(_ context.Context, page, limit int, tag string, _ ...repository.ListOption) ([]domain.Snippet, error) {
 	f.mu.RLock()
 	defer f.mu.RUnlock()
 	f.listCall++
@@ -77,6 +101,96 @@ func (f *fakeRepo) List(_ context.Context, page, limit int, tag string) ([]domai
 	return f.listSnippets, nil
 }
 
+func (f *fakeRepo) ListAfter(_ context.Context, _ repository.Cursor, limit int, tag string, _ ...repository.ListOption) ([]domain.Snippet, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	f.listCall++
+	f.listArgs.page, f.listArgs.limit, f.listArgs.tag = 0, limit, tag
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return f.listSnippets, nil
+}
+
+func (f *fakeRepo) Count(_ context.Context, _ string, _ ...repository.ListOption) (int, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.listErr != nil {
+		return 0, f.listErr
+	}
+	if f.count > 0 {
+		return f.count, nil
+	}
+	return len(f.listSnippets), nil
+}
+
+func (f *fakeRepo) FindByContentHash(_ context.Context, hash string) (domain.Snippet, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, s := range f.findByID {
+		if s.ContentHash == hash {
+			return s, nil
+		}
+	}
+	return domain.Snippet{}, repository.ErrNotFound
+}
+
+func (f *fakeRepo) ListTags(_ context.Context) ([]repository.TagCount, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return f.tags, nil
+}
+
+func (f *fakeRepo) AddViews(_ context.Context, _ map[string]int64) error { return nil }
+
+func (f *fakeRepo) DeleteExpired(_ context.Context) (int64, error) {
+	return 0, nil
+}
+
+func (f *fakeRepo) SoftDelete(_ context.Context, id string, at time.Time) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	existing, ok := f.findByID[id]
+	if !ok || !existing.DeletedAt.IsZero() {
+		return repository.ErrNotFound
+	}
+	existing.DeletedAt = at
+	f.findByID[id] = existing
+	return nil
+}
+
+func (f *fakeRepo) Restore(_ context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	existing, ok := f.findByID[id]
+	if !ok || existing.DeletedAt.IsZero() {
+		return repository.ErrNotFound
+	}
+	existing.DeletedAt = time.Time{}
+	f.findByID[id] = existing
+	return nil
+}
+
+func (f *fakeRepo) TagExists(_ context.Context, tag string) (bool, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for _, s := range f.findByID {
+		for _, t := range s.Tags {
+			if t == tag {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (f *fakeRepo) FindVersion(_ context.Context, _ string, _ int) (domain.SnippetVersion, error) {
+	return domain.SnippetVersion{}, repository.ErrNotFound
+}
+
 func (f *fakeRepo) Update(_ context.Context, s domain.Snippet) error {
 	f.mu.Lock()
 	defer f.mu.Unlock()
@@ -90,6 +204,33 @@ func (f *fakeRepo) Update(_ context.Context, s domain.Snippet) error {
 	return nil
 }
 
+func TestCreateSnippet_LanguageDetection(t *testing.T) {
+	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	goSrc := "package main\n\nfunc main() {}\n"
+	tests := []struct {
+		name    string
+		enabled bool
+		opts    []SnippetOption
+		want    string
+	}{
+		{"detects when unset", true, nil, "go"},
+		{"keeps explicit language", true, []SnippetOption{WithLanguage("text")}, "text"},
+		{"disabled", false, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServiceWithOptions(&fakeRepo{}, stubClock{t: fixed}, WithLanguageDetection(tt.enabled))
+			got, err := s.CreateSnippet(context.Background(), goSrc, 0, nil, tt.opts...)
+			if err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if got.Language != tt.want {
+				t.Fatalf("want language %q, got %q", tt.want, got.Language)
+			}
+		})
+	}
+}
+
 func TestCreateSnippet_NoExpiry(t *testing.T) {
 	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
 	repo := &fakeRepo{}
@@ -167,6 +308,40 @@ func TestGetSnippetByID_Expired(t *testing.T) {
 	}
 }
 
+func TestGetSnippetByID_ReadGrace(t *testing.T) {
+	expires := time.Date(2025, 8, 31, 11, 0, 0, 0, time.UTC)
+	grace := 10 * time.Minute
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{
+		"x": {ID: "x", Content: "still here", CreatedAt: expires.Add(-time.Hour), ExpiresAt: expires},
+	}}
+	tests := []struct {
+		name        string
+		now         time.Time
+		wantErr     error
+		wantExpired bool
+	}{
+		{"before expiry", expires.Add(-time.Second), nil, false},
+		{"just expired", expires.Add(time.Second), nil, true},
+		{"end of grace", expires.Add(grace), nil, true},
+		{"past grace", expires.Add(grace + time.Nanosecond), ErrSnippetExpired, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServiceWithOptions(repo, stubClock{t: tt.now}, WithReadGrace(grace))
+			got, meta, err := s.GetSnippetByID(context.Background(), "x")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("want error %v, got %v", tt.wantErr, err)
+			}
+			if meta.Expired != tt.wantExpired {
+				t.Fatalf("want expired %v, got %v", tt.wantExpired, meta.Expired)
+			}
+			if tt.wantErr == nil && got.Content != "still here" {
+				t.Fatalf("want content within the grace window, got %q", got.Content)
+			}
+		})
+	}
+}
+
 func TestListSnippets_PassesParams(t *testing.T) {
 	repo := &fakeRepo{}
 	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
@@ -252,6 +427,28 @@ func TestCreateSnippet_RepositoryError(t *testing.T) {
 	}
 }
 
+func TestCreateSnippet_ClientID(t *testing.T) {
+	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	generated := 0
+	gen := func() string { generated++; return "generated" }
+
+	repo := &fakeRepo{}
+	s := NewServiceWithOptions(repo, stubClock{t: fixed}, WithIDGenerator(gen))
+	got, err := s.CreateSnippet(context.Background(), "content", 0, nil, WithID("my-id"))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got.ID != "my-id" || generated != 0 {
+		t.Fatalf("want supplied id and no generation, got %q after %d generations", got.ID, generated)
+	}
+
+	repo = &fakeRepo{insertErr: fmt.Errorf("insert: %w", repository.ErrDuplicateID)}
+	s = NewServiceWithOptions(repo, stubClock{t: fixed}, WithIDGenerator(gen))
+	if _, err := s.CreateSnippet(context.Background(), "content", 0, nil, WithID("my-id")); !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("want ErrDuplicateID, got %v", err)
+	}
+}
+
 func TestCreateSnippet_NegativeExpiry(t *testing.T) {
 	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
 	repo := &fakeRepo{}
@@ -410,6 +607,62 @@ func TestGetSnippetByID_JustAfterExpiry(t *testing.T) {
 	}
 }
 
+func TestCountSnippets_MatchesList(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }))
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+	for _, c := range []struct {
+		expiresIn int
+		tags      []string
+	}{{0, []string{"go"}}, {60, []string{"go"}}, {0, nil}, {3600, []string{"web"}}} {
+		if _, err := s.CreateSnippet(ctx, "x", c.expiresIn, c.tags); err != nil {
+			t.Fatalf("create: %v", err)
+		}
+	}
+	listed := func(tag string) int {
+		var n int
+		for page := 1; ; page++ {
+			items, err := s.ListSnippets(ctx, page, 2, tag)
+			if err != nil {
+				t.Fatalf("list: %v", err)
+			}
+			if len(items) == 0 {
+				return n
+			}
+			n += len(items)
+		}
+	}
+	check := func(tag string, want int) {
+		t.Helper()
+		n, err := s.CountSnippets(ctx, tag)
+		if err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		if n != want || n != listed(tag) {
+			t.Fatalf("tag %q: want count %d matching list %d, got %d", tag, want, listed(tag), n)
+		}
+	}
+	check("", 4)
+	check("go", 2)
+
+	// The 60s snippet expires
+	now = now.Add(2 * time.Minute)
+	check("", 3)
+	check("go", 1)
+
+	created, err := s.CreateSnippet(ctx, "y", 0, []string{"go"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	check("go", 2)
+	if err := s.DeleteSnippet(ctx, created.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	check("go", 1)
+	check("", 3)
+}
+
 func TestListSnippets_EmptyList(t *testing.T) {
 	repo := &fakeRepo{listSnippets: []domain.Snippet{}}
 	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
@@ -662,6 +915,21 @@ func TestUpdateSnippet_Success(t *testing.T) {
 	}
 }
 
+func TestUpdateSnippet_PreservesViews(t *testing.T) {
+	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	existing := domain.Snippet{ID: "test-id", Content: "original", CreatedAt: fixed.Add(-time.Hour), Views: 4, MaxViews: 10}
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{"test-id": existing}}
+	s := NewServiceWithOptions(repo, stubClock{t: fixed})
+
+	updated, err := s.UpdateSnippet(context.Background(), "test-id", "updated content", 300, nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if updated.Views != 4 || updated.MaxViews != 10 {
+		t.Errorf("expected views and view limit to be preserved: got %d of %d", updated.Views, updated.MaxViews)
+	}
+}
+
 func TestUpdateSnippet_NotFound(t *testing.T) {
 	repo := &fakeRepo{findByID: map[string]domain.Snippet{}}
 	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
@@ -690,6 +958,80 @@ func TestUpdateSnippet_Expired(t *testing.T) {
 	}
 }
 
+func TestUpdateSnippet_ExpiredRevive(t *testing.T) {
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	existing := domain.Snippet{
+		ID:        "expired-id",
+		Content:   "content",
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(-time.Minute),
+	}
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{"expired-id": existing}}
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithReviveOnUpdate(true))
+
+	got, err := s.UpdateSnippet(context.Background(), "expired-id", "revived", 300, nil)
+	if err != nil {
+		t.Fatalf("revive: %v", err)
+	}
+	if want := now.Add(300 * time.Second); !got.ExpiresAt.Equal(want) {
+		t.Fatalf("want expiry reset to %v, got %v", want, got.ExpiresAt)
+	}
+	if repo.findByID["expired-id"].Content != "revived" {
+		t.Fatalf("expected revived snippet to be stored")
+	}
+}
+
+func TestPatchSnippet_KeepsOmittedFields(t *testing.T) {
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	existing := domain.Snippet{
+		ID:        "p1",
+		Content:   "original",
+		Tags:      []string{"go"},
+		CreatedAt: now.Add(-time.Hour),
+		ExpiresAt: now.Add(time.Hour),
+		Version:   1,
+	}
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{"p1": existing}}
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+
+	tags := []string{"web"}
+	got, err := s.PatchSnippet(context.Background(), "p1", SnippetPatch{Tags: &tags})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if got.Content != "original" || !got.ExpiresAt.Equal(existing.ExpiresAt) || got.Version != 2 {
+		t.Fatalf("want content and expiry kept at version 2, got %+v", got)
+	}
+	if len(got.Tags) != 1 || got.Tags[0] != "web" {
+		t.Fatalf("want tags replaced, got %v", got.Tags)
+	}
+
+	content, noExpiry := "changed", 0
+	got, err = s.PatchSnippet(context.Background(), "p1", SnippetPatch{Content: &content, ExpiresIn: &noExpiry})
+	if err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if got.Content != "changed" || !got.ExpiresAt.IsZero() || got.Tags[0] != "web" || got.ContentHash != hashContent("changed") {
+		t.Fatalf("want content changed, expiry cleared and tags kept, got %+v", got)
+	}
+}
+
+func TestPatchSnippet_ExpiredNeedsNewExpiryToRevive(t *testing.T) {
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	existing := domain.Snippet{ID: "e1", Content: "c", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)}
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{"e1": existing}}
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithReviveOnUpdate(true))
+
+	tags := []string{"x"}
+	if _, err := s.PatchSnippet(context.Background(), "e1", SnippetPatch{Tags: &tags}); !errors.Is(err, ErrSnippetExpired) {
+		t.Fatalf("want ErrSnippetExpired without a new expiry, got %v", err)
+	}
+	ttl := 60
+	if _, err := s.PatchSnippet(context.Background(), "e1", SnippetPatch{ExpiresIn: &ttl}); err != nil {
+		t.Fatalf("want revive with a new expiry, got %v", err)
+	}
+}
+
 func TestUpdateSnippet_NoExpiry(t *testing.T) {
 	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
 	existing := domain.Snippet{
@@ -789,6 +1131,101 @@ func TestUpdateSnippet_MaxContentLength(t *testing.T) {
 	}
 }
 
+func TestContentSizeLimit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "a", Content: "short", CreatedAt: now}))
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+
+	// 10240 runes, but three bytes each: within a character limit, over the byte limit
+	multibyte := strings.Repeat("€", DefaultMaxContentBytes)
+	atLimit := strings.Repeat("a", DefaultMaxContentBytes)
+	if _, err := s.CreateSnippet(ctx, atLimit, 0, nil); err != nil {
+		t.Fatalf("create at limit: %v", err)
+	}
+	if _, err := s.CreateSnippet(ctx, multibyte, 0, nil); !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("create: want ErrContentTooLarge for multibyte content, got %v", err)
+	}
+	if _, err := s.UpdateSnippet(ctx, "a", multibyte, 0, nil); !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("update: want ErrContentTooLarge, got %v", err)
+	}
+	if _, err := s.PatchSnippet(ctx, "a", SnippetPatch{Content: &multibyte}); !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("patch: want ErrContentTooLarge, got %v", err)
+	}
+	if _, err := s.ImportSnippet(ctx, ImportRecord{Content: multibyte}); !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("import: want ErrContentTooLarge, got %v", err)
+	}
+	if stored, _ := repo.FindByID(ctx, "a"); stored.Content != "short" {
+		t.Fatalf("rejected updates must not be stored, got %d bytes", len(stored.Content))
+	}
+
+	small := NewServiceWithOptions(repo, stubClock{t: now}, WithMaxContentBytes(4))
+	if _, err := small.CreateSnippet(ctx, "€€", 0, nil); !errors.Is(err, ErrContentTooLarge) {
+		t.Fatalf("configured limit: want ErrContentTooLarge for 6 bytes, got %v", err)
+	}
+	if _, err := small.CreateSnippet(ctx, "€", 0, nil); err != nil {
+		t.Fatalf("configured limit: want 3 bytes accepted, got %v", err)
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "a", Content: "x", CreatedAt: now, Tags: []string{"old"}}))
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+
+	created, err := s.CreateSnippet(ctx, "x", 0, []string{" Go", "go", "GO ", "", "  ", "Web", "go"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if fmt.Sprint(created.Tags) != "[go web]" {
+		t.Fatalf("want trimmed, lowercased, de-duplicated tags in first-seen order, got %q", created.Tags)
+	}
+	if stored, _ := repo.FindByID(ctx, created.ID); fmt.Sprint(stored.Tags) != "[go web]" {
+		t.Fatalf("want normalized tags stored, got %q", stored.Tags)
+	}
+	for _, tags := range [][]string{nil, {}} {
+		got, err := s.CreateSnippet(ctx, "x", 0, tags)
+		if err != nil || len(got.Tags) != 0 {
+			t.Fatalf("want no tags for %#v, got %q, %v", tags, got.Tags, err)
+		}
+	}
+	updated, err := s.UpdateSnippet(ctx, "a", "y", 0, []string{"CLI", " cli"})
+	if err != nil || fmt.Sprint(updated.Tags) != "[cli]" {
+		t.Fatalf("update: got %q, %v", updated.Tags, err)
+	}
+	patchTags := []string{" Rust ", "rust"}
+	patched, err := s.PatchSnippet(ctx, "a", SnippetPatch{Tags: &patchTags})
+	if err != nil || fmt.Sprint(patched.Tags) != "[rust]" {
+		t.Fatalf("patch: got %q, %v", patched.Tags, err)
+	}
+}
+
+func TestNormalizeTags_Limits(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "a", Content: "x", CreatedAt: now}))
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithMaxTags(2), WithMaxTagLength(4))
+
+	// Duplicates and blanks do not count toward the limit
+	if _, err := s.CreateSnippet(ctx, "x", 0, []string{"a", "A", " ", "b"}); err != nil {
+		t.Fatalf("want 2 distinct tags accepted, got %v", err)
+	}
+	if _, err := s.CreateSnippet(ctx, "x", 0, []string{"a", "b", "c"}); !errors.Is(err, ErrTooManyTags) || !errors.Is(err, repository.ErrTooManyTags) {
+		t.Fatalf("want ErrTooManyTags, got %v", err)
+	}
+	// Length is checked after trimming and in characters, not bytes
+	if _, err := s.CreateSnippet(ctx, "x", 0, []string{"  abcd  ", "éééé"}); err != nil {
+		t.Fatalf("want 4-character tags accepted, got %v", err)
+	}
+	if _, err := s.UpdateSnippet(ctx, "a", "x", 0, []string{"abcde"}); !errors.Is(err, ErrTagTooLong) {
+		t.Fatalf("want ErrTagTooLong, got %v", err)
+	}
+	if _, err := s.ImportSnippet(ctx, ImportRecord{Content: "x", Tags: []string{"a", "b", "c"}}); !errors.Is(err, ErrTooManyTags) {
+		t.Fatalf("import: want ErrTooManyTags, got %v", err)
+	}
+}
+
 func TestUpdateSnippet_EmptyContent(t *testing.T) {
 	existing := domain.Snippet{
 		ID:        "empty-content-id",
@@ -1128,3 +1565,1055 @@ func TestUpdateSnippet_UnicodeID(t *testing.T) {
 		t.Error("expected unicode ID preserved")
 	}
 }
+
+func TestCreateSnippet_DuplicateHint(t *testing.T) {
+	fixed := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	ids := []string{"first", "second", "third"}
+	next := 0
+	gen := func() string { id := ids[next]; next++; return id }
+	repo := &fakeRepo{}
+	s := NewServiceWithOptions(repo, stubClock{t: fixed}, WithIDGenerator(gen), WithDuplicateHint(true))
+
+	first, err := s.CreateSnippet(context.Background(), "same", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if first.DuplicateOf != "" {
+		t.Fatalf("want no hint for first snippet, got %q", first.DuplicateOf)
+	}
+	second, err := s.CreateSnippet(context.Background(), "same", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if second.DuplicateOf != "first" {
+		t.Fatalf("want duplicate_of=first, got %q", second.DuplicateOf)
+	}
+	if second.ID != "second" || repo.insertCall != 2 {
+		t.Fatalf("duplicate must still be created: id=%s inserts=%d", second.ID, repo.insertCall)
+	}
+	third, err := s.CreateSnippet(context.Background(), "different", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if third.DuplicateOf != "" {
+		t.Fatalf("want no hint for distinct content, got %q", third.DuplicateOf)
+	}
+}
+
+func TestCreateSnippet_DuplicateHintDisabled(t *testing.T) {
+	repo := &fakeRepo{}
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
+	_, _ = s.CreateSnippet(context.Background(), "same", 0, nil)
+	got, err := s.CreateSnippet(context.Background(), "same", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got.DuplicateOf != "" {
+		t.Fatalf("want no hint when disabled, got %q", got.DuplicateOf)
+	}
+}
+
+func TestWithSeededIDGenerator_Deterministic(t *testing.T) {
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	s1 := NewServiceWithOptions(&fakeRepo{}, stubClock{t: now}, WithSeededIDGenerator(42))
+	s2 := NewServiceWithOptions(&fakeRepo{}, stubClock{t: now}, WithSeededIDGenerator(42))
+	s3 := NewServiceWithOptions(&fakeRepo{}, stubClock{t: now}, WithSeededIDGenerator(7))
+
+	seen := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		a, _ := s1.CreateSnippet(context.Background(), "x", 0, nil)
+		b, _ := s2.CreateSnippet(context.Background(), "x", 0, nil)
+		c, _ := s3.CreateSnippet(context.Background(), "x", 0, nil)
+		if a.ID != b.ID {
+			t.Fatalf("same seed must yield same sequence: %s != %s at %d", a.ID, b.ID, i)
+		}
+		if a.ID == c.ID {
+			t.Fatalf("different seeds should diverge: both %s at %d", a.ID, i)
+		}
+		if len(a.ID) != seededIDLength {
+			t.Fatalf("want id length %d, got %q", seededIDLength, a.ID)
+		}
+		if seen[a.ID] {
+			t.Fatalf("duplicate id %s in sequence", a.ID)
+		}
+		seen[a.ID] = true
+	}
+}
+
+func TestScheduledPublication_VisibleFrom(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	clk := &stubClock{t: start}
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return clk.t }))
+	s := NewServiceWithOptions(repo, clk, WithIDGenerator(func() string { return "sched" }))
+
+	publishAt := start.Add(time.Hour)
+	if _, err := s.CreateSnippet(ctx, "announcement", 0, nil, WithVisibleFrom(publishAt)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// Before visible_from: hidden from Get and List
+	if _, _, err := s.GetSnippetByID(ctx, "sched"); !errors.Is(err, ErrSnippetNotYetAvailable) {
+		t.Fatalf("want ErrSnippetNotYetAvailable, got %v", err)
+	}
+	items, err := s.ListSnippets(ctx, 1, 10, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("want scheduled snippet excluded from list, got %d items", len(items))
+	}
+
+	// At visible_from: visible everywhere
+	clk.t = publishAt
+	got, _, err := s.GetSnippetByID(ctx, "sched")
+	if err != nil {
+		t.Fatalf("get after publish: %v", err)
+	}
+	if !got.VisibleFrom.Equal(publishAt) {
+		t.Fatalf("visible_from mismatch: %v", got.VisibleFrom)
+	}
+	items, _ = s.ListSnippets(ctx, 1, 10, "")
+	if len(items) != 1 {
+		t.Fatalf("want 1 item after publish, got %d", len(items))
+	}
+
+	// Update without a new schedule keeps it
+	updated, err := s.UpdateSnippet(ctx, "sched", "edited", 0, nil)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !updated.VisibleFrom.Equal(publishAt) {
+		t.Fatalf("update should preserve visible_from, got %v", updated.VisibleFrom)
+	}
+}
+
+// hitRepo reports every read as served from cache.
+type hitRepo struct{ fakeRepo }
+
+func (h *hitRepo) FindByIDCached(ctx context.Context, id string) (domain.Snippet, bool, error) {
+	s, err := h.FindByID(ctx, id)
+	return s, err == nil, err
+}
+
+func TestGetSnippetByID_ReportsCacheHit(t *testing.T) {
+	repo := &hitRepo{fakeRepo{findByID: map[string]domain.Snippet{"x": {ID: "x"}}}}
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
+	_, meta, err := s.GetSnippetByID(context.Background(), "x")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if meta.CacheStatus != CacheHit {
+		t.Fatalf("want HIT from cache-aware repository, got %s", meta.CacheStatus)
+	}
+}
+
+// blockingRepo blocks FindByID until release is closed and counts calls.
+type blockingRepo struct {
+	fakeRepo
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (b *blockingRepo) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	b.calls.Add(1)
+	<-b.release
+	return b.fakeRepo.FindByID(ctx, id)
+}
+
+func TestGetSnippetByID_CoalescesConcurrentReads(t *testing.T) {
+	repo := &blockingRepo{
+		fakeRepo: fakeRepo{findByID: map[string]domain.Snippet{"hot": {ID: "hot", Content: "x"}}},
+		release:  make(chan struct{}),
+	}
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()}, WithGetCoalescing(true))
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, _, err := s.GetSnippetByID(context.Background(), "hot")
+			if err == nil && got.ID != "hot" {
+				err = fmt.Errorf("unexpected snippet %q", got.ID)
+			}
+			errs <- err
+		}()
+	}
+	// give every goroutine time to join the in-flight read before releasing it
+	time.Sleep(50 * time.Millisecond)
+	close(repo.release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+	}
+	if got := repo.calls.Load(); got != 1 {
+		t.Fatalf("want FindByID invoked once, got %d", got)
+	}
+}
+
+func TestListSnippets_StrictTag(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }), fake.WithItems(
+		domain.Snippet{ID: "old", Tags: []string{"legacy"}, CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)},
+	))
+
+	strict := NewServiceWithOptions(repo, stubClock{t: now}, WithStrictTag(true))
+	if _, err := strict.ListSnippets(ctx, 1, 10, "never-used"); !errors.Is(err, ErrUnknownTag) {
+		t.Fatalf("want ErrUnknownTag for never-used tag, got %v", err)
+	}
+	items, err := strict.ListSnippets(ctx, 1, 10, "legacy")
+	if err != nil {
+		t.Fatalf("used-but-empty tag should not error, got %v", err)
+	}
+	if len(items) != 0 {
+		t.Fatalf("want no active items, got %d", len(items))
+	}
+
+	lenient := NewServiceWithOptions(repo, stubClock{t: now})
+	if _, err := lenient.ListSnippets(ctx, 1, 10, "never-used"); err != nil {
+		t.Fatalf("default mode should return empty list, got %v", err)
+	}
+}
+
+func TestDiffVersions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }))
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { return "v" }))
+
+	if _, err := s.CreateSnippet(ctx, "line one\nold line\n", 0, []string{"a", "b"}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := s.UpdateSnippet(ctx, "v", "line one\nmiddle line\n", 0, []string{"a"}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	updated, err := s.UpdateSnippet(ctx, "v", "line one\nnew line\n", 0, []string{"a", "c"})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Version != 3 {
+		t.Fatalf("want version 3, got %d", updated.Version)
+	}
+
+	diff, err := s.DiffVersions(ctx, "v", 1, 3)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if !strings.Contains(diff.UnifiedDiff, "-old line") || !strings.Contains(diff.UnifiedDiff, "+new line") {
+		t.Fatalf("unexpected diff:\n%s", diff.UnifiedDiff)
+	}
+	if len(diff.TagsAdded) != 1 || diff.TagsAdded[0] != "c" {
+		t.Fatalf("want tags added [c], got %v", diff.TagsAdded)
+	}
+	if len(diff.TagsRemoved) != 1 || diff.TagsRemoved[0] != "b" {
+		t.Fatalf("want tags removed [b], got %v", diff.TagsRemoved)
+	}
+
+	if _, err := s.DiffVersions(ctx, "v", 1, 9); !errors.Is(err, ErrVersionNotFound) {
+		t.Fatalf("want ErrVersionNotFound, got %v", err)
+	}
+}
+
+func TestDiffVersions_SoftDeleted(t *testing.T) {
+	now := time.Now()
+	repo := fake.NewSnippetRepository()
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { return "d" }))
+	alice := ctxutil.WithClientID(context.Background(), "alice")
+	bob := ctxutil.WithClientID(context.Background(), "bob")
+
+	if _, err := s.CreateSnippet(alice, "secret v1", 0, nil, WithVisibility(domain.VisibilityPrivate)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := s.UpdateSnippet(alice, "d", "secret v2", 0, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := s.DeleteSnippet(alice, "d"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for name, ctx := range map[string]context.Context{"owner": alice, "other client": bob} {
+		diff, err := s.DiffVersions(ctx, "d", 1, 2)
+		if !errors.Is(err, ErrVersionNotFound) {
+			t.Fatalf("%s: want ErrVersionNotFound for a deleted snippet, got %v", name, err)
+		}
+		if diff.UnifiedDiff != "" {
+			t.Fatalf("%s: deleted content leaked: %s", name, diff.UnifiedDiff)
+		}
+	}
+	if _, err := s.DiffVersions(ctxutil.WithIncludeDeleted(alice), "d", 1, 2); err != nil {
+		t.Fatalf("want the history when deleted snippets are included, got %v", err)
+	}
+}
+
+// orphanedVersionsRepo has version rows for snippets it cannot find, or fails the lookup.
+type orphanedVersionsRepo struct {
+	*fake.SnippetRepository
+	findErr error
+}
+
+func (r orphanedVersionsRepo) FindByID(context.Context, string) (domain.Snippet, error) {
+	return domain.Snippet{}, r.findErr
+}
+
+func TestDiffVersions_RequiresVisibleSnippet(t *testing.T) {
+	now := time.Now()
+	repo := fake.NewSnippetRepository()
+	setup := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { return "o" }))
+	alice := ctxutil.WithClientID(context.Background(), "alice")
+	if _, err := setup.CreateSnippet(alice, "secret v1", 0, nil, WithVisibility(domain.VisibilityPrivate)); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := setup.UpdateSnippet(alice, "o", "secret v2", 0, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// Version rows remain but the snippet itself is missing: nothing is served, even to the owner
+	s := NewServiceWithOptions(orphanedVersionsRepo{SnippetRepository: repo, findErr: repository.ErrNotFound}, stubClock{t: now})
+	if diff, err := s.DiffVersions(alice, "o", 1, 2); !errors.Is(err, ErrVersionNotFound) || diff.UnifiedDiff != "" {
+		t.Fatalf("missing snippet: want ErrVersionNotFound and no diff, got %q, %v", diff.UnifiedDiff, err)
+	}
+	// A failed lookup is an error, never a reason to skip the owner check
+	s = NewServiceWithOptions(orphanedVersionsRepo{SnippetRepository: repo, findErr: errors.New("db down")}, stubClock{t: now})
+	if diff, err := s.DiffVersions(alice, "o", 1, 2); err == nil || diff.UnifiedDiff != "" {
+		t.Fatalf("failed lookup: want an error and no diff, got %q, %v", diff.UnifiedDiff, err)
+	}
+}
+
+func TestDiffVersions_ExpiryAndSchedule(t *testing.T) {
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }))
+	at := func(t time.Time) *Service {
+		return NewServiceWithOptions(repo, stubClock{t: t}, WithReadGrace(time.Minute))
+	}
+	s := at(now)
+	ctx := context.Background()
+
+	expiring, err := s.CreateSnippet(ctx, "v1", 60, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := s.UpdateSnippet(ctx, expiring.ID, "v2", 60, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	scheduled, err := s.CreateSnippet(ctx, "draft", 0, nil, WithVisibleFrom(now.Add(time.Hour)))
+	if err != nil {
+		t.Fatalf("create scheduled: %v", err)
+	}
+
+	if _, err := s.DiffVersions(ctx, scheduled.ID, 1, 1); !errors.Is(err, ErrSnippetNotYetAvailable) {
+		t.Fatalf("scheduled: want ErrSnippetNotYetAvailable, got %v", err)
+	}
+	// Within the read grace the history is served like the snippet itself
+	if _, err := at(now.Add(90*time.Second)).DiffVersions(ctx, expiring.ID, 1, 2); err != nil {
+		t.Fatalf("within the read grace: %v", err)
+	}
+	if diff, err := at(now.Add(3*time.Minute)).DiffVersions(ctx, expiring.ID, 1, 2); !errors.Is(err, ErrSnippetExpired) || diff.UnifiedDiff != "" {
+		t.Fatalf("expired: want ErrSnippetExpired and no diff, got %q, %v", diff.UnifiedDiff, err)
+	}
+}
+
+func TestImportSnippet_InvertedExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	created := now.Add(-48 * time.Hour)
+	inverted := ImportRecord{Content: "imported", CreatedAt: created, ExpiresAt: created.Add(-time.Hour)}
+
+	t.Run("reject by default", func(t *testing.T) {
+		repo := fake.NewSnippetRepository()
+		s := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { return "imp" }))
+		if _, err := s.ImportSnippet(ctx, inverted); !errors.Is(err, ErrInvalidExpiry) {
+			t.Fatalf("want ErrInvalidExpiry, got %v", err)
+		}
+		if _, err := repo.FindByID(ctx, "imp"); !errors.Is(err, repository.ErrNotFound) {
+			t.Fatalf("rejected record must not be stored, got %v", err)
+		}
+	})
+
+	t.Run("auto-correct drops expiry", func(t *testing.T) {
+		repo := fake.NewSnippetRepository()
+		s := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { return "imp" }), WithImportDropInvalidExpiry(true))
+		got, err := s.ImportSnippet(ctx, inverted)
+		if err != nil {
+			t.Fatalf("import: %v", err)
+		}
+		if !got.ExpiresAt.IsZero() || !got.CreatedAt.Equal(created) {
+			t.Fatalf("want original created_at and no expiry, got created=%v expires=%v", got.CreatedAt, got.ExpiresAt)
+		}
+		if _, _, err := s.GetSnippetByID(ctx, "imp"); err != nil {
+			t.Fatalf("corrected snippet should be readable, got %v", err)
+		}
+	})
+}
+
+func TestGetSnippetByIDRaw_IgnoresExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }), fake.WithItems(
+		domain.Snippet{ID: "old", Content: "archived", CreatedAt: now.Add(-time.Hour), ExpiresAt: now.Add(-time.Minute)},
+	))
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+
+	if _, _, err := s.GetSnippetByID(ctx, "old"); !errors.Is(err, ErrSnippetExpired) {
+		t.Fatalf("want ErrSnippetExpired from GetSnippetByID, got %v", err)
+	}
+	got, err := s.GetSnippetByIDRaw(ctx, "old")
+	if err != nil {
+		t.Fatalf("raw: %v", err)
+	}
+	if got.Content != "archived" {
+		t.Fatalf("want expired snippet content, got %q", got.Content)
+	}
+	if _, err := s.GetSnippetByIDRaw(ctx, "missing"); !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("want ErrSnippetNotFound, got %v", err)
+	}
+}
+
+func TestCreateSnippet_IDCollisionCheck(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name          string
+		opts          []Option
+		wantFindCalls int
+	}{
+		{"uuid skips by default", nil, 0},
+		{"short ids check by default", []Option{WithSeededIDGenerator(1)}, 1},
+		{"skip configured for short ids", []Option{WithSeededIDGenerator(1), WithIDCollisionCheck(false)}, 0},
+		{"forced for uuid", []Option{WithIDCollisionCheck(true)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeRepo{}
+			s := NewServiceWithOptions(repo, stubClock{t: now}, tt.opts...)
+			if _, err := s.CreateSnippet(ctx, "x", 0, nil); err != nil {
+				t.Fatalf("create: %v", err)
+			}
+			if repo.findCall != tt.wantFindCalls {
+				t.Fatalf("want %d pre-insert existence queries, got %d", tt.wantFindCalls, repo.findCall)
+			}
+		})
+	}
+}
+
+func TestCreateSnippet_IDCollisionRegenerates(t *testing.T) {
+	ctx := context.Background()
+	ids := []string{"taken", "taken", "fresh"}
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{"taken": {ID: "taken"}}}
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()}, WithIDGenerator(func() string {
+		id := ids[0]
+		ids = ids[1:]
+		return id
+	}))
+	got, err := s.CreateSnippet(ctx, "x", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got.ID != "fresh" {
+		t.Fatalf("want regenerated id fresh, got %s", got.ID)
+	}
+
+	always := NewServiceWithOptions(repo, stubClock{t: time.Now()}, WithIDGenerator(func() string { return "taken" }))
+	if _, err := always.CreateSnippet(ctx, "x", 0, nil); !errors.Is(err, ErrIDCollision) {
+		t.Fatalf("want ErrIDCollision, got %v", err)
+	}
+}
+
+func TestShortIDGenerator(t *testing.T) {
+	s := NewServiceWithOptions(&fakeRepo{}, stubClock{t: time.Now()}, WithShortIDs(0))
+	if s.IDLength() != DefaultShortIDLength {
+		t.Fatalf("want id length %d, got %d", DefaultShortIDLength, s.IDLength())
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 200; i++ {
+		got, err := s.CreateSnippet(context.Background(), "x", 0, nil)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		if len(got.ID) != DefaultShortIDLength {
+			t.Fatalf("want %d characters, got %q", DefaultShortIDLength, got.ID)
+		}
+		if strings.Trim(got.ID, idAlphabet) != "" {
+			t.Fatalf("want only base62 characters, got %q", got.ID)
+		}
+		if seen[got.ID] {
+			t.Fatalf("duplicate id %q", got.ID)
+		}
+		seen[got.ID] = true
+	}
+	if id := shortIDGenerator(8, cryptorand.Reader)(); len(id) != 8 {
+		t.Fatalf("want 8 characters, got %q", id)
+	}
+
+	// Bytes of 248 and above are skipped rather than wrapped around the alphabet
+	gen := shortIDGenerator(4, bytes.NewReader([]byte{61, 248, 255, 62, 0, 123, 9, 9}))
+	if id := gen(); id != "Z00Z" {
+		t.Fatalf("want Z00Z, got %q", id)
+	}
+}
+
+func TestShortIDGenerator_RegeneratesCollision(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "00000000", Content: "taken", CreatedAt: now}))
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithShortIDs(8))
+	s.idGen = shortIDGenerator(8, bytes.NewReader(append(make([]byte, 8), bytes.Repeat([]byte{1}, 8)...)))
+
+	got, err := s.CreateSnippet(ctx, "x", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if got.ID != "11111111" {
+		t.Fatalf("want the colliding id regenerated as 11111111, got %q", got.ID)
+	}
+	if existing, _ := repo.FindByID(ctx, "00000000"); existing.Content != "taken" {
+		t.Fatalf("want the existing snippet untouched, got %+v", existing)
+	}
+}
+
+func TestSnippetSource_StampedPerPath(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }))
+	n := 0
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithIDGenerator(func() string { n++; return fmt.Sprintf("s%d", n) }))
+
+	created, err := s.CreateSnippet(ctx, "via api", 0, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	forked, err := s.CreateSnippet(ctx, "via fork", 0, nil, WithSource(domain.SourceFork))
+	if err != nil {
+		t.Fatalf("create fork: %v", err)
+	}
+	imported, err := s.ImportSnippet(ctx, ImportRecord{Content: "via import"})
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	for got, want := range map[string]string{created.ID: domain.SourceAPI, forked.ID: domain.SourceFork, imported.ID: domain.SourceImport} {
+		stored, err := repo.FindByID(ctx, got)
+		if err != nil {
+			t.Fatalf("find %s: %v", got, err)
+		}
+		if stored.Source != want {
+			t.Fatalf("%s: want source %q, got %q", got, want, stored.Source)
+		}
+	}
+
+	updated, err := s.UpdateSnippet(ctx, imported.ID, "edited", 0, nil)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.Source != domain.SourceImport {
+		t.Fatalf("update must keep source, got %q", updated.Source)
+	}
+
+	items, err := s.ListSnippets(ctx, 1, 10, "", repository.WithSource(domain.SourceImport))
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 1 || items[0].ID != imported.ID {
+		t.Fatalf("want only the imported snippet, got %v", items)
+	}
+}
+
+func TestTagFacets_Window(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }), fake.WithItems(
+		domain.Snippet{ID: "1", CreatedAt: now.Add(-1 * time.Minute), Tags: []string{"go", "web"}},
+		domain.Snippet{ID: "2", CreatedAt: now.Add(-2 * time.Minute), Tags: []string{"go"}},
+		domain.Snippet{ID: "3", CreatedAt: now.Add(-3 * time.Minute), Tags: []string{"web"}},
+		domain.Snippet{ID: "4", CreatedAt: now.Add(-4 * time.Minute), Tags: []string{"python"}},
+		domain.Snippet{ID: "5", CreatedAt: now.Add(-5 * time.Minute), Tags: []string{"python"}},
+	))
+
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithFacetWindow(3))
+	got, err := s.TagFacets(ctx, "")
+	if err != nil {
+		t.Fatalf("facets: %v", err)
+	}
+	// Only the three most recent snippets are counted
+	if got.Scanned != 3 || !got.Approximate {
+		t.Fatalf("want 3 scanned and approximate, got %+v", got)
+	}
+	if fmt.Sprint(got.Tags) != "map[go:2 web:2]" {
+		t.Fatalf("want go:2 web:2, got %v", got.Tags)
+	}
+
+	s = NewServiceWithOptions(repo, stubClock{t: now}, WithFacetWindow(5))
+	got, err = s.TagFacets(ctx, "")
+	if err != nil {
+		t.Fatalf("facets: %v", err)
+	}
+	if got.Scanned != 5 || got.Approximate || got.Tags["python"] != 2 {
+		t.Fatalf("want exact counts over all 5, got %+v", got)
+	}
+}
+
+func TestSnippetMetadataByTag(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 1, 9, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }), fake.WithItems(
+		domain.Snippet{ID: "1", Content: "a", CreatedAt: now.Add(-1 * time.Minute), Tags: []string{"go"}},
+		domain.Snippet{ID: "2", Content: "b", CreatedAt: now.Add(-2 * time.Minute), Tags: []string{"go", "web"}},
+		domain.Snippet{ID: "3", Content: "c", CreatedAt: now.Add(-3 * time.Minute), Tags: []string{"go"}},
+		domain.Snippet{ID: "4", Content: "d", CreatedAt: now.Add(-4 * time.Minute), Tags: []string{"web"}},
+		domain.Snippet{ID: "5", Content: "e", CreatedAt: now.Add(-5 * time.Minute), ExpiresAt: now.Add(-time.Second), Tags: []string{"go"}},
+		domain.Snippet{ID: "6", Content: "f", CreatedAt: now.Add(-6 * time.Minute), Tags: []string{"go"}, OwnerID: "c1", Visibility: domain.VisibilityPrivate},
+	))
+
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithMetadataMaxItems(2))
+	items, truncated, err := s.SnippetMetadataByTag(ctx, "go")
+	if err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	// The two most recent of three active public go snippets
+	if len(items) != 2 || items[0].ID != "1" || items[1].ID != "2" || !truncated {
+		t.Fatalf("want 1, 2 and truncated, got %+v truncated=%v", items, truncated)
+	}
+	for _, it := range items {
+		if it.Content != "" {
+			t.Fatalf("want no content, got %q for %s", it.Content, it.ID)
+		}
+	}
+
+	s = NewServiceWithOptions(repo, stubClock{t: now}, WithMetadataMaxItems(3))
+	items, truncated, err = s.SnippetMetadataByTag(ctx, "go")
+	if err != nil {
+		t.Fatalf("metadata: %v", err)
+	}
+	if len(items) != 3 || truncated {
+		t.Fatalf("want all 3 without truncation, got %d truncated=%v", len(items), truncated)
+	}
+}
+
+func TestCreateSnippet_ExpiryJitter(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 2, 8, 0, 0, 0, time.UTC)
+	ttl := 300 * time.Second
+	rng := rand.New(rand.NewSource(42))
+	s := NewServiceWithOptions(fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now })), stubClock{t: now},
+		WithExpiryJitter(0.1), WithRand(rng.Float64))
+
+	distinct := map[time.Time]bool{}
+	lo, hi := now.Add(ttl), now.Add(ttl)
+	for i := 0; i < 200; i++ {
+		created, err := s.CreateSnippet(ctx, fmt.Sprintf("batch %d", i), int(ttl.Seconds()), nil)
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		exp := created.ExpiresAt
+		if exp.Before(now.Add(ttl*9/10)) || exp.After(now.Add(ttl*11/10)) {
+			t.Fatalf("expiry %s outside ±10%% of %s", exp.Sub(now), ttl)
+		}
+		distinct[exp] = true
+		if exp.Before(lo) {
+			lo = exp
+		}
+		if exp.After(hi) {
+			hi = exp
+		}
+	}
+	// 200 uniform draws should cover most of the 60s band
+	if len(distinct) < 100 || hi.Sub(lo) < 45*time.Second {
+		t.Fatalf("expiries not spread: %d distinct over %s", len(distinct), hi.Sub(lo))
+	}
+
+	exact := NewServiceWithOptions(fake.NewSnippetRepository(), stubClock{t: now})
+	created, err := exact.CreateSnippet(ctx, "no jitter", int(ttl.Seconds()), nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if !created.ExpiresAt.Equal(now.Add(ttl)) {
+		t.Fatalf("default: want exact expiry, got %s", created.ExpiresAt.Sub(now))
+	}
+}
+
+func TestCreateSnippet_ExpiryRounding(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 2, 8, 0, 13, 0, time.UTC)
+	s := NewServiceWithOptions(fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now })), stubClock{t: now},
+		WithExpiryRounding(time.Minute))
+
+	created, err := s.CreateSnippet(ctx, "rounded", 90, nil)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	// 08:00:13 + 90s = 08:01:43, rounded up to the next whole minute
+	if want := time.Date(2025, 9, 2, 8, 2, 0, 0, time.UTC); !created.ExpiresAt.Equal(want) {
+		t.Fatalf("want expiry %s, got %s", want, created.ExpiresAt)
+	}
+	updated, err := s.UpdateSnippet(ctx, created.ID, "rounded", 3600, nil)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if want := time.Date(2025, 9, 2, 9, 1, 0, 0, time.UTC); !updated.ExpiresAt.Equal(want) {
+		t.Fatalf("update: want expiry %s, got %s", want, updated.ExpiresAt)
+	}
+
+	for mode, want := range map[string]time.Duration{"": 0, ExpiryRoundingNone: 0, ExpiryRoundingMinute: time.Minute, ExpiryRoundingHour: time.Hour} {
+		if got, err := ParseExpiryRounding(mode); err != nil || got != want {
+			t.Fatalf("ParseExpiryRounding(%q) = %s, %v; want %s", mode, got, err, want)
+		}
+	}
+	if _, err := ParseExpiryRounding("day"); err == nil {
+		t.Fatalf("want an error for an unknown mode")
+	}
+}
+
+func TestGetSnippetsByIDs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 9, 3, 10, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{findByID: map[string]domain.Snippet{
+		"a":       {ID: "a", CreatedAt: now},
+		"b":       {ID: "b", CreatedAt: now},
+		"expired": {ID: "expired", CreatedAt: now, ExpiresAt: now.Add(-time.Second)},
+		"later":   {ID: "later", CreatedAt: now, VisibleFrom: now.Add(time.Hour)},
+	}}
+	s := NewService(repo, stubClock{t: now})
+
+	got, err := s.GetSnippetsByIDs(ctx, []string{"b", "missing", "expired", "a", "later", "b"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var ids []string
+	for _, snippet := range got {
+		ids = append(ids, snippet.ID)
+	}
+	if strings.Join(ids, ",") != "b,a" {
+		t.Fatalf("want readable snippets in request order without duplicates, got %v", ids)
+	}
+
+	repo.findErr = errors.New("db down")
+	if _, err := s.GetSnippetsByIDs(ctx, []string{"a"}); err == nil {
+		t.Fatalf("want repository error")
+	}
+}
+
+func TestListTags_Prefix(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{tags: []repository.TagCount{{Tag: "go", Count: 3}, {Tag: "web", Count: 2}, {Tag: "GoLang", Count: 1}}}
+	s := NewService(repo, stubClock{t: time.Now()})
+
+	all, err := s.ListTags(ctx, "")
+	if err != nil || len(all) != 3 {
+		t.Fatalf("want all 3 tags, got %v, %v", all, err)
+	}
+	got, err := s.ListTags(ctx, "GO")
+	if err != nil {
+		t.Fatalf("list tags: %v", err)
+	}
+	if fmt.Sprint(got) != "[{go 3} {GoLang 1}]" {
+		t.Fatalf("want case-insensitive prefix matches in order, got %v", got)
+	}
+
+	repo.listErr = errors.New("db down")
+	if _, err := s.ListTags(ctx, ""); err == nil {
+		t.Fatalf("want repository error")
+	}
+}
+
+func TestCheckIDEntropy(t *testing.T) {
+	// 6 base62 characters give ~5.7e10 IDs; a million snippets make a collision near certain
+	risk, err := CheckIDEntropy(6, 1_000_000, 0)
+	if !errors.Is(err, ErrIDEntropy) {
+		t.Fatalf("want ErrIDEntropy, got risk %v, err %v", risk, err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("at least %d characters", RecommendedIDLength(1_000_000, DefaultIDCollisionRisk))) {
+		t.Fatalf("want a recommended length, got %v", err)
+	}
+	if n := RecommendedIDLength(1_000_000, DefaultIDCollisionRisk); IDCollisionRisk(n, 1_000_000) > DefaultIDCollisionRisk || n <= 6 {
+		t.Fatalf("recommended length %d does not meet the threshold", n)
+	}
+	if _, err := CheckIDEntropy(10, 1000, 0); err != nil {
+		t.Fatalf("want 10 characters fine for 1000 snippets, got %v", err)
+	}
+
+	// The service checks its seeded short IDs against the stored count, and skips UUIDs
+	repo := &fakeRepo{count: 1_000_000_000}
+	if _, err := NewServiceWithOptions(repo, stubClock{t: time.Now()}, WithSeededIDGenerator(1)).CheckIDEntropy(context.Background(), 0); !errors.Is(err, ErrIDEntropy) {
+		t.Fatalf("want ErrIDEntropy for seeded ids, got %v", err)
+	}
+	if _, err := NewService(repo, stubClock{t: time.Now()}).CheckIDEntropy(context.Background(), 0); err != nil {
+		t.Fatalf("want uuids unchecked, got %v", err)
+	}
+}
+
+// viewCountingRepo counts views like the cached repository, optionally failing.
+type viewCountingRepo struct {
+	*fakeRepo
+	pending map[string]int64
+	err     error
+}
+
+func (r *viewCountingRepo) IncrementView(_ context.Context, id string) (int64, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	r.pending[id]++
+	return r.pending[id], nil
+}
+
+func TestRecordView(t *testing.T) {
+	ctx := context.Background()
+	repo := &viewCountingRepo{fakeRepo: &fakeRepo{}, pending: map[string]int64{}}
+	if n := NewService(repo, stubClock{t: time.Now()}).RecordView(ctx, "a"); n != 0 {
+		t.Fatalf("want no counting unless enabled, got %d", n)
+	}
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()}, WithViewCounting(true))
+	s.RecordView(ctx, "a")
+	if n := s.RecordView(ctx, "a"); n != 2 {
+		t.Fatalf("want 2 pending views, got %d", n)
+	}
+	repo.err = errors.New("redis down")
+	if n := s.RecordView(ctx, "a"); n != 0 {
+		t.Fatalf("want a failed count ignored, got %d", n)
+	}
+	// Repositories without a view counter are not counted
+	if n := NewServiceWithOptions(&fakeRepo{}, stubClock{t: time.Now()}, WithViewCounting(true)).RecordView(ctx, "a"); n != 0 {
+		t.Fatalf("want 0 without a counter, got %d", n)
+	}
+}
+
+func (r *viewCountingRepo) IncrementViewWithin(_ context.Context, id string, allowed int64) (int64, bool, error) {
+	if r.err != nil {
+		return 0, false, r.err
+	}
+	if r.pending[id] >= allowed {
+		return r.pending[id], false, nil
+	}
+	r.pending[id]++
+	return r.pending[id], true, nil
+}
+
+func TestGetSnippetByID_MaxViews(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := &viewCountingRepo{
+		fakeRepo: &fakeRepo{findByID: map[string]domain.Snippet{"a": {ID: "a", CreatedAt: now, MaxViews: 3, Views: 1}}},
+		pending:  map[string]int64{},
+	}
+	s := NewService(repo, stubClock{t: now})
+
+	for want := int64(2); want <= 3; want++ {
+		got, meta, err := s.GetSnippetByID(ctx, "a")
+		if err != nil {
+			t.Fatalf("read %d: %v", want, err)
+		}
+		if got.Views != want || !meta.ViewCounted {
+			t.Fatalf("want %d views counted, got %d (counted %v)", want, got.Views, meta.ViewCounted)
+		}
+	}
+	if _, _, err := s.GetSnippetByID(ctx, "a"); !errors.Is(err, ErrViewLimitReached) {
+		t.Fatalf("want ErrViewLimitReached, got %v", err)
+	}
+
+	// Without a working counter the stored views still enforce the limit
+	repo.err = errors.New("redis down")
+	if _, _, err := s.GetSnippetByID(ctx, "a"); err != nil {
+		t.Fatalf("want read allowed below the stored limit, got %v", err)
+	}
+	repo.findByID["a"] = domain.Snippet{ID: "a", CreatedAt: now, MaxViews: 3, Views: 3}
+	if _, _, err := s.GetSnippetByID(ctx, "a"); !errors.Is(err, ErrViewLimitReached) {
+		t.Fatalf("want ErrViewLimitReached from stored views, got %v", err)
+	}
+	if got, _ := s.GetSnippetsByIDs(ctx, []string{"a"}); len(got) != 0 {
+		t.Fatalf("want view-limited snippets left out of batch reads, got %+v", got)
+	}
+}
+
+func TestPrivateSnippets(t *testing.T) {
+	now := time.Now()
+	repo := fake.NewSnippetRepository()
+	s := NewServiceWithOptions(repo, stubClock{t: now}, WithDuplicateHint(true))
+	owner := ctxutil.WithClientID(context.Background(), "alice")
+	other := ctxutil.WithClientID(context.Background(), "bob")
+	anon := context.Background()
+
+	if _, err := s.CreateSnippet(anon, "x", 0, nil, WithVisibility(domain.VisibilityPrivate)); !errors.Is(err, ErrPrivateRequiresOwner) {
+		t.Fatalf("want ErrPrivateRequiresOwner without a client id, got %v", err)
+	}
+	private, err := s.CreateSnippet(owner, "secret", 0, []string{"go"}, WithVisibility(domain.VisibilityPrivate))
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	public, err := s.CreateSnippet(other, "hello", 0, []string{"go"})
+	if err != nil || public.Visibility != domain.VisibilityPublic {
+		t.Fatalf("want public by default, got %q, %v", public.Visibility, err)
+	}
+
+	if _, _, err := s.GetSnippetByID(owner, private.ID); err != nil {
+		t.Fatalf("owner read: %v", err)
+	}
+	for name, ctx := range map[string]context.Context{"other client": other, "anonymous": anon} {
+		if _, _, err := s.GetSnippetByID(ctx, private.ID); !errors.Is(err, ErrSnippetNotFound) {
+			t.Fatalf("%s: want ErrSnippetNotFound, got %v", name, err)
+		}
+		if _, err := s.UpdateSnippet(ctx, private.ID, "overwritten", 0, nil); !errors.Is(err, ErrSnippetNotFound) {
+			t.Fatalf("%s: want update to find nothing, got %v", name, err)
+		}
+		if got, _ := s.GetSnippetsByIDs(ctx, []string{private.ID, public.ID}); len(got) != 1 || got[0].ID != public.ID {
+			t.Fatalf("%s: want only the public snippet from a batch, got %+v", name, got)
+		}
+		if _, err := s.DiffVersions(ctx, private.ID, 1, 1); !errors.Is(err, ErrVersionNotFound) {
+			t.Fatalf("%s: want diff to find nothing, got %v", name, err)
+		}
+	}
+
+	listed, _ := s.ListSnippets(anon, 1, 10, "go")
+	if len(listed) != 1 || listed[0].ID != public.ID {
+		t.Fatalf("want private snippets left out of public lists, got %+v", listed)
+	}
+	mine, _ := s.ListSnippets(owner, 1, 10, "", repository.WithOwner("alice"))
+	if len(mine) != 1 || mine[0].ID != private.ID {
+		t.Fatalf("want the owner's listing to include their private snippet, got %+v", mine)
+	}
+	if dup, _ := s.CreateSnippet(other, "secret", 0, nil); dup.DuplicateOf != "" {
+		t.Fatalf("duplicate hint must not reveal another client's private snippet, got %q", dup.DuplicateOf)
+	}
+}
+
+func TestSnippetTitle(t *testing.T) {
+	ctx := context.Background()
+	repo := fake.NewSnippetRepository()
+	s := NewServiceWithOptions(repo, stubClock{t: time.Now()})
+
+	created, err := s.CreateSnippet(ctx, "x", 0, nil, WithTitle("Notes"))
+	if err != nil || created.Title != "Notes" {
+		t.Fatalf("create: got %q, %v", created.Title, err)
+	}
+	updated, err := s.UpdateSnippet(ctx, created.ID, "y", 0, nil)
+	if err != nil || updated.Title != "Notes" {
+		t.Fatalf("update without title should keep it: got %q, %v", updated.Title, err)
+	}
+	cleared, err := s.UpdateSnippet(ctx, created.ID, "z", 0, nil, WithTitle(""))
+	if err != nil || cleared.Title != "" {
+		t.Fatalf("empty title should clear it: got %q, %v", cleared.Title, err)
+	}
+	stored, err := repo.FindByID(ctx, created.ID)
+	if err != nil || stored.Title != "" {
+		t.Fatalf("stored title: got %q, %v", stored.Title, err)
+	}
+}
+
+// hardDeleteRepo adds repository.HardDeleter to the fake repository.
+type hardDeleteRepo struct{ *fake.SnippetRepository }
+
+func (r hardDeleteRepo) DeleteByID(ctx context.Context, id string) error {
+	if _, err := r.FindByID(ctx, id); err != nil {
+		return err
+	}
+	r.SnippetRepository.DeleteByID(id)
+	return nil
+}
+
+func TestDeleteSnippet_SoftDeleteAndRestore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 8, 30, 12, 0, 0, 0, time.UTC)
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return now }))
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+	created, err := s.CreateSnippet(ctx, "x", 0, []string{"go"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := s.DeleteSnippet(ctx, created.ID); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, _, err := s.GetSnippetByID(ctx, created.ID); !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("want deleted snippet not found, got %v", err)
+	}
+	if items, _ := s.ListSnippets(ctx, 1, 10, "go"); len(items) != 0 {
+		t.Fatalf("want deleted snippet left out of lists, got %+v", items)
+	}
+	if err := s.DeleteSnippet(ctx, created.ID); !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("want deleting twice to report not found, got %v", err)
+	}
+	deleted, _, err := s.GetSnippetByID(ctxutil.WithIncludeDeleted(ctx), created.ID)
+	if err != nil || !deleted.DeletedAt.Equal(now) {
+		t.Fatalf("want deleted snippet with include-deleted context, got %+v, %v", deleted, err)
+	}
+
+	restored, err := s.RestoreSnippet(ctx, created.ID)
+	if err != nil || !restored.DeletedAt.IsZero() {
+		t.Fatalf("restore: got %+v, %v", restored, err)
+	}
+	if _, _, err := s.GetSnippetByID(ctx, created.ID); err != nil {
+		t.Fatalf("want restored snippet readable, got %v", err)
+	}
+	if _, err := s.RestoreSnippet(ctx, created.ID); !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("want restoring a live snippet to report not found, got %v", err)
+	}
+}
+
+func TestDeleteSnippet_Hard(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := fake.NewSnippetRepository(fake.WithItems(domain.Snippet{ID: "a", CreatedAt: now}))
+
+	if err := NewServiceWithOptions(repo, stubClock{t: now}, WithHardDelete(true)).DeleteSnippet(ctx, "a"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Fatalf("want ErrUnsupported without a hard-deleting repository, got %v", err)
+	}
+
+	s := NewServiceWithOptions(hardDeleteRepo{repo}, stubClock{t: now}, WithHardDelete(true))
+	if err := s.DeleteSnippet(ctx, "a"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := repo.FindByID(ctxutil.WithIncludeDeleted(ctx), "a"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("want the row removed, got %v", err)
+	}
+	if _, err := s.RestoreSnippet(ctx, "a"); !errors.Is(err, ErrSnippetNotFound) {
+		t.Fatalf("want hard-deleted snippet not restorable, got %v", err)
+	}
+}
+
+// primaryReadsRepo records, per snippet ID, whether its last FindByID asked for the primary database.
+type primaryReadsRepo struct {
+	*fake.SnippetRepository
+	primary map[string]bool
+}
+
+func (r primaryReadsRepo) FindByID(ctx context.Context, id string) (domain.Snippet, error) {
+	r.primary[id] = ctxutil.ReadPrimary(ctx)
+	return r.SnippetRepository.FindByID(ctx, id)
+}
+
+func TestReadModifyWriteReadsPrimary(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	repo := primaryReadsRepo{fake.NewSnippetRepository(fake.WithItems(
+		domain.Snippet{ID: "a", Content: "x", CreatedAt: now},
+		domain.Snippet{ID: "b", Content: "x", CreatedAt: now},
+	)), map[string]bool{}}
+	s := NewServiceWithOptions(repo, stubClock{t: now})
+
+	if _, _, err := s.GetSnippetByID(ctx, "a"); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if repo.primary["a"] {
+		t.Fatal("plain reads may use a replica")
+	}
+	if _, err := s.UpdateSnippet(ctx, "a", "y", 0, nil); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if !repo.primary["a"] {
+		t.Fatal("update must read the snippet from the primary")
+	}
+	if err := s.DeleteSnippet(ctx, "b"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := s.RestoreSnippet(ctx, "b"); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !repo.primary["b"] {
+		t.Fatal("restore must read the restored snippet from the primary")
+	}
+}
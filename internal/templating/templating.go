@@ -0,0 +1,52 @@
+// Package templating substitutes {{var}} placeholders in snippet content at read time.
+package templating
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// MaxValueLength caps a substituted value in bytes; longer values are truncated.
+const MaxValueLength = 256
+
+// placeholder matches {{name}} with optional surrounding spaces. Names are letters, digits, '_', '-' and '.'.
+var placeholder = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// Render replaces each {{name}} in content with vars[name] in a single pass, so substituted
+// values are never expanded again. Placeholders without a value are left as-is and their
+// names are returned sorted and de-duplicated.
+func Render(content string, vars map[string]string) (string, []string) {
+	missingSet := map[string]bool{}
+	out := placeholder.ReplaceAllStringFunc(content, func(m string) string {
+		name := placeholder.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok {
+			missingSet[name] = true
+			return m
+		}
+		return Sanitize(v)
+	})
+	missing := make([]string, 0, len(missingSet))
+	for name := range missingSet {
+		missing = append(missing, name)
+	}
+	sort.Strings(missing)
+	return out, missing
+}
+
+// Sanitize strips control characters (including newlines) and caps the length of a value,
+// so a variable cannot inject new lines or block structure into rendered output.
+func Sanitize(v string) string {
+	v = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, v)
+	if len(v) > MaxValueLength {
+		v = strings.ToValidUTF8(v[:MaxValueLength], "")
+	}
+	return v
+}
@@ -0,0 +1,39 @@
+package templating
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		vars        map[string]string
+		want        string
+		wantMissing []string
+	}{
+		{"substitutes", "Hello {{name}}, port {{ port }}", map[string]string{"name": "bob", "port": "8080"}, "Hello bob, port 8080", []string{}},
+		{"missing left as-is", "{{a}} {{b}} {{b}}", map[string]string{"a": "1"}, "1 {{b}} {{b}}", []string{"b"}},
+		{"no recursive expansion", "{{a}}", map[string]string{"a": "{{b}}", "b": "x"}, "{{b}}", []string{}},
+		{"strips control characters", "x={{v}}", map[string]string{"v": "1\n# injected\r\x00"}, "x=1# injected", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, missing := Render(tt.content, tt.vars)
+			if got != tt.want {
+				t.Fatalf("want %q, got %q", tt.want, got)
+			}
+			if !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Fatalf("want missing %v, got %v", tt.wantMissing, missing)
+			}
+		})
+	}
+}
+
+func TestSanitize_CapsLength(t *testing.T) {
+	if got := Sanitize(strings.Repeat("é", MaxValueLength)); len(got) > MaxValueLength {
+		t.Fatalf("want at most %d bytes, got %d", MaxValueLength, len(got))
+	}
+}
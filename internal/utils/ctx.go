@@ -6,10 +6,15 @@ import "context"
 // key is an unexported type to avoid collisions.
 type key int
 
-// requestIDKey and clientIDKey are context keys for IDs.
+// requestIDKey and clientIDKey are context keys for IDs; cacheBypassKey marks cache-exempt requests,
+// includeDeletedKey marks reads that should also see soft-deleted snippets and readPrimaryKey
+// marks reads that must not be served by a database replica.
 const (
 	requestIDKey key = iota
 	clientIDKey
+	cacheBypassKey
+	includeDeletedKey
+	readPrimaryKey
 )
 
 // WithRequestID returns a new context with the given request ID.
@@ -41,3 +46,38 @@ func ClientID(ctx context.Context) string {
 	}
 	return ""
 }
+
+// WithCacheBypass returns a new context whose repository reads skip the cache and hit the primary store.
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey, true)
+}
+
+// CacheBypass reports whether reads made with the context must skip the cache. Reads that include
+// soft-deleted snippets always do, since the cache only holds live ones.
+func CacheBypass(ctx context.Context) bool {
+	v, _ := ctx.Value(cacheBypassKey).(bool)
+	return v || IncludeDeleted(ctx)
+}
+
+// WithIncludeDeleted returns a new context whose repository reads also return soft-deleted snippets.
+func WithIncludeDeleted(ctx context.Context) context.Context {
+	return context.WithValue(ctx, includeDeletedKey, true)
+}
+
+// IncludeDeleted reports whether reads made with the context should return soft-deleted snippets.
+func IncludeDeleted(ctx context.Context) bool {
+	v, _ := ctx.Value(includeDeletedKey).(bool)
+	return v
+}
+
+// WithReadPrimary returns a new context whose database reads go to the primary rather than a
+// replica, so they see writes that a lagging replica has not applied yet.
+func WithReadPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readPrimaryKey, true)
+}
+
+// ReadPrimary reports whether database reads made with the context must use the primary.
+func ReadPrimary(ctx context.Context) bool {
+	v, _ := ctx.Value(readPrimaryKey).(bool)
+	return v
+}
@@ -23,3 +23,27 @@ func TestRequestAndClientID(t *testing.T) {
 		t.Fatalf("client id mismatch, got %q", got)
 	}
 }
+
+func TestIncludeDeletedImpliesCacheBypass(t *testing.T) {
+	ctx := context.Background()
+	if IncludeDeleted(ctx) || CacheBypass(ctx) {
+		t.Fatal("expected both flags unset on a bare context")
+	}
+	ctx = WithIncludeDeleted(ctx)
+	if !IncludeDeleted(ctx) {
+		t.Fatal("expected IncludeDeleted to be set")
+	}
+	if !CacheBypass(ctx) {
+		t.Fatal("expected include-deleted reads to bypass the cache")
+	}
+}
+
+func TestReadPrimary(t *testing.T) {
+	ctx := context.Background()
+	if ReadPrimary(ctx) {
+		t.Fatal("expected replica reads on a bare context")
+	}
+	if !ReadPrimary(WithReadPrimary(ctx)) {
+		t.Fatal("expected ReadPrimary to be set")
+	}
+}
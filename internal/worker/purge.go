@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// JobPurge names the expired-snippet purge job.
+const JobPurge = "purge"
+
+// JobCacheInvalidation names the subscriber applying cache invalidations broadcast by other instances.
+const JobCacheInvalidation = "cache-invalidation"
+
+// JobCacheWriteBehind names the worker applying cache writes queued by inserts and updates.
+const JobCacheWriteBehind = "cache-write-behind"
+
+// DefaultPurgeInterval is how often PurgeExpired runs when no interval is configured.
+const DefaultPurgeInterval = 5 * time.Minute
+
+// ExpiredPurger deletes snippets whose expiry has passed.
+type ExpiredPurger interface {
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// PurgeExpired returns a Job that deletes expired snippets every interval until ctx is cancelled.
+// Non-positive intervals use DefaultPurgeInterval. A failed cycle is logged and retried on the next tick.
+func PurgeExpired(repo ExpiredPurger, interval time.Duration) Job {
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		return purgeOnTick(repo, ticker.C)(ctx)
+	}
+}
+
+// purgeOnTick runs one purge per value received from ticks, so tests can drive cycles directly.
+func purgeOnTick(repo ExpiredPurger, ticks <-chan time.Time) Job {
+	return func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticks:
+			}
+			n, err := repo.DeleteExpired(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				logger.WithField(ctx, "error", err.Error()).Error("failed to purge expired snippets")
+				continue
+			}
+			logger.WithField(ctx, "purged", n).Info("purged expired snippets")
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roguepikachu/bonsai/internal/domain"
+	"github.com/roguepikachu/bonsai/internal/repository/fake"
+)
+
+// reportingPurger hands each cycle's result to the test, which also orders the test's
+// reads of the (non-concurrency-safe) fake after the job's writes.
+type reportingPurger struct {
+	repo    ExpiredPurger
+	results chan int64
+}
+
+func (p reportingPurger) DeleteExpired(ctx context.Context) (int64, error) {
+	n, err := p.repo.DeleteExpired(ctx)
+	p.results <- n
+	return n, err
+}
+
+func TestPurgeExpired(t *testing.T) {
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	repo := fake.NewSnippetRepository(fake.WithNow(func() time.Time { return clock() }), fake.WithItems(
+		domain.Snippet{ID: "forever", CreatedAt: now},
+		domain.Snippet{ID: "expired", CreatedAt: now, ExpiresAt: now.Add(-time.Second)},
+		domain.Snippet{ID: "soon", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+	))
+	purger := reportingPurger{repo: repo, results: make(chan int64)}
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- purgeOnTick(purger, ticks)(ctx) }()
+
+	ticks <- now
+	if n := <-purger.results; n != 1 {
+		t.Fatalf("first cycle: want 1 purged, got %d", n)
+	}
+	// Advance the clock past the second expiry
+	clock = func() time.Time { return now.Add(2 * time.Hour) }
+	ticks <- now
+	if n := <-purger.results; n != 1 {
+		t.Fatalf("second cycle: want 1 purged, got %d", n)
+	}
+	ticks <- now
+	if n := <-purger.results; n != 0 {
+		t.Fatalf("third cycle: want nothing left to purge, got %d", n)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("job should stop cleanly, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("job did not stop on cancel")
+	}
+	if _, err := repo.FindByID(context.Background(), "forever"); err != nil {
+		t.Fatalf("snippet without expiry must survive, got %v", err)
+	}
+	for _, id := range []string{"expired", "soon"} {
+		if _, err := repo.FindByID(context.Background(), id); err == nil {
+			t.Fatalf("%s should have been purged", id)
+		}
+	}
+}
+
+type failingPurger struct{ calls chan struct{} }
+
+func (p failingPurger) DeleteExpired(context.Context) (int64, error) {
+	p.calls <- struct{}{}
+	return 0, errors.New("db down")
+}
+
+func TestPurgeExpired_ContinuesAfterError(t *testing.T) {
+	p := failingPurger{calls: make(chan struct{})}
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = purgeOnTick(p, ticks)(ctx) }()
+	for i := 0; i < 2; i++ {
+		ticks <- time.Now()
+		<-p.calls
+	}
+}
+
+type countingFlusher struct{ calls chan struct{} }
+
+func (f countingFlusher) FlushViews(context.Context) (int, error) {
+	f.calls <- struct{}{}
+	return 1, nil
+}
+
+func TestFlushViews_FinalFlushOnCancel(t *testing.T) {
+	f := countingFlusher{calls: make(chan struct{}, 1)}
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- flushViewsOnTick(f, ticks)(ctx) }()
+
+	ticks <- time.Now()
+	<-f.calls
+	cancel()
+	select {
+	case <-f.calls:
+	case <-time.After(2 * time.Second):
+		t.Fatal("want a final flush on cancel")
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("job should stop cleanly, got %v", err)
+	}
+}
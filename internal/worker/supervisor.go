@@ -0,0 +1,186 @@
+// Package worker supervises long-running background jobs such as purges and cache warmups.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// ErrLimitReached is returned by Go when a job type already runs its maximum number of workers.
+var ErrLimitReached = errors.New("worker limit reached")
+
+// Job is a background worker. It should run until ctx is cancelled and return nil,
+// or return an error to stop the whole supervisor.
+type Job func(ctx context.Context) error
+
+// JobState reports the running workers and panic restarts of one job type.
+type JobState struct {
+	Name     string `json:"name"`
+	Running  int    `json:"running"`
+	Limit    int    `json:"limit"`
+	Restarts int    `json:"restarts"`
+}
+
+// Supervisor runs jobs in an errgroup, restarts workers that panic and caps concurrency per job type.
+type Supervisor struct {
+	group *errgroup.Group
+	ctx   context.Context
+
+	defaultLimit int
+	limits       map[string]int
+	backoff      func(attempt int) time.Duration
+
+	mu       sync.Mutex
+	running  map[string]int
+	restarts map[string]int
+}
+
+// Option configures a Supervisor.
+type Option func(*Supervisor)
+
+// WithDefaultLimit caps workers for job types without an explicit limit. Values below 1 are ignored.
+func WithDefaultLimit(n int) Option {
+	return func(s *Supervisor) {
+		if n > 0 {
+			s.defaultLimit = n
+		}
+	}
+}
+
+// WithJobLimit caps concurrent workers of one job type.
+func WithJobLimit(name string, n int) Option {
+	return func(s *Supervisor) {
+		if n > 0 {
+			s.limits[name] = n
+		}
+	}
+}
+
+// WithBackoff overrides the delay before restarting a worker after its attempt-th panic.
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(s *Supervisor) {
+		if fn != nil {
+			s.backoff = fn
+		}
+	}
+}
+
+// NewSupervisor returns a Supervisor whose workers stop when ctx is cancelled.
+func NewSupervisor(ctx context.Context, opts ...Option) *Supervisor {
+	g, gctx := errgroup.WithContext(ctx)
+	s := &Supervisor{
+		group:        g,
+		ctx:          gctx,
+		defaultLimit: 1,
+		limits:       map[string]int{},
+		backoff:      exponentialBackoff,
+		running:      map[string]int{},
+		restarts:     map[string]int{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// exponentialBackoff doubles from 100ms up to 30s.
+func exponentialBackoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond
+	for i := 1; i < attempt && d < 30*time.Second; i++ {
+		d *= 2
+	}
+	return min(d, 30*time.Second)
+}
+
+func (s *Supervisor) limit(name string) int {
+	if n, ok := s.limits[name]; ok {
+		return n
+	}
+	return s.defaultLimit
+}
+
+// Go starts a worker of the named job type, or returns ErrLimitReached when the type is at its cap.
+func (s *Supervisor) Go(name string, job Job) error {
+	s.mu.Lock()
+	if s.running[name] >= s.limit(name) {
+		s.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrLimitReached, name)
+	}
+	s.running[name]++
+	s.mu.Unlock()
+
+	s.group.Go(func() error {
+		defer func() {
+			s.mu.Lock()
+			s.running[name]--
+			s.mu.Unlock()
+		}()
+		return s.run(name, job)
+	})
+	return nil
+}
+
+// run invokes job until it returns, restarting it with backoff after each panic.
+func (s *Supervisor) run(name string, job Job) error {
+	for attempt := 1; ; attempt++ {
+		panicked, err := s.runOnce(name, job)
+		if !panicked {
+			return err
+		}
+		s.mu.Lock()
+		s.restarts[name]++
+		s.mu.Unlock()
+		select {
+		case <-s.ctx.Done():
+			return nil
+		case <-time.After(s.backoff(attempt)):
+		}
+	}
+}
+
+func (s *Supervisor) runOnce(name string, job Job) (panicked bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.With(s.ctx, map[string]any{"job": name, "panic": r, "stack": string(debug.Stack())}).Error("worker panicked, restarting")
+			panicked = true
+		}
+	}()
+	return false, job(s.ctx)
+}
+
+// Running is a gauge of workers currently running across all job types.
+func (s *Supervisor) Running() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, n := range s.running {
+		total += n
+	}
+	return total
+}
+
+// State reports every job type the supervisor has seen, sorted by name.
+func (s *Supervisor) State() []JobState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	states := make([]JobState, 0, len(s.running))
+	for name, n := range s.running {
+		states = append(states, JobState{Name: name, Running: n, Limit: s.limit(name), Restarts: s.restarts[name]})
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Name < states[j].Name })
+	return states
+}
+
+// Wait blocks until every worker has returned and reports the first error.
+func (s *Supervisor) Wait() error {
+	return s.group.Wait()
+}
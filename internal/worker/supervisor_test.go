@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor_RestartsPanickedWorker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := NewSupervisor(ctx, WithBackoff(func(int) time.Duration { return time.Millisecond }))
+
+	var calls atomic.Int32
+	restarted := make(chan struct{})
+	err := s.Go("purge", func(ctx context.Context) error {
+		if calls.Add(1) <= 2 {
+			panic("boom")
+		}
+		close(restarted)
+		<-ctx.Done()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("go: %v", err)
+	}
+
+	select {
+	case <-restarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("worker was not restarted after panic")
+	}
+	if got := s.Running(); got != 1 {
+		t.Fatalf("want 1 running worker, got %d", got)
+	}
+	state := s.State()
+	if len(state) != 1 || state[0].Name != "purge" || state[0].Restarts != 2 || state[0].Running != 1 {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+
+	cancel()
+	if err := s.Wait(); err != nil {
+		t.Fatalf("wait: %v", err)
+	}
+	if got := s.Running(); got != 0 {
+		t.Fatalf("want 0 running after shutdown, got %d", got)
+	}
+}
+
+func TestSupervisor_CapsWorkersPerJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := NewSupervisor(ctx, WithDefaultLimit(1), WithJobLimit("webhook", 2))
+	block := func(ctx context.Context) error { <-ctx.Done(); return nil }
+
+	if err := s.Go("purge", block); err != nil {
+		t.Fatalf("first purge: %v", err)
+	}
+	if err := s.Go("purge", block); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("want ErrLimitReached, got %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.Go("webhook", block); err != nil {
+			t.Fatalf("webhook %d: %v", i, err)
+		}
+	}
+	if err := s.Go("webhook", block); !errors.Is(err, ErrLimitReached) {
+		t.Fatalf("want ErrLimitReached for third webhook, got %v", err)
+	}
+	if got := s.Running(); got != 3 {
+		t.Fatalf("want 3 running, got %d", got)
+	}
+	cancel()
+	_ = s.Wait()
+}
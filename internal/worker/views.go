@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/roguepikachu/bonsai/pkg/logger"
+)
+
+// JobViewFlush names the job moving pending snippet view counts to Postgres.
+const JobViewFlush = "view-flush"
+
+// DefaultViewFlushInterval is how often FlushViews runs when no interval is configured.
+const DefaultViewFlushInterval = 10 * time.Second
+
+// viewFlushShutdownTimeout bounds the final flush run when the job is stopped.
+const viewFlushShutdownTimeout = 5 * time.Second
+
+// ViewFlusher moves pending view counts to storage.
+type ViewFlusher interface {
+	FlushViews(ctx context.Context) (int, error)
+}
+
+// FlushViews returns a Job that flushes pending view counts every interval, and once more when
+// ctx is cancelled so a clean shutdown loses no counts. Non-positive intervals use
+// DefaultViewFlushInterval. A failed cycle is logged and retried on the next tick.
+func FlushViews(f ViewFlusher, interval time.Duration) Job {
+	if interval <= 0 {
+		interval = DefaultViewFlushInterval
+	}
+	return func(ctx context.Context) error {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		return flushViewsOnTick(f, ticker.C)(ctx)
+	}
+}
+
+// flushViewsOnTick runs one flush per value received from ticks, so tests can drive cycles directly.
+func flushViewsOnTick(f ViewFlusher, ticks <-chan time.Time) Job {
+	return func(ctx context.Context) error {
+		for {
+			select {
+			case <-ctx.Done():
+				finalCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), viewFlushShutdownTimeout)
+				defer cancel()
+				flushViews(finalCtx, f)
+				return nil
+			case <-ticks:
+			}
+			flushViews(ctx, f)
+		}
+	}
+}
+
+func flushViews(ctx context.Context, f ViewFlusher) {
+	n, err := f.FlushViews(ctx)
+	if err != nil {
+		logger.WithField(ctx, "error", err.Error()).Error("failed to flush view counts")
+		return
+	}
+	if n > 0 {
+		logger.WithField(ctx, "snippets", n).Debug("flushed view counts")
+	}
+}